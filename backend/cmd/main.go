@@ -1,21 +1,27 @@
 package main
 
 import (
+	"StudyHub/internal/ai"
 	"StudyHub/internal/auth"
-	"StudyHub/internal/aws"
 	"StudyHub/internal/comments"
 	"StudyHub/internal/config"
 	"StudyHub/internal/content"
-	"StudyHub/internal/gemini"
+	"StudyHub/internal/convert"
+	"StudyHub/internal/extract"
 	"StudyHub/internal/http"
 	"StudyHub/internal/modules"
 	"StudyHub/internal/rabbitmq"
 	"StudyHub/internal/resources"
+	"StudyHub/internal/storage"
+	"StudyHub/internal/tus"
 	"StudyHub/internal/users"
 	"StudyHub/pgk/postgres"
 	"context"
 	"fmt"
 	"log"
+	"time"
+	// the alpine image has no zoneinfo, analytics load the user's time zone
+	_ "time/tzdata"
 )
 
 // user reverse proxy ga borad, keyn nginx url ga qarap front yoki back ligni blad, agar /api busa bu back ga ketad
@@ -41,19 +47,39 @@ func main() {
 	commentRepo := comments.NewCommentRepositoryPostgres(pool)
 
 	//create instances for external services
-	s3Storage := aws.NewS3Storage(cfg.BucketName, cfg.AWS_S3_URL)
-	geminiClient := gemini.NewGeminiClient(cfg.GeminiKey)
+	fileStorage, err := storage.New(ctx, cfg.Storage())
+	if err != nil {
+		log.Fatal(err)
+	}
+	//the local driver serves its own download links
+	localFiles, _ := fileStorage.(*storage.LocalStorage)
+	tusUploads, err := tus.NewStore(cfg.Tus())
+	if err != nil {
+		log.Fatal(err)
+	}
+	tusUploads.StartCleanup(ctx, time.Hour)
+	aiProvider, err := ai.New(cfg.AI())
+	if err != nil {
+		log.Fatal(err)
+	}
 	rbmq := rabbitmq.New(cfg.RBMQUser, cfg.RBMQPass, cfg.RBMQHost)
+	linkFetcher := extract.NewFetcher(cfg.Fetcher())
+	converter, err := convert.New(cfg.Converter())
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	//createing srvs
 	moduleSrv := modules.NewModuleService(moduleRepo, weeksRepo, moduleRunRepo, academicCalRepo)
 	userSrv := users.NewUserService(userRepo)
 	authSrv := auth.NewAuthSerivce("", userRepo)
-	resourceSrv := resources.NewResourceService(resourceRepo, s3Storage, rbmq)
-	contentSrv := content.NewContentService(contentRepo, rbmq, s3Storage, geminiClient)
+	resourceSrv := resources.NewResourceService(resourceRepo, fileStorage, rbmq, cfg.Uploads())
+	resourceSrv.StartUploadSweep(ctx, 10*time.Minute)
+	contentSrv := content.NewContentService(contentRepo, rbmq, fileStorage, aiProvider, linkFetcher, converter)
+	contentSrv.StartExamSweep(ctx, time.Minute)
 	commentSrv := comments.NewCommentService(commentRepo)
 
-	httpServer := http.NewHTTPServer(moduleSrv, userSrv, authSrv, resourceSrv, contentSrv, commentSrv, geminiClient, cfg.RAGServiceURL, ":8080")
+	httpServer := http.NewHTTPServer(moduleSrv, userSrv, authSrv, resourceSrv, contentSrv, commentSrv, localFiles, tusUploads, aiProvider, cfg.RAGServiceURL, ":8080")
 
 	log.Println("listening...")
 	httpServer.Start()
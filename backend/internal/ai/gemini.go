@@ -0,0 +1,197 @@
+package ai
+
+import (
+	"StudyHub/internal/content"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const (
+	defaultGeminiModel     = "gemini-2.5-flash"
+	defaultGeminiChatModel = "gemini-2.5-flash-lite"
+)
+
+type GeminiClient struct {
+	client    *genai.Client
+	model     string
+	chatModel string
+	config    *genai.GenerateContentConfig
+	// cards, summaries and quizzes are generated with a response schema so the model can only answer with their JSON
+	cardConfig     *genai.GenerateContentConfig
+	summaryConfig  *genai.GenerateContentConfig
+	quizConfig     *genai.GenerateContentConfig
+	maxInputTokens int
+}
+
+var flashcardSchema = &genai.Schema{
+	Type: genai.TypeArray,
+	Items: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"front": {Type: genai.TypeString},
+			"back":  {Type: genai.TypeString},
+		},
+		Required: []string{"front", "back"},
+	},
+}
+
+var summarySchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"key_points": {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+		"glossary": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"term":       {Type: genai.TypeString},
+					"definition": {Type: genai.TypeString},
+				},
+				Required: []string{"term", "definition"},
+			},
+		},
+	},
+	Required: []string{"key_points", "glossary"},
+}
+
+var quizSchema = &genai.Schema{
+	Type: genai.TypeArray,
+	Items: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"question":       {Type: genai.TypeString},
+			"options":        {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+			"correct_option": {Type: genai.TypeInteger},
+			"explanation":    {Type: genai.TypeString},
+		},
+		Required: []string{"question", "options", "correct_option", "explanation"},
+	},
+}
+
+func newGemini(cfg Config) (Provider, error) {
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:  cfg.APIKey,
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, err
+	}
+	gc := &GeminiClient{client: client, model: defaultGeminiModel, chatModel: defaultGeminiChatModel, maxInputTokens: cfg.MaxInputTokens}
+	if cfg.Model != "" {
+		gc.model = cfg.Model
+	}
+	if cfg.ChatModel != "" {
+		gc.chatModel = cfg.ChatModel
+	}
+	gc.cardConfig = jsonConfig(flashcardSchema, cfg.MaxOutputTokens)
+	gc.summaryConfig = jsonConfig(summarySchema, cfg.MaxOutputTokens)
+	gc.quizConfig = jsonConfig(quizSchema, cfg.MaxOutputTokens)
+	if cfg.MaxOutputTokens > 0 {
+		gc.config = &genai.GenerateContentConfig{MaxOutputTokens: int32(cfg.MaxOutputTokens)}
+	}
+	return gc, nil
+}
+
+func jsonConfig(schema *genai.Schema, maxOutputTokens int) *genai.GenerateContentConfig {
+	return &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   schema,
+		MaxOutputTokens:  int32(maxOutputTokens),
+	}
+}
+
+func (gc *GeminiClient) GenerateFlashCards(ctx context.Context, file io.ReadCloser, opts content.GenerationOptions) (string, error) {
+	return gc.generateFromDocument(ctx, file, flashcardRequest(opts), gc.cardConfig)
+}
+
+func (gc *GeminiClient) GenerateSummary(ctx context.Context, file io.ReadCloser) (string, error) {
+	return gc.generateFromDocument(ctx, file, summaryPrompt, gc.summaryConfig)
+}
+
+func (gc *GeminiClient) GenerateQuiz(ctx context.Context, file io.ReadCloser) (string, error) {
+	return gc.generateFromDocument(ctx, file, quizPrompt, gc.quizConfig)
+}
+
+// generateFromDocument asks the model about a document, pdfs are uploaded and extracted text is sent inline
+func (gc *GeminiClient) generateFromDocument(ctx context.Context, file io.ReadCloser, prompt string, config *genai.GenerateContentConfig) (string, error) {
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			slog.Error("failed to close file", "err", closeErr)
+		}
+	}()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read document: %w", err)
+	}
+
+	var promptParts []*genai.Part
+	if strings.HasPrefix(http.DetectContentType(data), "text/") {
+		promptParts = []*genai.Part{
+			genai.NewPartFromText(prompt),
+			genai.NewPartFromText("Document:\n" + truncateText(data, gc.maxInputTokens)),
+		}
+	} else {
+		uploadConfig := &genai.UploadFileConfig{MIMEType: "application/pdf"}
+		uploadedFile, err := gc.client.Files.Upload(ctx, bytes.NewReader(data), uploadConfig)
+		if err != nil {
+			return "", fmt.Errorf("failed to upload file to the Gemini: %w", err)
+		}
+		// every generation uploads its own copy, so it is deleted as soon as the answer is in
+		defer func() {
+			if _, err := gc.client.Files.Delete(context.WithoutCancel(ctx), uploadedFile.Name, nil); err != nil {
+				slog.Error("failed to delete uploaded file", "file", uploadedFile.Name, "err", err)
+			}
+		}()
+		promptParts = []*genai.Part{
+			genai.NewPartFromURI(uploadedFile.URI, uploadConfig.MIMEType),
+			genai.NewPartFromText(prompt),
+		}
+	}
+
+	contents := []*genai.Content{
+		genai.NewContentFromParts(promptParts, genai.RoleUser),
+	}
+
+	result, err := gc.client.Models.GenerateContent(
+		ctx,
+		gc.model,
+		contents,
+		config,
+	)
+	if err != nil {
+		return "", err
+	}
+	return result.Text(), nil
+}
+
+func (gc *GeminiClient) RepairFlashCards(ctx context.Context, response string, problems []string) (string, error) {
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{genai.NewPartFromText(repairRequest(response, problems))}, genai.RoleUser),
+	}
+	result, err := gc.client.Models.GenerateContent(ctx, gc.model, contents, gc.cardConfig)
+	if err != nil {
+		return "", fmt.Errorf("gemini repair failed: %w", err)
+	}
+	return result.Text(), nil
+}
+
+func (gc *GeminiClient) Chat(ctx context.Context, message string) (string, error) {
+	fullPrompt := chatSystemContext + "\n\nUser question: " + message
+
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{genai.NewPartFromText(fullPrompt)}, genai.RoleUser),
+	}
+
+	result, err := gc.client.Models.GenerateContent(ctx, gc.chatModel, contents, gc.config)
+	if err != nil {
+		return "", fmt.Errorf("gemini chat failed: %w", err)
+	}
+	return result.Text(), nil
+}
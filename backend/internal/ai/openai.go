@@ -0,0 +1,249 @@
+package ai
+
+import (
+	"StudyHub/internal/content"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4o-mini"
+)
+
+// OpenAIClient talks to any server implementing the OpenAI chat completions API,
+// which includes local llama.cpp and Ollama servers
+type OpenAIClient struct {
+	httpClient      *http.Client
+	baseURL         string
+	apiKey          string
+	model           string
+	chatModel       string
+	maxOutputTokens int
+	maxInputTokens  int
+}
+
+func newOpenAI(cfg Config) (Provider, error) {
+	c := &OpenAIClient{
+		httpClient:      &http.Client{},
+		baseURL:         defaultOpenAIBaseURL,
+		apiKey:          cfg.APIKey,
+		model:           defaultOpenAIModel,
+		maxOutputTokens: cfg.MaxOutputTokens,
+		maxInputTokens:  cfg.MaxInputTokens,
+	}
+	if cfg.BaseURL != "" {
+		c.baseURL = strings.TrimRight(cfg.BaseURL, "/")
+	}
+	if cfg.Model != "" {
+		c.model = cfg.Model
+	}
+	c.chatModel = c.model
+	if cfg.ChatModel != "" {
+		c.chatModel = cfg.ChatModel
+	}
+	return c, nil
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"` // a string or a list of content parts
+}
+
+type openAIContentPart struct {
+	Type string          `json:"type"`
+	Text string          `json:"text,omitempty"`
+	File *openAIFilePart `json:"file,omitempty"`
+}
+
+type openAIFilePart struct {
+	Filename string `json:"filename"`
+	FileData string `json:"file_data"`
+}
+
+type openAIRequest struct {
+	Model          string          `json:"model"`
+	Messages       []openAIMessage `json:"messages"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	ResponseFormat any             `json:"response_format,omitempty"`
+}
+
+// flashcardResponseFormat constrains the answer to a card list, strict schemas need an object at the root
+// so the cards are wrapped in {"cards": [...]}
+var flashcardResponseFormat = jsonSchemaFormat("flashcards", map[string]any{
+	"cards": map[string]any{
+		"type": "array",
+		"items": strictObject(map[string]any{
+			"front": map[string]string{"type": "string"},
+			"back":  map[string]string{"type": "string"},
+		}),
+	},
+})
+
+var summaryResponseFormat = jsonSchemaFormat("summary", map[string]any{
+	"key_points": map[string]any{"type": "array", "items": map[string]string{"type": "string"}},
+	"glossary": map[string]any{
+		"type": "array",
+		"items": strictObject(map[string]any{
+			"term":       map[string]string{"type": "string"},
+			"definition": map[string]string{"type": "string"},
+		}),
+	},
+})
+
+// quizResponseFormat wraps the questions in {"questions": [...]} for the same reason as the cards
+var quizResponseFormat = jsonSchemaFormat("quiz", map[string]any{
+	"questions": map[string]any{
+		"type": "array",
+		"items": strictObject(map[string]any{
+			"question":       map[string]string{"type": "string"},
+			"options":        map[string]any{"type": "array", "items": map[string]string{"type": "string"}},
+			"correct_option": map[string]string{"type": "integer"},
+			"explanation":    map[string]string{"type": "string"},
+		}),
+	},
+})
+
+func jsonSchemaFormat(name string, properties map[string]any) map[string]any {
+	return map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   name,
+			"strict": true,
+			"schema": strictObject(properties),
+		},
+	}
+}
+
+// strictObject is an object schema requiring all of its properties, as strict mode demands
+func strictObject(properties map[string]any) map[string]any {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             required,
+		"properties":           properties,
+	}
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// GenerateFlashCards sends text documents inline with the prompt, anything else is attached as a file,
+// which needs a server and model that accept documents
+func (c *OpenAIClient) GenerateFlashCards(ctx context.Context, file io.ReadCloser, opts content.GenerationOptions) (string, error) {
+	return c.generateFromDocument(ctx, file, flashcardRequest(opts), flashcardResponseFormat)
+}
+
+func (c *OpenAIClient) GenerateSummary(ctx context.Context, file io.ReadCloser) (string, error) {
+	return c.generateFromDocument(ctx, file, summaryPrompt, summaryResponseFormat)
+}
+
+func (c *OpenAIClient) GenerateQuiz(ctx context.Context, file io.ReadCloser) (string, error) {
+	return c.generateFromDocument(ctx, file, quizPrompt, quizResponseFormat)
+}
+
+func (c *OpenAIClient) generateFromDocument(ctx context.Context, file io.ReadCloser, prompt string, format any) (string, error) {
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			slog.Error("failed to close file", "err", closeErr)
+		}
+	}()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read document: %w", err)
+	}
+
+	var parts []openAIContentPart
+	contentType := http.DetectContentType(data)
+	if strings.HasPrefix(contentType, "text/") {
+		parts = []openAIContentPart{
+			{Type: "text", Text: prompt},
+			{Type: "text", Text: "Document:\n" + truncateText(data, c.maxInputTokens)},
+		}
+	} else {
+		parts = []openAIContentPart{
+			{Type: "file", File: &openAIFilePart{
+				Filename: "document.pdf",
+				FileData: "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(data),
+			}},
+			{Type: "text", Text: prompt},
+		}
+	}
+
+	return c.complete(ctx, c.model, []openAIMessage{{Role: "user", Content: parts}}, format)
+}
+
+func (c *OpenAIClient) RepairFlashCards(ctx context.Context, response string, problems []string) (string, error) {
+	messages := []openAIMessage{{Role: "user", Content: repairRequest(response, problems)}}
+	reply, err := c.complete(ctx, c.model, messages, flashcardResponseFormat)
+	if err != nil {
+		return "", fmt.Errorf("openai repair failed: %w", err)
+	}
+	return reply, nil
+}
+
+func (c *OpenAIClient) Chat(ctx context.Context, message string) (string, error) {
+	messages := []openAIMessage{
+		{Role: "system", Content: chatSystemContext},
+		{Role: "user", Content: message},
+	}
+	reply, err := c.complete(ctx, c.chatModel, messages, nil)
+	if err != nil {
+		return "", fmt.Errorf("openai chat failed: %w", err)
+	}
+	return reply, nil
+}
+
+func (c *OpenAIClient) complete(ctx context.Context, model string, messages []openAIMessage, format any) (string, error) {
+	body, err := json.Marshal(openAIRequest{Model: model, Messages: messages, MaxTokens: c.maxOutputTokens, ResponseFormat: format})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("bad status: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode completion: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("completion has no choices")
+	}
+	return result.Choices[0].Message.Content, nil
+}
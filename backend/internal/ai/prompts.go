@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"StudyHub/internal/content"
+	"fmt"
+	"strings"
+)
+
+const chatSystemContext = `You are a helpful assistant for StudyHub, an academic study management platform.
+
+StudyHub helps students manage their academic modules and study resources. Here is what the app does:
+
+- Modules: Academic courses organised by department (e.g. "Introduction to Computer Science"). Each module has one or more runs.
+- Module Runs: A specific instance of a module in a semester and year (e.g. Spring 2024). Each run contains weekly sessions.
+- Weeks: Individual weeks within a module run, each holding uploaded resources.
+- Resources: Files (PDFs, docs, etc.) or external links that students upload to a specific week. Files are stored in AWS S3.
+- Flashcard Decks: AI-generated flashcards from uploaded PDF files that help students study. Users can also create custom cards.
+- Comments: Students can comment on weekly resources and upvote/downvote others' comments.
+- User Profiles: Each user can view their own uploads and those of other students.
+- Academic Terms: Admin-managed terms (semester + year) that group module runs.
+
+Navigation structure: Modules → Module Detail (runs & weeks) → Week Detail (resources, comments, flashcards)
+
+Answer questions about how to use the app, its features, and how to navigate it. If asked about real-time data (e.g. "what modules exist"), explain you can't access live data but describe where to find it in the app.`
+
+// flashcardPrompt states the length limits the content worker validates the cards against
+var flashcardPrompt = fmt.Sprintf(`You are an expert educator and data extraction assistant. Your task is to read the provided document and generate a comprehensive set of high-quality flashcards.
+Instructions:
+Content: Identify key concepts, definitions, dates, and relationships. Create "front" (question or term) and "back" (answer or definition) pairs.
+Atomicity: Each flashcard should cover exactly one discrete idea to ensure effective active recall.
+Length: Keep cards short, the front must stay under %d characters and the back under %d characters. Never repeat a question.
+Format: Your entire response must be a JSON array of objects and nothing else, no markdown fences and no introductory or concluding text:
+[
+  {"front": "The question or term goes here", "back": "The concise answer or definition goes here"}
+]
+`, content.MaxGeneratedFrontLength, content.MaxGeneratedBackLength)
+
+const summaryPrompt = `You are an expert educator. Read the provided document and summarise it for a student revising for an exam.
+Instructions:
+Key points: List the most important ideas of the document in the order they appear, one or two sentences each, at most 15.
+Glossary: List the technical terms the document introduces with a one sentence definition each, at most 20.
+Format: Your entire response must be a JSON object and nothing else, no markdown fences and no introductory or concluding text:
+{
+  "key_points": ["The first key idea of the document"],
+  "glossary": [{"term": "A term", "definition": "What the term means"}]
+}
+`
+
+const quizPrompt = `You are an expert educator. Read the provided document and write a multiple-choice quiz that checks whether a student understood it.
+Instructions:
+Questions: Write up to 10 questions on the key concepts, each with exactly 4 options of which exactly one is correct.
+Distractors: The wrong options must be plausible and about the same length as the correct one.
+Explanation: Explain in one or two sentences why the correct option is right.
+Format: Your entire response must be a JSON array of objects and nothing else, no markdown fences and no introductory or concluding text. correct_option is the 0-based index of the correct option:
+[
+  {"question": "The question", "options": ["A", "B", "C", "D"], "correct_option": 0, "explanation": "Why A is correct"}
+]
+`
+
+// repairPrompt asks the model to fix its own answer, it gets the validation problems and the answer
+const repairPrompt = `Your previous answer could not be used as flashcards because of these problems:
+%s
+
+Return the corrected flashcards as a JSON array of {"front", "back"} objects and nothing else.
+Keep the cards that had no problems unchanged, shorten the ones that are too long and drop duplicates.
+
+Previous answer:
+%s`
+
+// flashcardRequest adds what a regeneration asked for to the flashcard prompt, the defaults add nothing
+func flashcardRequest(opts content.GenerationOptions) string {
+	var extra []string
+	if opts.CardCount > 0 {
+		extra = append(extra, fmt.Sprintf("Count: Generate exactly %d flashcards, covering the most important concepts first.", opts.CardCount))
+	}
+	switch opts.Difficulty {
+	case content.DifficultyEasy:
+		extra = append(extra, "Difficulty: Ask about basic facts, terms and definitions a student should recall after a first reading.")
+	case content.DifficultyMedium:
+		extra = append(extra, "Difficulty: Ask about how concepts work and relate to each other, not only what they are called.")
+	case content.DifficultyHard:
+		extra = append(extra, "Difficulty: Ask questions that need applying, comparing or reasoning about the concepts, as in an exam.")
+	}
+	switch opts.CardType {
+	case content.GeneratedQuestion:
+		extra = append(extra, "Card type: Put a question on the front and its answer on the back.")
+	case content.GeneratedDefinition:
+		extra = append(extra, "Card type: Put a single term on the front and its definition on the back.")
+	case content.GeneratedCloze:
+		extra = append(extra, "Card type: Put a statement from the document on the front with one key word or phrase replaced by ____, and the missing text on the back.")
+	}
+	if len(opts.FocusTopics) > 0 {
+		extra = append(extra, "Focus: Only cover these topics and skip the rest of the document: "+strings.Join(opts.FocusTopics, "; ")+".")
+	}
+	if opts.Language != "" {
+		extra = append(extra, fmt.Sprintf("Language: Write every card in %s, whatever the language of the document.", opts.Language))
+	}
+	if len(extra) == 0 {
+		return flashcardPrompt
+	}
+	return flashcardPrompt + "Additional requirements:\n" + strings.Join(extra, "\n") + "\n"
+}
+
+func repairRequest(response string, problems []string) string {
+	return fmt.Sprintf(repairPrompt, "- "+strings.Join(problems, "\n- "), response)
+}
@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"StudyHub/internal/content"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// rough size of a token, used to keep text documents under MaxInputTokens
+const bytesPerToken = 4
+
+// Provider is a model backend the content worker and the chat can run against, the Generate methods
+// return the model's JSON answer for the content worker to validate
+type Provider interface {
+	GenerateFlashCards(ctx context.Context, file io.ReadCloser, opts content.GenerationOptions) (string, error)
+	// RepairFlashCards sends a generated answer back with the problems found in it and returns the corrected one
+	RepairFlashCards(ctx context.Context, response string, problems []string) (string, error)
+	GenerateSummary(ctx context.Context, file io.ReadCloser) (string, error)
+	GenerateQuiz(ctx context.Context, file io.ReadCloser) (string, error)
+	Chat(ctx context.Context, message string) (string, error)
+}
+
+// Config selects a provider and its limits, empty fields fall back to the provider's defaults
+type Config struct {
+	Provider        string
+	Model           string // model used for flashcard generation
+	ChatModel       string
+	BaseURL         string
+	APIKey          string
+	Timeout         time.Duration // per request, 0 means no timeout
+	MaxOutputTokens int
+	MaxInputTokens  int // only enforced for text documents sent inline, estimated at 4 bytes per token
+}
+
+// Factory builds a provider from its config
+type Factory func(cfg Config) (Provider, error)
+
+var providers = map[string]Factory{
+	"gemini": newGemini,
+	"openai": newOpenAI,
+	"fake":   newFake,
+}
+
+// Register adds a provider under name, an existing one with the same name is replaced
+func Register(name string, factory Factory) {
+	providers[strings.ToLower(name)] = factory
+}
+
+// Providers returns the registered provider names, sorted
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the provider named in cfg and applies its timeout to every request
+func New(cfg Config) (Provider, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	factory, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown AI provider %q, expected one of %s", cfg.Provider, strings.Join(Providers(), ", "))
+	}
+	if cfg.Timeout < 0 || cfg.MaxOutputTokens < 0 || cfg.MaxInputTokens < 0 {
+		return nil, fmt.Errorf("AI timeout and token limits cannot be negative")
+	}
+	provider, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s provider: %w", name, err)
+	}
+	if cfg.Timeout == 0 {
+		return provider, nil
+	}
+	return &timeoutProvider{provider: provider, timeout: cfg.Timeout}, nil
+}
+
+// truncateText cuts a text document down to maxTokens without splitting a character, 0 means no limit
+func truncateText(data []byte, maxTokens int) string {
+	limit := maxTokens * bytesPerToken
+	if limit == 0 || len(data) <= limit {
+		return string(data)
+	}
+	data = data[:limit]
+	for len(data) > 0 && !utf8.Valid(data) {
+		data = data[:len(data)-1]
+	}
+	return string(data)
+}
+
+type timeoutProvider struct {
+	provider Provider
+	timeout  time.Duration
+}
+
+func (p *timeoutProvider) GenerateFlashCards(ctx context.Context, file io.ReadCloser, opts content.GenerationOptions) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return p.provider.GenerateFlashCards(ctx, file, opts)
+}
+
+func (p *timeoutProvider) RepairFlashCards(ctx context.Context, response string, problems []string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return p.provider.RepairFlashCards(ctx, response, problems)
+}
+
+func (p *timeoutProvider) GenerateSummary(ctx context.Context, file io.ReadCloser) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return p.provider.GenerateSummary(ctx, file)
+}
+
+func (p *timeoutProvider) GenerateQuiz(ctx context.Context, file io.ReadCloser) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return p.provider.GenerateQuiz(ctx, file)
+}
+
+func (p *timeoutProvider) Chat(ctx context.Context, message string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return p.provider.Chat(ctx, message)
+}
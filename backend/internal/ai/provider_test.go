@@ -0,0 +1,144 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           Config
+		expectTimeout bool
+		expectedErr   string
+	}{
+		{name: "fake", cfg: Config{Provider: "fake"}},
+		{name: "name is case and space insensitive", cfg: Config{Provider: "  FAKE "}},
+		{name: "timeout wraps the provider", cfg: Config{Provider: "fake", Timeout: time.Minute}, expectTimeout: true},
+		{name: "unknown provider", cfg: Config{Provider: "claude"}, expectedErr: `unknown AI provider "claude", expected one of fake, gemini, openai`},
+		{name: "empty provider", cfg: Config{}, expectedErr: "unknown AI provider"},
+		{name: "negative timeout", cfg: Config{Provider: "fake", Timeout: -time.Second}, expectedErr: "cannot be negative"},
+		{name: "negative token limit", cfg: Config{Provider: "fake", MaxOutputTokens: -1}, expectedErr: "cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := New(tt.cfg)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			timeout, ok := provider.(*timeoutProvider)
+			if ok != tt.expectTimeout {
+				t.Fatalf("expected timeout wrapper %v, got %T", tt.expectTimeout, provider)
+			}
+			if ok {
+				if timeout.timeout != tt.cfg.Timeout {
+					t.Errorf("expected timeout %v, got %v", tt.cfg.Timeout, timeout.timeout)
+				}
+				provider = timeout.provider
+			}
+			if _, ok := provider.(FakeClient); !ok {
+				t.Errorf("expected the fake provider, got %T", provider)
+			}
+		})
+	}
+}
+
+func TestNewOpenAI(t *testing.T) {
+	provider, err := New(Config{Provider: "openai", Model: "small", BaseURL: "http://localhost:8080/v1/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client, ok := provider.(*OpenAIClient)
+	if !ok {
+		t.Fatalf("expected the openai provider, got %T", provider)
+	}
+	if client.model != "small" || client.chatModel != "small" {
+		t.Errorf("expected both models to be small, got %q and %q", client.model, client.chatModel)
+	}
+	if client.baseURL != "http://localhost:8080/v1" {
+		t.Errorf("expected the trailing slash to be trimmed, got %q", client.baseURL)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Cleanup(func() { delete(providers, "custom") })
+
+	var got Config
+	Register("Custom", func(cfg Config) (Provider, error) {
+		got = cfg
+		return FakeClient{}, nil
+	})
+	if !strings.Contains(strings.Join(Providers(), ","), "custom") {
+		t.Fatalf("expected custom in %v", Providers())
+	}
+
+	if _, err := New(Config{Provider: "custom", Model: "small"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Model != "small" {
+		t.Errorf("expected the factory to get the config, got %+v", got)
+	}
+
+	Register("custom", func(cfg Config) (Provider, error) {
+		return nil, errors.New("no credentials")
+	})
+	if _, err := New(Config{Provider: "custom"}); err == nil || !strings.Contains(err.Error(), "no credentials") {
+		t.Errorf("expected the replaced factory's error, got %v", err)
+	}
+}
+
+func TestTimeoutProvider(t *testing.T) {
+	deadlines := make(chan bool, 1)
+	provider := &timeoutProvider{provider: deadlineProvider{FakeClient{}, deadlines}, timeout: time.Minute}
+
+	if _, err := provider.Chat(context.Background(), "hi"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !<-deadlines {
+		t.Errorf("expected the request to run with a deadline")
+	}
+}
+
+// deadlineProvider reports whether a chat request came with a deadline
+type deadlineProvider struct {
+	FakeClient
+	deadlines chan bool
+}
+
+func (p deadlineProvider) Chat(ctx context.Context, message string) (string, error) {
+	_, ok := ctx.Deadline()
+	p.deadlines <- ok
+	return p.FakeClient.Chat(ctx, message)
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		maxTokens int
+		expected  string
+	}{
+		{name: "no limit", data: "photosynthesis", maxTokens: 0, expected: "photosynthesis"},
+		{name: "under the limit", data: "cell", maxTokens: 1, expected: "cell"},
+		{name: "cut at the limit", data: "mitochondria", maxTokens: 2, expected: "mitochon"},
+		{name: "multi-byte rune is not split", data: "abcäöü", maxTokens: 1, expected: "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateText([]byte(tt.data), tt.maxTokens); got != tt.expected {
+				t.Errorf("truncateText(%q, %d) = %q, want %q", tt.data, tt.maxTokens, got, tt.expected)
+			}
+		})
+	}
+}
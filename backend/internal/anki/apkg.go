@@ -0,0 +1,493 @@
+// Package anki reads and writes Anki deck packages (.apkg) in the legacy collection.anki2 format,
+// which every Anki version (desktop, AnkiDroid, AnkiMobile) can still import.
+package anki
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha1" //nolint:gosec // Anki's note checksum is defined as the first bytes of a SHA-1
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	collectionFile = "collection.anki2"
+	// Anki 2.1 exports keep the real notes in collection.anki21 and a placeholder in collection.anki2
+	collection21File = "collection.anki21"
+	// Anki 23.10+ compresses the collection with zstd unless "support older Anki versions" is ticked
+	collection21bFile = "collection.anki21b"
+	fieldSeparator    = "\x1f"
+	defaultDeckID     = 1
+	defaultConfID     = 1
+	// the collection is never extracted past this, so a small package cannot expand into gigabytes on disk
+	maxCollectionSize = 512 << 20
+	// due values past this are timestamps, no day count since the collection was created gets this high
+	learningDueThreshold = 1_000_000_000
+)
+
+// Note is one front/back note, which produces a single card in the Basic note type.
+type Note struct {
+	GUID  string // stable id so re-importing the same export updates instead of duplicating
+	Deck  string // nested decks are separated by "::"
+	Front string
+	Back  string
+	Tags  []string
+	Card  CardState
+}
+
+// CardState is the scheduling state carried over into Anki, a nil Due means the card is new.
+type CardState struct {
+	Due          *time.Time
+	IntervalDays int
+	EaseFactor   float64
+	Repetitions  int
+	Lapses       int
+}
+
+const schema = `
+CREATE TABLE col (
+    id integer primary key, crt integer not null, mod integer not null, scm integer not null,
+    ver integer not null, dty integer not null, usn integer not null, ls integer not null,
+    conf text not null, models text not null, decks text not null, dconf text not null, tags text not null
+);
+CREATE TABLE notes (
+    id integer primary key, guid text not null, mid integer not null, mod integer not null,
+    usn integer not null, tags text not null, flds text not null, sfld integer not null,
+    csum integer not null, flags integer not null, data text not null
+);
+CREATE TABLE cards (
+    id integer primary key, nid integer not null, did integer not null, ord integer not null,
+    mod integer not null, usn integer not null, type integer not null, queue integer not null,
+    due integer not null, ivl integer not null, factor integer not null, reps integer not null,
+    lapses integer not null, left integer not null, odue integer not null, odid integer not null,
+    flags integer not null, data text not null
+);
+CREATE TABLE revlog (
+    id integer primary key, cid integer not null, usn integer not null, ease integer not null,
+    ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null,
+    type integer not null
+);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+// WritePackage writes notes as an .apkg archive to w.
+func WritePackage(ctx context.Context, w io.Writer, notes []Note) error {
+	dir, err := os.MkdirTemp("", "apkg-export-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, collectionFile)
+	if err := writeCollection(ctx, path, notes); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	if err := addFileToZip(zw, collectionFile, path); err != nil {
+		return err
+	}
+	media, err := zw.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := media.Write([]byte("{}")); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func addFileToZip(zw *zip.Writer, name, path string) error {
+	f, err := os.Open(path) //nolint:gosec // path is inside our own temp dir
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	part, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, f)
+	return err
+}
+
+func writeCollection(ctx context.Context, path string, notes []Note) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create anki schema: %w", err)
+	}
+
+	now := time.Now()
+	// Anki measures review due dates in days since the collection was created, so start the
+	// collection on the earliest due date to keep every value positive
+	created := startOfDay(now)
+	for _, note := range notes {
+		if note.Card.Due != nil && note.Card.Due.Before(created) {
+			created = startOfDay(*note.Card.Due)
+		}
+	}
+
+	// ids in Anki are millisecond timestamps, hand them out sequentially to keep them unique
+	nextID := now.UnixMilli()
+	newID := func() int64 {
+		nextID++
+		return nextID
+	}
+
+	modelID := newID()
+	deckIDs := map[string]int64{}
+	for _, note := range notes {
+		for _, name := range deckPath(note.Deck) {
+			if _, ok := deckIDs[name]; !ok {
+				deckIDs[name] = newID()
+			}
+		}
+	}
+
+	colJSON, err := collectionJSON(now, modelID, deckIDs)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		created.Unix(), now.UnixMilli(), now.UnixMilli(), colJSON.conf, colJSON.models, colJSON.decks, colJSON.dconf,
+	)
+	if err != nil {
+		return fmt.Errorf("insert anki collection: %w", err)
+	}
+
+	for i, note := range notes {
+		noteID := newID()
+		front, back := toField(note.Front), toField(note.Back)
+		tags := ""
+		if len(note.Tags) > 0 {
+			tags = " " + strings.Join(note.Tags, " ") + " "
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`,
+			noteID, note.GUID, modelID, now.Unix(), tags, front+fieldSeparator+back, note.Front, checksum(note.Front),
+		)
+		if err != nil {
+			return fmt.Errorf("insert anki note: %w", err)
+		}
+
+		deckID := int64(defaultDeckID)
+		if note.Deck != "" {
+			deckID = deckIDs[note.Deck]
+		}
+		cardType, queue, due := 0, 0, int64(i+1)
+		if note.Card.Due != nil {
+			cardType, queue = 2, 2
+			due = int64(startOfDay(*note.Card.Due).Sub(created).Hours() / 24)
+		}
+		factor := int(math.Round(note.Card.EaseFactor * 1000))
+		if factor == 0 {
+			factor = 2500
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, ?, ?, ?, ?, ?, ?, ?, 0, 0, 0, 0, '')`,
+			newID(), noteID, deckID, now.Unix(), cardType, queue, due,
+			note.Card.IntervalDays, factor, note.Card.Repetitions, note.Card.Lapses,
+		)
+		if err != nil {
+			return fmt.Errorf("insert anki card: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// deckPath returns the deck and all of its parents, "A::B" gives ["A", "A::B"]
+func deckPath(name string) []string {
+	if name == "" {
+		return nil
+	}
+	parts := strings.Split(name, "::")
+	path := make([]string, len(parts))
+	for i := range parts {
+		path[i] = strings.Join(parts[:i+1], "::")
+	}
+	return path
+}
+
+type collectionConfig struct {
+	conf, models, decks, dconf string
+}
+
+func collectionJSON(now time.Time, modelID int64, deckIDs map[string]int64) (collectionConfig, error) {
+	conf := map[string]any{
+		"nextPos": 1, "estTimes": true, "activeDecks": []int{defaultDeckID}, "sortType": "noteFld",
+		"timeLim": 0, "sortBackwards": false, "addToCur": true, "curDeck": defaultDeckID,
+		"newSpread": 0, "dueCounts": true, "curModel": modelID, "collapseTime": 1200,
+	}
+
+	field := func(name string, ord int) map[string]any {
+		return map[string]any{"name": name, "ord": ord, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []any{}}
+	}
+	models := map[string]any{
+		fmt.Sprint(modelID): map[string]any{
+			"id": modelID, "name": "Basic (StudyHub)", "type": 0, "mod": now.Unix(), "usn": -1,
+			"sortf": 0, "did": defaultDeckID, "tags": []any{}, "vers": []any{},
+			"tmpls": []any{map[string]any{
+				"name": "Card 1", "ord": 0, "did": nil, "bqfmt": "", "bafmt": "",
+				"qfmt": "{{Front}}", "afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}",
+			}},
+			"flds":      []any{field("Front", 0), field("Back", 1)},
+			"css":       ".card {\n font-family: arial;\n font-size: 20px;\n text-align: center;\n color: black;\n background-color: white;\n}\n",
+			"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+			"latexPost": "\\end{document}",
+			"req":       []any{[]any{0, "any", []int{0}}},
+		},
+	}
+
+	deck := func(id int64, name string) map[string]any {
+		return map[string]any{
+			"id": id, "name": name, "mod": now.Unix(), "usn": -1, "desc": "", "dyn": 0, "conf": defaultConfID,
+			"collapsed": false, "browserCollapsed": false, "extendNew": 0, "extendRev": 0,
+			"lrnToday": []int{0, 0}, "revToday": []int{0, 0}, "newToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	decks := map[string]any{fmt.Sprint(defaultDeckID): deck(defaultDeckID, "Default")}
+	for name, id := range deckIDs {
+		decks[fmt.Sprint(id)] = deck(id, name)
+	}
+
+	dconf := map[string]any{
+		fmt.Sprint(defaultConfID): map[string]any{
+			"id": defaultConfID, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true,
+			"timer": 0, "replayq": true, "dyn": false,
+			"new": map[string]any{
+				"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500,
+				"order": 1, "perDay": 20, "bury": false, "separate": true,
+			},
+			"rev": map[string]any{
+				"perDay": 200, "ease4": 1.3, "fuzz": 0.05, "maxIvl": 36500, "ivlFct": 1,
+				"bury": false, "minSpace": 1, "hardFactor": 1.2,
+			},
+			"lapse": map[string]any{
+				"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 1,
+			},
+		},
+	}
+
+	var cfg collectionConfig
+	for _, part := range []struct {
+		dst *string
+		src any
+	}{{&cfg.conf, conf}, {&cfg.models, models}, {&cfg.decks, decks}, {&cfg.dconf, dconf}} {
+		b, err := json.Marshal(part.src)
+		if err != nil {
+			return collectionConfig{}, err
+		}
+		*part.dst = string(b)
+	}
+	return cfg, nil
+}
+
+// toField turns plain text into the HTML Anki stores in note fields
+func toField(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
+
+// checksum is the first 32 bits of the SHA-1 of the sort field, used by Anki for duplicate detection
+func checksum(sortField string) int64 {
+	sum := sha1.Sum([]byte(sortField)) //nolint:gosec // required by the Anki format
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ErrUnsupportedPackage is returned for packages that only contain the zstd-compressed collection format
+var ErrUnsupportedPackage = errors.New(`unsupported apkg format, export it from Anki with "Support older Anki versions" enabled`)
+
+// ReadPackage returns the notes of an .apkg archive. Only the first two fields of each note are kept,
+// converted from Anki's HTML to plain text, together with the deck and scheduling state of the note's first card.
+func ReadPackage(ctx context.Context, r io.ReaderAt, size int64) ([]Note, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("open apkg: %w", err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	collection, ok := files[collection21File]
+	if !ok {
+		collection, ok = files[collectionFile]
+	}
+	if !ok {
+		if _, ok := files[collection21bFile]; ok {
+			return nil, ErrUnsupportedPackage
+		}
+		return nil, errors.New("apkg does not contain a collection")
+	}
+
+	dir, err := os.MkdirTemp("", "apkg-import-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, collectionFile)
+	if err := extractFile(collection, path); err != nil {
+		return nil, err
+	}
+	return readNotes(ctx, path)
+}
+
+func extractFile(f *zip.File, path string) error {
+	if f.UncompressedSize64 > maxCollectionSize {
+		return fmt.Errorf("apkg collection is too large: %d bytes", f.UncompressedSize64)
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.Create(path) //nolint:gosec // path is inside our own temp dir
+	if err != nil {
+		return err
+	}
+	// the header size can lie, the copy stops one byte past the limit to tell
+	written, err := io.Copy(dst, io.LimitReader(src, maxCollectionSize+1))
+	if err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if written > maxCollectionSize {
+		_ = dst.Close()
+		return fmt.Errorf("apkg collection is too large: over %d bytes", maxCollectionSize)
+	}
+	return dst.Close()
+}
+
+func readNotes(ctx context.Context, path string) ([]Note, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+
+	var created int64
+	var decksJSON string
+	if err := db.QueryRowContext(ctx, `SELECT crt, decks FROM col`).Scan(&created, &decksJSON); err != nil {
+		return nil, fmt.Errorf("read anki collection: %w", err)
+	}
+	deckNames, err := readDeckNames(decksJSON)
+	if err != nil {
+		return nil, err
+	}
+	crt := startOfDay(time.Unix(created, 0))
+
+	// a note is read with its first card, the one the Basic note type produces
+	rows, err := db.QueryContext(ctx, `
+		SELECT n.guid, n.tags, n.flds, COALESCE(c.did, 0), COALESCE(c.type, 0),
+		       COALESCE(c.due, 0), COALESCE(c.ivl, 0), COALESCE(c.factor, 0), COALESCE(c.reps, 0), COALESCE(c.lapses, 0)
+		FROM notes n
+		LEFT JOIN cards c ON c.nid = n.id AND c.ord = 0
+		ORDER BY n.id`)
+	if err != nil {
+		return nil, fmt.Errorf("read anki notes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	notes := make([]Note, 0)
+	for rows.Next() {
+		var guid, tags, fields string
+		var deckID, due int64
+		var cardType, factor int
+		var card CardState
+		if err := rows.Scan(&guid, &tags, &fields, &deckID, &cardType, &due,
+			&card.IntervalDays, &factor, &card.Repetitions, &card.Lapses); err != nil {
+			return nil, fmt.Errorf("scan anki note: %w", err)
+		}
+		note := Note{GUID: guid, Deck: deckNames[deckID], Tags: strings.Fields(tags)}
+		parts := strings.Split(fields, fieldSeparator)
+		note.Front = fromField(parts[0])
+		if len(parts) > 1 {
+			note.Back = fromField(parts[1])
+		}
+		// new cards keep an empty state, their due is a position in the new queue
+		if cardType != 0 {
+			// cards in learning are due at a timestamp in seconds, the rest in days since the collection was created
+			dueAt := crt.AddDate(0, 0, int(due))
+			if due > learningDueThreshold {
+				dueAt = time.Unix(due, 0).UTC()
+			}
+			card.Due = &dueAt
+			card.EaseFactor = float64(factor) / 1000
+			note.Card = card
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// readDeckNames maps deck ids to their names, Anki's default deck maps to no deck
+func readDeckNames(decksJSON string) (map[int64]string, error) {
+	var decks map[string]struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(decksJSON), &decks); err != nil {
+		return nil, fmt.Errorf("read anki decks: %w", err)
+	}
+	names := make(map[int64]string, len(decks))
+	for _, deck := range decks {
+		if deck.ID != defaultDeckID {
+			names[deck.ID] = deck.Name
+		}
+	}
+	return names, nil
+}
+
+var (
+	lineBreakTags = regexp.MustCompile(`(?i)<br\s*/?>|</div>|</p>|</li>`)
+	htmlTags      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// fromField is the reverse of toField, it drops any formatting Anki added to the field
+func fromField(field string) string {
+	text := lineBreakTags.ReplaceAllString(field, "\n")
+	text = htmlTags.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(html.UnescapeString(text), "\u00a0", " ")
+	return strings.TrimSpace(text)
+}
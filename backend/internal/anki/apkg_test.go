@@ -0,0 +1,71 @@
+package anki
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPackageRoundTrip(t *testing.T) {
+	due := time.Date(2026, 3, 14, 15, 30, 0, 0, time.UTC)
+	past := time.Now().AddDate(0, 0, -40)
+	notes := []Note{
+		{
+			GUID: "a1b2c3", Deck: "Biology::Cells", Front: "What is ATP?", Back: "The cell's <energy> carrier\nmade in mitochondria",
+			Tags: []string{"biology", "exam"},
+			Card: CardState{Due: &due, IntervalDays: 12, EaseFactor: 2.35, Repetitions: 5, Lapses: 2},
+		},
+		{
+			GUID: "d4e5f6", Deck: "Biology", Front: "Define osmosis", Back: "Diffusion of water & solvents",
+			Card: CardState{Due: &past, IntervalDays: 3, EaseFactor: 1.3, Repetitions: 1, Lapses: 0},
+		},
+		{GUID: "g7h8i9", Front: "New card", Back: "never reviewed", Tags: []string{"new"}},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePackage(context.Background(), &buf, notes); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadPackage(context.Background(), bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(notes) {
+		t.Fatalf("expected %d notes, got %d", len(notes), len(got))
+	}
+
+	for i, expected := range notes {
+		note := got[i]
+		if note.GUID != expected.GUID || note.Deck != expected.Deck || note.Front != expected.Front || note.Back != expected.Back {
+			t.Errorf("note %d = %+v, want %+v", i, note, expected)
+		}
+		if strings.Join(note.Tags, " ") != strings.Join(expected.Tags, " ") {
+			t.Errorf("note %d tags = %v, want %v", i, note.Tags, expected.Tags)
+		}
+
+		card, want := note.Card, expected.Card
+		if want.Due == nil {
+			if card.Due != nil || card != (CardState{}) {
+				t.Errorf("note %d expected a new card, got %+v", i, card)
+			}
+			continue
+		}
+		// Anki keeps review due dates as whole days
+		if card.Due == nil || !card.Due.Equal(startOfDay(*want.Due)) {
+			t.Errorf("note %d due = %v, want %v", i, card.Due, startOfDay(*want.Due))
+		}
+		if card.IntervalDays != want.IntervalDays || card.EaseFactor != want.EaseFactor ||
+			card.Repetitions != want.Repetitions || card.Lapses != want.Lapses {
+			t.Errorf("note %d card = %+v, want %+v", i, card, want)
+		}
+	}
+}
+
+func TestReadPackageRejectsBrokenArchive(t *testing.T) {
+	data := []byte("not a zip")
+	if _, err := ReadPackage(context.Background(), bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("expected an error for a broken archive")
+	}
+}
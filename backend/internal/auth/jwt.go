@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type ContextKey string
@@ -79,3 +80,20 @@ func createNewJWT(userID string, key string) (string, error) {
 
 	return signedToken, nil
 }
+
+// AdminMiddleware only lets admins through, it has to run after JWTMiddleware
+func (s *AuthService) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(UserIDContextKey).(string)
+		if !ok {
+			http.Error(w, "missing user in context", http.StatusUnauthorized)
+			return
+		}
+		id, err := uuid.Parse(userID)
+		if err != nil || !s.IsAdmin(r.Context(), id) {
+			http.Error(w, "admin access required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
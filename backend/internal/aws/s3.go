@@ -10,6 +10,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
@@ -23,15 +24,51 @@ type S3Storage struct {
 	URL        string
 }
 
-func NewS3Storage(bucketname, url string) *S3Storage {
+// CompletedPart is an uploaded part of a multipart upload with the ETag S3 answered its PUT with
+type CompletedPart struct {
+	Number int32
+	ETag   string
+}
+
+// S3Config points the client at AWS or any S3-compatible server such as MinIO, empty fields
+// fall back to the AWS environment and shared config
+type S3Config struct {
+	Bucket          string
+	URL             string // public url of the bucket, stored with every object
+	Endpoint        string // e.g. http://minio:9000, empty for AWS
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UsePathStyle    bool // MinIO serves buckets as paths instead of subdomains
+}
+
+func NewS3Storage(ctx context.Context, cnf S3Config) (*S3Storage, error) {
 	_ = godotenv.Load()
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if cnf.Bucket == "" {
+		return nil, errors.New("s3 storage needs a bucket name")
+	}
+
+	var opts []func(*config.LoadOptions) error
+	if cnf.Region != "" {
+		opts = append(opts, config.WithRegion(cnf.Region))
+	}
+	if cnf.AccessKeyID != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cnf.AccessKeyID, cnf.SecretAccessKey, "")))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
 	}
-	client := s3.NewFromConfig(cfg)
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if cnf.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cnf.Endpoint)
+		}
+		o.UsePathStyle = cnf.UsePathStyle
+	})
 	presigner := s3.NewPresignClient(client)
-	return &S3Storage{s3Client: client, bucketName: bucketname, URL: url, presigner: presigner}
+	return &S3Storage{s3Client: client, bucketName: cnf.Bucket, URL: cnf.URL, presigner: presigner}, nil
 }
 
 func (s *S3Storage) UploadObject(ctx context.Context, filename string, size int64, body io.Reader) (string, error) {
@@ -89,6 +126,18 @@ func (s *S3Storage) GetObject(ctx context.Context, key string) (io.ReadCloser, e
 	return result.Body, nil
 }
 
+// StatObject returns the size of an object from a HEAD request, the body is not read
+func (s *S3Storage) StatObject(ctx context.Context, key string) (int64, error) {
+	output, err := s.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return aws.ToInt64(output.ContentLength), nil
+}
+
 func (s *S3Storage) DownloadObject(ctx context.Context, objectID string) (string, error) {
 	return "", nil
 }
@@ -106,3 +155,59 @@ func (s *S3Storage) CreatePresidedURL(ctx context.Context, key string) (string,
 	}
 	return request.URL, err
 }
+
+func (s *S3Storage) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	output, err := s.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.UploadId), nil
+}
+
+func (s *S3Storage) PresignUploadPart(ctx context.Context, key, uploadID string, part int32, expiry time.Duration) (string, error) {
+	request, err := s.presigner.PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucketName),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(part),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = expiry
+	})
+	if err != nil {
+		return "", err
+	}
+	return request.URL, nil
+}
+
+func (s *S3Storage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) (string, error) {
+	completed := make([]types.CompletedPart, len(parts))
+	for i, part := range parts {
+		completed[i] = types.CompletedPart{PartNumber: aws.Int32(part.Number), ETag: aws.String(part.ETag)}
+	}
+	_, err := s.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucketName),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s", s.URL, key), nil
+}
+
+func (s *S3Storage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	_, err := s.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	var noUpload *types.NoSuchUpload
+	if errors.As(err, &noUpload) {
+		return nil
+	}
+	return err
+}
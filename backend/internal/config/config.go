@@ -1,7 +1,15 @@
 package config
 
 import (
+	"StudyHub/internal/ai"
+	"StudyHub/internal/aws"
+	"StudyHub/internal/convert"
+	"StudyHub/internal/extract"
+	"StudyHub/internal/resources"
+	"StudyHub/internal/storage"
+	"StudyHub/internal/tus"
 	"log"
+	"time"
 
 	"github.com/caarlos0/env/v10"
 	"github.com/joho/godotenv"
@@ -21,6 +29,53 @@ type Config struct {
 	AWS_S3_URL    string `env:"AWS_S3_URL"`
 	GeminiKey     string `env:"GEMINI_API_KEY"`
 	RAGServiceURL string `env:"RAG_SERVICE_URL" envDefault:"http://rag-service:8001"`
+
+	// AI provider used for flashcard generation and the chat fallback: gemini, openai or fake
+	AIProvider        string        `env:"AI_PROVIDER" envDefault:"gemini"`
+	AIModel           string        `env:"AI_MODEL"`
+	AIChatModel       string        `env:"AI_CHAT_MODEL"`
+	AIBaseURL         string        `env:"AI_BASE_URL"` // for openai compatible servers, e.g. http://ollama:11434/v1
+	AIKey             string        `env:"AI_API_KEY"`  // falls back to GEMINI_API_KEY
+	AITimeout         time.Duration `env:"AI_TIMEOUT" envDefault:"2m"`
+	AIMaxOutputTokens int           `env:"AI_MAX_OUTPUT_TOKENS"`
+	AIMaxInputTokens  int           `env:"AI_MAX_INPUT_TOKENS"`
+
+	// fetching the pages behind link resources for flashcard generation
+	LinkFetchTimeout      time.Duration `env:"LINK_FETCH_TIMEOUT" envDefault:"20s"`
+	LinkFetchMaxBytes     int64         `env:"LINK_FETCH_MAX_BYTES" envDefault:"10485760"`
+	LinkFetchAllowPrivate bool          `env:"LINK_FETCH_ALLOW_PRIVATE"` // only for local setups, lets links reach private addresses
+
+	// document conversion to pdf: gotenberg or libreoffice (a local soffice binary)
+	ConverterBackend  string        `env:"CONVERTER" envDefault:"gotenberg"`
+	GotenbergURL      string        `env:"GOTENBERG_URL" envDefault:"http://gotenberg:3000"`
+	ConverterTimeout  time.Duration `env:"CONVERTER_TIMEOUT" envDefault:"2m"`
+	ConverterRetries  int           `env:"CONVERTER_RETRIES" envDefault:"2"`
+	ConverterMaxBytes int64         `env:"CONVERTER_MAX_BYTES" envDefault:"104857600"`
+	LibreOfficePath   string        `env:"LIBREOFFICE_PATH" envDefault:"soffice"`
+
+	// where uploads are stored: s3 (default), minio or local
+	StorageDriver     string        `env:"STORAGE_DRIVER" envDefault:"s3"`
+	S3Endpoint        string        `env:"S3_ENDPOINT"` // s3 compatible servers, e.g. http://minio:9000
+	S3Region          string        `env:"S3_REGION"`
+	S3AccessKey       string        `env:"S3_ACCESS_KEY"` // falls back to the AWS environment
+	S3SecretKey       string        `env:"S3_SECRET_KEY"`
+	S3UsePathStyle    bool          `env:"S3_USE_PATH_STYLE"`
+	StorageDir        string        `env:"STORAGE_DIR" envDefault:"./data/storage"`
+	StoragePublicURL  string        `env:"STORAGE_PUBLIC_URL" envDefault:"http://localhost:8080/api/v1"`
+	StorageSigningKey string        `env:"STORAGE_SIGNING_KEY"` // falls back to JWT_KEY
+	StorageURLExpiry  time.Duration `env:"STORAGE_URL_EXPIRY" envDefault:"1m"`
+
+	// uploads that can not be read twice are spooled here to be hashed before they are stored, "" is the os temp dir
+	UploadSpoolDir string `env:"UPLOAD_SPOOL_DIR"`
+	// the upload type is detected from the content and must be one of these, e.g. pdf,docx,pptx
+	UploadAllowedTypes []string `env:"UPLOAD_ALLOWED_TYPES" envSeparator:","`
+	UploadMaxFileSize  int64    `env:"UPLOAD_MAX_FILE_SIZE" envDefault:"524288000"` // 0 for no limit
+	UploadUserQuota    int64    `env:"UPLOAD_USER_QUOTA" envDefault:"5368709120"`   // bytes per user, 0 for no quota
+
+	// resumable (tus) uploads keep their chunks on the local disk until they are complete
+	TusDir     string        `env:"TUS_DIR" envDefault:"./data/tus"`
+	TusMaxSize int64         `env:"TUS_MAX_SIZE" envDefault:"2147483648"`
+	TusExpiry  time.Duration `env:"TUS_EXPIRY" envDefault:"24h"`
 }
 
 func Load() Config {
@@ -34,3 +89,85 @@ func Load() Config {
 	}
 	return cnf
 }
+
+// AI returns the provider config, the Gemini key is used when no AI key is set
+func (c Config) AI() ai.Config {
+	key := c.AIKey
+	if key == "" {
+		key = c.GeminiKey
+	}
+	return ai.Config{
+		Provider:        c.AIProvider,
+		Model:           c.AIModel,
+		ChatModel:       c.AIChatModel,
+		BaseURL:         c.AIBaseURL,
+		APIKey:          key,
+		Timeout:         c.AITimeout,
+		MaxOutputTokens: c.AIMaxOutputTokens,
+		MaxInputTokens:  c.AIMaxInputTokens,
+	}
+}
+
+// Fetcher returns the config of the link fetcher
+func (c Config) Fetcher() extract.FetcherConfig {
+	return extract.FetcherConfig{
+		Timeout:      c.LinkFetchTimeout,
+		MaxBytes:     c.LinkFetchMaxBytes,
+		AllowPrivate: c.LinkFetchAllowPrivate,
+	}
+}
+
+// Converter returns the document converter config
+func (c Config) Converter() convert.Config {
+	return convert.Config{
+		Backend:         c.ConverterBackend,
+		URL:             c.GotenbergURL,
+		Timeout:         c.ConverterTimeout,
+		Retries:         c.ConverterRetries,
+		MaxBytes:        c.ConverterMaxBytes,
+		LibreOfficePath: c.LibreOfficePath,
+	}
+}
+
+// Storage returns the storage driver config, download links are signed with the JWT key when no signing key is set
+func (c Config) Storage() storage.Config {
+	key := c.StorageSigningKey
+	if key == "" {
+		key = c.JwtKey
+	}
+	return storage.Config{
+		Driver: c.StorageDriver,
+		S3: aws.S3Config{
+			Bucket:          c.BucketName,
+			URL:             c.AWS_S3_URL,
+			Endpoint:        c.S3Endpoint,
+			Region:          c.S3Region,
+			AccessKeyID:     c.S3AccessKey,
+			SecretAccessKey: c.S3SecretKey,
+			UsePathStyle:    c.S3UsePathStyle,
+		},
+		Dir:        c.StorageDir,
+		PublicURL:  c.StoragePublicURL,
+		SigningKey: key,
+		URLExpiry:  c.StorageURLExpiry,
+	}
+}
+
+// Uploads returns the limits of the resource service, the default types are allowed when none are set
+func (c Config) Uploads() resources.Config {
+	return resources.Config{
+		SpoolDir:     c.UploadSpoolDir,
+		MaxFileSize:  c.UploadMaxFileSize,
+		UserQuota:    c.UploadUserQuota,
+		AllowedTypes: c.UploadAllowedTypes,
+	}
+}
+
+// Tus returns the config of the resumable upload store
+func (c Config) Tus() tus.Config {
+	return tus.Config{
+		Dir:     c.TusDir,
+		MaxSize: c.TusMaxSize,
+		Expiry:  c.TusExpiry,
+	}
+}
@@ -0,0 +1,117 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// cards with an interval of at least this many days count as mature, like in Anki
+	matureIntervalDays  = 21
+	DefaultHeatmapDays  = 365
+	maxHeatmapDays      = 3 * 365
+	reviewDayDateLayout = "2006-01-02"
+)
+
+// ErrInvalidTimeZone is returned for a time zone that is not an IANA name such as "Europe/Berlin"
+var ErrInvalidTimeZone = errors.New("invalid time zone")
+
+// LoadTimeZone looks up an IANA time zone name, an empty name is UTC
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	// "Local" would be the server's zone, which says nothing about the user
+	if name == "Local" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimeZone, name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimeZone, name)
+	}
+	return loc, nil
+}
+
+// GetStudyAnalytics builds the heatmap, streaks, retention and maturity breakdown from the user's review history.
+// Days are calendar days in loc, so a review at 23:30 local time counts for that day.
+func (s *ContentService) GetStudyAnalytics(ctx context.Context, userID uuid.UUID, days int, loc *time.Location) (StudyAnalytics, error) {
+	if days <= 0 {
+		days = DefaultHeatmapDays
+	}
+	days = min(days, maxHeatmapDays)
+
+	today := calendarDay(time.Now(), loc)
+	first := today.AddDate(0, 0, -(days - 1))
+	// the start of the first heatmap day in loc, as the instant retention is counted from
+	since := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc).UTC()
+
+	reviewDays, err := s.contentRepository.ListReviewDays(ctx, userID, loc.String())
+	if err != nil {
+		return StudyAnalytics{}, err
+	}
+
+	retention, err := s.contentRepository.GetModuleRetention(ctx, userID, since)
+	if err != nil {
+		return StudyAnalytics{}, err
+	}
+
+	maturity, err := s.contentRepository.GetCardMaturity(ctx, userID, matureIntervalDays)
+	if err != nil {
+		return StudyAnalytics{}, err
+	}
+
+	analytics := StudyAnalytics{
+		DailyReviews:    make([]DailyReviewCount, 0, days),
+		ModuleRetention: retention,
+		Cards:           maturity,
+	}
+	analytics.CurrentStreak, analytics.LongestStreak = computeStreaks(reviewDays, today)
+
+	// fill the heatmap with zero days so the client can render it without gaps
+	counts := make(map[string]int, len(reviewDays))
+	for _, day := range reviewDays {
+		analytics.TotalReviews += day.Count
+		counts[day.Date] = day.Count
+	}
+	for d := first; !d.After(today); d = d.AddDate(0, 0, 1) {
+		date := d.Format(reviewDayDateLayout)
+		analytics.DailyReviews = append(analytics.DailyReviews, DailyReviewCount{Date: date, Count: counts[date]})
+	}
+
+	return analytics, nil
+}
+
+// calendarDay is the date of t in loc, as midnight UTC to compare with the dates of review days
+func calendarDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// computeStreaks expects days sorted ascending and today from calendarDay. The current streak is still alive if the user
+// studied yesterday but not yet today.
+func computeStreaks(days []DailyReviewCount, today time.Time) (current, longest int) {
+	var prev time.Time
+	run := 0
+	for _, day := range days {
+		date, err := time.Parse(reviewDayDateLayout, day.Date)
+		if err != nil || day.Count == 0 {
+			continue
+		}
+		if !prev.IsZero() && date.Equal(prev.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+		prev = date
+	}
+
+	if !prev.IsZero() && (prev.Equal(today) || prev.Equal(today.AddDate(0, 0, -1))) {
+		current = run
+	}
+	return current, longest
+}
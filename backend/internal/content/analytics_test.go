@@ -0,0 +1,119 @@
+package content
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestComputeStreaks(t *testing.T) {
+	today := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	day := func(date string, count int) DailyReviewCount { return DailyReviewCount{Date: date, Count: count} }
+
+	tests := []struct {
+		name            string
+		days            []DailyReviewCount
+		expectedCurrent int
+		expectedLongest int
+	}{
+		{name: "no reviews", expectedCurrent: 0, expectedLongest: 0},
+		{
+			name:            "streak ending today",
+			days:            []DailyReviewCount{day("2026-10-16", 3), day("2026-10-17", 1), day("2026-10-18", 8)},
+			expectedCurrent: 3,
+			expectedLongest: 3,
+		},
+		{
+			name:            "streak ending yesterday is still alive",
+			days:            []DailyReviewCount{day("2026-10-15", 2), day("2026-10-16", 3), day("2026-10-17", 1)},
+			expectedCurrent: 3,
+			expectedLongest: 3,
+		},
+		{
+			name:            "streak ending two days ago is broken",
+			days:            []DailyReviewCount{day("2026-10-15", 2), day("2026-10-16", 3)},
+			expectedCurrent: 0,
+			expectedLongest: 2,
+		},
+		{
+			name: "gap restarts the streak",
+			days: []DailyReviewCount{
+				day("2026-10-10", 1), day("2026-10-11", 1), day("2026-10-12", 1), day("2026-10-13", 1),
+				day("2026-10-15", 4), day("2026-10-16", 2), day("2026-10-17", 5), day("2026-10-18", 1),
+			},
+			expectedCurrent: 4,
+			expectedLongest: 4,
+		},
+		{
+			name: "longest streak in the past",
+			days: []DailyReviewCount{
+				day("2026-09-01", 1), day("2026-09-02", 1), day("2026-09-03", 1), day("2026-09-04", 1), day("2026-09-05", 1),
+				day("2026-10-17", 6), day("2026-10-18", 2),
+			},
+			expectedCurrent: 2,
+			expectedLongest: 5,
+		},
+		{
+			name:            "days without reviews do not count",
+			days:            []DailyReviewCount{day("2026-10-16", 3), day("2026-10-17", 0), day("2026-10-18", 1)},
+			expectedCurrent: 1,
+			expectedLongest: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, longest := computeStreaks(tt.days, today)
+			if current != tt.expectedCurrent || longest != tt.expectedLongest {
+				t.Errorf("computeStreaks() = %d, %d, want %d, %d", current, longest, tt.expectedCurrent, tt.expectedLongest)
+			}
+		})
+	}
+}
+
+func TestCalendarDay(t *testing.T) {
+	berlin, err := LoadTimeZone("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := LoadTimeZone("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 22:30 UTC is already the next day in Berlin and still the same day in New York
+	now := time.Date(2026, 10, 17, 22, 30, 0, 0, time.UTC)
+	tests := []struct {
+		loc      *time.Location
+		expected string
+	}{
+		{loc: time.UTC, expected: "2026-10-17"},
+		{loc: berlin, expected: "2026-10-18"},
+		{loc: newYork, expected: "2026-10-17"},
+	}
+	for _, tt := range tests {
+		got := calendarDay(now, tt.loc)
+		if got.Format(reviewDayDateLayout) != tt.expected || got.Location() != time.UTC || got.Hour() != 0 {
+			t.Errorf("calendarDay(%s) = %v, want %s at midnight UTC", tt.loc, got, tt.expected)
+		}
+	}
+
+	// a review made yesterday evening in Berlin keeps the streak going on the Berlin day
+	days := []DailyReviewCount{{Date: "2026-10-16", Count: 1}, {Date: "2026-10-17", Count: 1}}
+	if current, _ := computeStreaks(days, calendarDay(now, berlin)); current != 2 {
+		t.Errorf("expected the streak to be alive on the Berlin day, got %d", current)
+	}
+}
+
+func TestLoadTimeZone(t *testing.T) {
+	if loc, err := LoadTimeZone(""); err != nil || loc != time.UTC {
+		t.Errorf("expected UTC for an empty name, got %v, %v", loc, err)
+	}
+	if loc, err := LoadTimeZone("Asia/Tashkent"); err != nil || loc.String() != "Asia/Tashkent" {
+		t.Errorf("expected Asia/Tashkent, got %v, %v", loc, err)
+	}
+	for _, name := range []string{"Local", "Mars/Olympus", "../etc/passwd"} {
+		if _, err := LoadTimeZone(name); !errors.Is(err, ErrInvalidTimeZone) {
+			t.Errorf("LoadTimeZone(%q) = %v, want %v", name, err, ErrInvalidTimeZone)
+		}
+	}
+}
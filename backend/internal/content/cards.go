@@ -0,0 +1,280 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxCardsPerNote   = 50
+	minChoiceOptions  = 2
+	maxChoiceOptions  = 8
+	clozePlaceholder  = "[...]"
+	imageCardFallback = "What is hidden in the image?"
+)
+
+// ErrInvalidCard is returned when a custom card does not pass validation
+var ErrInvalidCard = errors.New("invalid card")
+
+// clozePattern matches {{c1::answer}} and {{c1::answer::hint}}
+var clozePattern = regexp.MustCompile(`\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+
+var imageFileTypes = []string{"png", "jpg", "jpeg", "gif", "webp", "svg"}
+
+// CreateCustomCard validates the request and creates the card, or one card per cloze deletion or
+// occlusion region, in user's deck
+func (s *ContentService) CreateCustomCard(ctx context.Context, userID, weekID uuid.UUID, req CreateCustomCardRequest) ([]UserDeckCard, error) {
+	if req.Type == "" {
+		req.Type = CardBasic
+	}
+	if req.Type == CardImage {
+		if err := s.validateImageObject(ctx, userID, req.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	cards, err := buildCustomCards(req)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := NormalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var noteID *uuid.UUID
+	if len(cards) > 1 {
+		id := uuid.New()
+		noteID = &id
+	}
+	for i := range cards {
+		cards[i].ID = uuid.New()
+		cards[i].UserID = userID
+		cards[i].WeekID = weekID
+		cards[i].IsCustom = true
+		cards[i].NoteID = noteID
+		cards[i].Tags = tags
+		cards[i].CreatedAt = now
+		cards[i].UpdatedAt = now
+	}
+
+	if err := s.contentRepository.CreateCustomCardsInDeck(ctx, cards); err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		s.attachImageURL(ctx, &cards[i])
+	}
+	return cards, nil
+}
+
+// validateImageObject accepts images the user uploaded or can see as a resource, any other object id is
+// reported as not found so cards cannot be used to get links to files of other users
+func (s *ContentService) validateImageObject(ctx context.Context, userID uuid.UUID, c *CardContent) error {
+	if c == nil || c.ImageObjectID == nil {
+		return fmt.Errorf("%w: image cards need content.image_object_id", ErrInvalidCard)
+	}
+	fileType, err := s.contentRepository.GetVisibleStorageObjectFileType(ctx, *c.ImageObjectID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New("image object not found")
+		}
+		return err
+	}
+	if !slices.Contains(imageFileTypes, strings.ToLower(fileType)) {
+		return fmt.Errorf("%w: %s files cannot be used as card images", ErrInvalidCard, fileType)
+	}
+	return nil
+}
+
+// attachImageURL fills in the download link of an image card, a failed link only leaves the URL empty
+func (s *ContentService) attachImageURL(ctx context.Context, card *UserDeckCard) {
+	card.Content = s.withImageURL(ctx, card.Content)
+}
+
+// withImageURL returns a copy of the content with the image download link set. Image ids are checked when
+// the card is created, so only images its owner could see are linked
+func (s *ContentService) withImageURL(ctx context.Context, c *CardContent) *CardContent {
+	if c == nil || c.ImageObjectID == nil {
+		return c
+	}
+	url, err := s.fileStorage.CreatePresidedURL(ctx, c.ImageObjectID.String())
+	if err != nil {
+		return c
+	}
+	withURL := *c
+	withURL.ImageURL = url
+	return &withURL
+}
+
+// buildCustomCards turns a create request into the deck cards it generates, without ids
+func buildCustomCards(req CreateCustomCardRequest) ([]UserDeckCard, error) {
+	front, back := strings.TrimSpace(req.Front), strings.TrimSpace(req.Back)
+
+	switch req.Type {
+	case CardBasic:
+		if front == "" || back == "" {
+			return nil, fmt.Errorf("%w: front and back cannot be empty", ErrInvalidCard)
+		}
+		return []UserDeckCard{{CardType: CardBasic, Front: front, Back: back}}, nil
+	case CardCloze:
+		if req.Content == nil {
+			return nil, fmt.Errorf("%w: cloze cards need content.text", ErrInvalidCard)
+		}
+		return buildClozeCards(req.Content.Text, back)
+	case CardMultipleChoice:
+		return buildMultipleChoiceCard(front, req.Content)
+	case CardImage:
+		return buildImageCards(front, back, req.Content)
+	default:
+		return nil, fmt.Errorf("%w: unknown card type %q", ErrInvalidCard, req.Type)
+	}
+}
+
+func buildClozeCards(text, extra string) ([]UserDeckCard, error) {
+	text = strings.TrimSpace(text)
+	indexes := make([]int, 0)
+	for _, m := range clozePattern.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%w: cloze numbers start at c1", ErrInvalidCard)
+		}
+		if strings.TrimSpace(m[2]) == "" {
+			return nil, fmt.Errorf("%w: cloze deletion c%d is empty", ErrInvalidCard, n)
+		}
+		if !slices.Contains(indexes, n) {
+			indexes = append(indexes, n)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("%w: cloze text needs at least one {{c1::...}} deletion", ErrInvalidCard)
+	}
+	if len(indexes) > maxCardsPerNote {
+		return nil, fmt.Errorf("%w: a note can generate at most %d cards", ErrInvalidCard, maxCardsPerNote)
+	}
+	slices.Sort(indexes)
+
+	cards := make([]UserDeckCard, len(indexes))
+	for i, index := range indexes {
+		front, back := RenderCloze(text, index)
+		if extra != "" {
+			back += "\n\n" + extra
+		}
+		cards[i] = UserDeckCard{
+			CardType: CardCloze,
+			Front:    front,
+			Back:     back,
+			Content:  &CardContent{Text: text, ClozeIndex: index},
+		}
+	}
+	return cards, nil
+}
+
+// RenderCloze returns the question and answer text for one deletion of a cloze note. The asked
+// deletion is replaced by its hint or a placeholder, every other deletion shows its answer.
+func RenderCloze(text string, index int) (front, back string) {
+	front = clozePattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := clozePattern.FindStringSubmatch(match)
+		if m[1] != strconv.Itoa(index) {
+			return m[2]
+		}
+		if m[3] != "" {
+			return "[" + m[3] + "]"
+		}
+		return clozePlaceholder
+	})
+	back = clozePattern.ReplaceAllString(text, "$2")
+	return front, back
+}
+
+func buildMultipleChoiceCard(question string, c *CardContent) ([]UserDeckCard, error) {
+	if question == "" {
+		return nil, fmt.Errorf("%w: multiple choice cards need a question in front", ErrInvalidCard)
+	}
+	if c == nil || len(c.Options) < minChoiceOptions || len(c.Options) > maxChoiceOptions {
+		return nil, fmt.Errorf("%w: multiple choice cards need %d to %d options", ErrInvalidCard, minChoiceOptions, maxChoiceOptions)
+	}
+
+	options := make([]string, len(c.Options))
+	seen := make(map[string]bool, len(c.Options))
+	for i, option := range c.Options {
+		option = strings.TrimSpace(option)
+		key := strings.ToLower(option)
+		if option == "" {
+			return nil, fmt.Errorf("%w: options cannot be empty", ErrInvalidCard)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: option %q is listed twice", ErrInvalidCard, option)
+		}
+		seen[key] = true
+		options[i] = option
+	}
+	if c.CorrectOption == nil || *c.CorrectOption < 0 || *c.CorrectOption >= len(options) {
+		return nil, fmt.Errorf("%w: correct_option must point at one of the options", ErrInvalidCard)
+	}
+
+	correct := *c.CorrectOption
+	return []UserDeckCard{{
+		CardType: CardMultipleChoice,
+		Front:    question,
+		Back:     options[correct],
+		Content:  &CardContent{Options: options, CorrectOption: &correct},
+	}}, nil
+}
+
+func buildImageCards(prompt, back string, c *CardContent) ([]UserDeckCard, error) {
+	if c == nil || c.ImageObjectID == nil {
+		return nil, fmt.Errorf("%w: image cards need content.image_object_id", ErrInvalidCard)
+	}
+
+	if len(c.Occlusions) == 0 {
+		if back == "" {
+			return nil, fmt.Errorf("%w: back cannot be empty", ErrInvalidCard)
+		}
+		return []UserDeckCard{{
+			CardType: CardImage,
+			Front:    prompt,
+			Back:     back,
+			Content:  &CardContent{ImageObjectID: c.ImageObjectID},
+		}}, nil
+	}
+
+	if len(c.Occlusions) > maxCardsPerNote {
+		return nil, fmt.Errorf("%w: a note can generate at most %d cards", ErrInvalidCard, maxCardsPerNote)
+	}
+	occlusions := make([]Occlusion, len(c.Occlusions))
+	for i, o := range c.Occlusions {
+		if o.X < 0 || o.Y < 0 || o.Width <= 0 || o.Height <= 0 || o.X+o.Width > 1 || o.Y+o.Height > 1 {
+			return nil, fmt.Errorf("%w: occlusion %d must lie inside the image, coordinates are fractions from 0 to 1", ErrInvalidCard, i+1)
+		}
+		o.Label = strings.TrimSpace(o.Label)
+		if o.Label == "" {
+			return nil, fmt.Errorf("%w: occlusion %d needs a label", ErrInvalidCard, i+1)
+		}
+		occlusions[i] = o
+	}
+
+	if prompt == "" {
+		prompt = imageCardFallback
+	}
+	cards := make([]UserDeckCard, len(occlusions))
+	for i := range occlusions {
+		index := i
+		cards[i] = UserDeckCard{
+			CardType: CardImage,
+			Front:    prompt,
+			Back:     occlusions[i].Label,
+			Content:  &CardContent{ImageObjectID: c.ImageObjectID, Occlusions: occlusions, OcclusionIndex: &index},
+		}
+	}
+	return cards, nil
+}
@@ -0,0 +1,203 @@
+package content
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBuildClozeCards(t *testing.T) {
+	var manyDeletions strings.Builder
+	for i := 1; i <= maxCardsPerNote+1; i++ {
+		manyDeletions.WriteString("{{c" + strconv.Itoa(i) + "::x}} ")
+	}
+
+	tests := []struct {
+		name           string
+		text           string
+		extra          string
+		expectedFronts []string
+		expectedBack   string
+		expectedErr    string
+	}{
+		{
+			name:           "hint replaces the asked deletion",
+			text:           "{{c1::ATP::molecule}} is made in the {{c2::mitochondria}}",
+			expectedFronts: []string{"[molecule] is made in the mitochondria", "ATP is made in the [...]"},
+			expectedBack:   "ATP is made in the mitochondria",
+		},
+		{
+			name:           "repeated index is one card",
+			text:           "{{c2::Na}} out, {{c1::K}} in, {{c2::Na}} again",
+			extra:          "sodium-potassium pump",
+			expectedFronts: []string{"Na out, [...] in, Na again", "[...] out, K in, [...] again"},
+			expectedBack:   "Na out, K in, Na again\n\nsodium-potassium pump",
+		},
+		{name: "no cloze markers", text: "plain text without deletions", expectedErr: "at least one"},
+		{name: "empty deletion", text: "{{c1:: }} is empty", expectedErr: "c1 is empty"},
+		{name: "index zero", text: "{{c0::ATP}}", expectedErr: "start at c1"},
+		{name: "too many deletions", text: manyDeletions.String(), expectedErr: "at most"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards, err := buildClozeCards(tt.text, tt.extra)
+			if tt.expectedErr != "" {
+				if !errors.Is(err, ErrInvalidCard) || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected an invalid card error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cards) != len(tt.expectedFronts) {
+				t.Fatalf("expected %d cards, got %d", len(tt.expectedFronts), len(cards))
+			}
+			for i, card := range cards {
+				if card.Front != tt.expectedFronts[i] || card.Back != tt.expectedBack {
+					t.Errorf("card %d = %q / %q, want %q / %q", i, card.Front, card.Back, tt.expectedFronts[i], tt.expectedBack)
+				}
+				if card.CardType != CardCloze || card.Content == nil || card.Content.ClozeIndex != i+1 || card.Content.Text != tt.text {
+					t.Errorf("card %d has unexpected content %+v", i, card.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderCloze(t *testing.T) {
+	text := "{{c1::Mitosis::process}} makes {{c2::two}} cells, {{c3::meiosis}} makes four"
+	tests := []struct {
+		index         int
+		expectedFront string
+	}{
+		{index: 1, expectedFront: "[process] makes two cells, meiosis makes four"},
+		{index: 2, expectedFront: "Mitosis makes [...] cells, meiosis makes four"},
+		{index: 3, expectedFront: "Mitosis makes two cells, [...] makes four"},
+		{index: 4, expectedFront: "Mitosis makes two cells, meiosis makes four"},
+	}
+	for _, tt := range tests {
+		front, back := RenderCloze(text, tt.index)
+		if front != tt.expectedFront {
+			t.Errorf("RenderCloze(c%d) front = %q, want %q", tt.index, front, tt.expectedFront)
+		}
+		if back != "Mitosis makes two cells, meiosis makes four" {
+			t.Errorf("RenderCloze(c%d) back = %q", tt.index, back)
+		}
+	}
+}
+
+func TestBuildMultipleChoiceCard(t *testing.T) {
+	index := func(i int) *int { return &i }
+
+	tests := []struct {
+		name            string
+		question        string
+		content         *CardContent
+		expectedBack    string
+		expectedOptions []string
+		expectedErr     string
+	}{
+		{
+			name:            "options are trimmed",
+			question:        "Where is ATP made?",
+			content:         &CardContent{Options: []string{" Nucleus", "Mitochondria ", "Ribosome"}, CorrectOption: index(1)},
+			expectedBack:    "Mitochondria",
+			expectedOptions: []string{"Nucleus", "Mitochondria", "Ribosome"},
+		},
+		{name: "missing question", content: &CardContent{Options: []string{"a", "b"}, CorrectOption: index(0)}, expectedErr: "need a question"},
+		{name: "missing content", question: "Q?", expectedErr: "2 to 8 options"},
+		{name: "one option", question: "Q?", content: &CardContent{Options: []string{"a"}, CorrectOption: index(0)}, expectedErr: "2 to 8 options"},
+		{name: "too many options", question: "Q?", content: &CardContent{Options: strings.Split("abcdefghi", ""), CorrectOption: index(0)}, expectedErr: "2 to 8 options"},
+		{name: "duplicate distractors", question: "Q?", content: &CardContent{Options: []string{"Nucleus", "Ribosome", " nucleus"}, CorrectOption: index(1)}, expectedErr: "listed twice"},
+		{name: "empty option", question: "Q?", content: &CardContent{Options: []string{"a", "  "}, CorrectOption: index(0)}, expectedErr: "cannot be empty"},
+		{name: "correct index past the options", question: "Q?", content: &CardContent{Options: []string{"a", "b"}, CorrectOption: index(2)}, expectedErr: "correct_option"},
+		{name: "negative correct index", question: "Q?", content: &CardContent{Options: []string{"a", "b"}, CorrectOption: index(-1)}, expectedErr: "correct_option"},
+		{name: "no correct index", question: "Q?", content: &CardContent{Options: []string{"a", "b"}}, expectedErr: "correct_option"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards, err := buildMultipleChoiceCard(tt.question, tt.content)
+			if tt.expectedErr != "" {
+				if !errors.Is(err, ErrInvalidCard) || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected an invalid card error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cards) != 1 {
+				t.Fatalf("expected one card, got %d", len(cards))
+			}
+			card := cards[0]
+			if card.CardType != CardMultipleChoice || card.Front != tt.question || card.Back != tt.expectedBack {
+				t.Errorf("unexpected card %+v", card)
+			}
+			if strings.Join(card.Content.Options, "|") != strings.Join(tt.expectedOptions, "|") || *card.Content.CorrectOption != *tt.content.CorrectOption {
+				t.Errorf("unexpected content %+v", card.Content)
+			}
+			if card.Content.CorrectOption == tt.content.CorrectOption {
+				t.Error("expected the card to keep its own copy of the correct option")
+			}
+		})
+	}
+}
+
+func TestBuildImageCards(t *testing.T) {
+	imageID := uuid.New()
+	region := func(x, y, w, h float64) Occlusion { return Occlusion{X: x, Y: y, Width: w, Height: h, Label: "aorta"} }
+
+	tests := []struct {
+		name          string
+		prompt        string
+		back          string
+		content       *CardContent
+		expectedCards int
+		expectedFront string
+		expectedErr   string
+	}{
+		{name: "plain image card", prompt: "Name the organ", back: "Heart", content: &CardContent{ImageObjectID: &imageID}, expectedCards: 1, expectedFront: "Name the organ"},
+		{
+			name:          "one card per region with the fallback prompt",
+			content:       &CardContent{ImageObjectID: &imageID, Occlusions: []Occlusion{region(0, 0, 0.5, 0.5), region(0.5, 0.5, 0.5, 0.5)}},
+			expectedCards: 2,
+			expectedFront: imageCardFallback,
+		},
+		{name: "missing image", back: "Heart", content: &CardContent{}, expectedErr: "image_object_id"},
+		{name: "plain image without back", content: &CardContent{ImageObjectID: &imageID}, expectedErr: "back cannot be empty"},
+		{name: "negative x", content: &CardContent{ImageObjectID: &imageID, Occlusions: []Occlusion{region(-0.1, 0, 0.5, 0.5)}}, expectedErr: "occlusion 1 must lie inside"},
+		{name: "past the right edge", content: &CardContent{ImageObjectID: &imageID, Occlusions: []Occlusion{region(0.6, 0, 0.5, 0.5)}}, expectedErr: "occlusion 1 must lie inside"},
+		{name: "past the bottom edge", content: &CardContent{ImageObjectID: &imageID, Occlusions: []Occlusion{region(0, 0, 0.1, 0.1), region(0, 0.9, 0.1, 0.2)}}, expectedErr: "occlusion 2 must lie inside"},
+		{name: "zero size", content: &CardContent{ImageObjectID: &imageID, Occlusions: []Occlusion{region(0.2, 0.2, 0, 0.1)}}, expectedErr: "occlusion 1 must lie inside"},
+		{name: "missing label", content: &CardContent{ImageObjectID: &imageID, Occlusions: []Occlusion{{X: 0, Y: 0, Width: 1, Height: 1, Label: " "}}}, expectedErr: "needs a label"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards, err := buildImageCards(tt.prompt, tt.back, tt.content)
+			if tt.expectedErr != "" {
+				if !errors.Is(err, ErrInvalidCard) || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected an invalid card error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cards) != tt.expectedCards {
+				t.Fatalf("expected %d cards, got %d", tt.expectedCards, len(cards))
+			}
+			for i, card := range cards {
+				if card.CardType != CardImage || card.Front != tt.expectedFront || *card.Content.ImageObjectID != imageID {
+					t.Errorf("unexpected card %d %+v", i, card)
+				}
+				if len(tt.content.Occlusions) > 0 && (card.Content.OcclusionIndex == nil || *card.Content.OcclusionIndex != i || card.Back != "aorta") {
+					t.Errorf("card %d does not ask for its own region: %+v", i, card.Content)
+				}
+			}
+		})
+	}
+}
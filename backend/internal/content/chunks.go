@@ -0,0 +1,230 @@
+package content
+
+import (
+	"StudyHub/internal/convert"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// a chunk stays well inside the model's context and output limits
+	pagesPerChunk = 20
+	// chunks of one document generated at the same time, on top of the 5 workers
+	chunkWorkers = 3
+)
+
+var pdfPagePattern = regexp.MustCompile(`/Type\s*/Page\b`)
+
+// documentChunk is a page range of a document, the pages are nil when they could not be worked out
+type documentChunk struct {
+	PageStart *int
+	PageEnd   *int
+	Data      []byte
+}
+
+// splitDocument cuts a pdf into chunks of pagesPerChunk pages. When the split fails the whole
+// document is generated as one chunk, as it was before chunking
+func (s *ContentService) splitDocument(ctx context.Context, data []byte) []documentChunk {
+	parts, err := s.converter.SplitPdf(ctx, data, pagesPerChunk)
+	if err != nil {
+		if !errors.Is(err, convert.ErrUnsupported) {
+			slog.Warn("failed to split document, generating it in one go", "err", err)
+		}
+		chunk := documentChunk{Data: data}
+		if pages := countPdfPages(data); pages > 0 {
+			chunk.PageStart, chunk.PageEnd = intPtr(1), intPtr(pages)
+		}
+		return []documentChunk{chunk}
+	}
+
+	chunks := make([]documentChunk, len(parts))
+	for i, part := range parts {
+		start := i*pagesPerChunk + 1
+		end := start + pagesPerChunk - 1
+		// only the last chunk can be shorter, counting its pages is best effort as
+		// compressed object streams hide them
+		if pages := countPdfPages(part); i == len(parts)-1 && pages > 0 && pages < pagesPerChunk {
+			end = start + pages - 1
+		}
+		chunks[i] = documentChunk{PageStart: intPtr(start), PageEnd: intPtr(end), Data: part}
+	}
+	return chunks
+}
+
+// generateChunks generates the cards of every chunk, and with extras its summary and quiz, running at most
+// chunkWorkers calls at a time. The answers are parsed once every chunk returned, parsing is called right
+// before. The first chunk failing to produce cards cancels the rest and fails the document; a failing
+// summary or quiz is only logged, the cards are saved without it
+func (s *ContentService) generateChunks(ctx context.Context, chunks []documentChunk, objectID uuid.UUID, opts GenerationOptions, extras bool, parsing func()) (GeneratedContent, error) {
+	chunkOpts := opts.forChunk(len(chunks))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	responses := make([]string, len(chunks))
+	cards := make([][]Flashcard, len(chunks))
+	summaries := make([]*ResourceSummary, len(chunks))
+	quizzes := make([][]QuizQuestion, len(chunks))
+	sem := make(chan struct{}, chunkWorkers)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	run := func(task func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			if err := task(); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+
+	for i, chunk := range chunks {
+		run(func() (err error) {
+			responses[i], err = s.ai.GenerateFlashCards(ctx, chunk.reader(), chunkOpts)
+			if err != nil {
+				return fmt.Errorf("failed to generate flashcards for %s: %w", chunk.pages(), err)
+			}
+			return nil
+		})
+		if !extras {
+			continue
+		}
+		run(func() error {
+			summary, err := s.generateChunkSummary(ctx, chunk)
+			if err != nil {
+				slog.Warn("failed to generate summary", "object id", objectID, "err", err)
+				return nil
+			}
+			summaries[i] = &summary
+			return nil
+		})
+		run(func() (err error) {
+			quizzes[i], err = s.generateChunkQuiz(ctx, chunk)
+			if err != nil {
+				slog.Warn("failed to generate quiz", "object id", objectID, "err", err)
+			}
+			return nil
+		})
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return GeneratedContent{}, firstErr
+	}
+
+	// a repair is another call to the model, so the answers are parsed in the pool as well
+	parsing()
+	for i, chunk := range chunks {
+		run(func() (err error) {
+			cards[i], err = s.parseChunkCards(ctx, chunk, responses[i], objectID)
+			return err
+		})
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return GeneratedContent{}, firstErr
+	}
+
+	generated := GeneratedContent{
+		Cards:   mergeChunkCards(cards),
+		Summary: mergeSummaries(summaries, objectID),
+		Quiz:    mergeQuizzes(quizzes, objectID),
+	}
+	if opts.CardCount > 0 && len(generated.Cards) > opts.CardCount {
+		generated.Cards = generated.Cards[:opts.CardCount]
+	}
+	return generated, nil
+}
+
+// parseChunkCards validates the cards generated for a chunk and stamps them with its pages
+func (s *ContentService) parseChunkCards(ctx context.Context, chunk documentChunk, response string, objectID uuid.UUID) ([]Flashcard, error) {
+	cards, err := s.parseGeneratedCards(ctx, response, objectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated flashcards for %s: %w", chunk.pages(), err)
+	}
+	for i := range cards {
+		cards[i].PageStart, cards[i].PageEnd = chunk.PageStart, chunk.PageEnd
+	}
+	return cards, nil
+}
+
+func (s *ContentService) generateChunkSummary(ctx context.Context, chunk documentChunk) (ResourceSummary, error) {
+	result, err := s.ai.GenerateSummary(ctx, chunk.reader())
+	if err != nil {
+		return ResourceSummary{}, fmt.Errorf("failed to generate summary for %s: %w", chunk.pages(), err)
+	}
+	summary, err := parseGeneratedSummary(result, chunk)
+	if err != nil {
+		return ResourceSummary{}, fmt.Errorf("failed to parse summary for %s: %w", chunk.pages(), err)
+	}
+	return summary, nil
+}
+
+func (s *ContentService) generateChunkQuiz(ctx context.Context, chunk documentChunk) ([]QuizQuestion, error) {
+	result, err := s.ai.GenerateQuiz(ctx, chunk.reader())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate quiz for %s: %w", chunk.pages(), err)
+	}
+	questions, err := parseGeneratedQuiz(result, chunk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quiz for %s: %w", chunk.pages(), err)
+	}
+	return questions, nil
+}
+
+func (c documentChunk) reader() io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(c.Data))
+}
+
+func (c documentChunk) pages() string {
+	if c.PageStart == nil {
+		return "the document"
+	}
+	return fmt.Sprintf("pages %d-%d", *c.PageStart, *c.PageEnd)
+}
+
+// mergeChunkCards joins the chunks in page order, a question asked again in a later chunk is dropped
+func mergeChunkCards(results [][]Flashcard) []Flashcard {
+	merged := make([]Flashcard, 0)
+	seen := make(map[string]bool)
+	for _, cards := range results {
+		for _, card := range cards {
+			key := textKey(card.Front)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, card)
+		}
+	}
+	return merged
+}
+
+// countPdfPages counts the page objects of a pdf, 0 when they are inside compressed object streams
+func countPdfPages(data []byte) int {
+	return len(pdfPagePattern.FindAllIndex(data, -1))
+}
+
+func intPtr(n int) *int {
+	return &n
+}
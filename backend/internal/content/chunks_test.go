@@ -0,0 +1,219 @@
+package content
+
+import (
+	"StudyHub/internal/convert"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// splitConverter answers SplitPdf with fixed parts or an error
+type splitConverter struct {
+	DocumentConverter
+	parts [][]byte
+	err   error
+}
+
+func (c splitConverter) SplitPdf(ctx context.Context, data []byte, span int) ([][]byte, error) {
+	return c.parts, c.err
+}
+
+// fakePdf has pages page objects, the way countPdfPages finds them
+func fakePdf(pages int) []byte {
+	return []byte("%PDF-1.7\n" + strings.Repeat("<< /Type /Page >>\n", pages) + "<< /Type /Pages >>")
+}
+
+func chunkPages(chunks []documentChunk) []string {
+	pages := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		pages[i] = chunk.pages()
+	}
+	return pages
+}
+
+func TestSplitDocument(t *testing.T) {
+	tests := []struct {
+		name          string
+		converter     splitConverter
+		document      []byte
+		expectedPages []string
+	}{
+		{
+			name:          "full chunks and a short last one",
+			converter:     splitConverter{parts: [][]byte{fakePdf(20), fakePdf(20), fakePdf(7)}},
+			expectedPages: []string{"pages 1-20", "pages 21-40", "pages 41-47"},
+		},
+		{
+			name:          "last chunk with hidden pages keeps the full range",
+			converter:     splitConverter{parts: [][]byte{fakePdf(20), []byte("%PDF compressed")}},
+			expectedPages: []string{"pages 1-20", "pages 21-40"},
+		},
+		{
+			name:          "only the last chunk is shortened",
+			converter:     splitConverter{parts: [][]byte{fakePdf(3), fakePdf(20)}},
+			expectedPages: []string{"pages 1-20", "pages 21-40"},
+		},
+		{
+			name:          "single short document",
+			converter:     splitConverter{parts: [][]byte{fakePdf(5)}},
+			expectedPages: []string{"pages 1-5"},
+		},
+		{
+			name:          "failed split is one chunk with the counted pages",
+			converter:     splitConverter{err: errors.New("gotenberg down")},
+			document:      fakePdf(12),
+			expectedPages: []string{"pages 1-12"},
+		},
+		{
+			name:          "unsupported split without countable pages",
+			converter:     splitConverter{err: convert.ErrUnsupported},
+			document:      []byte("%PDF compressed"),
+			expectedPages: []string{"the document"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ContentService{converter: tt.converter}
+			chunks := s.splitDocument(context.Background(), tt.document)
+			if got := chunkPages(chunks); strings.Join(got, ",") != strings.Join(tt.expectedPages, ",") {
+				t.Errorf("expected %v, got %v", tt.expectedPages, got)
+			}
+			if tt.converter.err != nil && string(chunks[0].Data) != string(tt.document) {
+				t.Error("expected the whole document in the single chunk")
+			}
+		})
+	}
+}
+
+func TestTextChunks(t *testing.T) {
+	// two paragraphs just fit in a chunk
+	paragraph := strings.Repeat("word ", charsPerChunk/10-1)
+
+	tests := []struct {
+		name           string
+		text           string
+		expectedChunks int
+		expectedErr    error
+	}{
+		{name: "short text is one chunk", text: "Photosynthesis\n\nLight reactions", expectedChunks: 1},
+		{name: "paragraphs fill chunks", text: strings.Repeat(paragraph+"\n", 5), expectedChunks: 3},
+		{name: "one long line is cut", text: strings.Repeat("a ", charsPerChunk), expectedChunks: 2},
+		{name: "blank text", text: " \n\t ", expectedErr: errNoText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := textChunks(tt.text)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+			}
+			if len(chunks) != tt.expectedChunks {
+				t.Fatalf("expected %d chunks, got %d", tt.expectedChunks, len(chunks))
+			}
+			for i, chunk := range chunks {
+				if len(chunk.Data) > charsPerChunk+1 {
+					t.Errorf("chunk %d has %d bytes, over the limit", i, len(chunk.Data))
+				}
+				if chunk.PageStart != nil {
+					t.Errorf("chunk %d of plain text has pages", i)
+				}
+			}
+		})
+	}
+}
+
+func TestSlideChunks(t *testing.T) {
+	slides := make([]string, 45)
+	for i := range slides {
+		slides[i] = "content"
+	}
+	slides[1] = ""
+	for i := 20; i < 40; i++ {
+		slides[i] = ""
+	}
+
+	chunks, err := slideChunks(slides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second group has only empty slides and is skipped
+	if got := chunkPages(chunks); strings.Join(got, ",") != "pages 1-20,pages 41-45" {
+		t.Errorf("unexpected chunks %v", got)
+	}
+	text := string(chunks[0].Data)
+	if !strings.HasPrefix(text, "Slide 1:\ncontent\n\n") || strings.Contains(text, "Slide 2:") || !strings.Contains(text, "Slide 20:") {
+		t.Errorf("unexpected first chunk %q", text)
+	}
+
+	if _, err := slideChunks([]string{"", ""}); !errors.Is(err, errNoText) {
+		t.Errorf("expected %v for empty slides, got %v", errNoText, err)
+	}
+}
+
+func TestSplitLong(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		limit    int
+		expected []string
+	}{
+		{name: "short lines stay", lines: []string{"one", "two"}, limit: 10, expected: []string{"one", "two"}},
+		{name: "cut at the last space", lines: []string{"alpha beta gamma"}, limit: 12, expected: []string{"alpha beta", "gamma"}},
+		{name: "no space is cut at the limit", lines: []string{"abcdefghij"}, limit: 4, expected: []string{"abcd", "efgh", "ij"}},
+		{name: "multi-byte runes are not cut", lines: []string{"ääää"}, limit: 3, expected: []string{"ä", "ä", "ä", "ä"}},
+		{name: "cut before a multi-byte rune", lines: []string{"aé€b"}, limit: 4, expected: []string{"aé", "€b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitLong(tt.lines, tt.limit)
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("splitLong() = %q, want %q", got, tt.expected)
+			}
+			for _, line := range got {
+				if !utf8.ValidString(line) {
+					t.Errorf("line %q is not valid utf-8", line)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeChunkCards(t *testing.T) {
+	merged := mergeChunkCards([][]Flashcard{
+		{{Front: "What is ATP?"}, {Front: "Define osmosis"}},
+		nil,
+		{{Front: "what is  ATP?", Back: "asked again"}, {Front: "What is a ribosome?"}},
+	})
+	fronts := make([]string, len(merged))
+	for i, card := range merged {
+		fronts[i] = card.Front
+	}
+	if strings.Join(fronts, "|") != "What is ATP?|Define osmosis|What is a ribosome?" {
+		t.Errorf("unexpected merge %q", fronts)
+	}
+	if merged := mergeChunkCards(nil); merged == nil || len(merged) != 0 {
+		t.Errorf("expected an empty list, got %v", merged)
+	}
+}
+
+func TestGenerationOptionsForChunk(t *testing.T) {
+	tests := []struct {
+		cardCount int
+		chunks    int
+		expected  int
+	}{
+		{cardCount: 30, chunks: 1, expected: 30},
+		{cardCount: 30, chunks: 3, expected: 10},
+		{cardCount: 31, chunks: 3, expected: 11},
+		{cardCount: 2, chunks: 5, expected: 1},
+		{cardCount: 0, chunks: 4, expected: 0},
+	}
+	for _, tt := range tests {
+		opts := GenerationOptions{CardCount: tt.cardCount, Language: "German"}
+		got := opts.forChunk(tt.chunks)
+		if got.CardCount != tt.expected || got.Language != "German" {
+			t.Errorf("forChunk(%d) of %d cards = %+v, want %d cards", tt.chunks, tt.cardCount, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package content
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultExamQuestions = 20
+	DefaultExamMinutes   = 30
+	maxExamQuestions     = 100
+	maxExamMinutes       = 240
+	examChoices          = 4
+)
+
+var (
+	// ErrInvalidExam is returned when an exam cannot be created or answered with the given input
+	ErrInvalidExam = errors.New("invalid exam")
+	// ErrExamFinished is returned when answering an exam that was submitted or ran out of time
+	ErrExamFinished = errors.New("exam already finished")
+	// ErrExamInProgress is returned when asking for the result of an exam that is still running
+	ErrExamInProgress = errors.New("exam is still in progress")
+	// ErrNotCurrentQuestion is returned when the answer is not for the question being served
+	ErrNotCurrentQuestion = errors.New("question already answered or not reached yet")
+)
+
+// CreateExam picks up to QuestionCount cards from the selected weeks, spread evenly across the weeks,
+// and turns each into a multiple-choice question. Cards without their own options get the answers of
+// other cards as distractors. The clock starts right away.
+func (s *ContentService) CreateExam(ctx context.Context, userID uuid.UUID, req CreateExamRequest) (ExamSession, error) {
+	if req.QuestionCount == 0 {
+		req.QuestionCount = DefaultExamQuestions
+	}
+	if req.DurationMinutes == 0 {
+		req.DurationMinutes = DefaultExamMinutes
+	}
+	if req.Source == "" {
+		req.Source = ExamSourceBoth
+	}
+	switch {
+	case req.ModuleRunID == uuid.Nil:
+		return ExamSession{}, fmt.Errorf("%w: module_run_id is required", ErrInvalidExam)
+	case req.QuestionCount < 1 || req.QuestionCount > maxExamQuestions:
+		return ExamSession{}, fmt.Errorf("%w: question_count must be between 1 and %d", ErrInvalidExam, maxExamQuestions)
+	case req.DurationMinutes < 1 || req.DurationMinutes > maxExamMinutes:
+		return ExamSession{}, fmt.Errorf("%w: duration_minutes must be between 1 and %d", ErrInvalidExam, maxExamMinutes)
+	case !req.Source.IsValid():
+		return ExamSession{}, fmt.Errorf("%w: unknown source %q", ErrInvalidExam, req.Source)
+	}
+
+	candidates, err := s.contentRepository.ListExamCandidates(ctx, userID, req.ModuleRunID, req.WeekIDs, req.Source)
+	if err != nil {
+		return ExamSession{}, err
+	}
+	questions := buildExamQuestions(dedupeExamCandidates(candidates), req.QuestionCount)
+	if len(questions) == 0 {
+		return ExamSession{}, fmt.Errorf("%w: the selected weeks do not have enough cards to build questions from", ErrInvalidExam)
+	}
+
+	exam := Exam{
+		ID:              uuid.New(),
+		UserID:          userID,
+		ModuleRunID:     req.ModuleRunID,
+		Source:          req.Source,
+		QuestionCount:   len(questions),
+		DurationSeconds: req.DurationMinutes * 60,
+	}
+	if err := s.contentRepository.CreateExam(ctx, exam, questions); err != nil {
+		return ExamSession{}, err
+	}
+	return s.GetExamSession(ctx, userID, exam.ID)
+}
+
+// GetExamSession returns the state of the exam and the question to answer next
+func (s *ContentService) GetExamSession(ctx context.Context, userID, examID uuid.UUID) (ExamSession, error) {
+	exam, err := s.loadExam(ctx, userID, examID)
+	if err != nil {
+		return ExamSession{}, err
+	}
+	session := ExamSession{Exam: exam}
+	if exam.Status != ExamInProgress {
+		return session, nil
+	}
+
+	question, err := s.contentRepository.GetCurrentExamQuestion(ctx, examID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return session, nil
+	}
+	if err != nil {
+		return ExamSession{}, err
+	}
+	question.Content = s.withImageURL(ctx, question.Content)
+	session.Question = &question
+	return session, nil
+}
+
+// AnswerExamQuestion records the answer to the current question and serves the next one.
+// Whether the answer was right is only revealed in the result.
+func (s *ContentService) AnswerExamQuestion(ctx context.Context, userID, examID uuid.UUID, req AnswerExamQuestionRequest) (ExamSession, error) {
+	exam, err := s.loadExam(ctx, userID, examID)
+	if err != nil {
+		return ExamSession{}, err
+	}
+	if exam.Status != ExamInProgress {
+		return ExamSession{}, ErrExamFinished
+	}
+
+	current, err := s.contentRepository.GetCurrentExamQuestion(ctx, examID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ExamSession{}, ErrNotCurrentQuestion
+	}
+	if err != nil {
+		return ExamSession{}, err
+	}
+	if current.ID != req.QuestionID {
+		return ExamSession{}, ErrNotCurrentQuestion
+	}
+	if req.Option != nil && (*req.Option < 0 || *req.Option >= len(current.Options)) {
+		return ExamSession{}, fmt.Errorf("%w: option must be between 0 and %d", ErrInvalidExam, len(current.Options)-1)
+	}
+
+	if err := s.contentRepository.AnswerExamQuestion(ctx, examID, req.QuestionID, req.Option); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ExamSession{}, ErrNotCurrentQuestion
+		}
+		return ExamSession{}, err
+	}
+	return s.GetExamSession(ctx, userID, examID)
+}
+
+// SubmitExam grades the attempt, unanswered questions count as wrong. Submitting a finished exam
+// returns its result again, an attempt whose time ran out is stored as expired.
+func (s *ContentService) SubmitExam(ctx context.Context, userID, examID uuid.UUID) (ExamResult, error) {
+	exam, err := s.loadExam(ctx, userID, examID)
+	if err != nil {
+		return ExamResult{}, err
+	}
+	switch {
+	case exam.TimedOut:
+		err = s.contentRepository.FinishExam(ctx, examID, ExamExpired)
+	case exam.Status == ExamInProgress:
+		err = s.contentRepository.FinishExam(ctx, examID, ExamCompleted)
+	}
+	if err != nil {
+		return ExamResult{}, err
+	}
+	return s.GetExamResult(ctx, userID, examID)
+}
+
+// GetExamResult returns the graded attempt with the score of every week, weakest week first
+func (s *ContentService) GetExamResult(ctx context.Context, userID, examID uuid.UUID) (ExamResult, error) {
+	exam, err := s.loadExam(ctx, userID, examID)
+	if err != nil {
+		return ExamResult{}, err
+	}
+	if exam.Status == ExamInProgress {
+		return ExamResult{}, ErrExamInProgress
+	}
+
+	questions, err := s.contentRepository.ListExamQuestions(ctx, examID)
+	if err != nil {
+		return ExamResult{}, err
+	}
+	for i := range questions {
+		questions[i].Content = s.withImageURL(ctx, questions[i].Content)
+	}
+	return ExamResult{Exam: exam, Weeks: examWeekScores(questions), Questions: questions}, nil
+}
+
+// ListExams returns the user's attempts, newest first
+func (s *ContentService) ListExams(ctx context.Context, userID uuid.UUID, moduleRunID *uuid.UUID, page Page) ([]Exam, error) {
+	page, err := normalizePage(page)
+	if err != nil {
+		return nil, err
+	}
+	return s.contentRepository.ListExams(ctx, userID, moduleRunID, page)
+}
+
+// StartExamSweep stores the grade of timed out attempts every interval until ctx is done, reads
+// already show them as expired in the meantime
+func (s *ContentService) StartExamSweep(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				finished, err := s.contentRepository.FinishExpiredExams(ctx)
+				if err != nil {
+					slog.Error("failed to finish expired exams", "err", err)
+					continue
+				}
+				if finished > 0 {
+					slog.Info("finished expired exams", "count", finished)
+				}
+			}
+		}
+	}()
+}
+
+// loadExam fetches the attempt, one whose time ran out reads as expired
+func (s *ContentService) loadExam(ctx context.Context, userID, examID uuid.UUID) (Exam, error) {
+	exam, err := s.contentRepository.GetExam(ctx, examID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Exam{}, errors.New("exam not found")
+	}
+	return exam, err
+}
+
+// dedupeExamCandidates drops generated flashcards the user already has in their deck
+func dedupeExamCandidates(candidates []ExamCandidate) []ExamCandidate {
+	inDeck := make(map[uuid.UUID]bool)
+	for _, c := range candidates {
+		if c.DeckCardID != nil && c.FlashcardID != nil {
+			inDeck[*c.FlashcardID] = true
+		}
+	}
+	return slices.DeleteFunc(candidates, func(c ExamCandidate) bool {
+		return c.DeckCardID == nil && inDeck[*c.FlashcardID]
+	})
+}
+
+// examAnswer returns the expected answer of a card, empty when the card cannot be asked
+func examAnswer(c ExamCandidate) string {
+	switch c.CardType {
+	case CardMultipleChoice:
+		if c.Content != nil && c.Content.CorrectOption != nil && *c.Content.CorrectOption < len(c.Content.Options) {
+			return c.Content.Options[*c.Content.CorrectOption]
+		}
+	case CardCloze:
+		if c.Content != nil {
+			return clozeAnswer(c.Content.Text, c.Content.ClozeIndex)
+		}
+	}
+	return strings.TrimSpace(c.Back)
+}
+
+// clozeAnswer joins the text of every deletion with the given number
+func clozeAnswer(text string, index int) string {
+	answers := make([]string, 0, 1)
+	for _, m := range clozePattern.FindAllStringSubmatch(text, -1) {
+		if m[1] == strconv.Itoa(index) {
+			answers = append(answers, m[2])
+		}
+	}
+	return strings.Join(answers, ", ")
+}
+
+// buildExamQuestions picks the cards round-robin across weeks so every selected week is represented,
+// then shuffles the questions and their options
+func buildExamQuestions(candidates []ExamCandidate, count int) []ExamQuestion {
+	answers := make([]string, 0, len(candidates))
+	seen := make(map[string]bool, len(candidates))
+	byWeek := make(map[uuid.UUID][]ExamCandidate)
+	for _, c := range candidates {
+		answer := examAnswer(c)
+		if answer == "" {
+			continue
+		}
+		if key := normalizeFront(answer); !seen[key] {
+			seen[key] = true
+			answers = append(answers, answer)
+		}
+		byWeek[c.WeekID] = append(byWeek[c.WeekID], c)
+	}
+
+	weeks := make([][]ExamCandidate, 0, len(byWeek))
+	for _, cards := range byWeek {
+		rand.Shuffle(len(cards), func(i, j int) { cards[i], cards[j] = cards[j], cards[i] })
+		weeks = append(weeks, cards)
+	}
+	slices.SortFunc(weeks, func(a, b []ExamCandidate) int { return cmp.Compare(a[0].WeekNumber, b[0].WeekNumber) })
+
+	questions := make([]ExamQuestion, 0, count)
+	for round := 0; len(questions) < count; round++ {
+		picked := false
+		for _, cards := range weeks {
+			if round >= len(cards) || len(questions) == count {
+				continue
+			}
+			picked = true
+			if q, ok := examQuestion(cards[round], answers); ok {
+				questions = append(questions, q)
+			}
+		}
+		if !picked {
+			break
+		}
+	}
+
+	rand.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	for i := range questions {
+		questions[i].Position = i + 1
+	}
+	return questions
+}
+
+// examQuestion turns a card into a multiple-choice question, false when no distractors are available
+func examQuestion(c ExamCandidate, answers []string) (ExamQuestion, bool) {
+	answer := examAnswer(c)
+	var options []string
+	if c.CardType == CardMultipleChoice && c.Content != nil && len(c.Content.Options) >= minChoiceOptions {
+		options = slices.Clone(c.Content.Options)
+	} else {
+		options = []string{answer}
+		for _, i := range rand.Perm(len(answers)) {
+			if len(options) == examChoices {
+				break
+			}
+			if normalizeFront(answers[i]) != normalizeFront(answer) {
+				options = append(options, answers[i])
+			}
+		}
+	}
+	if len(options) < minChoiceOptions {
+		return ExamQuestion{}, false
+	}
+	rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+
+	weekID := c.WeekID
+	q := ExamQuestion{
+		ID:            uuid.New(),
+		WeekID:        &weekID,
+		WeekNumber:    c.WeekNumber,
+		FlashcardID:   c.FlashcardID,
+		DeckCardID:    c.DeckCardID,
+		CardType:      c.CardType,
+		Prompt:        c.Front,
+		Options:       options,
+		CorrectOption: slices.Index(options, answer),
+	}
+	// image cards keep the picture and regions, without the labels that give the answers away
+	if c.CardType == CardImage && c.Content != nil {
+		content := CardContent{ImageObjectID: c.Content.ImageObjectID, OcclusionIndex: c.Content.OcclusionIndex}
+		for _, o := range c.Content.Occlusions {
+			o.Label = ""
+			content.Occlusions = append(content.Occlusions, o)
+		}
+		q.Content = &content
+	}
+	return q, true
+}
+
+// examWeekScores groups the graded questions by week, weakest week first
+func examWeekScores(questions []ExamQuestionReview) []ExamWeekScore {
+	scores := make([]ExamWeekScore, 0)
+	index := make(map[int]int)
+	for _, q := range questions {
+		i, ok := index[q.WeekNumber]
+		if !ok {
+			i = len(scores)
+			index[q.WeekNumber] = i
+			scores = append(scores, ExamWeekScore{WeekID: q.WeekID, WeekNumber: q.WeekNumber})
+		}
+		scores[i].Questions++
+		if q.Correct {
+			scores[i].Correct++
+		}
+	}
+	for i := range scores {
+		scores[i].Score = math.Round(1000*float64(scores[i].Correct)/float64(scores[i].Questions)) / 10
+	}
+	slices.SortFunc(scores, func(a, b ExamWeekScore) int {
+		return cmp.Or(cmp.Compare(a.Score, b.Score), cmp.Compare(a.WeekNumber, b.WeekNumber))
+	})
+	return scores
+}
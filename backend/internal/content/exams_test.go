@@ -0,0 +1,192 @@
+package content
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// examCandidates makes perWeek basic cards for each week, every card with its own answer
+func examCandidates(weeks, perWeek int) []ExamCandidate {
+	candidates := make([]ExamCandidate, 0, weeks*perWeek)
+	for w := 1; w <= weeks; w++ {
+		weekID := uuid.New()
+		for i := 1; i <= perWeek; i++ {
+			flashcardID := uuid.New()
+			candidates = append(candidates, ExamCandidate{
+				FlashcardID: &flashcardID,
+				WeekID:      weekID,
+				WeekNumber:  w,
+				CardType:    CardBasic,
+				Front:       fmt.Sprintf("question %d.%d", w, i),
+				Back:        fmt.Sprintf("answer %d.%d", w, i),
+			})
+		}
+	}
+	return candidates
+}
+
+func TestBuildExamQuestions(t *testing.T) {
+	tests := []struct {
+		name              string
+		weeks             int
+		perWeek           int
+		count             int
+		expectedQuestions int
+		expectedPerWeek   int
+	}{
+		{name: "spread evenly across weeks", weeks: 3, perWeek: 10, count: 12, expectedQuestions: 12, expectedPerWeek: 4},
+		{name: "exactly the available cards", weeks: 2, perWeek: 5, count: 10, expectedQuestions: 10, expectedPerWeek: 5},
+		{name: "fewer cards than asked", weeks: 2, perWeek: 3, count: 20, expectedQuestions: 6, expectedPerWeek: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := examCandidates(tt.weeks, tt.perWeek)
+			answers := make(map[string]string, len(candidates))
+			for _, c := range candidates {
+				answers[c.Front] = c.Back
+			}
+
+			questions := buildExamQuestions(candidates, tt.count)
+			if len(questions) != tt.expectedQuestions {
+				t.Fatalf("expected %d questions, got %d", tt.expectedQuestions, len(questions))
+			}
+
+			perWeek := make(map[int]int)
+			prompts := make(map[string]bool)
+			for i, q := range questions {
+				perWeek[q.WeekNumber]++
+				if q.Position != i+1 {
+					t.Errorf("question %d has position %d", i, q.Position)
+				}
+				if prompts[q.Prompt] {
+					t.Errorf("card %q was asked twice", q.Prompt)
+				}
+				prompts[q.Prompt] = true
+
+				if len(q.Options) != examChoices {
+					t.Fatalf("expected %d options, got %v", examChoices, q.Options)
+				}
+				distinct := make(map[string]bool)
+				for _, option := range q.Options {
+					distinct[option] = true
+				}
+				if len(distinct) != examChoices {
+					t.Errorf("expected distinct options, got %v", q.Options)
+				}
+				if q.CorrectOption < 0 || q.Options[q.CorrectOption] != answers[q.Prompt] {
+					t.Errorf("correct option %d of %v does not point at %q", q.CorrectOption, q.Options, answers[q.Prompt])
+				}
+			}
+			for week, n := range perWeek {
+				if n != tt.expectedPerWeek {
+					t.Errorf("week %d has %d questions, want %d", week, n, tt.expectedPerWeek)
+				}
+			}
+		})
+	}
+}
+
+func TestExamQuestionHidesTheAnswer(t *testing.T) {
+	imageID, deckCardID := uuid.New(), uuid.New()
+	index := 1
+	candidates := append(examCandidates(1, 4), ExamCandidate{
+		DeckCardID: &deckCardID,
+		WeekID:     uuid.New(),
+		WeekNumber: 2,
+		CardType:   CardImage,
+		Front:      "Name the hidden vessel",
+		Back:       "pulmonary artery",
+		Content: &CardContent{
+			ImageObjectID:  &imageID,
+			OcclusionIndex: &index,
+			Occlusions:     []Occlusion{{Width: 0.2, Height: 0.2, Label: "aorta"}, {X: 0.5, Width: 0.2, Height: 0.2, Label: "pulmonary artery"}},
+		},
+	})
+
+	for _, q := range buildExamQuestions(candidates, len(candidates)) {
+		body, err := json.Marshal(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		served := string(body)
+		for _, leak := range []string{"correct_option", "flashcard_id", "deck_card_id", deckCardID.String(), "aorta"} {
+			if strings.Contains(served, leak) {
+				t.Errorf("served question leaks %q: %s", leak, served)
+			}
+		}
+		if q.FlashcardID != nil && strings.Contains(served, q.FlashcardID.String()) {
+			t.Errorf("served question leaks its flashcard id: %s", served)
+		}
+		if q.CardType == CardImage && (q.Content == nil || len(q.Content.Occlusions) != 2 || *q.Content.OcclusionIndex != 1) {
+			t.Errorf("expected the image regions without labels, got %+v", q.Content)
+		}
+	}
+}
+
+func TestExamQuestionKeepsMultipleChoiceOptions(t *testing.T) {
+	correct := 2
+	c := ExamCandidate{
+		WeekID:   uuid.New(),
+		CardType: CardMultipleChoice,
+		Front:    "Which organelle makes ATP?",
+		Content:  &CardContent{Options: []string{"Nucleus", "Ribosome", "Mitochondria"}, CorrectOption: &correct},
+	}
+	q, ok := examQuestion(c, []string{"Mitochondria", "Golgi apparatus", "Lysosome", "Vacuole"})
+	if !ok {
+		t.Fatal("expected a question")
+	}
+	if len(q.Options) != 3 || q.Options[q.CorrectOption] != "Mitochondria" || strings.Contains(strings.Join(q.Options, ","), "Golgi") {
+		t.Errorf("expected the card's own options, got %v with correct %d", q.Options, q.CorrectOption)
+	}
+
+	if _, ok := examQuestion(ExamCandidate{CardType: CardBasic, Back: "only answer"}, []string{"only answer"}); ok {
+		t.Error("expected no question without distractors")
+	}
+}
+
+func TestExamWeekScores(t *testing.T) {
+	week1, week2, week3 := uuid.New(), uuid.New(), uuid.New()
+	selected := 0
+	review := func(weekID uuid.UUID, week int, answered, correct bool) ExamQuestionReview {
+		q := ExamQuestionReview{ExamQuestion: ExamQuestion{WeekID: &weekID, WeekNumber: week}, Correct: correct}
+		if answered {
+			q.SelectedOption = &selected
+		}
+		return q
+	}
+
+	scores := examWeekScores([]ExamQuestionReview{
+		review(week1, 1, true, true),
+		review(week2, 2, true, true),
+		review(week1, 1, true, false),
+		review(week2, 2, false, false),
+		review(week1, 1, true, true),
+		review(week2, 2, false, false),
+		review(week3, 3, true, true),
+		review(week3, 3, true, false),
+	})
+
+	expected := []ExamWeekScore{
+		{WeekID: &week2, WeekNumber: 2, Questions: 3, Correct: 1, Score: 33.3},
+		{WeekID: &week3, WeekNumber: 3, Questions: 2, Correct: 1, Score: 50},
+		{WeekID: &week1, WeekNumber: 1, Questions: 3, Correct: 2, Score: 66.7},
+	}
+	if len(scores) != len(expected) {
+		t.Fatalf("expected %d weeks, got %+v", len(expected), scores)
+	}
+	for i, score := range scores {
+		want := expected[i]
+		if *score.WeekID != *want.WeekID || score.WeekNumber != want.WeekNumber || score.Questions != want.Questions ||
+			score.Correct != want.Correct || score.Score != want.Score {
+			t.Errorf("week score %d = %+v, want %+v", i, score, want)
+		}
+	}
+
+	if scores := examWeekScores(nil); scores == nil || len(scores) != 0 {
+		t.Errorf("expected an empty list, got %v", scores)
+	}
+}
@@ -0,0 +1,172 @@
+package content
+
+import (
+	"StudyHub/internal/anki"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errEmptyDeck = errors.New("deck not found or empty")
+
+// ExportWeekDeck renders the user's deck for a single week in the requested format
+func (s *ContentService) ExportWeekDeck(ctx context.Context, userID, weekID uuid.UUID, format ExportFormat) (DeckExport, error) {
+	if !format.IsValid() {
+		return DeckExport{}, fmt.Errorf("unsupported export format %q", format)
+	}
+	cards, err := s.contentRepository.ListDeckCardsForExport(ctx, userID, &weekID, nil)
+	if err != nil {
+		return DeckExport{}, err
+	}
+	if len(cards) == 0 {
+		return DeckExport{}, errEmptyDeck
+	}
+	name := fmt.Sprintf("%s - Week %d", cards[0].ModuleName, cards[0].WeekNumber)
+	return renderDeck(ctx, name, cards, format)
+}
+
+// ExportModuleRunDeck renders the user's cards from every week of a module run, one sub-deck per week
+func (s *ContentService) ExportModuleRunDeck(ctx context.Context, userID, moduleRunID uuid.UUID, format ExportFormat) (DeckExport, error) {
+	if !format.IsValid() {
+		return DeckExport{}, fmt.Errorf("unsupported export format %q", format)
+	}
+	cards, err := s.contentRepository.ListDeckCardsForExport(ctx, userID, nil, &moduleRunID)
+	if err != nil {
+		return DeckExport{}, err
+	}
+	if len(cards) == 0 {
+		return DeckExport{}, errEmptyDeck
+	}
+	return renderDeck(ctx, cards[0].ModuleName, cards, format)
+}
+
+func renderDeck(ctx context.Context, name string, cards []DueCard, format ExportFormat) (DeckExport, error) {
+	var buf bytes.Buffer
+	export := DeckExport{FileName: exportFileName(name) + "." + string(format)}
+
+	switch format {
+	case ExportAPKG:
+		export.ContentType = "application/apkg"
+		if err := anki.WritePackage(ctx, &buf, ankiNotes(cards)); err != nil {
+			return DeckExport{}, fmt.Errorf("write anki package: %w", err)
+		}
+	case ExportCSV:
+		export.ContentType = "text/csv; charset=utf-8"
+		if err := writeDeckCSV(&buf, cards); err != nil {
+			return DeckExport{}, fmt.Errorf("write csv: %w", err)
+		}
+	case ExportJSON:
+		export.ContentType = "application/json"
+		if err := json.NewEncoder(&buf).Encode(cards); err != nil {
+			return DeckExport{}, err
+		}
+	}
+
+	export.Data = buf.Bytes()
+	return export, nil
+}
+
+// ankiNotes maps deck cards onto Anki notes, keeping the SM-2 state so reviews continue where they left off
+func ankiNotes(cards []DueCard) []anki.Note {
+	notes := make([]anki.Note, len(cards))
+	for i, card := range cards {
+		notes[i] = anki.Note{
+			GUID:  card.ID.String(),
+			Deck:  fmt.Sprintf("%s::Week %d", strings.ReplaceAll(card.ModuleName, "::", ":"), card.WeekNumber),
+			Front: card.Front,
+			Back:  card.Back,
+			Tags:  append([]string{"StudyHub", fmt.Sprintf("week_%d", card.WeekNumber)}, card.Tags...),
+			Card: anki.CardState{
+				Due:          card.DueAt,
+				IntervalDays: card.IntervalDays,
+				EaseFactor:   card.EaseFactor,
+				Repetitions:  card.Repetitions,
+				Lapses:       card.Lapses,
+			},
+		}
+	}
+	return notes
+}
+
+var deckCSVHeader = []string{
+	"id", "module", "week", "front", "back", "card_type", "is_custom", "review_count", "difficulty_rating",
+	"last_reviewed_at", "due_at", "ease_factor", "interval_days", "repetitions", "lapses",
+	"stability", "fsrs_difficulty", "tags",
+}
+
+func writeDeckCSV(buf *bytes.Buffer, cards []DueCard) error {
+	w := csv.NewWriter(buf)
+	if err := w.Write(deckCSVHeader); err != nil {
+		return err
+	}
+	for _, card := range cards {
+		record := []string{
+			card.ID.String(),
+			card.ModuleName,
+			strconv.Itoa(card.WeekNumber),
+			card.Front,
+			card.Back,
+			string(card.CardType),
+			strconv.FormatBool(card.IsCustom),
+			strconv.Itoa(card.ReviewCount),
+			formatOptionalInt(card.DifficultyRating),
+			formatOptionalTime(card.LastReviewedAt),
+			formatOptionalTime(card.DueAt),
+			strconv.FormatFloat(card.EaseFactor, 'f', -1, 64),
+			strconv.Itoa(card.IntervalDays),
+			strconv.Itoa(card.Repetitions),
+			strconv.Itoa(card.Lapses),
+			formatOptionalFloat(card.Stability),
+			formatOptionalFloat(card.FSRSDifficulty),
+			strings.Join(card.Tags, " "),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func formatOptionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func formatOptionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func formatOptionalTime(v *time.Time) string {
+	if v == nil {
+		return ""
+	}
+	return v.UTC().Format(time.RFC3339)
+}
+
+// exportFileName drops characters that are not safe in a file name on common file systems
+func exportFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, name)
+	if name = strings.TrimSpace(name); name == "" {
+		return "deck"
+	}
+	return name
+}
@@ -0,0 +1,14 @@
+package content
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// GenerateDocument runs the generation of a single chunk document against ai, it lets the tests of
+// content_test drive the pipeline with a provider from the ai package
+func GenerateDocument(ctx context.Context, ai AI, data []byte, objectID uuid.UUID, opts GenerationOptions, extras bool) (GeneratedContent, error) {
+	s := &ContentService{ai: ai}
+	return s.generateChunks(ctx, []documentChunk{{Data: data}}, objectID, opts, extras, func() {})
+}
@@ -0,0 +1,190 @@
+package content
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// generated cards longer than this are sent back for repair, the AI prompt states the same limits
+	MaxGeneratedFrontLength = 500
+	MaxGeneratedBackLength  = 2000
+	// the model gets the problems back once, listing every one of a long answer adds nothing
+	maxReportedProblems = 20
+)
+
+// errNoValidCards is returned when the repaired answer still has no usable card
+var errNoValidCards = errors.New("no valid flashcards after repair")
+
+type generatedCard struct {
+	Front string `json:"front"`
+	Back  string `json:"back"`
+}
+
+// parseGeneratedCards turns the model's answer into flashcards, the answer is asked to fix itself once
+// when it is not valid JSON or any card fails validation. Cards that are still invalid after that are
+// dropped, the job only fails when no card is left
+func (s *ContentService) parseGeneratedCards(ctx context.Context, response string, objectID uuid.UUID) ([]Flashcard, error) {
+	cards, problems := validateGeneratedCards(response)
+	if len(problems) == 0 {
+		return newGeneratedFlashcards(cards, objectID), nil
+	}
+
+	slog.Warn("generated flashcards failed validation, asking for a repair", "object id", objectID, "problems", len(problems))
+	repaired, err := s.ai.RepairFlashCards(ctx, response, problems)
+	if err != nil {
+		return nil, fmt.Errorf("failed to repair flashcards: %w", err)
+	}
+	cards, problems = validateGeneratedCards(repaired)
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("%w: %s", errNoValidCards, strings.Join(problems, "; "))
+	}
+	if len(problems) > 0 {
+		slog.Warn("dropped invalid flashcards after repair", "object id", objectID, "problems", strings.Join(problems, "; "))
+	}
+	return newGeneratedFlashcards(cards, objectID), nil
+}
+
+func newGeneratedFlashcards(cards []generatedCard, objectID uuid.UUID) []Flashcard {
+	flashcards := make([]Flashcard, len(cards))
+	for i, card := range cards {
+		flashcards[i] = Flashcard{ID: uuid.New(), ObjectID: &objectID, Front: card.Front, Back: card.Back}
+	}
+	return flashcards
+}
+
+// validateGeneratedCards returns the usable cards of an answer and what is wrong with the rest
+func validateGeneratedCards(response string) ([]generatedCard, []string) {
+	cards, err := decodeGeneratedCards(response)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	if len(cards) == 0 {
+		return nil, []string{"the answer contains no flashcards"}
+	}
+
+	valid := make([]generatedCard, 0, len(cards))
+	var problems []string
+	seen := make(map[string]int, len(cards))
+	for i, card := range cards {
+		card.Front = strings.TrimSpace(card.Front)
+		card.Back = strings.TrimSpace(card.Back)
+
+		problem := ""
+		switch {
+		case card.Front == "" || card.Back == "":
+			problem = fmt.Sprintf("card %d: front and back cannot be empty", i+1)
+		case utf8.RuneCountInString(card.Front) > MaxGeneratedFrontLength:
+			problem = fmt.Sprintf("card %d: front is longer than %d characters", i+1, MaxGeneratedFrontLength)
+		case utf8.RuneCountInString(card.Back) > MaxGeneratedBackLength:
+			problem = fmt.Sprintf("card %d: back is longer than %d characters", i+1, MaxGeneratedBackLength)
+		}
+		if problem == "" {
+			key := textKey(card.Front)
+			if first, ok := seen[key]; ok {
+				problem = fmt.Sprintf("card %d: duplicates the question of card %d", i+1, first)
+			} else {
+				seen[key] = i + 1
+			}
+		}
+
+		if problem != "" {
+			if len(problems) < maxReportedProblems {
+				problems = append(problems, problem)
+			}
+			continue
+		}
+		valid = append(valid, card)
+	}
+	return valid, problems
+}
+
+// decodeGeneratedCards reads a card array out of an answer, it strips markdown fences and any text
+// around the JSON and accepts the array wrapped in an object as well, e.g. {"cards": [...]}
+func decodeGeneratedCards(response string) ([]generatedCard, error) {
+	var cards []generatedCard
+	if err := decodeGeneratedArray(response, &cards, "cards", "flashcards"); err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
+
+// decodeGeneratedArray decodes an answer holding a JSON array, bare or wrapped in an object under one of keys
+func decodeGeneratedArray(response string, dest any, keys ...string) error {
+	text, err := generatedJSON(response)
+	if err != nil {
+		return err
+	}
+	if text[0] == '[' {
+		if err := json.Unmarshal([]byte(text), dest); err != nil {
+			return fmt.Errorf("the answer is not a valid %s array: %v", keys[0], err)
+		}
+		return nil
+	}
+
+	var wrapped map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &wrapped); err != nil {
+		return fmt.Errorf("the answer is not valid JSON: %v", err)
+	}
+	for _, key := range keys {
+		if raw, ok := wrapped[key]; ok {
+			if err := json.Unmarshal(raw, dest); err != nil {
+				return fmt.Errorf("%q is not a valid %s array: %v", key, keys[0], err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("the answer is an object without a %s array", keys[0])
+}
+
+// generatedJSON cuts the JSON array or object out of an answer
+func generatedJSON(response string) (string, error) {
+	text := strings.TrimSpace(strings.TrimPrefix(response, "\ufeff"))
+	text = stripCodeFence(text)
+
+	start := strings.IndexAny(text, "[{")
+	if start < 0 {
+		return "", fmt.Errorf("the answer is not JSON: %s", preview(text))
+	}
+	closing := "]"
+	if text[start] == '{' {
+		closing = "}"
+	}
+	end := strings.LastIndex(text, closing)
+	if end < start {
+		return "", fmt.Errorf("the answer is not complete JSON: %s", preview(text))
+	}
+	return text[start : end+1], nil
+}
+
+// stripCodeFence removes a ```json ... ``` wrapper
+func stripCodeFence(text string) string {
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	// drop the language tag as well, e.g. ```json
+	text = strings.TrimLeftFunc(strings.TrimPrefix(text, "```"), unicode.IsLetter)
+	text = strings.TrimSpace(text)
+	return strings.TrimSpace(strings.TrimSuffix(text, "```"))
+}
+
+// textKey normalises text for duplicate checks, case and spacing do not matter
+func textKey(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+func preview(text string) string {
+	const maxPreview = 80
+	if utf8.RuneCountInString(text) <= maxPreview {
+		return text
+	}
+	return string([]rune(text)[:maxPreview]) + "..."
+}
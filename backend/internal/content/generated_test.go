@@ -0,0 +1,243 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// repairAI answers repair requests with a fixed response and records the problems it was sent
+type repairAI struct {
+	AI
+	repaired string
+	err      error
+	calls    int
+	problems []string
+}
+
+func (a *repairAI) RepairFlashCards(ctx context.Context, response string, problems []string) (string, error) {
+	a.calls++
+	a.problems = problems
+	return a.repaired, a.err
+}
+
+func TestStripCodeFence(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"no fence", `[{"front": "a"}]`, `[{"front": "a"}]`},
+		{"json fence", "```json\n[{\"front\": \"a\"}]\n```", `[{"front": "a"}]`},
+		{"bare fence", "```\n[]\n```", "[]"},
+		{"missing closing fence", "```json\n[1, 2]", "[1, 2]"},
+		{"fence later in the text is kept", "Here you go:\n```json\n[]\n```", "Here you go:\n```json\n[]\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripCodeFence(tt.text); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateGeneratedCards(t *testing.T) {
+	longFront := strings.Repeat("é", MaxGeneratedFrontLength+1)
+	longBack := strings.Repeat("b", MaxGeneratedBackLength+1)
+
+	tests := []struct {
+		name             string
+		response         string
+		expectedFronts   []string
+		expectedProblems []string
+	}{
+		{
+			name:           "bare array",
+			response:       `[{"front": "What is Go?", "back": "A language"}, {"front": "Who made it?", "back": "Google"}]`,
+			expectedFronts: []string{"What is Go?", "Who made it?"},
+		},
+		{
+			name:           "fenced json with text around it",
+			response:       "```json\nSure, the cards: [{\"front\": \" Q \", \"back\": \" A \"}] hope it helps\n```",
+			expectedFronts: []string{"Q"},
+		},
+		{
+			name:           "wrapped in an object",
+			response:       `{"flashcards": [{"front": "Q", "back": "A"}]}`,
+			expectedFronts: []string{"Q"},
+		},
+		{
+			name:             "truncated json",
+			response:         `[{"front": "Q", "back": "A"}, {"front": "Q2", "ba`,
+			expectedProblems: []string{"the answer is not complete JSON"},
+		},
+		{
+			name:             "truncated inside the array",
+			response:         `[{"front": "Q", "back": "A"}, {"front": "Q2"]`,
+			expectedProblems: []string{"the answer is not a valid cards array"},
+		},
+		{
+			name:             "not json",
+			response:         "I cannot read this document",
+			expectedProblems: []string{"the answer is not JSON"},
+		},
+		{
+			name:             "object without cards",
+			response:         `{"questions": []}`,
+			expectedProblems: []string{"the answer is an object without a cards array"},
+		},
+		{
+			name:             "empty array",
+			response:         `[]`,
+			expectedProblems: []string{"the answer contains no flashcards"},
+		},
+		{
+			name:             "over-length fields",
+			response:         `[{"front": "` + longFront + `", "back": "A"}, {"front": "Q", "back": "` + longBack + `"}, {"front": "ok", "back": "ok"}]`,
+			expectedFronts:   []string{"ok"},
+			expectedProblems: []string{"card 1: front is longer than 500 characters", "card 2: back is longer than 2000 characters"},
+		},
+		{
+			name:             "empty sides",
+			response:         `[{"front": "  ", "back": "A"}, {"front": "Q", "back": ""}]`,
+			expectedProblems: []string{"card 1: front and back cannot be empty", "card 2: front and back cannot be empty"},
+		},
+		{
+			name:             "duplicate fronts ignore case and spacing",
+			response:         `[{"front": "What is  Go?", "back": "A"}, {"front": "what is go?", "back": "B"}, {"front": "Other", "back": "C"}]`,
+			expectedFronts:   []string{"What is  Go?", "Other"},
+			expectedProblems: []string{"card 2: duplicates the question of card 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards, problems := validateGeneratedCards(tt.response)
+
+			if len(cards) != len(tt.expectedFronts) {
+				t.Fatalf("expected %d cards, got %d: %v", len(tt.expectedFronts), len(cards), problems)
+			}
+			for i, front := range tt.expectedFronts {
+				if cards[i].Front != front {
+					t.Errorf("expected card %d front %q, got %q", i, front, cards[i].Front)
+				}
+			}
+			if len(problems) != len(tt.expectedProblems) {
+				t.Fatalf("expected %d problems, got %v", len(tt.expectedProblems), problems)
+			}
+			for i, problem := range tt.expectedProblems {
+				if !strings.HasPrefix(problems[i], problem) {
+					t.Errorf("expected problem %q, got %q", problem, problems[i])
+				}
+			}
+		})
+	}
+
+	t.Run("reported problems are capped", func(t *testing.T) {
+		response := "[" + strings.Repeat(`{"front": "", "back": ""},`, maxReportedProblems+5) + `{"front": "Q", "back": "A"}]`
+		cards, problems := validateGeneratedCards(response)
+		if len(cards) != 1 || len(problems) != maxReportedProblems {
+			t.Errorf("expected 1 card and %d problems, got %d and %d", maxReportedProblems, len(cards), len(problems))
+		}
+	})
+}
+
+func TestParseGeneratedCards(t *testing.T) {
+	objectID := uuid.New()
+	valid := `[{"front": "Q1", "back": "A1"}, {"front": "Q2", "back": "A2"}]`
+
+	tests := []struct {
+		name           string
+		response       string
+		ai             *repairAI
+		expectedCards  int
+		expectedRepair bool
+		expectedErr    error
+		expectedErrMsg string
+	}{
+		{
+			name:          "valid answer is not repaired",
+			response:      valid,
+			ai:            &repairAI{},
+			expectedCards: 2,
+		},
+		{
+			name:           "truncated answer is repaired",
+			response:       `[{"front": "Q1", "back": "A1"}, {"fro`,
+			ai:             &repairAI{repaired: valid},
+			expectedCards:  2,
+			expectedRepair: true,
+		},
+		{
+			name:           "cards still invalid after the repair are dropped",
+			response:       `[{"front": "Q1", "back": "A1"}, {"front": "q1", "back": "A2"}]`,
+			ai:             &repairAI{repaired: `[{"front": "Q1", "back": "A1"}, {"front": "", "back": "A2"}]`},
+			expectedCards:  1,
+			expectedRepair: true,
+		},
+		{
+			name:           "no valid card after the repair",
+			response:       "not json",
+			ai:             &repairAI{repaired: "still not json"},
+			expectedRepair: true,
+			expectedErr:    errNoValidCards,
+		},
+		{
+			name:           "repair request fails",
+			response:       "[]",
+			ai:             &repairAI{err: errors.New("model unavailable")},
+			expectedRepair: true,
+			expectedErrMsg: "failed to repair flashcards: model unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ContentService{ai: tt.ai}
+			cards, err := s.parseGeneratedCards(context.Background(), tt.response, objectID)
+
+			if (tt.ai.calls > 0) != tt.expectedRepair {
+				t.Errorf("expected repair %v, got %d calls", tt.expectedRepair, tt.ai.calls)
+			}
+			if tt.expectedErr != nil || tt.expectedErrMsg != "" {
+				if err == nil {
+					t.Fatalf("expected an error, got %d cards", len(cards))
+				}
+				if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+					t.Errorf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if tt.expectedErrMsg != "" && err.Error() != tt.expectedErrMsg {
+					t.Errorf("expected %q, got %q", tt.expectedErrMsg, err.Error())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(cards) != tt.expectedCards {
+				t.Fatalf("expected %d cards, got %d", tt.expectedCards, len(cards))
+			}
+			for _, card := range cards {
+				if card.ID == uuid.Nil || card.ObjectID == nil || *card.ObjectID != objectID {
+					t.Errorf("expected a new card of %s, got %+v", objectID, card)
+				}
+			}
+		})
+	}
+
+	t.Run("the repair gets the problems", func(t *testing.T) {
+		ai := &repairAI{repaired: valid}
+		s := &ContentService{ai: ai}
+		if _, err := s.parseGeneratedCards(context.Background(), `[{"front": "Q", "back": ""}]`, objectID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(ai.problems) != 1 || ai.problems[0] != "card 1: front and back cannot be empty" {
+			t.Errorf("expected the empty card to be reported, got %v", ai.problems)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package content_test
+
+import (
+	"StudyHub/internal/ai"
+	"StudyHub/internal/content"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func newFakeProvider(t *testing.T) ai.Provider {
+	t.Helper()
+	provider, err := ai.New(ai.Config{Provider: "fake"})
+	if err != nil {
+		t.Fatalf("failed to create the fake provider: %v", err)
+	}
+	return provider
+}
+
+func TestGenerateDocumentWithFakeProvider(t *testing.T) {
+	provider := newFakeProvider(t)
+	objectID := uuid.New()
+	document := []byte("Photosynthesis turns light into chemical energy.")
+
+	tests := []struct {
+		name          string
+		opts          content.GenerationOptions
+		extras        bool
+		expectedCards int
+	}{
+		{name: "default card count", expectedCards: 3},
+		{name: "requested card count", opts: content.GenerationOptions{CardCount: 7}, expectedCards: 7},
+		{name: "with summary and quiz", extras: true, expectedCards: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generated, err := content.GenerateDocument(context.Background(), provider, document, objectID, tt.opts, tt.extras)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(generated.Cards) != tt.expectedCards {
+				t.Fatalf("expected %d cards, got %d", tt.expectedCards, len(generated.Cards))
+			}
+			for _, card := range generated.Cards {
+				if card.ObjectID == nil || *card.ObjectID != objectID {
+					t.Errorf("expected the card to belong to %s, got %v", objectID, card.ObjectID)
+				}
+				if card.Front == "" || card.Back == "" {
+					t.Errorf("expected front and back to be set, got %+v", card)
+				}
+			}
+
+			if !tt.extras {
+				if generated.Summary != nil || len(generated.Quiz) != 0 {
+					t.Errorf("expected no summary or quiz without extras")
+				}
+				return
+			}
+			if generated.Summary == nil || len(generated.Summary.KeyPoints) == 0 {
+				t.Errorf("expected a summary with key points, got %+v", generated.Summary)
+			}
+			if len(generated.Quiz) != 1 {
+				t.Errorf("expected 1 quiz question, got %d", len(generated.Quiz))
+			}
+		})
+	}
+
+	t.Run("same document, same cards", func(t *testing.T) {
+		first, err := content.GenerateDocument(context.Background(), provider, document, objectID, content.GenerationOptions{}, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		second, err := content.GenerateDocument(context.Background(), provider, document, objectID, content.GenerationOptions{}, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for i := range first.Cards {
+			if first.Cards[i].Front != second.Cards[i].Front {
+				t.Errorf("expected card %d to repeat, got %q and %q", i, first.Cards[i].Front, second.Cards[i].Front)
+			}
+		}
+	})
+}
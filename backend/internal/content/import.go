@@ -0,0 +1,243 @@
+package content
+
+import (
+	"StudyHub/internal/anki"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxImportFileSize = 20 << 20
+	maxImportRows     = 5000
+	// longer sides are almost always a broken column split rather than a card
+	maxImportSideLength = 10000
+	utf8BOM             = "\ufeff"
+)
+
+// ErrInvalidImportFile is returned when the uploaded file cannot be parsed in the requested format
+var ErrInvalidImportFile = errors.New("invalid import file")
+
+// ImportFormatFromFileName guesses the format from the file extension, Quizlet exports are plain .txt files
+func ImportFormatFromFileName(name string) ImportFormat {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".apkg":
+		return ImportAPKG
+	case ".csv":
+		return ImportCSV
+	case ".txt", ".tsv":
+		return ImportQuizlet
+	default:
+		return ""
+	}
+}
+
+// ImportDeck parses the file and adds its cards to the user's deck for the week as custom cards.
+// Rows with an empty side and fronts already in the deck or earlier in the file are skipped and reported.
+// With dryRun nothing is written and the result previews what would be imported.
+func (s *ContentService) ImportDeck(ctx context.Context, userID, weekID uuid.UUID, format ImportFormat, data []byte, dryRun bool) (ImportResult, error) {
+	rows, err := parseImport(ctx, format, data)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if len(rows) > maxImportRows {
+		return ImportResult{}, fmt.Errorf("%w: file has more than %d cards", ErrInvalidImportFile, maxImportRows)
+	}
+
+	existing, err := s.contentRepository.GetUserDeckForWeek(ctx, userID, weekID)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	// maps a normalised front to the row it first appeared on, 0 for cards already in the deck
+	seen := make(map[string]int, len(existing)+len(rows))
+	for _, card := range existing {
+		seen[normalizeFront(card.Front)] = 0
+	}
+
+	result := ImportResult{DryRun: dryRun, Total: len(rows), Rows: rows}
+	now := time.Now()
+	cards := make([]UserDeckCard, 0, len(rows))
+	for i := range result.Rows {
+		row := &result.Rows[i]
+		if row.Status == ImportRowInvalid {
+			result.Invalid++
+			continue
+		}
+		if row.Front == "" || row.Back == "" {
+			row.Status, row.Error = ImportRowInvalid, "front and back cannot be empty"
+			result.Invalid++
+			continue
+		}
+		if utf8.RuneCountInString(row.Front) > maxImportSideLength || utf8.RuneCountInString(row.Back) > maxImportSideLength {
+			row.Status, row.Error = ImportRowInvalid, fmt.Sprintf("front and back cannot be longer than %d characters", maxImportSideLength)
+			result.Invalid++
+			continue
+		}
+
+		key := normalizeFront(row.Front)
+		if first, ok := seen[key]; ok {
+			row.Status = ImportRowDuplicate
+			row.Error = "card already in deck"
+			if first > 0 {
+				row.Error = fmt.Sprintf("duplicate of row %d", first)
+			}
+			result.Duplicates++
+			continue
+		}
+		seen[key] = row.Row
+
+		row.Status = ImportRowOK
+		cards = append(cards, UserDeckCard{
+			ID:        uuid.New(),
+			UserID:    userID,
+			WeekID:    weekID,
+			Front:     row.Front,
+			Back:      row.Back,
+			IsCustom:  true,
+			CardType:  CardBasic,
+			Tags:      row.Tags,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+	result.Imported = len(cards)
+
+	if dryRun || len(cards) == 0 {
+		return result, nil
+	}
+	if err := s.contentRepository.CreateCustomCardsInDeck(ctx, cards); err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// normalizeFront makes duplicate detection ignore case and whitespace differences
+func normalizeFront(front string) string {
+	return strings.ToLower(strings.Join(strings.Fields(front), " "))
+}
+
+func parseImport(ctx context.Context, format ImportFormat, data []byte) ([]ImportRow, error) {
+	switch format {
+	case ImportAPKG:
+		return parseAPKG(ctx, data)
+	case ImportCSV:
+		return parseCSV(data)
+	case ImportQuizlet:
+		return parseQuizlet(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported import format %q", ErrInvalidImportFile, format)
+	}
+}
+
+func parseAPKG(ctx context.Context, data []byte) ([]ImportRow, error) {
+	notes, err := anki.ReadPackage(ctx, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+	rows := make([]ImportRow, len(notes))
+	for i, note := range notes {
+		rows[i] = ImportRow{Row: i + 1, Front: note.Front, Back: note.Back, Tags: importTags(note.Tags)}
+	}
+	return rows, nil
+}
+
+// importTags normalizes the tags of an imported note, tags StudyHub cannot store are dropped
+// instead of rejecting the whole note
+func importTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil || slices.Contains(normalized, tag) {
+			continue
+		}
+		if len(normalized) == maxTagsPerCard {
+			break
+		}
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// parseCSV reads front and back from the first two columns, or from the "front" and "back" columns
+// when the file has a header row such as the one written by the CSV export
+func parseCSV(data []byte) ([]ImportRow, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte(utf8BOM))))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	frontCol, backCol := 0, 1
+	rows := make([]ImportRow, 0)
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+		line, _ := r.FieldPos(0)
+
+		if first {
+			header := make([]string, len(record))
+			for i, col := range record {
+				header[i] = strings.ToLower(strings.TrimSpace(col))
+			}
+			f, b := slices.Index(header, "front"), slices.Index(header, "back")
+			if f >= 0 && b >= 0 {
+				frontCol, backCol = f, b
+				continue
+			}
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		row := ImportRow{Row: line}
+		if len(record) <= max(frontCol, backCol) {
+			row.Status, row.Error = ImportRowInvalid, fmt.Sprintf("expected at least %d columns", max(frontCol, backCol)+1)
+		} else {
+			row.Front, row.Back = strings.TrimSpace(record[frontCol]), strings.TrimSpace(record[backCol])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseQuizlet(data []byte) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte(utf8BOM))))
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxImportFileSize)
+
+	rows := make([]ImportRow, 0)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		row := ImportRow{Row: line}
+		front, back, ok := strings.Cut(text, "\t")
+		if !ok {
+			row.Status, row.Error = ImportRowInvalid, "expected a tab between term and definition"
+		} else {
+			row.Front, row.Back = strings.TrimSpace(front), strings.TrimSpace(back)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+	return rows, nil
+}
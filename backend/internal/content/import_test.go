@@ -0,0 +1,193 @@
+package content
+
+import (
+	"StudyHub/internal/anki"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// importRepo has a fixed deck and records the cards an import creates
+type importRepo struct {
+	ContentRepository
+	deck    []UserDeckCard
+	created []UserDeckCard
+}
+
+func (r *importRepo) GetUserDeckForWeek(ctx context.Context, userID, weekID uuid.UUID) ([]UserDeckCard, error) {
+	return r.deck, nil
+}
+
+func (r *importRepo) CreateCustomCardsInDeck(ctx context.Context, cards []UserDeckCard) error {
+	r.created = append(r.created, cards...)
+	return nil
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		expectedRows []ImportRow
+	}{
+		{
+			name:         "header row is skipped",
+			data:         "Front,Back\nWhat is ATP?,Energy carrier\n",
+			expectedRows: []ImportRow{{Row: 2, Front: "What is ATP?", Back: "Energy carrier"}},
+		},
+		{
+			name:         "header picks the columns",
+			data:         "tags, BACK ,front\nbio,Energy carrier,What is ATP?\n",
+			expectedRows: []ImportRow{{Row: 2, Front: "What is ATP?", Back: "Energy carrier"}},
+		},
+		{
+			name: "headerless file uses the first two columns",
+			data: "What is ATP?,Energy carrier,ignored\nOsmosis,Diffusion of water\n",
+			expectedRows: []ImportRow{
+				{Row: 1, Front: "What is ATP?", Back: "Energy carrier"},
+				{Row: 2, Front: "Osmosis", Back: "Diffusion of water"},
+			},
+		},
+		{
+			name: "quoted multiline fields",
+			data: "\"Define\nosmosis\",\"Diffusion of water, \"\"passively\"\"\nacross a membrane\"\nATP,Energy carrier\n",
+			expectedRows: []ImportRow{
+				{Row: 1, Front: "Define\nosmosis", Back: "Diffusion of water, \"passively\"\nacross a membrane"},
+				{Row: 4, Front: "ATP", Back: "Energy carrier"},
+			},
+		},
+		{
+			name: "byte order mark, blank lines and short rows",
+			data: utf8BOM + "ATP,Energy carrier\n\nonly a front\n",
+			expectedRows: []ImportRow{
+				{Row: 1, Front: "ATP", Back: "Energy carrier"},
+				{Row: 3, Status: ImportRowInvalid, Error: "expected at least 2 columns"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseCSV([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertRows(t, rows, tt.expectedRows)
+		})
+	}
+}
+
+func TestParseQuizlet(t *testing.T) {
+	data := utf8BOM + "ATP\tEnergy carrier\r\n\n  \nOsmosis\tDiffusion\tof water\nno tab here\n"
+	rows, err := parseQuizlet([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, rows, []ImportRow{
+		{Row: 1, Front: "ATP", Back: "Energy carrier"},
+		{Row: 4, Front: "Osmosis", Back: "Diffusion\tof water"},
+		{Row: 5, Status: ImportRowInvalid, Error: "expected a tab between term and definition"},
+	})
+}
+
+func assertRows(t *testing.T, rows, expected []ImportRow) {
+	t.Helper()
+	if len(rows) != len(expected) {
+		t.Fatalf("expected %d rows, got %d: %+v", len(expected), len(rows), rows)
+	}
+	for i, row := range rows {
+		want := expected[i]
+		if row.Row != want.Row || row.Front != want.Front || row.Back != want.Back || row.Status != want.Status || row.Error != want.Error {
+			t.Errorf("row %d = %+v, want %+v", i, row, want)
+		}
+	}
+}
+
+func TestImportDeck(t *testing.T) {
+	userID, weekID := uuid.New(), uuid.New()
+	data := strings.Join([]string{
+		" what is  ATP? ,asked again",
+		"Osmosis,Diffusion of water",
+		"OSMOSIS,in the file twice",
+		",no front",
+		"Too long," + strings.Repeat("a", maxImportSideLength+1),
+		"only a front",
+		"Ribosome,Makes proteins",
+	}, "\n")
+
+	expected := []struct {
+		status ImportRowStatus
+		err    string
+	}{
+		{ImportRowDuplicate, "card already in deck"},
+		{ImportRowOK, ""},
+		{ImportRowDuplicate, "duplicate of row 2"},
+		{ImportRowInvalid, "front and back cannot be empty"},
+		{ImportRowInvalid, "front and back cannot be longer than 10000 characters"},
+		{ImportRowInvalid, "expected at least 2 columns"},
+		{ImportRowOK, ""},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		repo := &importRepo{deck: []UserDeckCard{{Front: "What is ATP?"}}}
+		s := &ContentService{contentRepository: repo}
+
+		result, err := s.ImportDeck(context.Background(), userID, weekID, ImportCSV, []byte(data), dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.DryRun != dryRun || result.Total != 7 || result.Imported != 2 || result.Duplicates != 2 || result.Invalid != 3 {
+			t.Errorf("dry run %v: unexpected counts %+v", dryRun, result)
+		}
+		for i, row := range result.Rows {
+			if row.Row != i+1 || row.Status != expected[i].status || row.Error != expected[i].err {
+				t.Errorf("dry run %v: row %d = %d %s %q, want %s %q", dryRun, i, row.Row, row.Status, row.Error, expected[i].status, expected[i].err)
+			}
+		}
+
+		if dryRun {
+			if len(repo.created) != 0 {
+				t.Errorf("expected a dry run to create nothing, got %d cards", len(repo.created))
+			}
+			continue
+		}
+		if len(repo.created) != 2 || repo.created[0].Front != "Osmosis" || repo.created[1].Front != "Ribosome" {
+			t.Fatalf("unexpected created cards %+v", repo.created)
+		}
+		for _, card := range repo.created {
+			if card.UserID != userID || card.WeekID != weekID || !card.IsCustom || card.CardType != CardBasic {
+				t.Errorf("unexpected card %+v", card)
+			}
+		}
+	}
+}
+
+func TestImportDeckAPKGTags(t *testing.T) {
+	var buf bytes.Buffer
+	notes := []anki.Note{
+		{GUID: "a", Front: "What is ATP?", Back: "Energy carrier", Tags: []string{"Biology::Cells", "Exam", "exam", strings.Repeat("x", maxTagLength+1)}},
+		{GUID: "b", Front: "Osmosis", Back: "Diffusion of water"},
+	}
+	if err := anki.WritePackage(context.Background(), &buf, notes); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &importRepo{}
+	s := &ContentService{contentRepository: repo}
+	result, err := s.ImportDeck(context.Background(), uuid.New(), uuid.New(), ImportAPKG, buf.Bytes(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(result.Rows[0].Tags, ",") != "biology::cells,exam" || result.Rows[1].Tags != nil {
+		t.Errorf("unexpected row tags %v, %v", result.Rows[0].Tags, result.Rows[1].Tags)
+	}
+	if len(repo.created) != 2 || strings.Join(repo.created[0].Tags, ",") != "biology::cells,exam" {
+		t.Errorf("expected the note tags on the created card, got %+v", repo.created)
+	}
+
+	if _, err := s.ImportDeck(context.Background(), uuid.New(), uuid.New(), ImportAPKG, []byte("not a zip"), true); !errors.Is(err, ErrInvalidImportFile) {
+		t.Errorf("expected %v for a broken package, got %v", ErrInvalidImportFile, err)
+	}
+}
@@ -0,0 +1,98 @@
+package content
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxQuizQuestions         = 30
+	maxQuizQuestionLength    = 1000
+	maxQuizOptionLength      = 500
+	maxQuizExplanationLength = 2000
+	minQuizOptions           = 2
+	maxQuizOptions           = 6
+)
+
+type generatedQuestion struct {
+	Question      string   `json:"question"`
+	Options       []string `json:"options"`
+	CorrectOption *int     `json:"correct_option"`
+	Explanation   string   `json:"explanation"`
+}
+
+// parseGeneratedQuiz reads the quiz of one chunk out of the model's answer, questions without a valid
+// correct option or with empty, repeated or overlong options are dropped
+func parseGeneratedQuiz(response string, chunk documentChunk) ([]QuizQuestion, error) {
+	var generated []generatedQuestion
+	if err := decodeGeneratedArray(response, &generated, "questions", "quiz"); err != nil {
+		return nil, err
+	}
+
+	questions := make([]QuizQuestion, 0, len(generated))
+	for _, q := range generated {
+		question, ok := validQuizQuestion(q)
+		if !ok {
+			continue
+		}
+		question.PageStart, question.PageEnd = chunk.PageStart, chunk.PageEnd
+		questions = append(questions, question)
+	}
+	if len(questions) == 0 {
+		return nil, errors.New("the quiz has no usable questions")
+	}
+	return questions, nil
+}
+
+func validQuizQuestion(q generatedQuestion) (QuizQuestion, bool) {
+	question := QuizQuestion{
+		Question:    strings.TrimSpace(q.Question),
+		Explanation: strings.TrimSpace(q.Explanation),
+		Options:     make([]string, len(q.Options)),
+	}
+	if question.Question == "" || utf8.RuneCountInString(question.Question) > maxQuizQuestionLength ||
+		utf8.RuneCountInString(question.Explanation) > maxQuizExplanationLength {
+		return QuizQuestion{}, false
+	}
+	if len(q.Options) < minQuizOptions || len(q.Options) > maxQuizOptions ||
+		q.CorrectOption == nil || *q.CorrectOption < 0 || *q.CorrectOption >= len(q.Options) {
+		return QuizQuestion{}, false
+	}
+
+	seen := make(map[string]bool, len(q.Options))
+	for i, option := range q.Options {
+		option = strings.TrimSpace(option)
+		key := textKey(option)
+		if option == "" || seen[key] || utf8.RuneCountInString(option) > maxQuizOptionLength {
+			return QuizQuestion{}, false
+		}
+		seen[key] = true
+		question.Options[i] = option
+	}
+	question.CorrectOption = *q.CorrectOption
+	return question, true
+}
+
+// mergeQuizzes joins the chunk quizzes in page order, drops questions asked again and keeps at most
+// maxQuizQuestions. Returns nil when no chunk produced a quiz
+func mergeQuizzes(parts [][]QuizQuestion, objectID uuid.UUID) []QuizQuestion {
+	var merged []QuizQuestion
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		for _, question := range part {
+			key := textKey(question.Question)
+			if seen[key] || len(merged) == maxQuizQuestions {
+				continue
+			}
+			seen[key] = true
+			question.ID = uuid.New()
+			question.StorageObjectID = objectID
+			question.Position = len(merged) + 1
+			merged = append(merged, question)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,142 @@
+package content
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseGeneratedQuiz(t *testing.T) {
+	chunk := documentChunk{PageStart: intPtr(1), PageEnd: intPtr(20)}
+	valid := `{"question": "What do mitochondria make?", "options": ["ATP", "DNA", "Lipids"], "correct_option": 0, "explanation": "Respiration"}`
+
+	tests := []struct {
+		name              string
+		response          string
+		expectedQuestions []string
+		expectedErr       bool
+	}{
+		{name: "plain array", response: "[" + valid + "]", expectedQuestions: []string{"What do mitochondria make?"}},
+		{name: "questions wrapper", response: `{"questions": [` + valid + `]}`, expectedQuestions: []string{"What do mitochondria make?"}},
+		{name: "quiz wrapper in a fence", response: "```json\n{\"quiz\": [" + valid + "]}\n```", expectedQuestions: []string{"What do mitochondria make?"}},
+		{
+			name: "invalid questions are dropped",
+			response: `[` + valid + `,
+				{"question": "Out of range", "options": ["a", "b"], "correct_option": 2},
+				{"question": "Negative", "options": ["a", "b"], "correct_option": -1},
+				{"question": "No answer", "options": ["a", "b"]},
+				{"question": "Duplicate options", "options": ["Yes", " yes ", "No"], "correct_option": 0},
+				{"question": "Empty option", "options": ["Yes", " ", "No"], "correct_option": 0},
+				{"question": "One option", "options": ["Only"], "correct_option": 0},
+				{"question": " ", "options": ["a", "b"], "correct_option": 0},
+				{"question": "Last option is right", "options": ["a", "b", "c"], "correct_option": 2}]`,
+			expectedQuestions: []string{"What do mitochondria make?", "Last option is right"},
+		},
+		{name: "no usable question", response: `[{"question": "Out of range", "options": ["a", "b"], "correct_option": 5}]`, expectedErr: true},
+		{name: "not json", response: "Sorry, no quiz", expectedErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions, err := parseGeneratedQuiz(tt.response, chunk)
+			if tt.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", questions)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, len(questions))
+			for i, question := range questions {
+				got[i] = question.Question
+				if *question.PageStart != 1 || *question.PageEnd != 20 {
+					t.Errorf("question %d lost the chunk pages", i)
+				}
+			}
+			if strings.Join(got, "|") != strings.Join(tt.expectedQuestions, "|") {
+				t.Errorf("questions = %q, want %q", got, tt.expectedQuestions)
+			}
+		})
+	}
+}
+
+func TestValidQuizQuestion(t *testing.T) {
+	correct := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		question generatedQuestion
+		valid    bool
+	}{
+		{"valid", generatedQuestion{Question: " Q ", Options: []string{" a ", "b"}, CorrectOption: correct(1)}, true},
+		{"correct option at the end", generatedQuestion{Question: "Q", Options: []string{"a", "b", "c", "d", "e", "f"}, CorrectOption: correct(5)}, true},
+		{"correct option out of range", generatedQuestion{Question: "Q", Options: []string{"a", "b"}, CorrectOption: correct(2)}, false},
+		{"negative correct option", generatedQuestion{Question: "Q", Options: []string{"a", "b"}, CorrectOption: correct(-1)}, false},
+		{"no correct option", generatedQuestion{Question: "Q", Options: []string{"a", "b"}}, false},
+		{"too many options", generatedQuestion{Question: "Q", Options: []string{"a", "b", "c", "d", "e", "f", "g"}, CorrectOption: correct(0)}, false},
+		{"duplicate options", generatedQuestion{Question: "Q", Options: []string{"Mitosis", "mitosis "}, CorrectOption: correct(0)}, false},
+		{"empty option", generatedQuestion{Question: "Q", Options: []string{"a", ""}, CorrectOption: correct(0)}, false},
+		{"overlong option", generatedQuestion{Question: "Q", Options: []string{"a", strings.Repeat("b", maxQuizOptionLength+1)}, CorrectOption: correct(0)}, false},
+		{"overlong explanation", generatedQuestion{Question: "Q", Options: []string{"a", "b"}, CorrectOption: correct(0), Explanation: strings.Repeat("e", maxQuizExplanationLength+1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question, ok := validQuizQuestion(tt.question)
+			if ok != tt.valid {
+				t.Fatalf("validQuizQuestion() ok = %v, want %v", ok, tt.valid)
+			}
+			if ok && (question.Question != strings.TrimSpace(tt.question.Question) || question.Options[0] != strings.TrimSpace(tt.question.Options[0]) ||
+				question.CorrectOption != *tt.question.CorrectOption) {
+				t.Errorf("unexpected question %+v", question)
+			}
+		})
+	}
+}
+
+func TestMergeQuizzes(t *testing.T) {
+	objectID := uuid.New()
+	questions := func(prefix string, n int) []QuizQuestion {
+		part := make([]QuizQuestion, n)
+		for i := range part {
+			part[i] = QuizQuestion{Question: fmt.Sprintf("%s %d", prefix, i+1)}
+		}
+		return part
+	}
+
+	t.Run("repeated questions are dropped", func(t *testing.T) {
+		merged := mergeQuizzes([][]QuizQuestion{
+			{{Question: "What is ATP?"}, {Question: "Define osmosis"}},
+			nil,
+			{{Question: "what is ATP?"}, {Question: "What is a ribosome?"}},
+		}, objectID)
+		got := make([]string, len(merged))
+		for i, question := range merged {
+			got[i] = question.Question
+			if question.Position != i+1 || question.StorageObjectID != objectID || question.ID == uuid.Nil {
+				t.Errorf("question %d = %+v, expected position %d of the object with an id", i, question, i+1)
+			}
+		}
+		if strings.Join(got, "|") != "What is ATP?|Define osmosis|What is a ribosome?" {
+			t.Errorf("unexpected merge %q", got)
+		}
+	})
+
+	t.Run("capped at maxQuizQuestions", func(t *testing.T) {
+		merged := mergeQuizzes([][]QuizQuestion{questions("first", 20), questions("second", 20)}, objectID)
+		if len(merged) != maxQuizQuestions {
+			t.Fatalf("expected %d questions, got %d", maxQuizQuestions, len(merged))
+		}
+		if merged[maxQuizQuestions-1].Question != "second 10" {
+			t.Errorf("expected the earliest questions to be kept, the last is %q", merged[maxQuizQuestions-1].Question)
+		}
+	})
+
+	t.Run("no quizzes", func(t *testing.T) {
+		if merged := mergeQuizzes([][]QuizQuestion{nil, nil}, objectID); merged != nil {
+			t.Errorf("expected nil, got %+v", merged)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxGeneratedCardCount = 200
+	maxLanguageLength     = 50
+	maxFocusTopics        = 10
+	maxFocusTopicLength   = 100
+)
+
+var (
+	// ErrInvalidGenerationOptions is returned when a regeneration asks for options out of range
+	ErrInvalidGenerationOptions = errors.New("invalid generation options")
+	// ErrGenerationInProgress is returned when the file already has a job that did not finish
+	ErrGenerationInProgress = errors.New("flashcard generation already in progress")
+)
+
+// RegenerateFlashcards queues a new generation run over the file behind a resource. The current cards stay
+// available until the run saves its set, which then supersedes them; deck cards copied from them are kept.
+// Only the uploader of the resource or an admin can regenerate its cards
+func (s *ContentService) RegenerateFlashcards(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts GenerationOptions) (GenerationJob, error) {
+	if err := opts.normalize(); err != nil {
+		return GenerationJob{}, err
+	}
+
+	objectID, err := s.contentRepository.GetRegenerationObject(ctx, resourceID, userID, asAdmin)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return GenerationJob{}, errors.New("file resource not found")
+		}
+		return GenerationJob{}, err
+	}
+
+	job, err := s.contentRepository.QueueGenerationJob(ctx, objectID, opts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return GenerationJob{}, ErrGenerationInProgress
+		}
+		return GenerationJob{}, err
+	}
+
+	if err := s.queue.Publish(ctx, objectID); err != nil {
+		reason := "failed to queue regeneration: " + err.Error()
+		if failErr := s.contentRepository.UpdateGenerationJobStatus(ctx, job.ID, GenerationFailed, &reason); failErr != nil {
+			slog.Error("failed to mark generation job as failed", "job id", job.ID, "err", failErr)
+		}
+		return GenerationJob{}, fmt.Errorf("failed to queue regeneration: %w", err)
+	}
+	return job, nil
+}
+
+// normalize trims the options and checks they are in range
+func (o *GenerationOptions) normalize() error {
+	// 0 leaves the number of cards to the model, as for uploads
+	if o.CardCount < 0 || o.CardCount > maxGeneratedCardCount {
+		return fmt.Errorf("%w: card_count must be between 0 and %d, 0 lets the model decide", ErrInvalidGenerationOptions, maxGeneratedCardCount)
+	}
+
+	switch o.Difficulty {
+	case "", DifficultyEasy, DifficultyMedium, DifficultyHard:
+	default:
+		return fmt.Errorf("%w: difficulty must be easy, medium or hard", ErrInvalidGenerationOptions)
+	}
+
+	switch o.CardType {
+	case "", GeneratedQuestion, GeneratedDefinition, GeneratedCloze:
+	default:
+		return fmt.Errorf("%w: card_type must be question, definition or cloze", ErrInvalidGenerationOptions)
+	}
+
+	o.Language = strings.TrimSpace(o.Language)
+	if utf8.RuneCountInString(o.Language) > maxLanguageLength {
+		return fmt.Errorf("%w: language is longer than %d characters", ErrInvalidGenerationOptions, maxLanguageLength)
+	}
+
+	topics := make([]string, 0, len(o.FocusTopics))
+	for _, topic := range o.FocusTopics {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+		if utf8.RuneCountInString(topic) > maxFocusTopicLength {
+			return fmt.Errorf("%w: focus topics cannot be longer than %d characters", ErrInvalidGenerationOptions, maxFocusTopicLength)
+		}
+		topics = append(topics, topic)
+	}
+	if len(topics) > maxFocusTopics {
+		return fmt.Errorf("%w: at most %d focus topics", ErrInvalidGenerationOptions, maxFocusTopics)
+	}
+	o.FocusTopics = topics
+	if len(topics) == 0 {
+		o.FocusTopics = nil
+	}
+	return nil
+}
+
+// forChunk spreads the requested card count over the chunks of a document, the merged cards are cut
+// back to CardCount afterwards
+func (o GenerationOptions) forChunk(chunks int) GenerationOptions {
+	if o.CardCount > 0 && chunks > 1 {
+		o.CardCount = (o.CardCount + chunks - 1) / chunks
+	}
+	return o
+}
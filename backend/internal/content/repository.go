@@ -2,6 +2,7 @@ package content
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -20,20 +21,9 @@ func NewContentRepositoryPostgres(p *pgxpool.Pool) *ContentRepositoryPostgres {
 	}
 }
 
-// should do the batch insert
-func (r *ContentRepositoryPostgres) CreateCardsFromObject(ctx context.Context, cards []Flashcard) error {
-	query := `INSERT INTO flashcards(id, storage_object_id,front, back) VALUES ($1, $2, $3, $4)`
-
-	batch := pgx.Batch{}
-	for _, card := range cards {
-		batch.Queue(query, card.ID, card.ObjectID, card.Front, card.Back)
-	}
-	err := r.pool.SendBatch(ctx, &batch).Close()
-	return err
-}
 func (r *ContentRepositoryPostgres) ListCardsFromObjects(ctx context.Context, ids []uuid.UUID) ([]Flashcard, error) {
 
-	query := `SELECT id, storage_object_id, front, back FROM flashcards WHERE storage_object_id = ANY ($1)`
+	query := `SELECT id, storage_object_id, front, back, page_start, page_end FROM flashcards WHERE storage_object_id = ANY ($1) AND superseded_at IS NULL ORDER BY page_start NULLS LAST, created_at`
 	cards := make([]Flashcard, 0)
 	rows, err := r.pool.Query(ctx, query, ids)
 	if err != nil {
@@ -44,7 +34,7 @@ func (r *ContentRepositoryPostgres) ListCardsFromObjects(ctx context.Context, id
 
 	for rows.Next() {
 		var card Flashcard
-		err = rows.Scan(&card.ID, &card.ObjectID, &card.Front, &card.Back)
+		err = rows.Scan(&card.ID, &card.ObjectID, &card.Front, &card.Back, &card.PageStart, &card.PageEnd)
 		if err != nil {
 			return []Flashcard{}, err
 		}
@@ -54,23 +44,86 @@ func (r *ContentRepositoryPostgres) ListCardsFromObjects(ctx context.Context, id
 	return cards, nil
 }
 
-func (r *ContentRepositoryPostgres) isPdf(ctx context.Context, id uuid.UUID) string {
-	query := `SELECT file_type from storage_objects WHERE id=$1`
-	var fileType string
-	err := r.pool.QueryRow(ctx, query, id).Scan(&fileType)
+// ListSummariesFromObjects returns the generated summaries of the objects
+func (r *ContentRepositoryPostgres) ListSummariesFromObjects(ctx context.Context, ids []uuid.UUID) ([]ResourceSummary, error) {
+	query := `
+		SELECT storage_object_id, key_points, glossary, created_at, updated_at
+		FROM resource_summaries
+		WHERE storage_object_id = ANY ($1)
+	`
+	rows, err := r.pool.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("ListSummariesFromObjects query: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := make([]ResourceSummary, 0)
+	for rows.Next() {
+		var summary ResourceSummary
+		if err := rows.Scan(&summary.StorageObjectID, &summary.KeyPoints, &summary.Glossary, &summary.CreatedAt, &summary.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("ListSummariesFromObjects scan: %w", err)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// ListQuizzesFromObjects returns the generated quiz questions of the objects in quiz order
+func (r *ContentRepositoryPostgres) ListQuizzesFromObjects(ctx context.Context, ids []uuid.UUID) ([]QuizQuestion, error) {
+	query := `
+		SELECT id, storage_object_id, position, question, options, correct_option, explanation, page_start, page_end
+		FROM resource_quiz_questions
+		WHERE storage_object_id = ANY ($1)
+		ORDER BY storage_object_id, position
+	`
+	rows, err := r.pool.Query(ctx, query, ids)
 	if err != nil {
-		return "pdf"
+		return nil, fmt.Errorf("ListQuizzesFromObjects query: %w", err)
+	}
+	defer rows.Close()
+
+	questions := make([]QuizQuestion, 0)
+	for rows.Next() {
+		var q QuizQuestion
+		if err := rows.Scan(&q.ID, &q.StorageObjectID, &q.Position, &q.Question, &q.Options, &q.CorrectOption, &q.Explanation, &q.PageStart, &q.PageEnd); err != nil {
+			return nil, fmt.Errorf("ListQuizzesFromObjects scan: %w", err)
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+// HasSummaryAndQuiz reports whether a previous run already saved both the summary and the quiz of an object
+func (r *ContentRepositoryPostgres) HasSummaryAndQuiz(ctx context.Context, objectID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM resource_summaries WHERE storage_object_id = $1)
+		   AND EXISTS (SELECT 1 FROM resource_quiz_questions WHERE storage_object_id = $1)
+	`
+	var exists bool
+	if err := r.pool.QueryRow(ctx, query, objectID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("HasSummaryAndQuiz: %w", err)
 	}
-	return fileType
+	return exists, nil
+}
 
+// GetGenerationSource returns the file type of a storage object and its url, for links the url is the page
+func (r *ContentRepositoryPostgres) GetGenerationSource(ctx context.Context, id uuid.UUID) (string, string, error) {
+	query := `SELECT file_type, url FROM storage_objects WHERE id = $1`
+	var fileType, url string
+	if err := r.pool.QueryRow(ctx, query, id).Scan(&fileType, &url); err != nil {
+		return "", "", fmt.Errorf("GetGenerationSource: %w", err)
+	}
+	return fileType, url, nil
 }
 
 // AddCardToUserDeck adds an auto-generated flashcard to user's personal deck
 func (r *ContentRepositoryPostgres) AddCardToUserDeck(ctx context.Context, userID, weekID, flashcardID uuid.UUID) error {
 	// First, get the card content from the flashcards table
 	var front, back string
-	getCardQuery := `SELECT front, back FROM flashcards WHERE id = $1`
-	err := r.pool.QueryRow(ctx, getCardQuery, flashcardID).Scan(&front, &back)
+	var cardType CardType
+	var content *CardContent
+	getCardQuery := `SELECT front, back, card_type, content FROM flashcards WHERE id = $1`
+	err := r.pool.QueryRow(ctx, getCardQuery, flashcardID).Scan(&front, &back, &cardType, &content)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return fmt.Errorf("flashcard not found")
@@ -80,38 +133,54 @@ func (r *ContentRepositoryPostgres) AddCardToUserDeck(ctx context.Context, userI
 
 	// Insert into user_deck_cards
 	insertQuery := `
-		INSERT INTO user_deck_cards (id, user_id, week_id, source_flashcard_id, front, back, is_custom)
-		VALUES ($1, $2, $3, $4, $5, $6, false)
+		INSERT INTO user_deck_cards (id, user_id, week_id, source_flashcard_id, front, back, is_custom, card_type, content)
+		VALUES ($1, $2, $3, $4, $5, $6, false, $7, $8)
 	`
-	_, err = r.pool.Exec(ctx, insertQuery, uuid.New(), userID, weekID, flashcardID, front, back)
+	_, err = r.pool.Exec(ctx, insertQuery, uuid.New(), userID, weekID, flashcardID, front, back, cardType, content)
 	return err
 }
 
-// CreateCustomCardInDeck creates a custom flashcard in user's deck
-func (r *ContentRepositoryPostgres) CreateCustomCardInDeck(ctx context.Context, userID, weekID uuid.UUID, front, back string) (UserDeckCard, error) {
-	card := UserDeckCard{
-		ID:        uuid.New(),
-		UserID:    userID,
-		WeekID:    weekID,
-		Front:     front,
-		Back:      back,
-		IsCustom:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+// CreateCustomCardsInDeck inserts custom cards into user's deck in one transaction
+func (r *ContentRepositoryPostgres) CreateCustomCardsInDeck(ctx context.Context, cards []UserDeckCard) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer func() { _ = tx.Rollback(ctx) }()
 
 	query := `
-		INSERT INTO user_deck_cards (id, user_id, week_id, source_flashcard_id, front, back, is_custom)
-		VALUES ($1, $2, $3, NULL, $4, $5, true)
-		RETURNING created_at, updated_at
+		INSERT INTO user_deck_cards (id, user_id, week_id, source_flashcard_id, front, back, is_custom,
+		                             card_type, content, note_id, tags, created_at, updated_at)
+		VALUES ($1, $2, $3, NULL, $4, $5, true, $6, $7, $8, COALESCE($9::text[], '{}'), $10, $11)
 	`
-	err := r.pool.QueryRow(ctx, query, card.ID, card.UserID, card.WeekID, card.Front, card.Back).
-		Scan(&card.CreatedAt, &card.UpdatedAt)
-	if err != nil {
-		return UserDeckCard{}, err
+	batch := pgx.Batch{}
+	for _, card := range cards {
+		batch.Queue(query, card.ID, card.UserID, card.WeekID, card.Front, card.Back,
+			card.CardType, card.Content, card.NoteID, card.Tags, card.CreatedAt, card.UpdatedAt)
+	}
+	if err := tx.SendBatch(ctx, &batch).Close(); err != nil {
+		return fmt.Errorf("CreateCustomCardsInDeck insert: %w", err)
 	}
 
-	return card, nil
+	return tx.Commit(ctx)
+}
+
+// GetVisibleStorageObjectFileType returns the file type of an object the user uploaded, owns a resource of
+// or can see as a resource of a week. pgx.ErrNoRows if it does not exist or the user cannot see it
+func (r *ContentRepositoryPostgres) GetVisibleStorageObjectFileType(ctx context.Context, id, userID uuid.UUID) (string, error) {
+	query := `
+		SELECT so.file_type FROM storage_objects so
+		WHERE so.id = $1 AND (
+			so.uploaded_by = $2
+			OR EXISTS (SELECT 1 FROM resources r JOIN resource_owners ro ON ro.resource_id = r.id
+			           WHERE r.storage_object_id = so.id AND ro.user_id = $2)
+			OR EXISTS (SELECT 1 FROM resources r JOIN week_resources wr ON wr.resource_id = r.id
+			           WHERE r.storage_object_id = so.id)
+		)
+	`
+	var fileType string
+	err := r.pool.QueryRow(ctx, query, id, userID).Scan(&fileType)
+	return fileType, err
 }
 
 // RemoveCardFromUserDeck removes a card from user's deck
@@ -127,14 +196,51 @@ func (r *ContentRepositoryPostgres) RemoveCardFromUserDeck(ctx context.Context,
 	return nil
 }
 
+// userDeckCardColumns is the column list scanned by scanUserDeckCard
+// and expects user_deck_cards to be aliased as c. The source file and pages come from the generated card
+const userDeckCardColumns = `c.id, c.user_id, c.week_id, c.source_flashcard_id, c.front, c.back, c.is_custom,
+		       c.review_count, c.difficulty_rating, c.created_at, c.updated_at,
+		       c.last_reviewed_at, c.due_at, c.ease_factor, c.interval_days, c.repetitions, c.lapses, c.stability, c.fsrs_difficulty,
+		       c.card_type, c.content, c.note_id, c.tags,
+		       src.storage_object_id, src.page_start, src.page_end`
+
+// userDeckCardSource joins the generated card a deck card was copied from, every query selecting
+// userDeckCardColumns from user_deck_cards c needs it
+const userDeckCardSource = `LEFT JOIN flashcards src ON src.id = c.source_flashcard_id`
+
+// userDeckCardDest returns the scan destinations matching userDeckCardColumns
+func userDeckCardDest(card *UserDeckCard) []any {
+	return []any{
+		&card.ID, &card.UserID, &card.WeekID, &card.SourceFlashcardID,
+		&card.Front, &card.Back, &card.IsCustom,
+		&card.ReviewCount, &card.DifficultyRating, &card.CreatedAt, &card.UpdatedAt,
+		&card.LastReviewedAt, &card.DueAt, &card.EaseFactor, &card.IntervalDays,
+		&card.Repetitions, &card.Lapses, &card.Stability, &card.FSRSDifficulty,
+		&card.CardType, &card.Content, &card.NoteID, &card.Tags,
+		&card.SourceObjectID, &card.PageStart, &card.PageEnd,
+	}
+}
+
+func scanUserDeckCard(row pgx.Row) (UserDeckCard, error) {
+	var card UserDeckCard
+	err := row.Scan(userDeckCardDest(&card)...)
+	return card, err
+}
+
+// dueCardColumns adds the module and week of a card to userDeckCardColumns, scanned by dueCardDest
+const dueCardColumns = userDeckCardColumns + `, mr.module_id, m.name, w.module_run_id, w.number`
+
+func dueCardDest(card *DueCard) []any {
+	return append(userDeckCardDest(&card.UserDeckCard), &card.ModuleID, &card.ModuleName, &card.ModuleRunID, &card.WeekNumber)
+}
+
 // GetUserDeckForWeek retrieves all cards in user's deck for a specific week
 func (r *ContentRepositoryPostgres) GetUserDeckForWeek(ctx context.Context, userID, weekID uuid.UUID) ([]UserDeckCard, error) {
-	query := `
-		SELECT id, user_id, week_id, source_flashcard_id, front, back, is_custom, 
-		       last_reviewed_at, review_count, difficulty_rating, created_at, updated_at
-		FROM user_deck_cards
-		WHERE user_id = $1 AND week_id = $2
-		ORDER BY created_at DESC
+	query := `SELECT ` + userDeckCardColumns + `
+		FROM user_deck_cards c
+		` + userDeckCardSource + `
+		WHERE c.user_id = $1 AND c.week_id = $2
+		ORDER BY c.created_at DESC
 	`
 	rows, err := r.pool.Query(ctx, query, userID, weekID)
 	if err != nil {
@@ -144,12 +250,7 @@ func (r *ContentRepositoryPostgres) GetUserDeckForWeek(ctx context.Context, user
 
 	cards := make([]UserDeckCard, 0)
 	for rows.Next() {
-		var card UserDeckCard
-		err := rows.Scan(
-			&card.ID, &card.UserID, &card.WeekID, &card.SourceFlashcardID,
-			&card.Front, &card.Back, &card.IsCustom, &card.LastReviewedAt,
-			&card.ReviewCount, &card.DifficultyRating, &card.CreatedAt, &card.UpdatedAt,
-		)
+		card, err := scanUserDeckCard(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -159,20 +260,172 @@ func (r *ContentRepositoryPostgres) GetUserDeckForWeek(ctx context.Context, user
 	return cards, rows.Err()
 }
 
+// ListDueCards returns the user's cards that are due for review across every week, most overdue first,
+// followed by cards that were never reviewed. Review and new cards are limited separately.
+func (r *ContentRepositoryPostgres) ListDueCards(ctx context.Context, userID uuid.UUID, filter DueCardsFilter) ([]DueCard, error) {
+	query := `
+		SELECT * FROM (
+			(SELECT ` + dueCardColumns + `
+			FROM user_deck_cards c
+			` + userDeckCardSource + `
+			JOIN weeks w ON w.id = c.week_id
+			JOIN module_runs mr ON mr.id = w.module_run_id
+			JOIN modules m ON m.id = mr.module_id
+			WHERE c.user_id = $1 AND c.due_at <= $2
+			  AND ($3::uuid IS NULL OR mr.module_id = $3)
+			  AND ($4::uuid IS NULL OR w.module_run_id = $4)
+			ORDER BY c.due_at ASC
+			LIMIT $5)
+			UNION ALL
+			(SELECT ` + dueCardColumns + `
+			FROM user_deck_cards c
+			` + userDeckCardSource + `
+			JOIN weeks w ON w.id = c.week_id
+			JOIN module_runs mr ON mr.id = w.module_run_id
+			JOIN modules m ON m.id = mr.module_id
+			WHERE c.user_id = $1 AND c.due_at IS NULL
+			  AND ($3::uuid IS NULL OR mr.module_id = $3)
+			  AND ($4::uuid IS NULL OR w.module_run_id = $4)
+			ORDER BY c.created_at ASC
+			LIMIT $6)
+		) due
+		ORDER BY due.due_at ASC NULLS LAST, due.created_at ASC
+	`
+	rows, err := r.pool.Query(ctx, query, userID, filter.Now, filter.ModuleID, filter.ModuleRunID, filter.ReviewLimit, filter.NewLimit)
+	if err != nil {
+		return nil, fmt.Errorf("ListDueCards query: %w", err)
+	}
+	return scanDueCards(rows)
+}
+
+// ListDeckCardsForExport returns every card of the user's deck for a single week or a whole module run,
+// ordered by week and creation time
+func (r *ContentRepositoryPostgres) ListDeckCardsForExport(ctx context.Context, userID uuid.UUID, weekID, moduleRunID *uuid.UUID) ([]DueCard, error) {
+	query := `
+		SELECT ` + dueCardColumns + `
+		FROM user_deck_cards c
+		` + userDeckCardSource + `
+		JOIN weeks w ON w.id = c.week_id
+		JOIN module_runs mr ON mr.id = w.module_run_id
+		JOIN modules m ON m.id = mr.module_id
+		WHERE c.user_id = $1
+		  AND ($2::uuid IS NULL OR c.week_id = $2)
+		  AND ($3::uuid IS NULL OR w.module_run_id = $3)
+		ORDER BY w.number ASC, c.created_at ASC
+	`
+	rows, err := r.pool.Query(ctx, query, userID, weekID, moduleRunID)
+	if err != nil {
+		return nil, fmt.Errorf("ListDeckCardsForExport query: %w", err)
+	}
+	return scanDueCards(rows)
+}
+
+func scanDueCards(rows pgx.Rows) ([]DueCard, error) {
+	defer rows.Close()
+
+	cards := make([]DueCard, 0)
+	for rows.Next() {
+		var card DueCard
+		if err := rows.Scan(dueCardDest(&card)...); err != nil {
+			return nil, fmt.Errorf("scan due card: %w", err)
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
+
+// SetUserDeckCardTags replaces the tags of a card in user's deck
+func (r *ContentRepositoryPostgres) SetUserDeckCardTags(ctx context.Context, cardID, userID uuid.UUID, tags []string) error {
+	query := `UPDATE user_deck_cards SET tags = $3, updated_at = NOW() WHERE id = $1 AND user_id = $2`
+	result, err := r.pool.Exec(ctx, query, cardID, userID, tags)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ListUserTags returns every tag used in the user's decks, most used first
+func (r *ContentRepositoryPostgres) ListUserTags(ctx context.Context, userID uuid.UUID) ([]TagCount, error) {
+	query := `
+		SELECT tag, COUNT(*)
+		FROM user_deck_cards c, unnest(c.tags) AS tag
+		WHERE c.user_id = $1
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag ASC
+	`
+	rows, err := r.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ListUserTags query: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make([]TagCount, 0)
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("ListUserTags scan: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// SearchUserDeckCards runs a full-text search over the fronts and backs of all the user's decks, best
+// matches first. An empty query only filters by tag and module. Highlights use the ts_headline options
+// passed in, the back is cut down to the fragments around the matches.
+func (r *ContentRepositoryPostgres) SearchUserDeckCards(ctx context.Context, userID uuid.UUID, search CardSearchQuery, frontOptions, backOptions string) ([]CardSearchResult, error) {
+	query := `
+		WITH q AS (
+			SELECT CASE WHEN $2 = '' THEN NULL ELSE websearch_to_tsquery('english', $2) END AS query
+		)
+		SELECT ` + dueCardColumns + `,
+		       COALESCE(ts_rank(c.search_vector, q.query), 0) AS rank,
+		       COALESCE(ts_headline('english', c.front, q.query, $5), c.front),
+		       COALESCE(ts_headline('english', c.back, q.query, $6), c.back)
+		FROM user_deck_cards c
+		` + userDeckCardSource + `
+		JOIN weeks w ON w.id = c.week_id
+		JOIN module_runs mr ON mr.id = w.module_run_id
+		JOIN modules m ON m.id = mr.module_id
+		CROSS JOIN q
+		WHERE c.user_id = $1
+		  AND (q.query IS NULL OR c.search_vector @@ q.query)
+		  AND ($3 = '' OR c.tags @> ARRAY[$3]::text[])
+		  AND ($4::uuid IS NULL OR mr.module_id = $4)
+		ORDER BY rank DESC, c.updated_at DESC
+		LIMIT $7 OFFSET $8
+	`
+	rows, err := r.pool.Query(ctx, query, userID, search.Query, search.Tag, search.ModuleID,
+		frontOptions, backOptions, search.Page.Limit, search.Page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("SearchUserDeckCards query: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]CardSearchResult, 0)
+	for rows.Next() {
+		var result CardSearchResult
+		dest := append(dueCardDest(&result.DueCard), &result.Rank, &result.FrontHighlight, &result.BackHighlight)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("SearchUserDeckCards scan: %w", err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
 // GetUserDeckCard retrieves a single card from user's deck
 func (r *ContentRepositoryPostgres) GetUserDeckCard(ctx context.Context, cardID, userID uuid.UUID) (UserDeckCard, error) {
-	query := `
-		SELECT id, user_id, week_id, source_flashcard_id, front, back, is_custom, 
-		       last_reviewed_at, review_count, difficulty_rating, created_at, updated_at
-		FROM user_deck_cards
-		WHERE id = $1 AND user_id = $2
+	query := `SELECT ` + userDeckCardColumns + `
+		FROM user_deck_cards c
+		` + userDeckCardSource + `
+		WHERE c.id = $1 AND c.user_id = $2
 	`
-	var card UserDeckCard
-	err := r.pool.QueryRow(ctx, query, cardID, userID).Scan(
-		&card.ID, &card.UserID, &card.WeekID, &card.SourceFlashcardID,
-		&card.Front, &card.Back, &card.IsCustom, &card.LastReviewedAt,
-		&card.ReviewCount, &card.DifficultyRating, &card.CreatedAt, &card.UpdatedAt,
-	)
+	card, err := scanUserDeckCard(r.pool.QueryRow(ctx, query, cardID, userID))
 	if err != nil {
 		return UserDeckCard{}, err
 	}
@@ -186,7 +439,7 @@ func (r *ContentRepositoryPostgres) UpdateUserDeckCard(ctx context.Context, card
 		return nil // Nothing to update
 	}
 
-	query := `UPDATE user_deck_cards SET updated_at = NOW()`
+	query := `UPDATE user_deck_cards SET updated_at = NOW(), edited_at = NOW()`
 	args := []interface{}{}
 	argPos := 1
 
@@ -214,24 +467,224 @@ func (r *ContentRepositoryPostgres) UpdateUserDeckCard(ctx context.Context, card
 	return nil
 }
 
-// RecordCardReview records a review session for a card
-func (r *ContentRepositoryPostgres) RecordCardReview(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error {
-	query := `
+// RecordCardReview stores the card's next schedule and appends the review to its history in one transaction.
+// The schedule is only stored if the card still has reviewCount reviews, the count it was computed from,
+// a review that lost the race to a concurrent one returns ErrReviewConflict
+func (r *ContentRepositoryPostgres) RecordCardReview(ctx context.Context, review CardReview, schedule CardSchedule, reviewCount int) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	updateQuery := `
 		UPDATE user_deck_cards 
-		SET last_reviewed_at = NOW(),
+		SET last_reviewed_at = $1,
 		    review_count = review_count + 1,
-		    difficulty_rating = $1,
+		    difficulty_rating = $2,
+		    due_at = $3,
+		    ease_factor = $4,
+		    interval_days = $5,
+		    repetitions = $6,
+		    lapses = $7,
+		    stability = $8,
+		    fsrs_difficulty = $9,
 		    updated_at = NOW()
-		WHERE id = $2 AND user_id = $3
+		WHERE id = $10 AND user_id = $11 AND review_count = $12
 	`
-	result, err := r.pool.Exec(ctx, query, difficultyRating, cardID, userID)
+	result, err := tx.Exec(ctx, updateQuery,
+		schedule.LastReviewedAt, review.DifficultyRating, schedule.DueAt, schedule.EaseFactor, schedule.IntervalDays,
+		schedule.Repetitions, schedule.Lapses, schedule.Stability, schedule.FSRSDifficulty,
+		review.CardID, review.UserID, reviewCount,
+	)
 	if err != nil {
 		return err
 	}
 	if result.RowsAffected() == 0 {
+		var exists bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM user_deck_cards WHERE id = $1 AND user_id = $2)`, review.CardID, review.UserID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrReviewConflict
+		}
 		return pgx.ErrNoRows
 	}
-	return nil
+
+	insertQuery := `
+		INSERT INTO card_reviews (id, card_id, user_id, week_id, scheduler, difficulty_rating, recalled,
+		                          response_time_ms, previous_interval_days, next_interval_days, reviewed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	_, err = tx.Exec(ctx, insertQuery,
+		review.ID, review.CardID, review.UserID, review.WeekID, review.Scheduler, review.DifficultyRating, review.Recalled,
+		review.ResponseTimeMs, review.PreviousIntervalDays, review.NextIntervalDays, review.ReviewedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert card review: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+const cardReviewColumns = `id, card_id, user_id, week_id, scheduler, difficulty_rating, recalled,
+		       response_time_ms, previous_interval_days, next_interval_days, reviewed_at`
+
+func scanCardReviews(rows pgx.Rows) ([]CardReview, error) {
+	defer rows.Close()
+	reviews := make([]CardReview, 0)
+	for rows.Next() {
+		var review CardReview
+		err := rows.Scan(
+			&review.ID, &review.CardID, &review.UserID, &review.WeekID, &review.Scheduler,
+			&review.DifficultyRating, &review.Recalled, &review.ResponseTimeMs,
+			&review.PreviousIntervalDays, &review.NextIntervalDays, &review.ReviewedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+// ListCardReviews pages through the review history of one card, newest first
+func (r *ContentRepositoryPostgres) ListCardReviews(ctx context.Context, cardID, userID uuid.UUID, page Page) ([]CardReview, error) {
+	query := `SELECT ` + cardReviewColumns + `
+		FROM card_reviews
+		WHERE card_id = $1 AND user_id = $2
+		ORDER BY reviewed_at DESC
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := r.pool.Query(ctx, query, cardID, userID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("ListCardReviews query: %w", err)
+	}
+	return scanCardReviews(rows)
+}
+
+// ListUserReviews pages through every review the user has made, newest first
+func (r *ContentRepositoryPostgres) ListUserReviews(ctx context.Context, userID uuid.UUID, page Page) ([]CardReview, error) {
+	query := `SELECT ` + cardReviewColumns + `
+		FROM card_reviews
+		WHERE user_id = $1
+		ORDER BY reviewed_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.pool.Query(ctx, query, userID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("ListUserReviews query: %w", err)
+	}
+	return scanCardReviews(rows)
+}
+
+// ListReviewDays counts the user's reviews per calendar day in the IANA time zone over their whole history,
+// oldest first. reviewed_at is stored in UTC.
+func (r *ContentRepositoryPostgres) ListReviewDays(ctx context.Context, userID uuid.UUID, timeZone string) ([]DailyReviewCount, error) {
+	query := `
+		SELECT to_char((reviewed_at AT TIME ZONE 'UTC' AT TIME ZONE $2)::date, 'YYYY-MM-DD') AS day, COUNT(*)
+		FROM card_reviews
+		WHERE user_id = $1
+		GROUP BY day
+		ORDER BY day ASC
+	`
+	rows, err := r.pool.Query(ctx, query, userID, timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("ListReviewDays query: %w", err)
+	}
+	defer rows.Close()
+
+	days := make([]DailyReviewCount, 0)
+	for rows.Next() {
+		var day DailyReviewCount
+		if err := rows.Scan(&day.Date, &day.Count); err != nil {
+			return nil, fmt.Errorf("ListReviewDays scan: %w", err)
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// GetModuleRetention computes the recall rate per module since the given time. First reviews of
+// new cards are left out since there was nothing to retain yet.
+func (r *ContentRepositoryPostgres) GetModuleRetention(ctx context.Context, userID uuid.UUID, since time.Time) ([]ModuleRetention, error) {
+	query := `
+		SELECT m.id, m.name, COUNT(*) AS reviews, COUNT(*) FILTER (WHERE cr.recalled) AS recalled
+		FROM card_reviews cr
+		JOIN weeks w ON w.id = cr.week_id
+		JOIN module_runs mr ON mr.id = w.module_run_id
+		JOIN modules m ON m.id = mr.module_id
+		WHERE cr.user_id = $1 AND cr.reviewed_at >= $2 AND cr.previous_interval_days > 0
+		GROUP BY m.id, m.name
+		ORDER BY m.name
+	`
+	rows, err := r.pool.Query(ctx, query, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("GetModuleRetention query: %w", err)
+	}
+	defer rows.Close()
+
+	retention := make([]ModuleRetention, 0)
+	for rows.Next() {
+		var module ModuleRetention
+		if err := rows.Scan(&module.ModuleID, &module.ModuleName, &module.Reviews, &module.Recalled); err != nil {
+			return nil, fmt.Errorf("GetModuleRetention scan: %w", err)
+		}
+		if module.Reviews > 0 {
+			module.RetentionRate = float64(module.Recalled) / float64(module.Reviews)
+		}
+		retention = append(retention, module)
+	}
+	return retention, rows.Err()
+}
+
+// GetCardMaturity counts the user's cards that were never reviewed, are still young or are mature
+func (r *ContentRepositoryPostgres) GetCardMaturity(ctx context.Context, userID uuid.UUID, matureIntervalDays int) (CardMaturity, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE due_at IS NULL),
+			COUNT(*) FILTER (WHERE due_at IS NOT NULL AND interval_days < $2),
+			COUNT(*) FILTER (WHERE due_at IS NOT NULL AND interval_days >= $2)
+		FROM user_deck_cards
+		WHERE user_id = $1
+	`
+	var maturity CardMaturity
+	err := r.pool.QueryRow(ctx, query, userID, matureIntervalDays).Scan(&maturity.New, &maturity.Young, &maturity.Mature)
+	if err != nil {
+		return CardMaturity{}, err
+	}
+	return maturity, nil
+}
+
+// GetStudySettings returns the user's scheduler settings, falling back to SM-2 when none are stored
+func (r *ContentRepositoryPostgres) GetStudySettings(ctx context.Context, userID uuid.UUID) (StudySettings, error) {
+	settings := StudySettings{UserID: userID}
+	query := `SELECT scheduler, desired_retention FROM user_study_settings WHERE user_id = $1`
+	err := r.pool.QueryRow(ctx, query, userID).Scan(&settings.Scheduler, &settings.DesiredRetention)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			settings.Scheduler = SchedulerSM2
+			settings.DesiredRetention = defaultDesiredRetention
+			return settings, nil
+		}
+		return StudySettings{}, err
+	}
+	return settings, nil
+}
+
+// UpsertStudySettings creates or replaces the user's scheduler settings
+func (r *ContentRepositoryPostgres) UpsertStudySettings(ctx context.Context, settings StudySettings) error {
+	query := `
+		INSERT INTO user_study_settings (user_id, scheduler, desired_retention)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET scheduler = EXCLUDED.scheduler,
+		    desired_retention = EXCLUDED.desired_retention,
+		    updated_at = NOW()
+	`
+	_, err := r.pool.Exec(ctx, query, settings.UserID, settings.Scheduler, settings.DesiredRetention)
+	return err
 }
 
 // GetDeckStatistics retrieves statistics for a user's deck in a specific week
@@ -257,3 +710,833 @@ func (r *ContentRepositoryPostgres) GetDeckStatistics(ctx context.Context, userI
 	}
 	return stats, nil
 }
+
+// querier is implemented by both the pool and a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// sharedDeckSelect is scanned by scanSharedDeck, the viewing user must be passed as $1
+const sharedDeckSelect = `
+	SELECT d.id, d.owner_id, u.first_name || ' ' || u.last_name, d.week_id, d.title, d.description, d.version,
+	       (SELECT COUNT(*) FROM flashcards f WHERE f.shared_deck_id = d.id),
+	       (SELECT COUNT(*) FROM shared_deck_subscriptions s WHERE s.shared_deck_id = d.id),
+	       EXISTS (SELECT 1 FROM shared_deck_subscriptions s WHERE s.shared_deck_id = d.id AND s.user_id = $1),
+	       d.published_at, d.updated_at
+	FROM shared_decks d
+	JOIN users u ON u.id = d.owner_id`
+
+func scanSharedDeck(row pgx.Row) (SharedDeck, error) {
+	var deck SharedDeck
+	err := row.Scan(
+		&deck.ID, &deck.OwnerID, &deck.OwnerName, &deck.WeekID, &deck.Title, &deck.Description, &deck.Version,
+		&deck.CardCount, &deck.SubscriberCount, &deck.Subscribed, &deck.PublishedAt, &deck.UpdatedAt,
+	)
+	return deck, err
+}
+
+// PublishDeck creates or refreshes the shared snapshot of the owner's week deck in one transaction.
+// Re-publishing bumps the version, and only cards that are new or changed get the new version.
+func (r *ContentRepositoryPostgres) PublishDeck(ctx context.Context, ownerID, weekID uuid.UUID, title, description string) (uuid.UUID, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var deckID uuid.UUID
+	var version int
+	upsertQuery := `
+		INSERT INTO shared_decks (id, owner_id, week_id, title, description)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (owner_id, week_id) DO UPDATE
+		SET title = EXCLUDED.title,
+		    description = EXCLUDED.description,
+		    version = shared_decks.version + 1,
+		    updated_at = NOW()
+		RETURNING id, version
+	`
+	err = tx.QueryRow(ctx, upsertQuery, uuid.New(), ownerID, weekID, title, description).Scan(&deckID, &version)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("PublishDeck upsert: %w", err)
+	}
+
+	// cards the author removed from their deck are no longer offered, subscribers keep their copies
+	deleteQuery := `
+		DELETE FROM flashcards f
+		WHERE f.shared_deck_id = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM user_deck_cards c
+			WHERE c.id = f.author_card_id AND c.user_id = $2 AND c.week_id = $3
+		  )
+	`
+	if _, err := tx.Exec(ctx, deleteQuery, deckID, ownerID, weekID); err != nil {
+		return uuid.Nil, fmt.Errorf("PublishDeck delete: %w", err)
+	}
+
+	updateQuery := `
+		UPDATE flashcards f
+		SET front = c.front, back = c.back, card_type = c.card_type, content = c.content, shared_version = $2
+		FROM user_deck_cards c
+		WHERE f.shared_deck_id = $1 AND c.id = f.author_card_id
+		  AND (f.front <> c.front OR f.back <> c.back OR f.content IS DISTINCT FROM c.content)
+	`
+	if _, err := tx.Exec(ctx, updateQuery, deckID, version); err != nil {
+		return uuid.Nil, fmt.Errorf("PublishDeck update: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO flashcards (id, user_id, week_id, front, back, card_type, content, shared_deck_id, author_card_id, shared_version)
+		SELECT gen_random_uuid(), c.user_id, c.week_id, c.front, c.back, c.card_type, c.content, $1, c.id, $2::int
+		FROM user_deck_cards c
+		WHERE c.user_id = $3 AND c.week_id = $4
+		  AND NOT EXISTS (SELECT 1 FROM flashcards f WHERE f.shared_deck_id = $1 AND f.author_card_id = c.id)
+		ORDER BY c.created_at
+	`
+	if _, err := tx.Exec(ctx, insertQuery, deckID, version, ownerID, weekID); err != nil {
+		return uuid.Nil, fmt.Errorf("PublishDeck insert: %w", err)
+	}
+
+	return deckID, tx.Commit(ctx)
+}
+
+// UnpublishDeck deletes the owner's shared deck for the week, subscribers keep the cards they cloned
+func (r *ContentRepositoryPostgres) UnpublishDeck(ctx context.Context, ownerID, weekID uuid.UUID) error {
+	query := `DELETE FROM shared_decks WHERE owner_id = $1 AND week_id = $2`
+	result, err := r.pool.Exec(ctx, query, ownerID, weekID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ListSharedDecksForWeek returns the decks published for a week, most subscribed first
+func (r *ContentRepositoryPostgres) ListSharedDecksForWeek(ctx context.Context, weekID, viewerID uuid.UUID) ([]SharedDeck, error) {
+	query := sharedDeckSelect + `
+		WHERE d.week_id = $2
+		ORDER BY 9 DESC, d.updated_at DESC -- subscriber count first
+	`
+	rows, err := r.pool.Query(ctx, query, viewerID, weekID)
+	if err != nil {
+		return nil, fmt.Errorf("ListSharedDecksForWeek query: %w", err)
+	}
+	defer rows.Close()
+
+	decks := make([]SharedDeck, 0)
+	for rows.Next() {
+		deck, err := scanSharedDeck(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListSharedDecksForWeek scan: %w", err)
+		}
+		decks = append(decks, deck)
+	}
+	return decks, rows.Err()
+}
+
+// GetSharedDeck retrieves a single published deck as seen by the viewer
+func (r *ContentRepositoryPostgres) GetSharedDeck(ctx context.Context, deckID, viewerID uuid.UUID) (SharedDeck, error) {
+	query := sharedDeckSelect + `
+		WHERE d.id = $2
+	`
+	return scanSharedDeck(r.pool.QueryRow(ctx, query, viewerID, deckID))
+}
+
+// ListSharedDeckCards returns the published cards of a shared deck
+func (r *ContentRepositoryPostgres) ListSharedDeckCards(ctx context.Context, deckID uuid.UUID) ([]Flashcard, error) {
+	query := `
+		SELECT id, storage_object_id, user_id, week_id, front, back, card_type, content
+		FROM flashcards
+		WHERE shared_deck_id = $1
+		ORDER BY created_at ASC
+	`
+	rows, err := r.pool.Query(ctx, query, deckID)
+	if err != nil {
+		return nil, fmt.Errorf("ListSharedDeckCards query: %w", err)
+	}
+	return scanFlashcards(rows)
+}
+
+func scanFlashcards(rows pgx.Rows) ([]Flashcard, error) {
+	defer rows.Close()
+
+	cards := make([]Flashcard, 0)
+	for rows.Next() {
+		var card Flashcard
+		if err := rows.Scan(&card.ID, &card.ObjectID, &card.UserID, &card.WeekID, &card.Front, &card.Back, &card.CardType, &card.Content); err != nil {
+			return nil, fmt.Errorf("scan flashcard: %w", err)
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+// SubscribeToSharedDeck records the subscription and clones every published card into the user's deck
+// for the same week, returning how many cards were added. created is false when the user was
+// subscribed already, nothing is cloned then
+func (r *ContentRepositoryPostgres) SubscribeToSharedDeck(ctx context.Context, deckID, userID uuid.UUID) (int, bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	subscribeQuery := `
+		INSERT INTO shared_deck_subscriptions (shared_deck_id, user_id, synced_version)
+		SELECT id, $2::uuid, version FROM shared_decks WHERE id = $1
+		ON CONFLICT (shared_deck_id, user_id) DO NOTHING
+	`
+	result, err := tx.Exec(ctx, subscribeQuery, deckID, userID)
+	if err != nil {
+		return 0, false, fmt.Errorf("SubscribeToSharedDeck subscribe: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		// either the deck is gone or a concurrent request subscribed first
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM shared_decks WHERE id = $1)`, deckID).Scan(&exists); err != nil {
+			return 0, false, fmt.Errorf("SubscribeToSharedDeck subscribe: %w", err)
+		}
+		if exists {
+			return 0, false, nil
+		}
+		return 0, false, pgx.ErrNoRows
+	}
+
+	added, err := cloneSharedCards(ctx, tx, deckID, userID, 0)
+	if err != nil {
+		return 0, false, err
+	}
+	return added, true, tx.Commit(ctx)
+}
+
+// cloneSharedCards copies the deck's cards published after sinceVersion into the user's deck,
+// skipping cards the user already has
+func cloneSharedCards(ctx context.Context, tx pgx.Tx, deckID, userID uuid.UUID, sinceVersion int) (int, error) {
+	query := `
+		INSERT INTO user_deck_cards (id, user_id, week_id, source_flashcard_id, front, back, is_custom, card_type, content)
+		SELECT gen_random_uuid(), $2::uuid, f.week_id, f.id, f.front, f.back, false, f.card_type, f.content
+		FROM flashcards f
+		WHERE f.shared_deck_id = $1 AND f.shared_version > $3
+		ORDER BY f.created_at
+		ON CONFLICT (user_id, week_id, source_flashcard_id) DO NOTHING
+	`
+	result, err := tx.Exec(ctx, query, deckID, userID, sinceVersion)
+	if err != nil {
+		return 0, fmt.Errorf("clone shared cards: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// UnsubscribeFromSharedDeck stops offering updates, the cloned cards stay in the user's deck
+func (r *ContentRepositoryPostgres) UnsubscribeFromSharedDeck(ctx context.Context, deckID, userID uuid.UUID) error {
+	query := `DELETE FROM shared_deck_subscriptions WHERE shared_deck_id = $1 AND user_id = $2`
+	result, err := r.pool.Exec(ctx, query, deckID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// GetSharedDeckUpdates lists the cards the author added or edited since the subscriber last synced.
+// Cards the subscriber deleted from their deck before that are not offered again.
+func (r *ContentRepositoryPostgres) GetSharedDeckUpdates(ctx context.Context, deckID, userID uuid.UUID) (SharedDeckUpdates, error) {
+	return sharedDeckUpdates(ctx, r.pool, deckID, userID)
+}
+
+func sharedDeckUpdates(ctx context.Context, q querier, deckID, userID uuid.UUID) (SharedDeckUpdates, error) {
+	updates := SharedDeckUpdates{SharedDeckID: deckID, Changed: make([]SharedCardChange, 0)}
+
+	versionQuery := `
+		SELECT s.synced_version, d.version
+		FROM shared_deck_subscriptions s
+		JOIN shared_decks d ON d.id = s.shared_deck_id
+		WHERE s.shared_deck_id = $1 AND s.user_id = $2
+	`
+	if err := q.QueryRow(ctx, versionQuery, deckID, userID).Scan(&updates.SyncedVersion, &updates.Version); err != nil {
+		return SharedDeckUpdates{}, err
+	}
+
+	addedQuery := `
+		SELECT f.id, f.storage_object_id, f.user_id, f.week_id, f.front, f.back, f.card_type, f.content
+		FROM flashcards f
+		WHERE f.shared_deck_id = $1 AND f.shared_version > $3
+		  AND NOT EXISTS (SELECT 1 FROM user_deck_cards c WHERE c.user_id = $2 AND c.source_flashcard_id = f.id)
+		ORDER BY f.created_at
+	`
+	rows, err := q.Query(ctx, addedQuery, deckID, userID, updates.SyncedVersion)
+	if err != nil {
+		return SharedDeckUpdates{}, fmt.Errorf("shared deck added cards query: %w", err)
+	}
+	if updates.Added, err = scanFlashcards(rows); err != nil {
+		return SharedDeckUpdates{}, err
+	}
+
+	changedQuery := `
+		SELECT c.id, f.id, c.front, c.back, f.front, f.back, c.edited_at IS NOT NULL
+		FROM flashcards f
+		JOIN user_deck_cards c ON c.source_flashcard_id = f.id AND c.user_id = $2
+		WHERE f.shared_deck_id = $1 AND f.shared_version > $3
+		  AND (c.front <> f.front OR c.back <> f.back OR c.content IS DISTINCT FROM f.content)
+		ORDER BY f.created_at
+	`
+	rows, err = q.Query(ctx, changedQuery, deckID, userID, updates.SyncedVersion)
+	if err != nil {
+		return SharedDeckUpdates{}, fmt.Errorf("shared deck changed cards query: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var change SharedCardChange
+		if err := rows.Scan(&change.CardID, &change.FlashcardID, &change.Front, &change.Back, &change.NewFront, &change.NewBack, &change.Conflict); err != nil {
+			return SharedDeckUpdates{}, fmt.Errorf("shared deck changed cards scan: %w", err)
+		}
+		updates.Changed = append(updates.Changed, change)
+	}
+	return updates, rows.Err()
+}
+
+// ApplySharedDeckUpdates adds new author cards, overwrites the content of edited ones (keeping the
+// subscriber's scheduling state) and marks the subscription as synced, all in one transaction. Cards the
+// subscriber edited keep their content unless listed in req.Overwrite, which also clears their edited_at so
+// later author edits apply again. While a conflict is neither overwritten nor kept the synced version stays
+// put, so the conflict shows up again on the next preview.
+func (r *ContentRepositoryPostgres) ApplySharedDeckUpdates(ctx context.Context, deckID, userID uuid.UUID, req ApplySharedDeckUpdatesRequest) (SharedDeckUpdates, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return SharedDeckUpdates{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	updates, err := sharedDeckUpdates(ctx, tx, deckID, userID)
+	if err != nil {
+		return SharedDeckUpdates{}, err
+	}
+
+	if _, err := cloneSharedCards(ctx, tx, deckID, userID, updates.SyncedVersion); err != nil {
+		return SharedDeckUpdates{}, err
+	}
+
+	overwrite := req.Overwrite
+	if overwrite == nil {
+		overwrite = []uuid.UUID{}
+	}
+	updateQuery := `
+		UPDATE user_deck_cards c
+		SET front = f.front, back = f.back, card_type = f.card_type, content = f.content, updated_at = NOW(),
+		    edited_at = NULL
+		FROM flashcards f
+		WHERE c.source_flashcard_id = f.id AND c.user_id = $2
+		  AND f.shared_deck_id = $1 AND f.shared_version > $3 AND (c.edited_at IS NULL OR c.id = ANY ($4))
+		  AND (c.front <> f.front OR c.back <> f.back OR c.content IS DISTINCT FROM f.content)
+	`
+	if _, err := tx.Exec(ctx, updateQuery, deckID, userID, updates.SyncedVersion, overwrite); err != nil {
+		return SharedDeckUpdates{}, fmt.Errorf("ApplySharedDeckUpdates update: %w", err)
+	}
+
+	if resolveConflicts(updates.Changed, req) > 0 {
+		return updates, tx.Commit(ctx)
+	}
+
+	syncQuery := `UPDATE shared_deck_subscriptions SET synced_version = $3 WHERE shared_deck_id = $1 AND user_id = $2`
+	if _, err := tx.Exec(ctx, syncQuery, deckID, userID, updates.Version); err != nil {
+		return SharedDeckUpdates{}, fmt.Errorf("ApplySharedDeckUpdates sync: %w", err)
+	}
+
+	return updates, tx.Commit(ctx)
+}
+
+// ListExamCandidates returns the generated flashcards of the module run's week resources and/or the user's
+// deck cards for the run, optionally limited to some weeks. A generated card linked to several weeks is
+// returned once, for its earliest week.
+func (r *ContentRepositoryPostgres) ListExamCandidates(ctx context.Context, userID, moduleRunID uuid.UUID, weekIDs []uuid.UUID, source ExamSource) ([]ExamCandidate, error) {
+	query := `
+		SELECT * FROM (
+			SELECT DISTINCT ON (f.id) f.id, NULL::uuid, w.id, w.number, f.card_type, f.front, f.back, f.content
+			FROM flashcards f
+			JOIN resources r ON r.storage_object_id = f.storage_object_id
+			JOIN week_resources wr ON wr.resource_id = r.id
+			JOIN weeks w ON w.id = wr.week_id
+			WHERE $4 <> 'deck' AND w.module_run_id = $2 AND f.superseded_at IS NULL
+			  AND ($3::uuid[] IS NULL OR w.id = ANY ($3))
+			ORDER BY f.id, w.number
+		) generated
+		UNION ALL
+		SELECT c.source_flashcard_id, c.id, w.id, w.number, c.card_type, c.front, c.back, c.content
+		FROM user_deck_cards c
+		JOIN weeks w ON w.id = c.week_id
+		WHERE $4 <> 'flashcards' AND c.user_id = $1 AND w.module_run_id = $2
+		  AND ($3::uuid[] IS NULL OR w.id = ANY ($3))
+	`
+	if len(weekIDs) == 0 {
+		weekIDs = nil
+	}
+	rows, err := r.pool.Query(ctx, query, userID, moduleRunID, weekIDs, string(source))
+	if err != nil {
+		return nil, fmt.Errorf("ListExamCandidates query: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := make([]ExamCandidate, 0)
+	for rows.Next() {
+		var c ExamCandidate
+		err := rows.Scan(&c.FlashcardID, &c.DeckCardID, &c.WeekID, &c.WeekNumber, &c.CardType, &c.Front, &c.Back, &c.Content)
+		if err != nil {
+			return nil, fmt.Errorf("ListExamCandidates scan: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// CreateExam stores a new attempt and its questions in one transaction, the deadline is taken from the
+// database clock
+func (r *ContentRepositoryPostgres) CreateExam(ctx context.Context, exam Exam, questions []ExamQuestion) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	examQuery := `
+		INSERT INTO exams (id, user_id, module_run_id, source, question_count, duration_seconds, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW() + $6 * INTERVAL '1 second')
+	`
+	_, err = tx.Exec(ctx, examQuery, exam.ID, exam.UserID, exam.ModuleRunID, exam.Source, exam.QuestionCount, exam.DurationSeconds)
+	if err != nil {
+		return fmt.Errorf("CreateExam insert exam: %w", err)
+	}
+
+	questionQuery := `
+		INSERT INTO exam_questions (id, exam_id, position, week_id, week_number, flashcard_id, deck_card_id,
+		                            card_type, prompt, content, options, correct_option)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+	batch := pgx.Batch{}
+	for _, q := range questions {
+		batch.Queue(questionQuery, q.ID, exam.ID, q.Position, q.WeekID, q.WeekNumber, q.FlashcardID, q.DeckCardID,
+			q.CardType, q.Prompt, q.Content, q.Options, q.CorrectOption)
+	}
+	if err := tx.SendBatch(ctx, &batch).Close(); err != nil {
+		return fmt.Errorf("CreateExam insert questions: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// examSelect is scanned by scanExam, an attempt counts as timed out 5 seconds after its deadline
+// so answers sent right before it are still accepted. A timed out attempt reads as expired and
+// graded until FinishExam or FinishExpiredExams stores that grade.
+const examSelect = `
+	SELECT e.id, e.user_id, e.module_run_id, e.source,
+	       CASE WHEN t.timed_out THEN 'expired' ELSE e.status END,
+	       e.question_count, q.answered, e.duration_seconds,
+	       CASE WHEN e.status = 'in_progress' AND NOT t.timed_out
+	            THEN GREATEST(CEIL(EXTRACT(EPOCH FROM e.expires_at - NOW())), 0)::int ELSE 0 END,
+	       CASE WHEN t.timed_out THEN q.correct ELSE e.correct_count END,
+	       CASE WHEN t.timed_out THEN ROUND(100.0 * q.correct / e.question_count, 1)::float8 ELSE e.score END,
+	       e.started_at, e.expires_at,
+	       CASE WHEN t.timed_out THEN e.expires_at ELSE e.finished_at END,
+	       t.timed_out
+	FROM exams e
+	CROSS JOIN LATERAL (
+		SELECT e.status = 'in_progress' AND e.expires_at + INTERVAL '5 seconds' < NOW() AS timed_out
+	) t
+	CROSS JOIN LATERAL (
+		SELECT COUNT(*) FILTER (WHERE answered_at IS NOT NULL)::int AS answered,
+		       COUNT(*) FILTER (WHERE correct)::int AS correct
+		FROM exam_questions WHERE exam_id = e.id
+	) q`
+
+func scanExam(row pgx.Row) (Exam, error) {
+	var exam Exam
+	err := row.Scan(
+		&exam.ID, &exam.UserID, &exam.ModuleRunID, &exam.Source, &exam.Status, &exam.QuestionCount,
+		&exam.AnsweredCount, &exam.DurationSeconds, &exam.RemainingSeconds,
+		&exam.CorrectCount, &exam.Score, &exam.StartedAt, &exam.ExpiresAt, &exam.FinishedAt, &exam.TimedOut,
+	)
+	return exam, err
+}
+
+// GetExam retrieves one of the user's exam attempts
+func (r *ContentRepositoryPostgres) GetExam(ctx context.Context, examID, userID uuid.UUID) (Exam, error) {
+	query := examSelect + `
+		WHERE e.id = $1 AND e.user_id = $2
+	`
+	return scanExam(r.pool.QueryRow(ctx, query, examID, userID))
+}
+
+// ListExams returns the user's attempts, newest first, optionally for a single module run
+func (r *ContentRepositoryPostgres) ListExams(ctx context.Context, userID uuid.UUID, moduleRunID *uuid.UUID, page Page) ([]Exam, error) {
+	query := examSelect + `
+		WHERE e.user_id = $1 AND ($2::uuid IS NULL OR e.module_run_id = $2)
+		ORDER BY e.started_at DESC
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := r.pool.Query(ctx, query, userID, moduleRunID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("ListExams query: %w", err)
+	}
+	defer rows.Close()
+
+	exams := make([]Exam, 0)
+	for rows.Next() {
+		exam, err := scanExam(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListExams scan: %w", err)
+		}
+		exams = append(exams, exam)
+	}
+	return exams, rows.Err()
+}
+
+const examQuestionColumns = `id, exam_id, position, week_id, week_number, flashcard_id, deck_card_id,
+		       card_type, prompt, content, options, correct_option`
+
+func examQuestionDest(q *ExamQuestion) []any {
+	return []any{
+		&q.ID, &q.ExamID, &q.Position, &q.WeekID, &q.WeekNumber, &q.FlashcardID, &q.DeckCardID,
+		&q.CardType, &q.Prompt, &q.Content, &q.Options, &q.CorrectOption,
+	}
+}
+
+// GetCurrentExamQuestion returns the first unanswered question of the exam, pgx.ErrNoRows once all are answered
+func (r *ContentRepositoryPostgres) GetCurrentExamQuestion(ctx context.Context, examID uuid.UUID) (ExamQuestion, error) {
+	query := `
+		SELECT ` + examQuestionColumns + `
+		FROM exam_questions
+		WHERE exam_id = $1 AND answered_at IS NULL
+		ORDER BY position ASC
+		LIMIT 1
+	`
+	var q ExamQuestion
+	err := r.pool.QueryRow(ctx, query, examID).Scan(examQuestionDest(&q)...)
+	return q, err
+}
+
+// AnswerExamQuestion grades the answer to the exam's current question, pgx.ErrNoRows when the question
+// is not the current one
+func (r *ContentRepositoryPostgres) AnswerExamQuestion(ctx context.Context, examID, questionID uuid.UUID, option *int) error {
+	query := `
+		UPDATE exam_questions
+		SET selected_option = $3, correct = COALESCE($3 = correct_option, false), answered_at = NOW()
+		WHERE id = $2 AND exam_id = $1 AND answered_at IS NULL
+		  AND position = (SELECT MIN(position) FROM exam_questions WHERE exam_id = $1 AND answered_at IS NULL)
+	`
+	result, err := r.pool.Exec(ctx, query, examID, questionID, option)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// FinishExam marks unanswered questions as wrong and stores the score of an attempt that is still in progress
+func (r *ContentRepositoryPostgres) FinishExam(ctx context.Context, examID uuid.UUID, status ExamStatus) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	unansweredQuery := `UPDATE exam_questions SET correct = false WHERE exam_id = $1 AND correct IS NULL`
+	if _, err := tx.Exec(ctx, unansweredQuery, examID); err != nil {
+		return fmt.Errorf("FinishExam unanswered: %w", err)
+	}
+
+	gradeQuery := `
+		UPDATE exams e
+		SET status = $2, correct_count = q.correct,
+		    finished_at = CASE WHEN $2 = 'expired' THEN e.expires_at ELSE NOW() END,
+		    score = ROUND(100.0 * q.correct / e.question_count, 1)
+		FROM (SELECT COUNT(*) FILTER (WHERE correct) AS correct FROM exam_questions WHERE exam_id = $1) q
+		WHERE e.id = $1 AND e.status = 'in_progress'
+	`
+	if _, err := tx.Exec(ctx, gradeQuery, examID, status); err != nil {
+		return fmt.Errorf("FinishExam grade: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FinishExpiredExams grades every attempt whose time ran out, unanswered questions count as wrong
+func (r *ContentRepositoryPostgres) FinishExpiredExams(ctx context.Context) (int64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	expiredQuery := `
+		SELECT id FROM exams
+		WHERE status = 'in_progress' AND expires_at + INTERVAL '5 seconds' < NOW()
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.Query(ctx, expiredQuery)
+	if err != nil {
+		return 0, fmt.Errorf("FinishExpiredExams query: %w", err)
+	}
+	examIDs := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var examID uuid.UUID
+		if err := rows.Scan(&examID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("FinishExpiredExams scan: %w", err)
+		}
+		examIDs = append(examIDs, examID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("FinishExpiredExams rows: %w", err)
+	}
+	if len(examIDs) == 0 {
+		return 0, nil
+	}
+
+	unansweredQuery := `UPDATE exam_questions SET correct = false WHERE exam_id = ANY($1) AND correct IS NULL`
+	if _, err := tx.Exec(ctx, unansweredQuery, examIDs); err != nil {
+		return 0, fmt.Errorf("FinishExpiredExams unanswered: %w", err)
+	}
+
+	gradeQuery := `
+		UPDATE exams e
+		SET status = 'expired', finished_at = e.expires_at, correct_count = q.correct,
+		    score = ROUND(100.0 * q.correct / e.question_count, 1)
+		FROM (
+			SELECT exam_id, COUNT(*) FILTER (WHERE correct) AS correct
+			FROM exam_questions WHERE exam_id = ANY($1)
+			GROUP BY exam_id
+		) q
+		WHERE e.id = q.exam_id
+	`
+	tag, err := tx.Exec(ctx, gradeQuery, examIDs)
+	if err != nil {
+		return 0, fmt.Errorf("FinishExpiredExams grade: %w", err)
+	}
+
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+// ListExamQuestions returns every question of an exam with its grading, in the order they were asked
+func (r *ContentRepositoryPostgres) ListExamQuestions(ctx context.Context, examID uuid.UUID) ([]ExamQuestionReview, error) {
+	query := `
+		SELECT ` + examQuestionColumns + `, selected_option, COALESCE(correct, false), answered_at
+		FROM exam_questions
+		WHERE exam_id = $1
+		ORDER BY position ASC
+	`
+	rows, err := r.pool.Query(ctx, query, examID)
+	if err != nil {
+		return nil, fmt.Errorf("ListExamQuestions query: %w", err)
+	}
+	defer rows.Close()
+
+	questions := make([]ExamQuestionReview, 0)
+	for rows.Next() {
+		var q ExamQuestionReview
+		dest := append(examQuestionDest(&q.ExamQuestion), &q.SelectedOption, &q.Correct, &q.AnsweredAt)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("ListExamQuestions scan: %w", err)
+		}
+		q.CorrectOption = q.ExamQuestion.CorrectOption
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+// StartGenerationJob moves the unfinished job of a storage object to converting and counts the attempt.
+// Objects queued without a job (or before jobs were tracked) get a new one, it takes the options of the
+// object's last job so a replayed regeneration runs with what it was asked for
+func (r *ContentRepositoryPostgres) StartGenerationJob(ctx context.Context, objectID uuid.UUID) (GenerationJob, error) {
+	startQuery := `
+		UPDATE generation_jobs
+		SET status = 'converting', error = NULL, attempts = attempts + 1, started_at = NOW(), updated_at = NOW()
+		WHERE storage_object_id = $1 AND status NOT IN ('saved', 'failed')
+		RETURNING id, attempts, options, created_at, updated_at, started_at
+	`
+	insertQuery := `
+		INSERT INTO generation_jobs (id, storage_object_id, status, attempts, started_at, options)
+		VALUES ($1, $2, 'converting', 1, NOW(), (
+			SELECT options FROM generation_jobs
+			WHERE storage_object_id = $2
+			ORDER BY created_at DESC
+			LIMIT 1
+		))
+		ON CONFLICT (storage_object_id) WHERE status NOT IN ('saved', 'failed') DO NOTHING
+		RETURNING id, attempts, options, created_at, updated_at, started_at
+	`
+
+	// a job queued between the update and the insert makes the insert a no-op, the update then finds it
+	for range 2 {
+		job := GenerationJob{StorageObjectID: objectID, Status: GenerationConverting}
+		err := r.pool.QueryRow(ctx, startQuery, objectID).Scan(&job.ID, &job.Attempts, &job.Options, &job.CreatedAt, &job.UpdatedAt, &job.StartedAt)
+		if err == nil {
+			return job, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return GenerationJob{}, fmt.Errorf("StartGenerationJob update: %w", err)
+		}
+
+		err = r.pool.QueryRow(ctx, insertQuery, uuid.New(), objectID).Scan(&job.ID, &job.Attempts, &job.Options, &job.CreatedAt, &job.UpdatedAt, &job.StartedAt)
+		if err == nil {
+			return job, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return GenerationJob{}, fmt.Errorf("StartGenerationJob insert: %w", err)
+		}
+	}
+	return GenerationJob{}, errors.New("StartGenerationJob: the job kept changing, try again")
+}
+
+// UpdateGenerationJobStatus records the stage a job reached, failed jobs keep the error and are finished
+func (r *ContentRepositoryPostgres) UpdateGenerationJobStatus(ctx context.Context, jobID uuid.UUID, status GenerationStatus, errMsg *string) error {
+	query := `
+		UPDATE generation_jobs
+		SET status = $2, error = $3, updated_at = NOW(),
+		    finished_at = CASE WHEN $2 = 'failed' THEN NOW() ELSE finished_at END
+		WHERE id = $1
+	`
+	result, err := r.pool.Exec(ctx, query, jobID, status, errMsg)
+	if err != nil {
+		return fmt.Errorf("UpdateGenerationJobStatus: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// FinishGenerationJob saves the generated cards as the next version of the object's card set, replaces the
+// summary and quiz when the run made them and marks the job as saved in one transaction. The previous card
+// version is superseded, not deleted, so deck cards copied from it keep their source
+func (r *ContentRepositoryPostgres) FinishGenerationJob(ctx context.Context, jobID uuid.UUID, generated GeneratedContent) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// locking the object keeps two runs over it from saving the same version
+	var objectID uuid.UUID
+	lockQuery := `
+		SELECT so.id FROM generation_jobs j
+		JOIN storage_objects so ON so.id = j.storage_object_id
+		WHERE j.id = $1
+		FOR UPDATE OF so
+	`
+	if err := tx.QueryRow(ctx, lockQuery, jobID).Scan(&objectID); err != nil {
+		return fmt.Errorf("FinishGenerationJob lock: %w", err)
+	}
+
+	var version int
+	versionQuery := `SELECT COALESCE(MAX(version), 0) + 1 FROM flashcards WHERE storage_object_id = $1`
+	if err := tx.QueryRow(ctx, versionQuery, objectID).Scan(&version); err != nil {
+		return fmt.Errorf("FinishGenerationJob version: %w", err)
+	}
+
+	supersedeQuery := `UPDATE flashcards SET superseded_at = NOW() WHERE storage_object_id = $1 AND superseded_at IS NULL`
+	if _, err := tx.Exec(ctx, supersedeQuery, objectID); err != nil {
+		return fmt.Errorf("FinishGenerationJob supersede: %w", err)
+	}
+
+	cardQuery := `INSERT INTO flashcards(id, storage_object_id, front, back, page_start, page_end, version) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	batch := pgx.Batch{}
+	for _, card := range generated.Cards {
+		batch.Queue(cardQuery, card.ID, objectID, card.Front, card.Back, card.PageStart, card.PageEnd, version)
+	}
+
+	if summary := generated.Summary; summary != nil {
+		summaryQuery := `
+			INSERT INTO resource_summaries (storage_object_id, key_points, glossary)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (storage_object_id) DO UPDATE
+			SET key_points = EXCLUDED.key_points, glossary = EXCLUDED.glossary, updated_at = NOW()
+		`
+		batch.Queue(summaryQuery, objectID, summary.KeyPoints, summary.Glossary)
+	}
+
+	if generated.Quiz != nil {
+		batch.Queue(`DELETE FROM resource_quiz_questions WHERE storage_object_id = $1`, objectID)
+		quizQuery := `
+			INSERT INTO resource_quiz_questions (id, storage_object_id, position, question, options, correct_option, explanation, page_start, page_end)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`
+		for _, q := range generated.Quiz {
+			batch.Queue(quizQuery, q.ID, objectID, q.Position, q.Question, q.Options, q.CorrectOption, q.Explanation, q.PageStart, q.PageEnd)
+		}
+	}
+
+	if err := tx.SendBatch(ctx, &batch).Close(); err != nil {
+		return fmt.Errorf("FinishGenerationJob content: %w", err)
+	}
+
+	jobQuery := `
+		UPDATE generation_jobs
+		SET status = 'saved', error = NULL, card_count = $2, version = $3, updated_at = NOW(), finished_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(ctx, jobQuery, jobID, len(generated.Cards), version); err != nil {
+		return fmt.Errorf("FinishGenerationJob job: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetResourceGenerationJob returns the newest generation job of the file behind a resource
+func (r *ContentRepositoryPostgres) GetResourceGenerationJob(ctx context.Context, resourceID uuid.UUID) (GenerationJob, error) {
+	query := `
+		SELECT j.id, j.storage_object_id, j.status, j.error, j.card_count, j.attempts,
+		       j.created_at, j.updated_at, j.started_at, j.finished_at, j.options, j.version
+		FROM resources res
+		JOIN generation_jobs j ON j.storage_object_id = res.storage_object_id
+		WHERE res.id = $1
+		ORDER BY j.created_at DESC
+		LIMIT 1
+	`
+	var job GenerationJob
+	err := r.pool.QueryRow(ctx, query, resourceID).Scan(
+		&job.ID, &job.StorageObjectID, &job.Status, &job.Error, &job.CardCount, &job.Attempts,
+		&job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.FinishedAt, &job.Options, &job.Version,
+	)
+	return job, err
+}
+
+// GetRegenerationObject returns the storage object behind a file resource, for anyone but an admin only
+// when the user uploaded the resource
+func (r *ContentRepositoryPostgres) GetRegenerationObject(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool) (uuid.UUID, error) {
+	query := `
+		SELECT res.storage_object_id
+		FROM resources res
+		WHERE res.id = $1 AND res.storage_object_id IS NOT NULL
+		  AND ($3 OR EXISTS (SELECT 1 FROM resource_owners o WHERE o.resource_id = res.id AND o.user_id = $2))
+	`
+	var objectID uuid.UUID
+	err := r.pool.QueryRow(ctx, query, resourceID, userID, asAdmin).Scan(&objectID)
+	return objectID, err
+}
+
+// QueueGenerationJob creates a queued job with the given options unless the object already has a job
+// that did not finish, pgx.ErrNoRows in that case
+func (r *ContentRepositoryPostgres) QueueGenerationJob(ctx context.Context, objectID uuid.UUID, opts GenerationOptions) (GenerationJob, error) {
+	job := GenerationJob{ID: uuid.New(), StorageObjectID: objectID, Status: GenerationQueued, Options: &opts}
+	query := `
+		INSERT INTO generation_jobs (id, storage_object_id, status, options)
+		VALUES ($1, $2, 'queued', $3)
+		ON CONFLICT (storage_object_id) WHERE status NOT IN ('saved', 'failed') DO NOTHING
+		RETURNING created_at, updated_at
+	`
+	err := r.pool.QueryRow(ctx, query, job.ID, objectID, opts).Scan(&job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return GenerationJob{}, err
+		}
+		return GenerationJob{}, fmt.Errorf("QueueGenerationJob: %w", err)
+	}
+	return job, nil
+}
@@ -0,0 +1,194 @@
+package content
+
+import (
+	"math"
+	"time"
+)
+
+type SchedulerType string
+
+const (
+	SchedulerSM2  SchedulerType = "sm2"
+	SchedulerFSRS SchedulerType = "fsrs"
+)
+
+const (
+	defaultEaseFactor       = 2.5
+	minEaseFactor           = 1.3
+	defaultDesiredRetention = 0.9
+	maxIntervalDays         = 36500
+)
+
+func (t SchedulerType) IsValid() bool {
+	return t == SchedulerSM2 || t == SchedulerFSRS
+}
+
+// Scheduler computes the next spaced-repetition state of a card after a review.
+// difficultyRating uses the same 1 (easy) to 5 (hard) scale as RecordReviewRequest.
+type Scheduler interface {
+	Schedule(card CardSchedule, difficultyRating int, now time.Time) CardSchedule
+}
+
+// NewScheduler returns the scheduler the user picked in their study settings, SM-2 by default
+func NewScheduler(settings StudySettings) Scheduler {
+	if settings.Scheduler == SchedulerFSRS {
+		retention := settings.DesiredRetention
+		if retention <= 0 || retention >= 1 {
+			retention = defaultDesiredRetention
+		}
+		return FSRSScheduler{DesiredRetention: retention}
+	}
+	return SM2Scheduler{}
+}
+
+// SM2Scheduler implements the classic SuperMemo-2 algorithm.
+type SM2Scheduler struct{}
+
+func (SM2Scheduler) Schedule(card CardSchedule, difficultyRating int, now time.Time) CardSchedule {
+	// SM-2 grades recall quality from 0 (blackout) to 5 (perfect), so the hardest rating maps to 1
+	quality := 6 - difficultyRating
+	next := card
+	if next.EaseFactor == 0 {
+		next.EaseFactor = defaultEaseFactor
+	}
+
+	if quality < 3 {
+		if card.Repetitions > 0 {
+			next.Lapses++
+		}
+		next.Repetitions = 0
+		next.IntervalDays = 1
+	} else {
+		switch next.Repetitions {
+		case 0:
+			next.IntervalDays = 1
+		case 1:
+			next.IntervalDays = 6
+		default:
+			next.IntervalDays = int(math.Round(float64(next.IntervalDays) * next.EaseFactor))
+		}
+		next.Repetitions++
+	}
+
+	q := float64(5 - quality)
+	next.EaseFactor += 0.1 - q*(0.08+q*0.02)
+	if next.EaseFactor < minEaseFactor {
+		next.EaseFactor = minEaseFactor
+	}
+
+	next.IntervalDays = min(max(next.IntervalDays, 1), maxIntervalDays)
+	due := now.AddDate(0, 0, next.IntervalDays)
+	next.DueAt = &due
+	next.LastReviewedAt = &now
+	return next
+}
+
+// default FSRS-4.5 model weights
+var fsrsWeights = [17]float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031, 1.6474,
+	0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+const (
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0
+)
+
+const (
+	fsrsAgain = iota + 1
+	fsrsHard
+	fsrsGood
+	fsrsEasy
+)
+
+// FSRSScheduler implements the Free Spaced Repetition Scheduler (FSRS-4.5) with default weights.
+type FSRSScheduler struct {
+	DesiredRetention float64
+}
+
+func (s FSRSScheduler) Schedule(card CardSchedule, difficultyRating int, now time.Time) CardSchedule {
+	grade := fsrsGrade(difficultyRating)
+	next := card
+
+	var stability, difficulty float64
+	if card.Stability == nil || card.FSRSDifficulty == nil || card.LastReviewedAt == nil {
+		stability = fsrsWeights[grade-1]
+		difficulty = fsrsInitialDifficulty(grade)
+	} else {
+		elapsedDays := math.Max(now.Sub(*card.LastReviewedAt).Hours()/24, 0)
+		retrievability := math.Pow(1+fsrsFactor*elapsedDays/(*card.Stability), fsrsDecay)
+		if grade == fsrsAgain {
+			stability = fsrsForgetStability(*card.FSRSDifficulty, *card.Stability, retrievability)
+			next.Lapses++
+		} else {
+			stability = fsrsRecallStability(*card.FSRSDifficulty, *card.Stability, retrievability, grade)
+		}
+		difficulty = fsrsNextDifficulty(*card.FSRSDifficulty, grade)
+	}
+
+	if grade == fsrsAgain {
+		next.Repetitions = 0
+	} else {
+		next.Repetitions++
+	}
+
+	interval := stability / fsrsFactor * (math.Pow(s.DesiredRetention, 1/fsrsDecay) - 1)
+	next.IntervalDays = min(max(int(math.Round(interval)), 1), maxIntervalDays)
+	next.Stability = &stability
+	next.FSRSDifficulty = &difficulty
+	due := now.AddDate(0, 0, next.IntervalDays)
+	next.DueAt = &due
+	next.LastReviewedAt = &now
+	return next
+}
+
+// fsrsGrade maps the 1-5 difficulty rating onto FSRS grades: 5 is Again, 4 Hard, 3 Good, 1-2 Easy
+func fsrsGrade(difficultyRating int) int {
+	switch {
+	case difficultyRating >= 5:
+		return fsrsAgain
+	case difficultyRating == 4:
+		return fsrsHard
+	case difficultyRating == 3:
+		return fsrsGood
+	default:
+		return fsrsEasy
+	}
+}
+
+func fsrsInitialDifficulty(grade int) float64 {
+	return clampDifficulty(fsrsWeights[4] - float64(grade-3)*fsrsWeights[5])
+}
+
+func fsrsNextDifficulty(difficulty float64, grade int) float64 {
+	next := difficulty - fsrsWeights[6]*float64(grade-3)
+	// mean reversion towards the default difficulty w4, as in the FSRS-4.5 reference implementation
+	next = fsrsWeights[7]*fsrsWeights[4] + (1-fsrsWeights[7])*next
+	return clampDifficulty(next)
+}
+
+func fsrsRecallStability(difficulty, stability, retrievability float64, grade int) float64 {
+	hardPenalty, easyBonus := 1.0, 1.0
+	if grade == fsrsHard {
+		hardPenalty = fsrsWeights[15]
+	}
+	if grade == fsrsEasy {
+		easyBonus = fsrsWeights[16]
+	}
+	return stability * (1 + math.Exp(fsrsWeights[8])*
+		(11-difficulty)*
+		math.Pow(stability, -fsrsWeights[9])*
+		(math.Exp((1-retrievability)*fsrsWeights[10])-1)*
+		hardPenalty*easyBonus)
+}
+
+func fsrsForgetStability(difficulty, stability, retrievability float64) float64 {
+	return fsrsWeights[11] *
+		math.Pow(difficulty, -fsrsWeights[12]) *
+		(math.Pow(stability+1, fsrsWeights[13]) - 1) *
+		math.Exp((1-retrievability)*fsrsWeights[14])
+}
+
+func clampDifficulty(d float64) float64 {
+	return math.Min(math.Max(d, 1), 10)
+}
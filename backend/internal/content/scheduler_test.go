@@ -0,0 +1,257 @@
+package content
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestSM2Scheduler(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		card             CardSchedule
+		difficultyRating int
+		expectedInterval int
+		expectedEase     float64
+		expectedReps     int
+		expectedLapses   int
+	}{
+		{
+			name:             "new card - easy",
+			card:             CardSchedule{},
+			difficultyRating: 1,
+			expectedInterval: 1,
+			expectedEase:     2.6,
+			expectedReps:     1,
+		},
+		{
+			name:             "second review - medium",
+			card:             CardSchedule{EaseFactor: 2.5, IntervalDays: 1, Repetitions: 1},
+			difficultyRating: 3,
+			expectedInterval: 6,
+			expectedEase:     2.36,
+			expectedReps:     2,
+		},
+		{
+			name:             "third review - interval grows by the ease",
+			card:             CardSchedule{EaseFactor: 2.5, IntervalDays: 6, Repetitions: 2},
+			difficultyRating: 2,
+			expectedInterval: 15,
+			expectedEase:     2.5,
+			expectedReps:     3,
+		},
+		{
+			name:             "lapse - hardest rating resets the card",
+			card:             CardSchedule{EaseFactor: 2.5, IntervalDays: 15, Repetitions: 3, Lapses: 1},
+			difficultyRating: 5,
+			expectedInterval: 1,
+			expectedEase:     1.96,
+			expectedReps:     0,
+			expectedLapses:   2,
+		},
+		{
+			name:             "failed new card - not a lapse",
+			card:             CardSchedule{},
+			difficultyRating: 4,
+			expectedInterval: 1,
+			expectedEase:     2.18,
+			expectedReps:     0,
+		},
+		{
+			name:             "ease does not drop below the minimum",
+			card:             CardSchedule{EaseFactor: 1.4, IntervalDays: 6, Repetitions: 2},
+			difficultyRating: 5,
+			expectedInterval: 1,
+			expectedEase:     minEaseFactor,
+			expectedReps:     0,
+			expectedLapses:   1,
+		},
+		{
+			name:             "interval is capped",
+			card:             CardSchedule{EaseFactor: 2.5, IntervalDays: 30000, Repetitions: 5},
+			difficultyRating: 1,
+			expectedInterval: maxIntervalDays,
+			expectedEase:     2.6,
+			expectedReps:     6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := SM2Scheduler{}.Schedule(tt.card, tt.difficultyRating, now)
+
+			if next.IntervalDays != tt.expectedInterval {
+				t.Errorf("expected interval %d, got %d", tt.expectedInterval, next.IntervalDays)
+			}
+			if math.Abs(next.EaseFactor-tt.expectedEase) > 1e-9 {
+				t.Errorf("expected ease %.2f, got %.4f", tt.expectedEase, next.EaseFactor)
+			}
+			if next.Repetitions != tt.expectedReps {
+				t.Errorf("expected %d repetitions, got %d", tt.expectedReps, next.Repetitions)
+			}
+			if next.Lapses != tt.expectedLapses {
+				t.Errorf("expected %d lapses, got %d", tt.expectedLapses, next.Lapses)
+			}
+			if next.DueAt == nil || !next.DueAt.Equal(now.AddDate(0, 0, tt.expectedInterval)) {
+				t.Errorf("expected due in %d days, got %v", tt.expectedInterval, next.DueAt)
+			}
+			if next.LastReviewedAt == nil || !next.LastReviewedAt.Equal(now) {
+				t.Errorf("expected last review at %v, got %v", now, next.LastReviewedAt)
+			}
+		})
+	}
+}
+
+func TestFSRSScheduler(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	lastReview := now.AddDate(0, 0, -10)
+	scheduler := FSRSScheduler{DesiredRetention: defaultDesiredRetention}
+
+	tests := []struct {
+		name               string
+		card               CardSchedule
+		difficultyRating   int
+		expectedInterval   int
+		expectedStability  float64 // 0 to skip
+		expectedDifficulty float64 // 0 to skip
+		expectedReps       int
+		expectedLapses     int
+	}{
+		{
+			// at 90% retention the interval equals the stability
+			name:               "first review - good",
+			difficultyRating:   3,
+			expectedInterval:   4,
+			expectedStability:  3.7145,
+			expectedDifficulty: 5.1618,
+			expectedReps:       1,
+		},
+		{
+			name:               "first review - easy",
+			difficultyRating:   1,
+			expectedInterval:   14,
+			expectedStability:  13.8206,
+			expectedDifficulty: 3.932,
+			expectedReps:       1,
+		},
+		{
+			name:               "first review - again is not a lapse",
+			difficultyRating:   5,
+			expectedInterval:   1,
+			expectedStability:  0.4872,
+			expectedDifficulty: 7.6214,
+			expectedReps:       0,
+		},
+		{
+			name: "lapse - again after a recalled review",
+			card: CardSchedule{
+				IntervalDays: 10, Repetitions: 3, Lapses: 1, LastReviewedAt: &lastReview,
+				Stability: floatPtr(10), FSRSDifficulty: floatPtr(5),
+			},
+			difficultyRating:   5,
+			expectedInterval:   3,
+			expectedStability:  2.560383,
+			expectedDifficulty: 6.7443708,
+			expectedReps:       0,
+			expectedLapses:     2,
+		},
+		// stability and difficulty below match the FSRS-4.5 reference implementation with default weights
+		{
+			name: "second review - good after the full interval",
+			card: CardSchedule{
+				IntervalDays: 10, Repetitions: 1, LastReviewedAt: &lastReview,
+				Stability: floatPtr(10), FSRSDifficulty: floatPtr(5),
+			},
+			difficultyRating:   3,
+			expectedInterval:   35,
+			expectedStability:  35.083894,
+			expectedDifficulty: 5.0050158,
+			expectedReps:       2,
+		},
+		{
+			name: "second review - easy after the full interval",
+			card: CardSchedule{
+				IntervalDays: 10, Repetitions: 1, LastReviewedAt: &lastReview,
+				Stability: floatPtr(10), FSRSDifficulty: floatPtr(5),
+			},
+			difficultyRating:   1,
+			expectedInterval:   82,
+			expectedStability:  82.128738,
+			expectedDifficulty: 4.1353383,
+			expectedReps:       2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := scheduler.Schedule(tt.card, tt.difficultyRating, now)
+
+			if next.IntervalDays != tt.expectedInterval {
+				t.Errorf("expected interval %d, got %d", tt.expectedInterval, next.IntervalDays)
+			}
+			if next.Stability == nil || next.FSRSDifficulty == nil {
+				t.Fatalf("expected stability and difficulty to be set")
+			}
+			if tt.expectedStability != 0 && math.Abs(*next.Stability-tt.expectedStability) > 1e-6 {
+				t.Errorf("expected stability %.4f, got %.4f", tt.expectedStability, *next.Stability)
+			}
+			if tt.expectedDifficulty != 0 && math.Abs(*next.FSRSDifficulty-tt.expectedDifficulty) > 1e-6 {
+				t.Errorf("expected difficulty %.4f, got %.4f", tt.expectedDifficulty, *next.FSRSDifficulty)
+			}
+			if next.Repetitions != tt.expectedReps {
+				t.Errorf("expected %d repetitions, got %d", tt.expectedReps, next.Repetitions)
+			}
+			if next.Lapses != tt.expectedLapses {
+				t.Errorf("expected %d lapses, got %d", tt.expectedLapses, next.Lapses)
+			}
+		})
+	}
+
+	t.Run("recall grows the stability, a lapse shrinks it", func(t *testing.T) {
+		card := CardSchedule{Repetitions: 2, LastReviewedAt: &lastReview, Stability: floatPtr(10), FSRSDifficulty: floatPtr(5)}
+		good := scheduler.Schedule(card, 3, now)
+		hard := scheduler.Schedule(card, 4, now)
+		again := scheduler.Schedule(card, 5, now)
+		if !(*good.Stability > *hard.Stability && *hard.Stability > 10 && *again.Stability < 10) {
+			t.Errorf("expected good > hard > 10 > again, got %.2f, %.2f, %.2f", *good.Stability, *hard.Stability, *again.Stability)
+		}
+		if *again.FSRSDifficulty <= 5 || *good.FSRSDifficulty >= *again.FSRSDifficulty {
+			t.Errorf("expected again to raise the difficulty above good, got %.2f and %.2f", *again.FSRSDifficulty, *good.FSRSDifficulty)
+		}
+	})
+
+	t.Run("lower retention schedules further out", func(t *testing.T) {
+		card := CardSchedule{Repetitions: 2, LastReviewedAt: &lastReview, Stability: floatPtr(10), FSRSDifficulty: floatPtr(5)}
+		strict := scheduler.Schedule(card, 3, now)
+		relaxed := FSRSScheduler{DesiredRetention: 0.8}.Schedule(card, 3, now)
+		if relaxed.IntervalDays <= strict.IntervalDays {
+			t.Errorf("expected 80%% retention to wait longer than 90%%, got %d and %d days", relaxed.IntervalDays, strict.IntervalDays)
+		}
+	})
+}
+
+func TestNewScheduler(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings StudySettings
+		expected Scheduler
+	}{
+		{"default is sm2", StudySettings{}, SM2Scheduler{}},
+		{"fsrs with retention", StudySettings{Scheduler: SchedulerFSRS, DesiredRetention: 0.85}, FSRSScheduler{DesiredRetention: 0.85}},
+		{"fsrs with invalid retention", StudySettings{Scheduler: SchedulerFSRS, DesiredRetention: 1.5}, FSRSScheduler{DesiredRetention: defaultDesiredRetention}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewScheduler(tt.settings); got != tt.expected {
+				t.Errorf("expected %#v, got %#v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxTagsPerCard = 20
+	maxTagLength   = 32
+	maxSearchQuery = 200
+
+	// private-use runes mark the matches in ts_headline output so the card text can be escaped
+	// before they are turned into <mark> tags
+	highlightStart = "\ue000"
+	highlightStop  = "\ue001"
+)
+
+var (
+	// ErrInvalidTags is returned when a tag list does not pass validation
+	ErrInvalidTags = errors.New("invalid tags")
+	// ErrInvalidSearch is returned when a search has neither a query nor a tag
+	ErrInvalidSearch = errors.New("invalid search")
+)
+
+var (
+	frontHeadlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, HighlightAll=true", highlightStart, highlightStop)
+	backHeadlineOptions  = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=25, MinWords=8", highlightStart, highlightStop)
+	highlightReplacer    = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+)
+
+// SetCardTags replaces the tags of a card in user's deck and returns them normalised
+func (s *ContentService) SetCardTags(ctx context.Context, cardID, userID uuid.UUID, tags []string) ([]string, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.contentRepository.SetUserDeckCardTags(ctx, cardID, userID, tags); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("card not found or access denied")
+		}
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ListTags returns the tags used across the user's decks with their card counts
+func (s *ContentService) ListTags(ctx context.Context, userID uuid.UUID) ([]TagCount, error) {
+	return s.contentRepository.ListUserTags(ctx, userID)
+}
+
+// SearchCards finds cards in all of the user's decks whose front or back match the query,
+// optionally narrowed to a tag and a module
+func (s *ContentService) SearchCards(ctx context.Context, userID uuid.UUID, search CardSearchQuery) ([]CardSearchResult, error) {
+	search.Query = strings.TrimSpace(search.Query)
+	if len(search.Query) > maxSearchQuery {
+		return nil, fmt.Errorf("%w: query cannot be longer than %d characters", ErrInvalidSearch, maxSearchQuery)
+	}
+	if search.Tag != "" {
+		tag, err := normalizeTag(search.Tag)
+		if err != nil {
+			return nil, err
+		}
+		search.Tag = tag
+	}
+	if search.Query == "" && search.Tag == "" {
+		return nil, fmt.Errorf("%w: q or tag is required", ErrInvalidSearch)
+	}
+	page, err := normalizePage(search.Page)
+	if err != nil {
+		return nil, err
+	}
+	search.Page = page
+
+	results, err := s.contentRepository.SearchUserDeckCards(ctx, userID, search, frontHeadlineOptions, backHeadlineOptions)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].FrontHighlight = highlight(results[i].FrontHighlight)
+		results[i].BackHighlight = highlight(results[i].BackHighlight)
+		s.attachImageURL(ctx, &results[i].UserDeckCard)
+	}
+	return results, nil
+}
+
+// highlight escapes the card text and turns the match markers into <mark> tags
+func highlight(text string) string {
+	return highlightReplacer.Replace(html.EscapeString(text))
+}
+
+// NormalizeTags lowercases the tags, replaces inner whitespace with dashes and drops duplicates,
+// keeping the order they were given in
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTagsPerCard {
+		return nil, fmt.Errorf("%w: a card can have at most %d tags", ErrInvalidTags, maxTagsPerCard)
+	}
+	return normalized, nil
+}
+
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.Join(strings.Fields(tag), "-"))
+	if tag == "" {
+		return "", fmt.Errorf("%w: tags cannot be empty", ErrInvalidTags)
+	}
+	if len([]rune(tag)) > maxTagLength {
+		return "", fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidTags, tag, maxTagLength)
+	}
+	for _, r := range tag {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("%w: tag %q contains invalid characters", ErrInvalidTags, tag)
+		}
+	}
+	return tag, nil
+}
@@ -1,41 +1,128 @@
 package content
 
 import (
+	"StudyHub/internal/extract"
+	"StudyHub/internal/rabbitmq"
 	"context"
 	"errors"
 	"io"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+const (
+	DefaultNewCardsLimit    = 20
+	DefaultReviewCardsLimit = 200
+	maxDueCardsLimit        = 1000
+
+	DefaultPageLimit = 50
+	maxPageLimit     = 500
+
+	// a review that keeps losing to concurrent reviews of the same card gives up after this
+	maxReviewAttempts = 3
+)
+
+// ErrReviewConflict is returned when concurrent reviews of a card kept changing it while it was rescheduled
+var ErrReviewConflict = errors.New("card was reviewed concurrently")
+
 type Queue interface {
+	Publish(ctx context.Context, objectID uuid.UUID) error
 	Consume() chan amqp.Delivery
+	Retry(ctx context.Context, msg amqp.Delivery, reason string) (bool, error)
+	Reject(ctx context.Context, msg amqp.Delivery, reason string) error
+	ListDeadLetters(ctx context.Context, limit int) ([]rabbitmq.DeadLetter, error)
+	ReplayDeadLetters(ctx context.Context, objectIDs []uuid.UUID) ([]uuid.UUID, error)
 }
 
 type FileStorage interface {
 	GetObject(ctx context.Context, objectID string) (io.ReadCloser, error)
+	CreatePresidedURL(ctx context.Context, key string) (string, error)
 }
 
 type AI interface {
-	GenerateFlashCards(ctx context.Context, file io.ReadCloser) (string, error)
+	GenerateFlashCards(ctx context.Context, file io.ReadCloser, opts GenerationOptions) (string, error)
+	RepairFlashCards(ctx context.Context, response string, problems []string) (string, error)
+	GenerateSummary(ctx context.Context, file io.ReadCloser) (string, error)
+	GenerateQuiz(ctx context.Context, file io.ReadCloser) (string, error)
+}
+
+// DocumentConverter turns uploads into pdfs and splits pdfs into page ranges, see the convert package
+type DocumentConverter interface {
+	ConvertToPdf(ctx context.Context, data []byte, name string) ([]byte, error)
+	SplitPdf(ctx context.Context, data []byte, span int) ([][]byte, error)
+}
+
+// Fetcher downloads the page behind a link resource and extracts its text
+type Fetcher interface {
+	Fetch(ctx context.Context, link string) (extract.Document, error)
 }
 
 type ContentRepository interface {
-	isPdf(ctx context.Context, id uuid.UUID) string
-	CreateCardsFromObject(ctx context.Context, cards []Flashcard) error
+	GetGenerationSource(ctx context.Context, objectID uuid.UUID) (string, string, error)
 	ListCardsFromObjects(ctx context.Context, ids []uuid.UUID) ([]Flashcard, error)
+	ListSummariesFromObjects(ctx context.Context, ids []uuid.UUID) ([]ResourceSummary, error)
+	ListQuizzesFromObjects(ctx context.Context, ids []uuid.UUID) ([]QuizQuestion, error)
+	HasSummaryAndQuiz(ctx context.Context, objectID uuid.UUID) (bool, error)
+	GetVisibleStorageObjectFileType(ctx context.Context, objectID, userID uuid.UUID) (string, error)
 
 	// User Deck Methods
 	AddCardToUserDeck(ctx context.Context, userID, weekID, flashcardID uuid.UUID) error
-	CreateCustomCardInDeck(ctx context.Context, userID, weekID uuid.UUID, front, back string) (UserDeckCard, error)
+	CreateCustomCardsInDeck(ctx context.Context, cards []UserDeckCard) error
 	RemoveCardFromUserDeck(ctx context.Context, cardID, userID uuid.UUID) error
 	GetUserDeckForWeek(ctx context.Context, userID, weekID uuid.UUID) ([]UserDeckCard, error)
 	GetUserDeckCard(ctx context.Context, cardID, userID uuid.UUID) (UserDeckCard, error)
+	ListDueCards(ctx context.Context, userID uuid.UUID, filter DueCardsFilter) ([]DueCard, error)
+	ListDeckCardsForExport(ctx context.Context, userID uuid.UUID, weekID, moduleRunID *uuid.UUID) ([]DueCard, error)
 	UpdateUserDeckCard(ctx context.Context, cardID, userID uuid.UUID, front, back *string) error
-	RecordCardReview(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error
+	SetUserDeckCardTags(ctx context.Context, cardID, userID uuid.UUID, tags []string) error
+	ListUserTags(ctx context.Context, userID uuid.UUID) ([]TagCount, error)
+	SearchUserDeckCards(ctx context.Context, userID uuid.UUID, search CardSearchQuery, frontOptions, backOptions string) ([]CardSearchResult, error)
+	RecordCardReview(ctx context.Context, review CardReview, schedule CardSchedule, reviewCount int) error
+	ListCardReviews(ctx context.Context, cardID, userID uuid.UUID, page Page) ([]CardReview, error)
+	ListUserReviews(ctx context.Context, userID uuid.UUID, page Page) ([]CardReview, error)
+
+	// Shared decks
+	PublishDeck(ctx context.Context, ownerID, weekID uuid.UUID, title, description string) (uuid.UUID, error)
+	UnpublishDeck(ctx context.Context, ownerID, weekID uuid.UUID) error
+	ListSharedDecksForWeek(ctx context.Context, weekID, viewerID uuid.UUID) ([]SharedDeck, error)
+	GetSharedDeck(ctx context.Context, deckID, viewerID uuid.UUID) (SharedDeck, error)
+	ListSharedDeckCards(ctx context.Context, deckID uuid.UUID) ([]Flashcard, error)
+	SubscribeToSharedDeck(ctx context.Context, deckID, userID uuid.UUID) (int, bool, error)
+	UnsubscribeFromSharedDeck(ctx context.Context, deckID, userID uuid.UUID) error
+	GetSharedDeckUpdates(ctx context.Context, deckID, userID uuid.UUID) (SharedDeckUpdates, error)
+	ApplySharedDeckUpdates(ctx context.Context, deckID, userID uuid.UUID, req ApplySharedDeckUpdatesRequest) (SharedDeckUpdates, error)
+
+	// Practice exams
+	ListExamCandidates(ctx context.Context, userID, moduleRunID uuid.UUID, weekIDs []uuid.UUID, source ExamSource) ([]ExamCandidate, error)
+	CreateExam(ctx context.Context, exam Exam, questions []ExamQuestion) error
+	GetExam(ctx context.Context, examID, userID uuid.UUID) (Exam, error)
+	ListExams(ctx context.Context, userID uuid.UUID, moduleRunID *uuid.UUID, page Page) ([]Exam, error)
+	GetCurrentExamQuestion(ctx context.Context, examID uuid.UUID) (ExamQuestion, error)
+	AnswerExamQuestion(ctx context.Context, examID, questionID uuid.UUID, option *int) error
+	FinishExam(ctx context.Context, examID uuid.UUID, status ExamStatus) error
+	FinishExpiredExams(ctx context.Context) (int64, error)
+	ListExamQuestions(ctx context.Context, examID uuid.UUID) ([]ExamQuestionReview, error)
+
+	// Generation jobs
+	StartGenerationJob(ctx context.Context, objectID uuid.UUID) (GenerationJob, error)
+	UpdateGenerationJobStatus(ctx context.Context, jobID uuid.UUID, status GenerationStatus, errMsg *string) error
+	FinishGenerationJob(ctx context.Context, jobID uuid.UUID, generated GeneratedContent) error
+	GetResourceGenerationJob(ctx context.Context, resourceID uuid.UUID) (GenerationJob, error)
+	GetRegenerationObject(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool) (uuid.UUID, error)
+	QueueGenerationJob(ctx context.Context, objectID uuid.UUID, opts GenerationOptions) (GenerationJob, error)
+
+	// Analytics
+	ListReviewDays(ctx context.Context, userID uuid.UUID, timeZone string) ([]DailyReviewCount, error)
+	GetModuleRetention(ctx context.Context, userID uuid.UUID, since time.Time) ([]ModuleRetention, error)
+	GetCardMaturity(ctx context.Context, userID uuid.UUID, matureIntervalDays int) (CardMaturity, error)
 	GetDeckStatistics(ctx context.Context, userID, weekID uuid.UUID) (DeckStats, error)
+
+	// Scheduling settings
+	GetStudySettings(ctx context.Context, userID uuid.UUID) (StudySettings, error)
+	UpsertStudySettings(ctx context.Context, settings StudySettings) error
 }
 
 type ContentService struct {
@@ -44,14 +131,18 @@ type ContentService struct {
 	delivery          chan amqp.Delivery
 	fileStorage       FileStorage
 	ai                AI
+	fetcher           Fetcher
+	converter         DocumentConverter
 }
 
-func NewContentService(contentRepo ContentRepository, q Queue, fileStorage FileStorage, ai AI) *ContentService {
+func NewContentService(contentRepo ContentRepository, q Queue, fileStorage FileStorage, ai AI, fetcher Fetcher, converter DocumentConverter) *ContentService {
 	s := &ContentService{
 		contentRepository: contentRepo,
 		queue:             q,
 		fileStorage:       fileStorage,
 		ai:                ai,
+		fetcher:           fetcher,
+		converter:         converter,
 		delivery:          q.Consume(),
 	}
 	s.startWorkers()
@@ -69,23 +160,52 @@ func (s *ContentService) ListSelectedObjectsCards(ctx context.Context, ids []uui
 
 }
 
+// ListSelectedObjectsSummaries returns the generated summaries of the objects, objects without one are left out
+func (s *ContentService) ListSelectedObjectsSummaries(ctx context.Context, ids []uuid.UUID) ([]ResourceSummary, error) {
+	return s.contentRepository.ListSummariesFromObjects(ctx, ids)
+}
+
+// ListSelectedObjectsQuizzes returns the generated quiz questions of the objects, ordered by object and position
+func (s *ContentService) ListSelectedObjectsQuizzes(ctx context.Context, ids []uuid.UUID) ([]QuizQuestion, error) {
+	return s.contentRepository.ListQuizzesFromObjects(ctx, ids)
+}
+
 // AddCardToUserDeck adds an auto-generated flashcard to user's personal deck
 func (s *ContentService) AddCardToUserDeck(ctx context.Context, userID, weekID, flashcardID uuid.UUID) error {
 	// Add to user's deck (validation and duplicate check handled by repository)
 	return s.contentRepository.AddCardToUserDeck(ctx, userID, weekID, flashcardID)
 }
 
-// CreateCustomCard creates a custom flashcard directly in user's deck
-func (s *ContentService) CreateCustomCard(ctx context.Context, userID, weekID uuid.UUID, front, back string) (UserDeckCard, error) {
-	if front == "" || back == "" {
-		return UserDeckCard{}, errors.New("front and back cannot be empty")
+// GetUserDeckForWeek retrieves all cards in user's deck for a specific week
+func (s *ContentService) GetUserDeckForWeek(ctx context.Context, userID, weekID uuid.UUID) ([]UserDeckCard, error) {
+	cards, err := s.contentRepository.GetUserDeckForWeek(ctx, userID, weekID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		s.attachImageURL(ctx, &cards[i])
 	}
-	return s.contentRepository.CreateCustomCardInDeck(ctx, userID, weekID, front, back)
+	return cards, nil
 }
 
-// GetUserDeckForWeek retrieves all cards in user's deck for a specific week
-func (s *ContentService) GetUserDeckForWeek(ctx context.Context, userID, weekID uuid.UUID) ([]UserDeckCard, error) {
-	return s.contentRepository.GetUserDeckForWeek(ctx, userID, weekID)
+// GetDueCards returns the review queue for today across all of the user's weeks
+func (s *ContentService) GetDueCards(ctx context.Context, userID uuid.UUID, filter DueCardsFilter) ([]DueCard, error) {
+	if filter.NewLimit < 0 || filter.ReviewLimit < 0 {
+		return nil, errors.New("limits cannot be negative")
+	}
+	filter.NewLimit = min(filter.NewLimit, maxDueCardsLimit)
+	filter.ReviewLimit = min(filter.ReviewLimit, maxDueCardsLimit)
+	if filter.Now.IsZero() {
+		filter.Now = time.Now().UTC()
+	}
+	cards, err := s.contentRepository.ListDueCards(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		s.attachImageURL(ctx, &cards[i].UserDeckCard)
+	}
+	return cards, nil
 }
 
 // UpdateDeckCard updates card content (only if user owns it)
@@ -111,18 +231,136 @@ func (s *ContentService) RemoveCardFromDeck(ctx context.Context, cardID, userID
 	return err
 }
 
-// RecordCardReview records a study session for a card
-func (s *ContentService) RecordCardReview(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error {
+// RecordCardReview records a study session for a card, reschedules it with the user's scheduler
+// and appends the review to the card's history
+func (s *ContentService) RecordCardReview(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int, responseTimeMs *int) (UserDeckCard, error) {
 	// Validate difficulty rating
 	if difficultyRating < 1 || difficultyRating > 5 {
-		return errors.New("difficulty rating must be between 1 and 5")
+		return UserDeckCard{}, errors.New("difficulty rating must be between 1 and 5")
+	}
+	if responseTimeMs != nil && *responseTimeMs < 0 {
+		return UserDeckCard{}, errors.New("response time cannot be negative")
+	}
+
+	settings, err := s.contentRepository.GetStudySettings(ctx, userID)
+	if err != nil {
+		return UserDeckCard{}, err
 	}
 
-	err := s.contentRepository.RecordCardReview(ctx, cardID, userID, difficultyRating)
+	// concurrent reviews of the card each schedule from the state the other one left
+	for attempt := 1; ; attempt++ {
+		card, err := s.recordCardReview(ctx, cardID, userID, settings, difficultyRating, responseTimeMs)
+		if errors.Is(err, ErrReviewConflict) && attempt < maxReviewAttempts {
+			continue
+		}
+		return card, err
+	}
+}
+
+// recordCardReview schedules the card from its current state, the write fails with ErrReviewConflict when
+// another review changed the card in between
+func (s *ContentService) recordCardReview(ctx context.Context, cardID, userID uuid.UUID, settings StudySettings, difficultyRating int, responseTimeMs *int) (UserDeckCard, error) {
+	card, err := s.contentRepository.GetUserDeckCard(ctx, cardID, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return UserDeckCard{}, errors.New("card not found or access denied")
+		}
+		return UserDeckCard{}, err
+	}
+
+	now := time.Now().UTC()
+	next := NewScheduler(settings).Schedule(card.CardSchedule, difficultyRating, now)
+	review := CardReview{
+		ID:                   uuid.New(),
+		CardID:               &card.ID,
+		UserID:               userID,
+		WeekID:               card.WeekID,
+		Scheduler:            settings.Scheduler,
+		DifficultyRating:     difficultyRating,
+		Recalled:             next.Repetitions > 0,
+		ResponseTimeMs:       responseTimeMs,
+		PreviousIntervalDays: card.IntervalDays,
+		NextIntervalDays:     next.IntervalDays,
+		ReviewedAt:           now,
+	}
+
+	err = s.contentRepository.RecordCardReview(ctx, review, next, card.ReviewCount)
 	if err == pgx.ErrNoRows {
-		return errors.New("card not found or access denied")
+		return UserDeckCard{}, errors.New("card not found or access denied")
 	}
-	return err
+	if err != nil {
+		return UserDeckCard{}, err
+	}
+
+	card.CardSchedule = next
+	card.ReviewCount++
+	card.DifficultyRating = &difficultyRating
+	return card, nil
+}
+
+// ListCardReviews returns a page of the review history of one of the user's cards
+func (s *ContentService) ListCardReviews(ctx context.Context, cardID, userID uuid.UUID, page Page) ([]CardReview, error) {
+	page, err := normalizePage(page)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.contentRepository.GetUserDeckCard(ctx, cardID, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.New("card not found or access denied")
+		}
+		return nil, err
+	}
+	return s.contentRepository.ListCardReviews(ctx, cardID, userID, page)
+}
+
+// ListUserReviews returns a page of every review the user made, including reviews of removed cards
+func (s *ContentService) ListUserReviews(ctx context.Context, userID uuid.UUID, page Page) ([]CardReview, error) {
+	page, err := normalizePage(page)
+	if err != nil {
+		return nil, err
+	}
+	return s.contentRepository.ListUserReviews(ctx, userID, page)
+}
+
+func normalizePage(page Page) (Page, error) {
+	if page.Limit < 0 || page.Offset < 0 {
+		return Page{}, errors.New("limit and offset cannot be negative")
+	}
+	if page.Limit == 0 {
+		page.Limit = DefaultPageLimit
+	}
+	page.Limit = min(page.Limit, maxPageLimit)
+	return page, nil
+}
+
+// GetStudySettings returns which scheduler is used for the user's reviews
+func (s *ContentService) GetStudySettings(ctx context.Context, userID uuid.UUID) (StudySettings, error) {
+	return s.contentRepository.GetStudySettings(ctx, userID)
+}
+
+// UpdateStudySettings switches the user's scheduler, existing card state is kept and picked up by the new one
+func (s *ContentService) UpdateStudySettings(ctx context.Context, userID uuid.UUID, scheduler SchedulerType, desiredRetention *float64) (StudySettings, error) {
+	if !scheduler.IsValid() {
+		return StudySettings{}, errors.New("scheduler must be one of sm2, fsrs")
+	}
+
+	settings, err := s.contentRepository.GetStudySettings(ctx, userID)
+	if err != nil {
+		return StudySettings{}, err
+	}
+	settings.Scheduler = scheduler
+	if desiredRetention != nil {
+		if *desiredRetention < 0.7 || *desiredRetention > 0.99 {
+			return StudySettings{}, errors.New("desired retention must be between 0.7 and 0.99")
+		}
+		settings.DesiredRetention = *desiredRetention
+	}
+
+	if err := s.contentRepository.UpsertStudySettings(ctx, settings); err != nil {
+		return StudySettings{}, err
+	}
+	return settings, nil
 }
 
 // GetDeckStats retrieves statistics for a user's deck in a specific week
@@ -0,0 +1,156 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	errSharedDeckNotFound   = errors.New("shared deck not found")
+	errSubscriptionNotFound = errors.New("subscription not found")
+)
+
+// PublishDeck shares the user's current deck for the week. Publishing again replaces the snapshot
+// and offers the changes to subscribers as updates.
+func (s *ContentService) PublishDeck(ctx context.Context, ownerID, weekID uuid.UUID, title, description string) (SharedDeck, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return SharedDeck{}, errors.New("title cannot be empty")
+	}
+
+	cards, err := s.contentRepository.GetUserDeckForWeek(ctx, ownerID, weekID)
+	if err != nil {
+		return SharedDeck{}, err
+	}
+	if len(cards) == 0 {
+		return SharedDeck{}, errors.New("cannot publish an empty deck")
+	}
+
+	deckID, err := s.contentRepository.PublishDeck(ctx, ownerID, weekID, title, strings.TrimSpace(description))
+	if err != nil {
+		return SharedDeck{}, err
+	}
+	return s.contentRepository.GetSharedDeck(ctx, deckID, ownerID)
+}
+
+// UnpublishDeck stops sharing the user's deck for the week
+func (s *ContentService) UnpublishDeck(ctx context.Context, ownerID, weekID uuid.UUID) error {
+	err := s.contentRepository.UnpublishDeck(ctx, ownerID, weekID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errSharedDeckNotFound
+	}
+	return err
+}
+
+// ListSharedDecks returns the decks classmates published for a week
+func (s *ContentService) ListSharedDecks(ctx context.Context, weekID, viewerID uuid.UUID) ([]SharedDeck, error) {
+	return s.contentRepository.ListSharedDecksForWeek(ctx, weekID, viewerID)
+}
+
+// GetSharedDeck returns a published deck with its cards
+func (s *ContentService) GetSharedDeck(ctx context.Context, deckID, viewerID uuid.UUID) (SharedDeckWithCards, error) {
+	deck, err := s.contentRepository.GetSharedDeck(ctx, deckID, viewerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SharedDeckWithCards{}, errSharedDeckNotFound
+		}
+		return SharedDeckWithCards{}, err
+	}
+
+	cards, err := s.contentRepository.ListSharedDeckCards(ctx, deckID)
+	if err != nil {
+		return SharedDeckWithCards{}, err
+	}
+	return SharedDeckWithCards{SharedDeck: deck, Cards: cards}, nil
+}
+
+// SubscribeToSharedDeck clones the shared deck into the user's own deck for the same week. Subscribing
+// again returns the existing subscription without cloning anything
+func (s *ContentService) SubscribeToSharedDeck(ctx context.Context, deckID, userID uuid.UUID) (SubscribeResult, error) {
+	deck, err := s.contentRepository.GetSharedDeck(ctx, deckID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SubscribeResult{}, errSharedDeckNotFound
+		}
+		return SubscribeResult{}, err
+	}
+	if deck.OwnerID == userID {
+		return SubscribeResult{}, errors.New("cannot subscribe to your own deck")
+	}
+	if deck.Subscribed {
+		return SubscribeResult{Deck: deck}, nil
+	}
+
+	added, created, err := s.contentRepository.SubscribeToSharedDeck(ctx, deckID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SubscribeResult{}, errSharedDeckNotFound
+		}
+		return SubscribeResult{}, err
+	}
+
+	deck, err = s.contentRepository.GetSharedDeck(ctx, deckID, userID)
+	if err != nil {
+		return SubscribeResult{}, err
+	}
+	return SubscribeResult{Deck: deck, CardsAdded: added, Created: created}, nil
+}
+
+// UnsubscribeFromSharedDeck stops updates from a shared deck, cards already cloned are kept
+func (s *ContentService) UnsubscribeFromSharedDeck(ctx context.Context, deckID, userID uuid.UUID) error {
+	err := s.contentRepository.UnsubscribeFromSharedDeck(ctx, deckID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errSubscriptionNotFound
+	}
+	return err
+}
+
+// GetSharedDeckUpdates previews the author's changes since the user last synced
+func (s *ContentService) GetSharedDeckUpdates(ctx context.Context, deckID, userID uuid.UUID) (SharedDeckUpdates, error) {
+	updates, err := s.contentRepository.GetSharedDeckUpdates(ctx, deckID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return SharedDeckUpdates{}, errSubscriptionNotFound
+	}
+	return updates, err
+}
+
+// ApplySharedDeckUpdates pulls the author's changes into the user's deck and returns what was applied,
+// conflicts left unresolved by req stay in the result and keep the subscription unsynced
+func (s *ContentService) ApplySharedDeckUpdates(ctx context.Context, deckID, userID uuid.UUID, req ApplySharedDeckUpdatesRequest) (SharedDeckUpdates, error) {
+	updates, err := s.contentRepository.ApplySharedDeckUpdates(ctx, deckID, userID, req)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return SharedDeckUpdates{}, errSubscriptionNotFound
+	}
+	return updates, err
+}
+
+// resolveConflicts clears the conflict flag of the changes req overwrites with the author's version and
+// returns how many conflicts req neither overwrites nor keeps
+func resolveConflicts(changes []SharedCardChange, req ApplySharedDeckUpdatesRequest) int {
+	resolved := make(map[uuid.UUID]bool, len(req.Overwrite)+len(req.Keep))
+	for _, id := range req.Keep {
+		resolved[id] = false
+	}
+	for _, id := range req.Overwrite {
+		resolved[id] = true
+	}
+
+	unresolved := 0
+	for i := range changes {
+		if !changes[i].Conflict {
+			continue
+		}
+		overwrite, ok := resolved[changes[i].CardID]
+		switch {
+		case !ok:
+			unresolved++
+		case overwrite:
+			changes[i].Conflict = false
+		}
+	}
+	return unresolved
+}
@@ -0,0 +1,116 @@
+package content
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// subscriptionRepo keeps the subscriptions of one shared deck in memory
+type subscriptionRepo struct {
+	ContentRepository
+	deck        SharedDeck
+	subscribers map[uuid.UUID]bool
+	// raceOnce makes the next subscribe find a subscription made after the service checked
+	raceOnce bool
+}
+
+func (r *subscriptionRepo) GetSharedDeck(ctx context.Context, deckID, userID uuid.UUID) (SharedDeck, error) {
+	deck := r.deck
+	deck.Subscribed = r.subscribers[userID]
+	return deck, nil
+}
+
+func (r *subscriptionRepo) SubscribeToSharedDeck(ctx context.Context, deckID, userID uuid.UUID) (int, bool, error) {
+	if r.raceOnce {
+		r.raceOnce = false
+		r.subscribers[userID] = true
+	}
+	if r.subscribers[userID] {
+		return 0, false, nil
+	}
+	r.subscribers[userID] = true
+	return r.deck.CardCount, true, nil
+}
+
+func TestSubscribeToSharedDeck(t *testing.T) {
+	ownerID, userID := uuid.New(), uuid.New()
+	deck := SharedDeck{ID: uuid.New(), OwnerID: ownerID, CardCount: 5}
+
+	t.Run("subscribing twice returns the existing subscription", func(t *testing.T) {
+		s := &ContentService{contentRepository: &subscriptionRepo{deck: deck, subscribers: map[uuid.UUID]bool{}}}
+
+		first, err := s.SubscribeToSharedDeck(context.Background(), deck.ID, userID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !first.Created || first.CardsAdded != 5 || !first.Deck.Subscribed {
+			t.Errorf("expected a new subscription with 5 cards, got %+v", first)
+		}
+
+		second, err := s.SubscribeToSharedDeck(context.Background(), deck.ID, userID)
+		if err != nil {
+			t.Fatalf("expected the second subscribe to succeed, got %v", err)
+		}
+		if second.Created || second.CardsAdded != 0 || !second.Deck.Subscribed {
+			t.Errorf("expected the existing subscription without new cards, got %+v", second)
+		}
+	})
+
+	t.Run("a concurrent subscribe is not an error", func(t *testing.T) {
+		s := &ContentService{contentRepository: &subscriptionRepo{deck: deck, subscribers: map[uuid.UUID]bool{}, raceOnce: true}}
+
+		result, err := s.SubscribeToSharedDeck(context.Background(), deck.ID, userID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Created || result.CardsAdded != 0 || !result.Deck.Subscribed {
+			t.Errorf("expected the existing subscription without new cards, got %+v", result)
+		}
+	})
+
+	t.Run("own deck", func(t *testing.T) {
+		s := &ContentService{contentRepository: &subscriptionRepo{deck: deck, subscribers: map[uuid.UUID]bool{}}}
+		if _, err := s.SubscribeToSharedDeck(context.Background(), deck.ID, ownerID); err == nil {
+			t.Errorf("expected subscribing to your own deck to fail")
+		}
+	})
+}
+
+func TestResolveConflicts(t *testing.T) {
+	edited, kept, plain := uuid.New(), uuid.New(), uuid.New()
+	changes := func() []SharedCardChange {
+		return []SharedCardChange{
+			{CardID: edited, Conflict: true},
+			{CardID: kept, Conflict: true},
+			{CardID: plain},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		req          ApplySharedDeckUpdatesRequest
+		wantLeft     int
+		wantConflict []bool
+	}{
+		{"nothing resolved", ApplySharedDeckUpdatesRequest{}, 2, []bool{true, true, false}},
+		{"overwrite one", ApplySharedDeckUpdatesRequest{Overwrite: []uuid.UUID{edited}}, 1, []bool{false, true, false}},
+		{"overwrite and keep", ApplySharedDeckUpdatesRequest{Overwrite: []uuid.UUID{edited}, Keep: []uuid.UUID{kept}}, 0, []bool{false, true, false}},
+		{"overwrite wins over keep", ApplySharedDeckUpdatesRequest{Overwrite: []uuid.UUID{edited}, Keep: []uuid.UUID{edited, kept}}, 0, []bool{false, true, false}},
+		{"unknown ids", ApplySharedDeckUpdatesRequest{Overwrite: []uuid.UUID{uuid.New()}}, 2, []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changes()
+			if left := resolveConflicts(got, tt.req); left != tt.wantLeft {
+				t.Errorf("unresolved = %d, want %d", left, tt.wantLeft)
+			}
+			for i, want := range tt.wantConflict {
+				if got[i].Conflict != want {
+					t.Errorf("change %d conflict = %v, want %v", i, got[i].Conflict, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package content
+
+import (
+	"StudyHub/internal/extract"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// file type of the storage object behind a link resource, its url is the page
+	linkFileType = "link"
+
+	// a text chunk is about as long as pagesPerChunk pages of a lecture pdf
+	charsPerChunk = 40000
+)
+
+var errNoText = errors.New("the document has no text to generate from")
+
+// loadChunks reads the source of a storage object into the chunks it is generated from. Pdfs are split
+// by page range, slides are grouped by slide number, links, notes and Word documents are cut into text
+// chunks. Any other file type is converted to pdf first
+func (s *ContentService) loadChunks(ctx context.Context, objectID uuid.UUID) ([]documentChunk, error) {
+	fileType, url, err := s.contentRepository.GetGenerationSource(ctx, objectID)
+	if err != nil {
+		return nil, err
+	}
+	fileType = strings.ToLower(strings.TrimPrefix(fileType, "."))
+
+	if fileType == linkFileType {
+		doc, err := s.fetcher.Fetch(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch link: %w", err)
+		}
+		if doc.PDF != nil {
+			return s.splitDocument(ctx, doc.PDF), nil
+		}
+		text := doc.Text
+		if doc.Title != "" {
+			text = doc.Title + "\n\n" + text
+		}
+		return textChunks(text)
+	}
+
+	file, err := s.fileStorage.GetObject(ctx, objectID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file from storage: %w", err)
+	}
+	data, err := io.ReadAll(file)
+	_ = file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	switch fileType {
+	case "pdf":
+		//large documents are generated page range by page range, every card keeps the pages it came from
+		return s.splitDocument(ctx, data), nil
+	case "txt", "text", "md", "markdown":
+		return textChunks(extract.Text(data))
+	case "docx":
+		text, err := extract.Docx(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract text: %w", err)
+		}
+		return textChunks(text)
+	case "pptx":
+		slides, err := extract.Pptx(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract text: %w", err)
+		}
+		return slideChunks(slides)
+	}
+
+	//older office formats and images still go through the converter
+	data, err = s.converter.ConvertToPdf(ctx, data, objectID.String()+"."+fileType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert file to pdf: %w", err)
+	}
+	return s.splitDocument(ctx, data), nil
+}
+
+// textChunks cuts text into chunks of about charsPerChunk characters, breaking between paragraphs.
+// Text has no pages, so its chunks and cards have none either
+func textChunks(text string) ([]documentChunk, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errNoText
+	}
+
+	var (
+		chunks  []documentChunk
+		current strings.Builder
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, documentChunk{Data: []byte(current.String())})
+			current.Reset()
+		}
+	}
+	for _, paragraph := range splitLong(strings.Split(text, "\n"), charsPerChunk) {
+		if current.Len()+len(paragraph)+1 > charsPerChunk {
+			flush()
+		}
+		current.WriteString(paragraph)
+		current.WriteByte('\n')
+	}
+	flush()
+	return chunks, nil
+}
+
+// slideChunks groups the slides of a presentation pagesPerChunk at a time, slide numbers stand in for pages
+func slideChunks(slides []string) ([]documentChunk, error) {
+	var chunks []documentChunk
+	for start := 0; start < len(slides); start += pagesPerChunk {
+		end := min(start+pagesPerChunk, len(slides))
+
+		var text strings.Builder
+		for i := start; i < end; i++ {
+			if slides[i] == "" {
+				continue
+			}
+			fmt.Fprintf(&text, "Slide %d:\n%s\n\n", i+1, slides[i])
+		}
+		if text.Len() == 0 {
+			continue
+		}
+		chunks = append(chunks, documentChunk{
+			PageStart: intPtr(start + 1),
+			PageEnd:   intPtr(end),
+			Data:      []byte(text.String()),
+		})
+	}
+	if len(chunks) == 0 {
+		return nil, errNoText
+	}
+	return chunks, nil
+}
+
+// splitLong cuts lines longer than limit, so a text without line breaks still fits in chunks
+func splitLong(lines []string, limit int) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		for len(line) > limit {
+			cut := strings.LastIndexByte(line[:limit], ' ')
+			if cut <= 0 {
+				cut = limit
+				// never cut a utf-8 sequence in half
+				for cut > 0 && line[cut]&0xC0 == 0x80 {
+					cut--
+				}
+			}
+			result = append(result, line[:cut])
+			line = strings.TrimLeft(line[cut:], " ")
+		}
+		result = append(result, line)
+	}
+	return result
+}
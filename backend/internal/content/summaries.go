@@ -0,0 +1,88 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxKeyPointLength           = 1000
+	maxGlossaryTermLength       = 200
+	maxGlossaryDefinitionLength = 1000
+)
+
+type generatedSummary struct {
+	KeyPoints []string       `json:"key_points"`
+	Glossary  []GlossaryTerm `json:"glossary"`
+}
+
+// parseGeneratedSummary reads the summary of one chunk out of the model's answer, empty or overlong
+// key points and terms are dropped and the key points keep the pages of the chunk
+func parseGeneratedSummary(response string, chunk documentChunk) (ResourceSummary, error) {
+	text, err := generatedJSON(response)
+	if err != nil {
+		return ResourceSummary{}, err
+	}
+	if text[0] != '{' {
+		return ResourceSummary{}, errors.New("the answer is not a summary object")
+	}
+	var generated generatedSummary
+	if err := json.Unmarshal([]byte(text), &generated); err != nil {
+		return ResourceSummary{}, fmt.Errorf("the answer is not a valid summary: %v", err)
+	}
+
+	summary := ResourceSummary{KeyPoints: make([]SummaryKeyPoint, 0), Glossary: make([]GlossaryTerm, 0)}
+	for _, point := range generated.KeyPoints {
+		point = strings.TrimSpace(point)
+		if point == "" || utf8.RuneCountInString(point) > maxKeyPointLength {
+			continue
+		}
+		summary.KeyPoints = append(summary.KeyPoints, SummaryKeyPoint{Text: point, PageStart: chunk.PageStart, PageEnd: chunk.PageEnd})
+	}
+	for _, term := range generated.Glossary {
+		term.Term = strings.TrimSpace(term.Term)
+		term.Definition = strings.TrimSpace(term.Definition)
+		if term.Term == "" || term.Definition == "" ||
+			utf8.RuneCountInString(term.Term) > maxGlossaryTermLength ||
+			utf8.RuneCountInString(term.Definition) > maxGlossaryDefinitionLength {
+			continue
+		}
+		summary.Glossary = append(summary.Glossary, term)
+	}
+	if len(summary.KeyPoints) == 0 && len(summary.Glossary) == 0 {
+		return ResourceSummary{}, errors.New("the summary has no usable key points or glossary terms")
+	}
+	return summary, nil
+}
+
+// mergeSummaries joins the chunk summaries in page order, a term defined again in a later chunk is dropped.
+// Returns nil when no chunk produced a summary
+func mergeSummaries(parts []*ResourceSummary, objectID uuid.UUID) *ResourceSummary {
+	merged := ResourceSummary{StorageObjectID: objectID, KeyPoints: make([]SummaryKeyPoint, 0), Glossary: make([]GlossaryTerm, 0)}
+	seen := make(map[string]bool)
+	found := false
+	for _, part := range parts {
+		if part == nil {
+			continue
+		}
+		found = true
+		merged.KeyPoints = append(merged.KeyPoints, part.KeyPoints...)
+		for _, term := range part.Glossary {
+			key := textKey(term.Term)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged.Glossary = append(merged.Glossary, term)
+		}
+	}
+	if !found {
+		return nil
+	}
+	return &merged
+}
@@ -0,0 +1,105 @@
+package content
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseGeneratedSummary(t *testing.T) {
+	chunk := documentChunk{PageStart: intPtr(21), PageEnd: intPtr(40)}
+
+	tests := []struct {
+		name              string
+		response          string
+		expectedKeyPoints []string
+		expectedTerms     []string
+		expectedErr       bool
+	}{
+		{
+			name:              "summary object",
+			response:          `{"key_points": ["Cells make ATP", "  Osmosis moves water  "], "glossary": [{"term": "ATP", "definition": "Energy carrier"}]}`,
+			expectedKeyPoints: []string{"Cells make ATP", "Osmosis moves water"},
+			expectedTerms:     []string{"ATP"},
+		},
+		{
+			name:              "in a code fence",
+			response:          "```json\n{\"key_points\": [\"Only point\"]}\n```",
+			expectedKeyPoints: []string{"Only point"},
+		},
+		{
+			name: "empty and overlong entries are dropped",
+			response: `{"key_points": ["", "` + strings.Repeat("x", maxKeyPointLength+1) + `", "Kept"],
+				"glossary": [{"term": "", "definition": "no term"}, {"term": "No definition", "definition": " "},
+				{"term": "` + strings.Repeat("t", maxGlossaryTermLength+1) + `", "definition": "long term"}, {"term": "Enzyme", "definition": "Catalyst"}]}`,
+			expectedKeyPoints: []string{"Kept"},
+			expectedTerms:     []string{"Enzyme"},
+		},
+		{name: "nothing usable", response: `{"key_points": [" "], "glossary": []}`, expectedErr: true},
+		{name: "array instead of object", response: `["Cells make ATP"]`, expectedErr: true},
+		{name: "not json", response: "Here is your summary", expectedErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := parseGeneratedSummary(tt.response, chunk)
+			if tt.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", summary)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			points := make([]string, len(summary.KeyPoints))
+			for i, point := range summary.KeyPoints {
+				points[i] = point.Text
+				if *point.PageStart != 21 || *point.PageEnd != 40 {
+					t.Errorf("key point %d lost the chunk pages", i)
+				}
+			}
+			terms := make([]string, len(summary.Glossary))
+			for i, term := range summary.Glossary {
+				terms[i] = term.Term
+			}
+			if strings.Join(points, "|") != strings.Join(tt.expectedKeyPoints, "|") {
+				t.Errorf("key points = %q, want %q", points, tt.expectedKeyPoints)
+			}
+			if strings.Join(terms, "|") != strings.Join(tt.expectedTerms, "|") {
+				t.Errorf("glossary = %q, want %q", terms, tt.expectedTerms)
+			}
+		})
+	}
+}
+
+func TestMergeSummaries(t *testing.T) {
+	objectID := uuid.New()
+	first := &ResourceSummary{
+		KeyPoints: []SummaryKeyPoint{{Text: "Page one point"}},
+		Glossary:  []GlossaryTerm{{Term: "ATP", Definition: "Energy carrier"}, {Term: "Osmosis", Definition: "Water movement"}},
+	}
+	second := &ResourceSummary{
+		KeyPoints: []SummaryKeyPoint{{Text: "Page two point"}},
+		Glossary:  []GlossaryTerm{{Term: " atp ", Definition: "defined again"}, {Term: "Enzyme", Definition: "Catalyst"}},
+	}
+
+	merged := mergeSummaries([]*ResourceSummary{first, nil, second}, objectID)
+	if merged == nil || merged.StorageObjectID != objectID {
+		t.Fatalf("expected a summary of the object, got %+v", merged)
+	}
+	if len(merged.KeyPoints) != 2 || merged.KeyPoints[0].Text != "Page one point" || merged.KeyPoints[1].Text != "Page two point" {
+		t.Errorf("expected the key points in page order, got %+v", merged.KeyPoints)
+	}
+	terms := make([]string, len(merged.Glossary))
+	for i, term := range merged.Glossary {
+		terms[i] = term.Term + "=" + term.Definition
+	}
+	if strings.Join(terms, "|") != "ATP=Energy carrier|Osmosis=Water movement|Enzyme=Catalyst" {
+		t.Errorf("expected the first definition of a term to win, got %q", terms)
+	}
+
+	if merged := mergeSummaries([]*ResourceSummary{nil, nil}, objectID); merged != nil {
+		t.Errorf("expected nil without chunk summaries, got %+v", merged)
+	}
+}
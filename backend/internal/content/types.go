@@ -13,6 +13,53 @@ type Flashcard struct {
 	WeekID   *uuid.UUID
 	Front    string
 	Back     string
+	CardType CardType
+	Content  *CardContent // nil for basic cards
+	// pages of the source document the card was generated from, nil when they are not known
+	PageStart *int
+	PageEnd   *int
+}
+
+type CardType string
+
+const (
+	CardBasic          CardType = "basic"
+	CardCloze          CardType = "cloze"
+	CardMultipleChoice CardType = "multiple_choice"
+	CardImage          CardType = "image"
+)
+
+func (t CardType) IsValid() bool {
+	return t == CardBasic || t == CardCloze || t == CardMultipleChoice || t == CardImage
+}
+
+// CardContent is the structured payload of non-basic cards. Front and Back always hold a plain-text
+// rendering of the card as well, so clients and exports that only know basic cards keep working.
+type CardContent struct {
+	// Cloze: note text with deletions written as {{c1::answer}} or {{c1::answer::hint}}.
+	// Every deletion number becomes its own card, ClozeIndex is the one the card asks for.
+	Text       string `json:"text,omitempty"`
+	ClozeIndex int    `json:"cloze_index,omitempty"`
+
+	// Multiple choice: Front is the question, Options holds the answer and its distractors
+	Options       []string `json:"options,omitempty"`
+	CorrectOption *int     `json:"correct_option,omitempty"`
+
+	// Image: an uploaded storage object, optionally with regions to hide. Every region becomes
+	// its own card, OcclusionIndex is the one the card asks for.
+	ImageObjectID  *uuid.UUID  `json:"image_object_id,omitempty"`
+	ImageURL       string      `json:"image_url,omitempty"` // short-lived download link, filled in responses only
+	Occlusions     []Occlusion `json:"occlusions,omitempty"`
+	OcclusionIndex *int        `json:"occlusion_index,omitempty"`
+}
+
+// Occlusion is a rectangle on an image card, in fractions of the image width and height
+type Occlusion struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Label  string  `json:"label"`
 }
 
 // UserDeckCard represents a flashcard in a user's personal deck for a specific week
@@ -24,11 +71,175 @@ type UserDeckCard struct {
 	Front             string
 	Back              string
 	IsCustom          bool
-	LastReviewedAt    *time.Time
 	ReviewCount       int
 	DifficultyRating  *int // 1-5 scale
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
+	CardType          CardType
+	Content           *CardContent // nil for basic cards
+	NoteID            *uuid.UUID   // shared by the cards generated from one cloze or image occlusion note
+	Tags              []string
+	// file and pages the source flashcard was generated from, nil for custom cards
+	SourceObjectID *uuid.UUID
+	PageStart      *int
+	PageEnd        *int
+	CardSchedule
+}
+
+// CardSchedule is the spaced-repetition state of a deck card, DueAt is nil until the first review
+type CardSchedule struct {
+	LastReviewedAt *time.Time
+	DueAt          *time.Time
+	EaseFactor     float64 // SM-2 only
+	IntervalDays   int
+	Repetitions    int
+	Lapses         int
+	Stability      *float64 // FSRS only
+	FSRSDifficulty *float64 // FSRS only
+}
+
+// DueCard is a deck card with enough context to show where it comes from, used by the review queue and exports
+type DueCard struct {
+	UserDeckCard
+	ModuleID    uuid.UUID
+	ModuleName  string
+	ModuleRunID uuid.UUID
+	WeekNumber  int
+}
+
+// DueCardsFilter narrows the cross-week review queue
+type DueCardsFilter struct {
+	Now         time.Time
+	NewLimit    int
+	ReviewLimit int
+	ModuleID    *uuid.UUID
+	ModuleRunID *uuid.UUID
+}
+
+// ExportFormat is the file format a deck can be downloaded in
+type ExportFormat string
+
+const (
+	ExportAPKG ExportFormat = "apkg"
+	ExportCSV  ExportFormat = "csv"
+	ExportJSON ExportFormat = "json"
+)
+
+func (f ExportFormat) IsValid() bool {
+	return f == ExportAPKG || f == ExportCSV || f == ExportJSON
+}
+
+// DeckExport is a rendered deck file ready to be downloaded
+type DeckExport struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// ImportFormat is a file format cards can be imported from
+type ImportFormat string
+
+const (
+	ImportAPKG    ImportFormat = "apkg"
+	ImportCSV     ImportFormat = "csv"
+	ImportQuizlet ImportFormat = "quizlet" // Quizlet's default export, one "term<TAB>definition" per line
+)
+
+func (f ImportFormat) IsValid() bool {
+	return f == ImportAPKG || f == ImportCSV || f == ImportQuizlet
+}
+
+type ImportRowStatus string
+
+const (
+	ImportRowOK        ImportRowStatus = "ok"
+	ImportRowDuplicate ImportRowStatus = "duplicate"
+	ImportRowInvalid   ImportRowStatus = "invalid"
+)
+
+// ImportRow is the outcome for a single card of an imported file, Row is the 1-based line or note number
+type ImportRow struct {
+	Row    int             `json:"row"`
+	Front  string          `json:"front"`
+	Back   string          `json:"back"`
+	Tags   []string        `json:"tags,omitempty"`
+	Status ImportRowStatus `json:"status"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// ImportResult reports what an import did, or would do on a dry run
+type ImportResult struct {
+	DryRun     bool        `json:"dry_run"`
+	Total      int         `json:"total"`
+	Imported   int         `json:"imported"` // cards created, or that would be created on a dry run
+	Duplicates int         `json:"duplicates"`
+	Invalid    int         `json:"invalid"`
+	Rows       []ImportRow `json:"rows"`
+}
+
+// SharedDeck is a snapshot of a user's week deck published for classmates.
+// Version is bumped on every re-publish so subscribers can see which author edits they have not synced yet.
+type SharedDeck struct {
+	ID              uuid.UUID `json:"id"`
+	OwnerID         uuid.UUID `json:"owner_id"`
+	OwnerName       string    `json:"owner_name"`
+	WeekID          uuid.UUID `json:"week_id"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	Version         int       `json:"version"`
+	CardCount       int       `json:"card_count"`
+	SubscriberCount int       `json:"subscriber_count"`
+	Subscribed      bool      `json:"subscribed"`
+	PublishedAt     time.Time `json:"published_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SharedDeckWithCards is a published deck together with its cards
+type SharedDeckWithCards struct {
+	SharedDeck
+	Cards []Flashcard `json:"cards"`
+}
+
+// SharedCardChange is an author edit to a card the subscriber already has in their deck
+type SharedCardChange struct {
+	CardID      uuid.UUID `json:"card_id"`
+	FlashcardID uuid.UUID `json:"flashcard_id"`
+	Front       string    `json:"front"`
+	Back        string    `json:"back"`
+	NewFront    string    `json:"new_front"`
+	NewBack     string    `json:"new_back"`
+	Conflict    bool      `json:"conflict"` // the subscriber edited the card, applying updates keeps their version
+}
+
+// ApplySharedDeckUpdatesRequest resolves conflicting cards by their deck card id. Overwrite takes the
+// author's version and makes the card follow the shared deck again, Keep keeps the subscriber's edit.
+// The subscription only counts as synced once every conflict is resolved.
+type ApplySharedDeckUpdatesRequest struct {
+	Overwrite []uuid.UUID `json:"overwrite,omitempty"`
+	Keep      []uuid.UUID `json:"keep,omitempty"`
+}
+
+// SharedDeckUpdates lists what changed in a shared deck since the subscriber last synced
+type SharedDeckUpdates struct {
+	SharedDeckID  uuid.UUID          `json:"shared_deck_id"`
+	SyncedVersion int                `json:"synced_version"`
+	Version       int                `json:"version"`
+	Added         []Flashcard        `json:"added"`
+	Changed       []SharedCardChange `json:"changed"`
+}
+
+// SubscribeResult is returned after subscribing to a shared deck
+type SubscribeResult struct {
+	Deck       SharedDeck `json:"deck"`
+	CardsAdded int        `json:"cards_added"`
+	// Created is false when the user was subscribed already, nothing is cloned again then
+	Created bool `json:"-"`
+}
+
+// PublishDeckRequest for publishing a week deck
+type PublishDeckRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
 }
 
 // AddCardToDeckRequest for adding auto-generated card to user deck
@@ -36,10 +247,14 @@ type AddCardToDeckRequest struct {
 	FlashcardID string `json:"flashcard_id"`
 }
 
-// CreateCustomCardRequest for creating custom flashcard
+// CreateCustomCardRequest for creating custom flashcard. Type defaults to basic.
+// Cloze notes take their text from Content.Text and use Back as optional extra info.
 type CreateCustomCardRequest struct {
-	Front string `json:"front"`
-	Back  string `json:"back"`
+	Type    CardType     `json:"type,omitempty"`
+	Front   string       `json:"front"`
+	Back    string       `json:"back"`
+	Content *CardContent `json:"content,omitempty"`
+	Tags    []string     `json:"tags,omitempty"`
 }
 
 // UpdateCardRequest for updating card content
@@ -48,9 +263,72 @@ type UpdateCardRequest struct {
 	Back  *string `json:"back,omitempty"`
 }
 
+// SetCardTagsRequest replaces the tags of a deck card, an empty list removes them all
+type SetCardTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// TagCount is one of the user's tags with the number of cards carrying it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// CardSearchQuery filters the full-text search over a user's cards, Query or Tag must be set
+type CardSearchQuery struct {
+	Query    string
+	Tag      string
+	ModuleID *uuid.UUID
+	Page     Page
+}
+
+// CardSearchResult is a matched card with its fronts and backs HTML-escaped and the matched
+// terms wrapped in <mark> tags. Rank is 0 when only filtering by tag.
+type CardSearchResult struct {
+	DueCard
+	Rank           float64
+	FrontHighlight string
+	BackHighlight  string
+}
+
 // RecordReviewRequest for recording card review
 type RecordReviewRequest struct {
-	DifficultyRating int `json:"difficulty_rating"` // 1-5
+	DifficultyRating int  `json:"difficulty_rating"`          // 1-5
+	ResponseTimeMs   *int `json:"response_time_ms,omitempty"` // time spent before answering
+}
+
+// CardReview is one entry of the append-only review log, CardID is nil once the card is removed from the deck
+type CardReview struct {
+	ID                   uuid.UUID     `json:"id"`
+	CardID               *uuid.UUID    `json:"card_id"`
+	UserID               uuid.UUID     `json:"user_id"`
+	WeekID               uuid.UUID     `json:"week_id"`
+	Scheduler            SchedulerType `json:"scheduler"`
+	DifficultyRating     int           `json:"difficulty_rating"`
+	Recalled             bool          `json:"recalled"`
+	ResponseTimeMs       *int          `json:"response_time_ms,omitempty"`
+	PreviousIntervalDays int           `json:"previous_interval_days"`
+	NextIntervalDays     int           `json:"next_interval_days"`
+	ReviewedAt           time.Time     `json:"reviewed_at"`
+}
+
+// Page is a limit/offset window used by the history endpoints
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// StudySettings holds the per-user choice of spaced-repetition algorithm
+type StudySettings struct {
+	UserID           uuid.UUID     `json:"user_id"`
+	Scheduler        SchedulerType `json:"scheduler"`
+	DesiredRetention float64       `json:"desired_retention"` // FSRS only
+}
+
+// UpdateStudySettingsRequest for changing the user's scheduler
+type UpdateStudySettingsRequest struct {
+	Scheduler        SchedulerType `json:"scheduler"`
+	DesiredRetention *float64      `json:"desired_retention,omitempty"`
 }
 
 // DeckStats represents statistics for a user's deck in a week
@@ -60,3 +338,252 @@ type DeckStats struct {
 	AverageRating  float64    `json:"average_rating"`
 	LastReviewedAt *time.Time `json:"last_reviewed_at,omitempty"`
 }
+
+// DailyReviewCount is one cell of the study heatmap, Date is formatted as YYYY-MM-DD
+type DailyReviewCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// ModuleRetention is the share of successful reviews of already learnt cards in a module
+type ModuleRetention struct {
+	ModuleID      uuid.UUID `json:"module_id"`
+	ModuleName    string    `json:"module_name"`
+	Reviews       int       `json:"reviews"`
+	Recalled      int       `json:"recalled"`
+	RetentionRate float64   `json:"retention_rate"`
+}
+
+// CardMaturity splits the user's cards by how well they are learnt
+type CardMaturity struct {
+	New    int `json:"new"`
+	Young  int `json:"young"`
+	Mature int `json:"mature"`
+}
+
+// StudyAnalytics is the per-user analytics across all decks
+type StudyAnalytics struct {
+	DailyReviews    []DailyReviewCount `json:"daily_reviews"`
+	TotalReviews    int                `json:"total_reviews"`
+	CurrentStreak   int                `json:"current_streak"`
+	LongestStreak   int                `json:"longest_streak"`
+	ModuleRetention []ModuleRetention  `json:"module_retention"`
+	Cards           CardMaturity       `json:"cards"`
+}
+
+// ExamSource selects where the questions of a practice exam come from
+type ExamSource string
+
+const (
+	ExamSourceFlashcards ExamSource = "flashcards" // cards generated from the week resources
+	ExamSourceDeck       ExamSource = "deck"       // the user's own deck
+	ExamSourceBoth       ExamSource = "both"
+)
+
+func (s ExamSource) IsValid() bool {
+	return s == ExamSourceFlashcards || s == ExamSourceDeck || s == ExamSourceBoth
+}
+
+// ExamStatus is the state of a practice exam attempt
+type ExamStatus string
+
+const (
+	ExamInProgress ExamStatus = "in_progress"
+	ExamCompleted  ExamStatus = "completed"
+	ExamExpired    ExamStatus = "expired" // the time ran out before the exam was submitted
+)
+
+// CreateExamRequest starts a practice exam, no WeekIDs means every week of the module run
+type CreateExamRequest struct {
+	ModuleRunID     uuid.UUID   `json:"module_run_id"`
+	WeekIDs         []uuid.UUID `json:"week_ids,omitempty"`
+	QuestionCount   int         `json:"question_count,omitempty"`
+	DurationMinutes int         `json:"duration_minutes,omitempty"`
+	Source          ExamSource  `json:"source,omitempty"`
+}
+
+// Exam is a timed practice exam attempt, CorrectCount and Score are set once it is finished
+type Exam struct {
+	ID               uuid.UUID  `json:"id"`
+	UserID           uuid.UUID  `json:"user_id"`
+	ModuleRunID      uuid.UUID  `json:"module_run_id"`
+	Source           ExamSource `json:"source"`
+	Status           ExamStatus `json:"status"`
+	QuestionCount    int        `json:"question_count"`
+	AnsweredCount    int        `json:"answered_count"`
+	DurationSeconds  int        `json:"duration_seconds"`
+	RemainingSeconds int        `json:"remaining_seconds"`
+	CorrectCount     *int       `json:"correct_count,omitempty"`
+	Score            *float64   `json:"score,omitempty"` // percentage of correct answers
+	StartedAt        time.Time  `json:"started_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+	TimedOut         bool       `json:"-"` // past the deadline and its grace period
+}
+
+// ExamCandidate is a card that can be turned into an exam question
+type ExamCandidate struct {
+	FlashcardID *uuid.UUID
+	DeckCardID  *uuid.UUID
+	WeekID      uuid.UUID
+	WeekNumber  int
+	CardType    CardType
+	Front       string
+	Back        string
+	Content     *CardContent
+}
+
+// ExamQuestion is a multiple-choice question as served during the exam, without the answer
+type ExamQuestion struct {
+	ID            uuid.UUID    `json:"id"`
+	ExamID        uuid.UUID    `json:"-"`
+	Position      int          `json:"position"`
+	WeekID        *uuid.UUID   `json:"week_id"`
+	WeekNumber    int          `json:"week_number"`
+	FlashcardID   *uuid.UUID   `json:"-"`
+	DeckCardID    *uuid.UUID   `json:"-"`
+	CardType      CardType     `json:"card_type"`
+	Prompt        string       `json:"prompt"`
+	Content       *CardContent `json:"content,omitempty"`
+	Options       []string     `json:"options"`
+	CorrectOption int          `json:"-"`
+}
+
+// ExamSession is the state of an exam together with the question to answer next, nil once all are answered
+type ExamSession struct {
+	Exam     Exam          `json:"exam"`
+	Question *ExamQuestion `json:"question"`
+}
+
+// AnswerExamQuestionRequest answers the current question, a nil option skips it
+type AnswerExamQuestionRequest struct {
+	QuestionID uuid.UUID `json:"question_id"`
+	Option     *int      `json:"option"`
+}
+
+// ExamQuestionReview is a graded question of a finished exam
+type ExamQuestionReview struct {
+	ExamQuestion
+	CorrectOption  int        `json:"correct_option"`
+	SelectedOption *int       `json:"selected_option"`
+	Correct        bool       `json:"correct"`
+	AnsweredAt     *time.Time `json:"answered_at,omitempty"`
+}
+
+// ExamWeekScore is the score of the questions that came from one week
+type ExamWeekScore struct {
+	WeekID     *uuid.UUID `json:"week_id"`
+	WeekNumber int        `json:"week_number"`
+	Questions  int        `json:"questions"`
+	Correct    int        `json:"correct"`
+	Score      float64    `json:"score"`
+}
+
+// ExamResult is a graded attempt with its per-week breakdown, weakest week first
+type ExamResult struct {
+	Exam      Exam                 `json:"exam"`
+	Weeks     []ExamWeekScore      `json:"weeks"`
+	Questions []ExamQuestionReview `json:"questions"`
+}
+
+// GenerationStatus is the stage a flashcard-generation job is in
+type GenerationStatus string
+
+const (
+	GenerationQueued     GenerationStatus = "queued"
+	GenerationConverting GenerationStatus = "converting" // fetching the file and converting it to pdf
+	GenerationGenerating GenerationStatus = "generating" // waiting for the AI to return the cards
+	GenerationParsing    GenerationStatus = "parsing"
+	GenerationSaved      GenerationStatus = "saved"
+	GenerationFailed     GenerationStatus = "failed"
+)
+
+// GenerationJob is one run of the flashcard-generation pipeline over an uploaded file
+type GenerationJob struct {
+	ID              uuid.UUID          `json:"id"`
+	StorageObjectID uuid.UUID          `json:"storage_object_id"`
+	Status          GenerationStatus   `json:"status"`
+	Error           *string            `json:"error,omitempty"`
+	CardCount       *int               `json:"card_count,omitempty"`
+	Attempts        int                `json:"attempts"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	StartedAt       *time.Time         `json:"started_at,omitempty"`
+	FinishedAt      *time.Time         `json:"finished_at,omitempty"`
+	Options         *GenerationOptions `json:"options,omitempty"` // set for regenerations
+	Version         *int               `json:"version,omitempty"` // card set version the job saved
+}
+
+// GenerationDifficulty is how demanding the generated questions should be
+type GenerationDifficulty string
+
+const (
+	DifficultyEasy   GenerationDifficulty = "easy"
+	DifficultyMedium GenerationDifficulty = "medium"
+	DifficultyHard   GenerationDifficulty = "hard"
+)
+
+// GeneratedCardType is the shape of the generated front/back pairs
+type GeneratedCardType string
+
+const (
+	GeneratedQuestion   GeneratedCardType = "question"   // a question and its answer
+	GeneratedDefinition GeneratedCardType = "definition" // a term and its definition
+	GeneratedCloze      GeneratedCardType = "cloze"      // a statement with a ____ gap and the missing text
+)
+
+// GenerationOptions tunes a generation run, zero values leave the choice to the model
+type GenerationOptions struct {
+	CardCount   int                  `json:"card_count,omitempty"`
+	Difficulty  GenerationDifficulty `json:"difficulty,omitempty"`
+	Language    string               `json:"language,omitempty"` // e.g. "German", defaults to the document's language
+	FocusTopics []string             `json:"focus_topics,omitempty"`
+	CardType    GeneratedCardType    `json:"card_type,omitempty"`
+}
+
+// ResourceSummary is the generated summary of an uploaded file, key points are in document order
+type ResourceSummary struct {
+	StorageObjectID uuid.UUID         `json:"storage_object_id"`
+	KeyPoints       []SummaryKeyPoint `json:"key_points"`
+	Glossary        []GlossaryTerm    `json:"glossary"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+type SummaryKeyPoint struct {
+	Text      string `json:"text"`
+	PageStart *int   `json:"page_start,omitempty"`
+	PageEnd   *int   `json:"page_end,omitempty"`
+}
+
+type GlossaryTerm struct {
+	Term       string `json:"term"`
+	Definition string `json:"definition"`
+}
+
+// QuizQuestion is a generated multiple-choice question about an uploaded file. Unlike exam questions it is
+// practice material, so the correct option and its explanation are sent along
+type QuizQuestion struct {
+	ID              uuid.UUID `json:"id"`
+	StorageObjectID uuid.UUID `json:"storage_object_id"`
+	Position        int       `json:"position"`
+	Question        string    `json:"question"`
+	Options         []string  `json:"options"`
+	CorrectOption   int       `json:"correct_option"`
+	Explanation     string    `json:"explanation"`
+	PageStart       *int      `json:"page_start,omitempty"`
+	PageEnd         *int      `json:"page_end,omitempty"`
+}
+
+// GeneratedContent is what one generation run saves for a document, Summary and Quiz are nil when the run
+// did not produce them and the saved ones are kept
+type GeneratedContent struct {
+	Cards   []Flashcard
+	Summary *ResourceSummary
+	Quiz    []QuizQuestion
+}
+
+// ReplayDeadLettersRequest picks the dead letters to replay, no object ids replays all of them
+type ReplayDeadLettersRequest struct {
+	ObjectIDs []uuid.UUID `json:"object_ids"`
+}
@@ -1,19 +1,25 @@
 package content
 
 import (
+	"StudyHub/internal/convert"
+	"StudyHub/internal/extract"
+	"StudyHub/internal/rabbitmq"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"log/slog"
-	"mime/multipart"
-	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-const GOTENBERG_URL = "http://gotenberg:3000/forms/libreoffice/convert"
+// failed jobs keep the start of the error, parse errors carry the whole AI response
+const maxGenerationErrorLength = 1000
+
+// errInvalidObjectID is returned for a delivery whose body is not an object id
+var errInvalidObjectID = errors.New("invalid object id")
 
 func (s *ContentService) startWorkers() {
 	for i := range 5 {
@@ -26,38 +32,21 @@ func (s *ContentService) worker() {
 	for msg := range s.delivery {
 
 		key := string(msg.Body)
-		slog.Info("started on job with object id", "ID", string(msg.Body))
-
-		file, err := s.fileStorage.GetObject(context.Background(), key)
-		if err != nil {
-			slog.Error("error getting file from storage", "err", err)
-			continue
-		}
-
-		//if file is not pdf, we get the file type and send to the ai service to convert it to pdf, then we continue with the pdf file
-		file, err = s.convertToPdf(context.TODO(), key, file)
-		if err != nil {
-			slog.Error("falied to convert pdf", "err", err)
-			continue
-		}
+		slog.Info("started on job with object id", "ID", key)
 
-		result, err := s.ai.GenerateFlashCards(context.Background(), file)
+		ctx := context.Background()
+		jobID, err := s.generateCards(ctx, key)
 		if err != nil {
-			slog.Error("failed to generate content", "error: ", err)
+			slog.Error("failed to generate flashcards", "object id", key, "err", err)
+			if permanentGenerationError(err) {
+				s.failGeneration(ctx, msg, jobID, err)
+				continue
+			}
+			s.retryGeneration(ctx, msg, jobID, err)
 			continue
 		}
-		flashcards, err := cleanupResult(result, string(msg.Body))
-		if err != nil {
-			slog.Error("failed to clenup flashcards", "error: ", err)
-			continue
-		}
-
-		//save to the DB
-
-		err = s.contentRepository.CreateCardsFromObject(context.Background(), flashcards)
-		if err != nil {
-			slog.Error("failed to save flashcards", "err", err)
-			continue
+		if err := msg.Ack(false); err != nil {
+			slog.Error("failed to ack message", "object id", key, "err", err)
 		}
 
 		slog.Info("finished job ", " object id :", key)
@@ -66,71 +55,145 @@ func (s *ContentService) worker() {
 	slog.Info("worker exiting")
 }
 
-func cleanupResult(data, id string) ([]Flashcard, error) {
-	var res []Flashcard
-	err := json.Unmarshal([]byte(data), &res)
+// generateCards runs the pipeline for one uploaded object and records every stage on its generation job.
+// A failed stage is left for the caller to record, since it depends on whether the delivery is retried
+func (s *ContentService) generateCards(ctx context.Context, key string) (uuid.UUID, error) {
+	objectID, err := uuid.Parse(key)
 	if err != nil {
-		return []Flashcard{}, fmt.Errorf("%w | Payload: %s", err, data)
+		return uuid.Nil, fmt.Errorf("%w: %v", errInvalidObjectID, err)
 	}
-	objectID, _ := uuid.Parse(id)
-	for i := range res {
-		res[i].ID = uuid.New()
-		res[i].ObjectID = &objectID
+	job, err := s.contentRepository.StartGenerationJob(ctx, objectID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	jobID := job.ID
+	// uploads run with the defaults, regenerations carry the options they were asked with
+	var opts GenerationOptions
+	if job.Options != nil {
+		opts = *job.Options
 	}
 
-	return res, nil
-}
+	//files are split by page range, links, notes and office files are read as text in Go
+	chunks, err := s.loadChunks(ctx, objectID)
+	if err != nil {
+		return jobID, err
+	}
 
-func (s *ContentService) convertToPdf(ctx context.Context, idString string, body io.ReadCloser) (io.ReadCloser, error) {
-	id, _ := uuid.Parse(idString)
-	fileType := s.contentRepository.isPdf(ctx, id)
-	if fileType == "pdf" {
-		return body, nil
+	//the summary and the quiz are made once per document, regenerating the cards keeps them
+	hasExtras, err := s.contentRepository.HasSummaryAndQuiz(ctx, objectID)
+	if err != nil {
+		return jobID, err
 	}
 
-	return makeGotenbergRequest(ctx, body, idString+"."+fileType)
+	s.setGenerationStatus(ctx, jobID, GenerationGenerating)
+	generated, err := s.generateChunks(ctx, chunks, objectID, opts, !hasExtras, func() {
+		s.setGenerationStatus(ctx, jobID, GenerationParsing)
+	})
+	if err != nil {
+		return jobID, err
+	}
 
+	//save to the DB
+	if err := s.contentRepository.FinishGenerationJob(ctx, jobID, generated); err != nil {
+		return jobID, fmt.Errorf("failed to save flashcards: %w", err)
+	}
+	return jobID, nil
 }
 
-func makeGotenbergRequest(ctx context.Context, body io.Reader, name string) (io.ReadCloser, error) {
-	//everything read from pr will be writen to pw
-	slog.Info("starting pdf conversion")
+// retryGeneration hands a failed delivery back to the queue, the job goes back to queued while
+// the delivery has attempts left and fails once it is dead-lettered
+func (s *ContentService) retryGeneration(ctx context.Context, msg amqp.Delivery, jobID uuid.UUID, cause error) {
+	reason := generationReason(cause)
+	retried, err := s.queue.Retry(ctx, msg, reason)
+	if err != nil {
+		slog.Error("failed to retry message", "object id", string(msg.Body), "err", err)
+	}
+	if jobID == uuid.Nil {
+		return
+	}
 
-	pr, pw := io.Pipe()
-	writer := multipart.NewWriter(pw)
+	status := GenerationFailed
+	if retried || err != nil {
+		// a delivery that could not be moved was put back on the queue
+		status = GenerationQueued
+	}
+	if updateErr := s.contentRepository.UpdateGenerationJobStatus(ctx, jobID, status, &reason); updateErr != nil {
+		slog.Error("failed to update generation job", "job id", jobID, "status", status, "err", updateErr)
+	}
+}
 
-	go func() {
-		defer func() {
-			if closeErr := writer.Close(); closeErr != nil {
-				slog.Error("failed to close multipart writer", "err", closeErr)
-			}
-			if closeErr := pw.Close(); closeErr != nil {
-				slog.Error("failed to close pipe writer", "err", closeErr)
-			}
-		}()
+// failGeneration fails the job at once and dead-letters the delivery, for errors a retry cannot fix
+func (s *ContentService) failGeneration(ctx context.Context, msg amqp.Delivery, jobID uuid.UUID, cause error) {
+	reason := generationReason(cause)
+	status := GenerationFailed
+	if err := s.queue.Reject(ctx, msg, reason); err != nil {
+		slog.Error("failed to dead-letter message", "object id", string(msg.Body), "err", err)
+		// a delivery that could not be moved was put back on the queue
+		status = GenerationQueued
+	}
+	if jobID == uuid.Nil {
+		return
+	}
+	if err := s.contentRepository.UpdateGenerationJobStatus(ctx, jobID, status, &reason); err != nil {
+		slog.Error("failed to update generation job", "job id", jobID, "status", status, "err", err)
+	}
+}
 
-		part, err := writer.CreateFormFile("file", name)
-		log.Println(name)
-		if err != nil {
-			return
-		}
+// permanentGenerationError reports whether a failure repeats on every attempt, those are not retried
+func permanentGenerationError(err error) bool {
+	switch {
+	case errors.Is(err, errInvalidObjectID),
+		errors.Is(err, errNoText),
+		errors.Is(err, errNoValidCards),
+		// a document the converter rejected fails the same way on every attempt
+		errors.Is(err, convert.ErrRejected),
+		errors.Is(err, extract.ErrBlockedAddress),
+		errors.Is(err, extract.ErrUnsupportedContent),
+		// a broken office file or a page over the size limit does not change between attempts
+		errors.Is(err, extract.ErrInvalidDocument),
+		errors.Is(err, extract.ErrTooLarge):
+		return true
+	}
+	return false
+}
 
-		_, _ = io.Copy(part, body)
-	}()
-	req, err := http.NewRequest("POST", GOTENBERG_URL, pr)
+// generationReason is the error kept on a failed job
+func generationReason(cause error) string {
+	reason := cause.Error()
+	if len(reason) > maxGenerationErrorLength {
+		reason = strings.ToValidUTF8(reason[:maxGenerationErrorLength], "") + "..."
+	}
+	return reason
+}
+
+// GetGenerationStatus returns the newest flashcard-generation job of the file behind a resource
+func (s *ContentService) GetGenerationStatus(ctx context.Context, resourceID uuid.UUID) (GenerationJob, error) {
+	job, err := s.contentRepository.GetResourceGenerationJob(ctx, resourceID)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, pgx.ErrNoRows) {
+			return GenerationJob{}, errors.New("generation job not found")
+		}
+		return GenerationJob{}, err
 	}
+	return job, nil
+}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// ListDeadLetters returns the generation deliveries that ran out of attempts
+func (s *ContentService) ListDeadLetters(ctx context.Context, limit int) ([]rabbitmq.DeadLetter, error) {
+	page, err := normalizePage(Page{Limit: limit})
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %s", resp.Status)
-	}
-	return resp.Body, err
+	return s.queue.ListDeadLetters(ctx, page.Limit)
+}
 
+// ReplayDeadLetters sends dead-lettered objects through the pipeline again, each gets a new generation job
+func (s *ContentService) ReplayDeadLetters(ctx context.Context, objectIDs []uuid.UUID) ([]uuid.UUID, error) {
+	return s.queue.ReplayDeadLetters(ctx, objectIDs)
+}
+
+func (s *ContentService) setGenerationStatus(ctx context.Context, jobID uuid.UUID, status GenerationStatus) {
+	if err := s.contentRepository.UpdateGenerationJobStatus(ctx, jobID, status, nil); err != nil {
+		slog.Error("failed to update generation job", "job id", jobID, "status", status, "err", err)
+	}
 }
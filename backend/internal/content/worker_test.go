@@ -0,0 +1,34 @@
+package content
+
+import (
+	"StudyHub/internal/convert"
+	"StudyHub/internal/extract"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPermanentGenerationError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		permanent bool
+	}{
+		{"invalid object id", errInvalidObjectID, true},
+		{"no text", fmt.Errorf("extract: %w", errNoText), true},
+		{"no valid cards", errNoValidCards, true},
+		{"converter rejected", fmt.Errorf("convert: %w", convert.ErrRejected), true},
+		{"blocked address", extract.ErrBlockedAddress, true},
+		{"unsupported link content", extract.ErrUnsupportedContent, true},
+		{"invalid office document", fmt.Errorf("pptx: %w", extract.ErrInvalidDocument), true},
+		{"link too large", fmt.Errorf("fetch: %w", extract.ErrTooLarge), true},
+		{"timeout", context.DeadlineExceeded, false},
+		{"storage down", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := permanentGenerationError(tt.err); got != tt.permanent {
+			t.Errorf("%s: permanentGenerationError() = %v, want %v", tt.name, got, tt.permanent)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Package convert turns uploaded documents into pdfs and splits pdfs into page ranges for the content worker
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGotenbergURL = "http://gotenberg:3000"
+	defaultTimeout      = 2 * time.Minute
+	defaultMaxBytes     = 100 << 20
+	defaultLibreOffice  = "soffice"
+)
+
+var (
+	// ErrRejected is returned when the converter refuses a document, sending it again gives the same result
+	ErrRejected = errors.New("document rejected by the converter")
+	// ErrUnsupported is returned by converters that cannot do an operation at all, e.g. LibreOffice splitting pdfs
+	ErrUnsupported = errors.New("operation not supported by the converter")
+)
+
+// Converter converts documents to pdf and splits pdfs, name is the file name the converter
+// uses to tell the input format from its extension
+type Converter interface {
+	ConvertToPdf(ctx context.Context, data []byte, name string) ([]byte, error)
+	// SplitPdf cuts a pdf every span pages and returns the parts in page order
+	SplitPdf(ctx context.Context, data []byte, span int) ([][]byte, error)
+}
+
+// Config selects a converter, empty fields fall back to the defaults
+type Config struct {
+	Backend         string // gotenberg or libreoffice
+	URL             string // gotenberg only, e.g. http://gotenberg:3000
+	Timeout         time.Duration
+	Retries         int   // gotenberg only, extra attempts after a failed request
+	MaxBytes        int64 // largest document sent to and accepted from the converter
+	LibreOfficePath string
+}
+
+// Factory builds a converter from its config
+type Factory func(cfg Config) (Converter, error)
+
+var backends = map[string]Factory{
+	"gotenberg":   newGotenberg,
+	"libreoffice": newLibreOffice,
+}
+
+// Backends returns the converter names, sorted
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the converter named in cfg
+func New(cfg Config) (Converter, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Backend))
+	if name == "" {
+		name = "gotenberg"
+	}
+	factory, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown converter %q, expected one of %s", cfg.Backend, strings.Join(Backends(), ", "))
+	}
+	if cfg.Timeout < 0 || cfg.Retries < 0 || cfg.MaxBytes < 0 {
+		return nil, fmt.Errorf("converter timeout, retries and size limit cannot be negative")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultMaxBytes
+	}
+	return factory(cfg)
+}
+
+func checkSize(data []byte, maxBytes int64) error {
+	if int64(len(data)) > maxBytes {
+		return fmt.Errorf("%w: document is larger than %d bytes", ErrRejected, maxBytes)
+	}
+	return nil
+}
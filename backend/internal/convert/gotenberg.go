@@ -0,0 +1,198 @@
+package convert
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	gotenbergConvertPath = "/forms/libreoffice/convert"
+	gotenbergSplitPath   = "/forms/pdfengines/split"
+
+	// the start of an error response is enough to tell what went wrong
+	maxErrorBody = 512
+	retryBackoff = time.Second
+)
+
+var trailingNumbers = regexp.MustCompile(`(\d+)\D*$`)
+
+// Gotenberg converts through a Gotenberg server, failed requests are retried unless Gotenberg rejected the document
+type Gotenberg struct {
+	baseURL  string
+	client   *http.Client
+	retries  int
+	maxBytes int64
+	// the wait before a retry grows by this with every attempt
+	backoff time.Duration
+}
+
+func newGotenberg(cfg Config) (Converter, error) {
+	baseURL := strings.TrimRight(cfg.URL, "/")
+	if baseURL == "" {
+		baseURL = defaultGotenbergURL
+	}
+	return &Gotenberg{
+		baseURL:  baseURL,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		retries:  cfg.Retries,
+		maxBytes: cfg.MaxBytes,
+		backoff:  retryBackoff,
+	}, nil
+}
+
+func (g *Gotenberg) ConvertToPdf(ctx context.Context, data []byte, name string) ([]byte, error) {
+	if err := checkSize(data, g.maxBytes); err != nil {
+		return nil, err
+	}
+	body, _, err := g.post(ctx, gotenbergConvertPath, nil, "files", name, data)
+	return body, err
+}
+
+// SplitPdf splits with Gotenberg's pdf engines, Gotenberg answers with the pdf itself when there is a
+// single part and with a zip of the parts otherwise
+func (g *Gotenberg) SplitPdf(ctx context.Context, data []byte, span int) ([][]byte, error) {
+	if err := checkSize(data, g.maxBytes); err != nil {
+		return nil, err
+	}
+	fields := map[string]string{"splitMode": "intervals", "splitSpan": strconv.Itoa(span)}
+	body, contentType, err := g.post(ctx, gotenbergSplitPath, fields, "files", "document.pdf", data)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(contentType, "zip") {
+		return [][]byte{body}, nil
+	}
+	return readZipParts(body, g.maxBytes)
+}
+
+// post sends a multipart form and returns the response body and its content type, retrying network
+// errors and 5xx/429 answers
+func (g *Gotenberg) post(ctx context.Context, path string, fields map[string]string, fileField, name string, data []byte) ([]byte, string, error) {
+	var lastErr error
+	for attempt := 0; attempt <= g.retries; attempt++ {
+		if attempt > 0 {
+			slog.Warn("retrying gotenberg request", "path", path, "attempt", attempt, "err", lastErr)
+			select {
+			case <-time.After(g.backoff * time.Duration(attempt)):
+			case <-ctx.Done():
+				return nil, "", ctx.Err()
+			}
+		}
+
+		body, contentType, retry, err := g.send(ctx, path, fields, fileField, name, data)
+		if err == nil {
+			return body, contentType, nil
+		}
+		if !retry || ctx.Err() != nil {
+			return nil, "", err
+		}
+		lastErr = err
+	}
+	return nil, "", lastErr
+}
+
+func (g *Gotenberg) send(ctx context.Context, path string, fields map[string]string, fileField, name string, data []byte) ([]byte, string, bool, error) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, "", false, err
+		}
+	}
+	part, err := writer.CreateFormFile(fileField, name)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, "", false, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+path, &form)
+	if err != nil {
+		return nil, "", false, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, "", true, fmt.Errorf("gotenberg request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		err := fmt.Errorf("gotenberg returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, "", true, err
+		}
+		return nil, "", false, fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, g.maxBytes+1))
+	if err != nil {
+		return nil, "", true, fmt.Errorf("failed to read gotenberg response: %w", err)
+	}
+	if int64(len(body)) > g.maxBytes {
+		return nil, "", false, fmt.Errorf("%w: converted document is larger than %d bytes", ErrRejected, g.maxBytes)
+	}
+	return body, resp.Header.Get("Content-Type"), false, nil
+}
+
+// readZipParts returns the files of a zip ordered by the number at the end of their names
+func readZipParts(data []byte, maxBytes int64) ([][]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read split archive: %w", err)
+	}
+	files := archive.File
+	sort.SliceStable(files, func(i, j int) bool {
+		return partNumber(files[i].Name) < partNumber(files[j].Name)
+	})
+
+	parts := make([][]byte, 0, len(files))
+	for _, file := range files {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		part, err := io.ReadAll(io.LimitReader(rc, maxBytes+1))
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(part)) > maxBytes {
+			return nil, errors.New("split part is too large")
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("split archive is empty")
+	}
+	return parts, nil
+}
+
+func partNumber(name string) int {
+	match := trailingNumbers.FindStringSubmatch(name)
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
@@ -0,0 +1,196 @@
+package convert
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func zipOf(t *testing.T, files map[string]string, order ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range order {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testGotenberg points a client at server without waiting between retries
+func testGotenberg(server *httptest.Server, retries int, maxBytes int64) *Gotenberg {
+	return &Gotenberg{baseURL: server.URL, client: server.Client(), retries: retries, maxBytes: maxBytes, backoff: time.Millisecond}
+}
+
+func TestGotenbergRetries(t *testing.T) {
+	tests := []struct {
+		name             string
+		statuses         []int
+		retries          int
+		expectedAttempts int32
+		expectedErr      error
+		expectedBody     string
+	}{
+		{name: "ok", statuses: []int{http.StatusOK}, retries: 2, expectedAttempts: 1, expectedBody: "%PDF"},
+		{name: "5xx is retried", statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}, retries: 2, expectedAttempts: 3, expectedBody: "%PDF"},
+		{name: "429 is retried", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, retries: 2, expectedAttempts: 2, expectedBody: "%PDF"},
+		{name: "retries run out", statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError}, retries: 1, expectedAttempts: 2},
+		{name: "4xx is rejected at once", statuses: []int{http.StatusBadRequest, http.StatusOK}, retries: 2, expectedAttempts: 1, expectedErr: ErrRejected},
+		{name: "415 is rejected", statuses: []int{http.StatusUnsupportedMediaType}, retries: 2, expectedAttempts: 1, expectedErr: ErrRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				if r.URL.Path != gotenbergConvertPath {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				file, header, err := r.FormFile("files")
+				if err != nil {
+					t.Errorf("expected the document in the files field: %v", err)
+				} else {
+					data, _ := io.ReadAll(file)
+					if header.Filename != "slides.pptx" || string(data) != "pptx bytes" {
+						t.Errorf("unexpected file %s %q", header.Filename, data)
+					}
+				}
+				status := tt.statuses[min(int(n)-1, len(tt.statuses)-1)]
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					_, _ = w.Write([]byte("%PDF"))
+				} else {
+					_, _ = w.Write([]byte("conversion failed"))
+				}
+			}))
+			defer server.Close()
+
+			body, err := testGotenberg(server, tt.retries, 1<<20).ConvertToPdf(context.Background(), []byte("pptx bytes"), "slides.pptx")
+			if got := attempts.Load(); got != tt.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.expectedAttempts, got)
+			}
+			switch {
+			case tt.expectedBody != "":
+				if err != nil || string(body) != tt.expectedBody {
+					t.Fatalf("ConvertToPdf() = %q, %v", body, err)
+				}
+			case tt.expectedErr != nil:
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+			default:
+				if err == nil || errors.Is(err, ErrRejected) || !strings.Contains(err.Error(), "conversion failed") {
+					t.Fatalf("expected the last server error, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestGotenbergSizeCaps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 64))
+	}))
+	defer server.Close()
+
+	if _, err := testGotenberg(server, 0, 16).ConvertToPdf(context.Background(), bytes.Repeat([]byte("a"), 17), "big.docx"); !errors.Is(err, ErrRejected) {
+		t.Errorf("expected a document over the limit to be rejected before sending, got %v", err)
+	}
+	if _, err := testGotenberg(server, 2, 32).ConvertToPdf(context.Background(), []byte("small"), "small.docx"); !errors.Is(err, ErrRejected) {
+		t.Errorf("expected a response over the limit to be rejected, got %v", err)
+	}
+	if body, err := testGotenberg(server, 0, 64).ConvertToPdf(context.Background(), []byte("small"), "small.docx"); err != nil || len(body) != 64 {
+		t.Errorf("expected a response at the limit to pass, got %d bytes, %v", len(body), err)
+	}
+}
+
+func TestGotenbergSplitPdf(t *testing.T) {
+	parts := zipOf(t, map[string]string{
+		"document_10.pdf": "pages 19-20",
+		"document_2.pdf":  "pages 3-4",
+		"document_1.pdf":  "pages 1-2",
+	}, "document_10.pdf", "document_2.pdf", "document_1.pdf")
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          []byte
+		maxBytes      int64
+		expectedParts []string
+		expectedErr   bool
+	}{
+		{name: "zip parts in page order", contentType: "application/zip", body: parts, maxBytes: 1 << 20, expectedParts: []string{"pages 1-2", "pages 3-4", "pages 19-20"}},
+		{name: "single part is the pdf itself", contentType: "application/pdf", body: []byte("%PDF whole"), maxBytes: 1 << 20, expectedParts: []string{"%PDF whole"}},
+		{name: "part over the limit", contentType: "application/zip", body: parts, maxBytes: 10, expectedErr: true},
+		{name: "broken zip", contentType: "application/zip", body: []byte("not a zip"), maxBytes: 1 << 20, expectedErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != gotenbergSplitPath || r.FormValue("splitMode") != "intervals" || r.FormValue("splitSpan") != "2" {
+					t.Errorf("unexpected split request %s %v", r.URL.Path, r.Form)
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			got, err := testGotenberg(server, 0, tt.maxBytes).SplitPdf(context.Background(), []byte("%PDF"), 2)
+			if tt.expectedErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.expectedParts) {
+				t.Fatalf("expected %d parts, got %d", len(tt.expectedParts), len(got))
+			}
+			for i, part := range got {
+				if string(part) != tt.expectedParts[i] {
+					t.Errorf("part %d = %q, want %q", i, part, tt.expectedParts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadZipPartsEmpty(t *testing.T) {
+	empty := zipOf(t, map[string]string{"dir/": ""}, "dir/")
+	if _, err := readZipParts(empty, 1<<20); err == nil {
+		t.Error("expected an archive without files to fail")
+	}
+}
+
+func TestPartNumber(t *testing.T) {
+	tests := map[string]int{
+		"document_1.pdf":     1,
+		"document_12.pdf":    12,
+		"split/part-003.pdf": 3,
+		"2024 notes_7.pdf":   7,
+		"no number.pdf":      0,
+	}
+	for name, expected := range tests {
+		if got := partNumber(name); got != expected {
+			t.Errorf("partNumber(%q) = %d, want %d", name, got, expected)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LibreOffice converts with a local soffice binary, for running without a Gotenberg server.
+// It cannot split pdfs, so documents are generated in one go
+type LibreOffice struct {
+	binary   string
+	timeout  time.Duration
+	maxBytes int64
+}
+
+func newLibreOffice(cfg Config) (Converter, error) {
+	binary := cfg.LibreOfficePath
+	if binary == "" {
+		binary = defaultLibreOffice
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("libreoffice not found: %w", err)
+	}
+	return &LibreOffice{binary: path, timeout: cfg.Timeout, maxBytes: cfg.MaxBytes}, nil
+}
+
+func (l *LibreOffice) ConvertToPdf(ctx context.Context, data []byte, name string) ([]byte, error) {
+	if err := checkSize(data, l.maxBytes); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "studyhub-convert-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// only the extension of the name matters, it tells LibreOffice the input format
+	input := filepath.Join(dir, "document"+strings.ToLower(filepath.Ext(name)))
+	if err := os.WriteFile(input, data, 0o600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	// a profile per run, soffice refuses to start while another process holds the default one
+	profile := "-env:UserInstallation=file://" + filepath.ToSlash(filepath.Join(dir, "profile"))
+	cmd := exec.CommandContext(ctx, l.binary, profile, "--headless", "--norestore",
+		"--convert-to", "pdf", "--outdir", filepath.Join(dir, "out"), input)
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("libreoffice conversion timed out: %w", ctx.Err())
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("%w: libreoffice failed: %s", ErrRejected, lastLine(output.String()))
+		}
+		return nil, fmt.Errorf("failed to run libreoffice: %w", err)
+	}
+
+	pdf, err := os.ReadFile(filepath.Join(dir, "out", "document.pdf"))
+	if err != nil {
+		// soffice exits with 0 when it cannot load the input, it just writes nothing
+		return nil, fmt.Errorf("%w: libreoffice produced no pdf: %s", ErrRejected, lastLine(output.String()))
+	}
+	if err := checkSize(pdf, l.maxBytes); err != nil {
+		return nil, err
+	}
+	return pdf, nil
+}
+
+func (l *LibreOffice) SplitPdf(ctx context.Context, data []byte, span int) ([][]byte, error) {
+	return nil, fmt.Errorf("%w: libreoffice cannot split pdfs", ErrUnsupported)
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,114 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSoffice writes a shell script standing in for soffice, it gets the arguments of a conversion
+func fakeSoffice(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake soffice is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "soffice")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLibreOfficeConvert(t *testing.T) {
+	// the output directory follows --outdir, the input is the last argument
+	const findOutdir = `while [ "$1" != "--outdir" ]; do shift; done; out="$2"; mkdir -p "$out"` + "\n"
+
+	tests := []struct {
+		name         string
+		script       string
+		maxBytes     int64
+		expectedPDF  string
+		expectedErr  error
+		expectedLine string
+	}{
+		{
+			name:        "converted",
+			script:      findOutdir + `printf '%%PDF converted' > "$out/document.pdf"`,
+			maxBytes:    1 << 20,
+			expectedPDF: "%PDF converted",
+		},
+		{
+			name:         "no pdf produced",
+			script:       "echo 'convert document.pptx'\necho 'Error: source file could not be loaded'\nexit 0",
+			maxBytes:     1 << 20,
+			expectedErr:  ErrRejected,
+			expectedLine: "Error: source file could not be loaded",
+		},
+		{
+			name:         "soffice fails",
+			script:       "echo 'starting'\necho 'fatal: broken profile' >&2\nexit 81",
+			maxBytes:     1 << 20,
+			expectedErr:  ErrRejected,
+			expectedLine: "fatal: broken profile",
+		},
+		{
+			name:        "pdf over the limit",
+			script:      findOutdir + `printf '%%PDF much too large' > "$out/document.pdf"`,
+			maxBytes:    12,
+			expectedErr: ErrRejected,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter, err := newLibreOffice(Config{LibreOfficePath: fakeSoffice(t, tt.script), Timeout: 10 * time.Second, MaxBytes: tt.maxBytes})
+			if err != nil {
+				t.Fatal(err)
+			}
+			pdf, err := converter.ConvertToPdf(context.Background(), []byte("slides"), "Slides.PPTX")
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if tt.expectedLine != "" && !strings.HasSuffix(err.Error(), tt.expectedLine) {
+					t.Errorf("expected the error to end with %q, got %v", tt.expectedLine, err)
+				}
+				return
+			}
+			if err != nil || string(pdf) != tt.expectedPDF {
+				t.Fatalf("ConvertToPdf() = %q, %v", pdf, err)
+			}
+		})
+	}
+}
+
+func TestLibreOfficeInputSize(t *testing.T) {
+	converter, err := newLibreOffice(Config{LibreOfficePath: fakeSoffice(t, "exit 1"), Timeout: time.Second, MaxBytes: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := converter.ConvertToPdf(context.Background(), []byte("too large"), "notes.docx"); !errors.Is(err, ErrRejected) {
+		t.Errorf("expected a document over the limit to be rejected, got %v", err)
+	}
+	if _, err := converter.SplitPdf(context.Background(), []byte("%PDF"), 10); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected splitting to be unsupported, got %v", err)
+	}
+}
+
+func TestLastLine(t *testing.T) {
+	tests := map[string]string{
+		"single":                  "single",
+		"first\nsecond\n":         "second",
+		"  \nwarning\nerror: x\n": "error: x",
+		"":                        "",
+	}
+	for output, expected := range tests {
+		if got := lastLine(output); got != expected {
+			t.Errorf("lastLine(%q) = %q, want %q", output, got, expected)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultFetchTimeout  = 20 * time.Second
+	defaultFetchMaxBytes = 10 << 20
+	defaultUserAgent     = "StudyHub/1.0 (+link flashcards)"
+	maxRedirects         = 5
+)
+
+var (
+	// ErrUnsupportedContent is returned for links to something that is neither a page, text nor a pdf
+	ErrUnsupportedContent = errors.New("unsupported link content")
+	// ErrBlockedAddress is returned for links to the server's own network
+	ErrBlockedAddress = errors.New("link points to a private address")
+	// ErrTooLarge is returned when a link serves more than the fetcher's MaxBytes
+	ErrTooLarge = errors.New("link content is too large")
+)
+
+// FetcherConfig configures link fetching, zero values use the defaults
+type FetcherConfig struct {
+	Timeout  time.Duration
+	MaxBytes int64
+	// AllowPrivate lets links reach loopback and private addresses, only for tests and local setups
+	AllowPrivate bool
+	UserAgent    string
+}
+
+// Document is what a link serves: the readable text of a page or text file, or the bytes of a pdf
+type Document struct {
+	URL   string
+	Title string
+	Text  string
+	PDF   []byte
+}
+
+// Fetcher downloads links, it only talks to public addresses unless AllowPrivate is set
+type Fetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+}
+
+func NewFetcher(cfg FetcherConfig) *Fetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultFetchTimeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultFetchMaxBytes
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = defaultUserAgent
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !cfg.AllowPrivate {
+		// checked on the resolved address of every connection, redirects and DNS tricks included
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !publicAddress(ip) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+			}
+			return nil
+		}
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				// a proxy would make the dialer check the proxy instead of the link
+				Proxy:                 nil,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: cfg.Timeout,
+				MaxIdleConns:          10,
+				IdleConnTimeout:       30 * time.Second,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return checkScheme(req.URL)
+			},
+		},
+		maxBytes:  cfg.MaxBytes,
+		userAgent: cfg.UserAgent,
+	}
+}
+
+// Fetch downloads a link and extracts its text, pdfs are returned as they are
+func (f *Fetcher) Fetch(ctx context.Context, link string) (Document, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return Document{}, fmt.Errorf("invalid link: %w", err)
+	}
+	if err := checkScheme(u); err != nil {
+		return Document{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Document{}, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain,application/pdf;q=0.9,*/*;q=0.1")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to fetch link: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return Document{}, fmt.Errorf("failed to fetch link: bad status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to read link: %w", err)
+	}
+	if int64(len(body)) > f.maxBytes {
+		return Document{}, ErrTooLarge
+	}
+
+	doc := Document{URL: resp.Request.URL.String()}
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		doc.Title, doc.Text, err = HTML(strings.NewReader(string(body)), contentType)
+		if err != nil {
+			return Document{}, err
+		}
+	case strings.HasPrefix(mediaType, "text/"):
+		doc.Text = strings.TrimSpace(Text(body))
+	case mediaType == "application/pdf":
+		doc.PDF = body
+		return doc, nil
+	default:
+		return Document{}, fmt.Errorf("%w: %s", ErrUnsupportedContent, mediaType)
+	}
+
+	if doc.Text == "" {
+		return Document{}, fmt.Errorf("%w: the page has no readable text", ErrUnsupportedContent)
+	}
+	return doc, nil
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid link: only http and https links can be fetched")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid link: missing host")
+	}
+	return nil
+}
+
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() &&
+		// carrier-grade NAT is as internal as the private ranges
+		!netip.MustParsePrefix("100.64.0.0/10").Contains(ip)
+}
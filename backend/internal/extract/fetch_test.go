@@ -0,0 +1,172 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	site := newStandIn(t, map[string]standInPage{
+		"/article": {
+			ContentType: "text/html; charset=utf-8",
+			Body: `<html><head><title> Cell   Biology </title><script>var x = 1;</script></head>
+				<body><nav>Home | About</nav><article><h1>Mitochondria</h1><p>The powerhouse of the cell.</p>
+				<ul><li>ATP</li><li>Respiration</li></ul></article><footer>Copyright</footer></body></html>`,
+		},
+		"/latin1": {
+			ContentType: "text/html; charset=iso-8859-1",
+			Body:        "<html><body><p>Caf\xe9</p></body></html>",
+		},
+		"/notes.txt":  {ContentType: "text/plain", Body: "\ufeff  Line one\nLine two  "},
+		"/slides.pdf": {ContentType: "application/pdf", Body: "%PDF-1.4 fake"},
+		"/sniffed":    {ContentType: "application/octet-stream", Body: "%PDF-1.7 sniffed"},
+		"/image.png":  {ContentType: "image/png", Body: "\x89PNG\r\n\x1a\n"},
+		"/empty":      {ContentType: "text/html", Body: "<html><body><script>only()</script></body></html>"},
+		"/missing":    {ContentType: "text/html", Body: "gone", Status: http.StatusGone},
+		"/large":      {ContentType: "text/plain", Body: strings.Repeat("a", 2048)},
+	})
+	fetcher := NewFetcher(FetcherConfig{AllowPrivate: true, MaxBytes: 1024})
+
+	tests := []struct {
+		name          string
+		path          string
+		expectedTitle string
+		expectedText  string
+		expectedPDF   string
+		expectedErr   error
+		expectedMsg   string
+	}{
+		{
+			name:          "html keeps the article and drops the page chrome",
+			path:          "/article",
+			expectedTitle: "Cell Biology",
+			expectedText:  "Mitochondria\nThe powerhouse of the cell.\nATP\nRespiration",
+		},
+		{name: "html in another charset", path: "/latin1", expectedText: "Café"},
+		{name: "plain text", path: "/notes.txt", expectedText: "Line one\nLine two"},
+		{name: "pdf is returned as it is", path: "/slides.pdf", expectedPDF: "%PDF-1.4 fake"},
+		{name: "octet-stream is sniffed", path: "/sniffed", expectedPDF: "%PDF-1.7 sniffed"},
+		{name: "image is rejected", path: "/image.png", expectedErr: ErrUnsupportedContent},
+		{name: "page without text is rejected", path: "/empty", expectedErr: ErrUnsupportedContent},
+		{name: "bad status", path: "/missing", expectedMsg: "bad status: 410 Gone"},
+		{name: "too large", path: "/large", expectedErr: ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := fetcher.Fetch(context.Background(), site.URL+tt.path)
+			if tt.expectedErr != nil || tt.expectedMsg != "" {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", doc)
+				}
+				if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+					t.Errorf("expected %v, got %v", tt.expectedErr, err)
+				}
+				if tt.expectedMsg != "" && !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if doc.URL != site.URL+tt.path {
+				t.Errorf("expected url %q, got %q", site.URL+tt.path, doc.URL)
+			}
+			if doc.Title != tt.expectedTitle {
+				t.Errorf("expected title %q, got %q", tt.expectedTitle, doc.Title)
+			}
+			if doc.Text != tt.expectedText {
+				t.Errorf("expected text %q, got %q", tt.expectedText, doc.Text)
+			}
+			if string(doc.PDF) != tt.expectedPDF {
+				t.Errorf("expected pdf %q, got %q", tt.expectedPDF, doc.PDF)
+			}
+		})
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	site := newStandIn(t, map[string]standInPage{
+		"/page": {ContentType: "text/plain", Body: "internal"},
+	})
+	fetcher := NewFetcher(FetcherConfig{})
+
+	tests := []struct {
+		name string
+		link string
+	}{
+		{"loopback", site.URL + "/page"},
+		{"localhost name", strings.Replace(site.URL, "127.0.0.1", "localhost", 1) + "/page"},
+		{"ipv6 loopback", "http://[::1]:1/page"},
+		{"private network", "http://10.0.0.1:1/page"},
+		{"cloud metadata", "http://169.254.169.254/latest/meta-data/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fetcher.Fetch(context.Background(), tt.link)
+			if !errors.Is(err, ErrBlockedAddress) {
+				t.Errorf("expected %v, got %v", ErrBlockedAddress, err)
+			}
+		})
+	}
+
+}
+
+func TestFetchRejectsLinks(t *testing.T) {
+	fetcher := NewFetcher(FetcherConfig{})
+
+	tests := []struct {
+		name string
+		link string
+	}{
+		{"file scheme", "file:///etc/passwd"},
+		{"ftp scheme", "ftp://example.com/notes.txt"},
+		{"missing host", "http:///notes.txt"},
+		{"not a url", "http://%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fetcher.Fetch(context.Background(), tt.link)
+			if err == nil || !strings.Contains(err.Error(), "invalid link") {
+				t.Errorf("expected an invalid link error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := publicAddress(netip.MustParseAddr(tt.addr)); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// elements that never hold the readable part of a page
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Select: true, atom.Iframe: true,
+	atom.Svg: true, atom.Canvas: true, atom.Object: true, atom.Head: true,
+}
+
+// elements that start a new line of text
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Li: true, atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Tr: true, atom.Table: true, atom.Blockquote: true, atom.Pre: true, atom.Br: true,
+	atom.Figcaption: true, atom.Hr: true,
+}
+
+// HTML returns the title and the readable text of a page. The text comes from the page's <article>,
+// or <main>, or else the whole <body>, without scripts, navigation and other page chrome.
+// contentType is the response's Content-Type and is used to decode pages that are not utf-8
+func HTML(r io.Reader, contentType string) (string, string, error) {
+	decoded, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	doc, err := html.Parse(decoded)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	title := ""
+	if node := findElement(doc, atom.Title); node != nil {
+		title = strings.Join(strings.Fields(nodeText(node)), " ")
+	}
+
+	root := findElement(doc, atom.Article)
+	if root == nil {
+		root = findElement(doc, atom.Main)
+	}
+	if root == nil {
+		root = findElement(doc, atom.Body)
+	}
+	if root == nil {
+		root = doc
+	}
+
+	var text strings.Builder
+	writeReadable(&text, root)
+	return title, cleanLines(text.String()), nil
+}
+
+// Text returns a plain text document as valid utf-8 without a byte order mark
+func Text(data []byte) string {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	if !utf8.ValidString(text) {
+		text = strings.ToValidUTF8(text, "")
+	}
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
+
+func findElement(node *html.Node, a atom.Atom) *html.Node {
+	if node.Type == html.ElementNode && node.DataAtom == a {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func nodeText(node *html.Node) string {
+	var text strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			text.WriteString(child.Data)
+		}
+	}
+	return text.String()
+}
+
+func writeReadable(text *strings.Builder, node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		text.WriteString(node.Data)
+		return
+	case html.ElementNode:
+		if skippedElements[node.DataAtom] {
+			return
+		}
+	}
+
+	block := node.Type == html.ElementNode && blockElements[node.DataAtom]
+	if block {
+		text.WriteByte('\n')
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeReadable(text, child)
+	}
+	if block {
+		text.WriteByte('\n')
+	}
+}
+
+// cleanLines collapses the spacing inside every line and drops the empty ones
+func cleanLines(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,210 @@
+// Package extract turns documents that are not pdfs into plain text the AI can read: office files,
+// web pages and notes
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a zip entry is never read past this, so a small archive cannot expand into gigabytes
+const maxEntrySize = 50 << 20
+
+var (
+	ErrInvalidDocument = errors.New("invalid document")
+	slideNumber        = regexp.MustCompile(`slide(\d+)\.xml$`)
+)
+
+// Docx returns the text of a Word document, one line per paragraph
+func Docx(data []byte) (string, error) {
+	archive, err := openZip(data)
+	if err != nil {
+		return "", err
+	}
+	body, err := readEntry(archive, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	return officeText(body, "p")
+}
+
+// Pptx returns the text of every slide of a PowerPoint presentation in presentation order,
+// slides without text are kept as empty strings so the index stays the slide number - 1
+func Pptx(data []byte) ([]string, error) {
+	archive, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	names := slideOrder(archive)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: presentation has no slides", ErrInvalidDocument)
+	}
+
+	slides := make([]string, len(names))
+	for i, name := range names {
+		body, err := readEntry(archive, name)
+		if err != nil {
+			return nil, err
+		}
+		if slides[i], err = officeText(body, "p"); err != nil {
+			return nil, err
+		}
+	}
+	return slides, nil
+}
+
+func openZip(data []byte) (*zip.Reader, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	return archive, nil
+}
+
+func readEntry(archive *zip.Reader, name string) ([]byte, error) {
+	file, err := archive.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidDocument, name)
+	}
+	defer func() { _ = file.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(file, maxEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	if len(body) > maxEntrySize {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidDocument, name)
+	}
+	return body, nil
+}
+
+// officeText collects the text runs of an OOXML part, the paragraph element ends a line.
+// Word and PowerPoint both keep text in <t> elements, they only differ in the namespace
+func officeText(body []byte, paragraph string) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var (
+		text   strings.Builder
+		line   strings.Builder
+		inText bool
+	)
+	endLine := func() {
+		if l := strings.TrimSpace(line.String()); l != "" {
+			text.WriteString(l)
+			text.WriteByte('\n')
+		}
+		line.Reset()
+	}
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				line.WriteByte('\t')
+			case "br", "cr":
+				endLine()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case paragraph:
+				endLine()
+			}
+		case xml.CharData:
+			if inText {
+				line.Write(t)
+			}
+		}
+	}
+	endLine()
+	return strings.TrimSpace(text.String()), nil
+}
+
+// slideOrder returns the slide parts in the order of the presentation's slide list, which can differ from
+// their file names after slides were moved. Falls back to the file names when the list cannot be read
+func slideOrder(archive *zip.Reader) []string {
+	if names, err := listedSlides(archive); err == nil && len(names) > 0 {
+		return names
+	}
+
+	var names []string
+	for _, file := range archive.File {
+		if path.Dir(file.Name) == "ppt/slides" && slideNumber.MatchString(file.Name) {
+			names = append(names, file.Name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return slideIndex(names[i]) < slideIndex(names[j])
+	})
+	return names
+}
+
+func slideIndex(name string) int {
+	n, _ := strconv.Atoi(slideNumber.FindStringSubmatch(name)[1])
+	return n
+}
+
+func listedSlides(archive *zip.Reader) ([]string, error) {
+	presentation, err := readEntry(archive, "ppt/presentation.xml")
+	if err != nil {
+		return nil, err
+	}
+	rels, err := readEntry(archive, "ppt/_rels/presentation.xml.rels")
+	if err != nil {
+		return nil, err
+	}
+
+	var relationships struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(rels, &relationships); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string, len(relationships.Items))
+	for _, rel := range relationships.Items {
+		targets[rel.ID] = path.Join("ppt", rel.Target)
+	}
+
+	var list struct {
+		Slides []struct {
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	if err := xml.Unmarshal(presentation, &list); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Slides))
+	for _, slide := range list.Slides {
+		for _, attr := range slide.Attrs {
+			// the relationship id is r:id, the plain id attribute is the slide's own number
+			if attr.Name.Local == "id" && attr.Name.Space != "" {
+				if target, ok := targets[attr.Value]; ok {
+					names = append(names, target)
+				}
+			}
+		}
+	}
+	return names, nil
+}
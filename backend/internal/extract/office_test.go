@@ -0,0 +1,145 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// zipFiles builds an archive holding the given files
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("failed to close the archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func slideXML(paragraphs ...string) string {
+	body := ""
+	for _, p := range paragraphs {
+		body += `<a:p><a:r><a:t>` + p + `</a:t></a:r></a:p>`
+	}
+	return `<p:sld xmlns:a="a" xmlns:p="p"><p:cSld><p:spTree><p:sp><p:txBody>` + body + `</p:txBody></p:sp></p:spTree></p:cSld></p:sld>`
+}
+
+func TestDocx(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="w"><w:body>
+	<w:p><w:r><w:t>Chapter</w:t></w:r><w:r><w:t xml:space="preserve"> one</w:t></w:r></w:p>
+	<w:p></w:p>
+	<w:p><w:r><w:t>Name</w:t><w:tab/><w:t>Value</w:t></w:r></w:p>
+	<w:p><w:r><w:t>First line</w:t><w:br/><w:t>second line</w:t></w:r></w:p>
+	<w:p><w:r><w:instrText>PAGE</w:instrText></w:r></w:p>
+</w:body></w:document>`
+
+	tests := []struct {
+		name        string
+		data        []byte
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "paragraphs, tabs and breaks",
+			data:     zipFiles(t, map[string]string{"word/document.xml": document}),
+			expected: "Chapter one\nName\tValue\nFirst line\nsecond line",
+		},
+		{name: "not a zip", data: []byte("plain text"), expectedErr: ErrInvalidDocument},
+		{name: "missing document part", data: zipFiles(t, map[string]string{"word/styles.xml": "<w:styles/>"}), expectedErr: ErrInvalidDocument},
+		{name: "broken xml", data: zipFiles(t, map[string]string{"word/document.xml": "<w:document><w:p>"}), expectedErr: ErrInvalidDocument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := Docx(tt.data)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if text != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, text)
+			}
+		})
+	}
+}
+
+func TestPptx(t *testing.T) {
+	presentation := `<p:presentation xmlns:p="p" xmlns:r="r"><p:sldIdLst>
+		<p:sldId id="256" r:id="rId3"/><p:sldId id="257" r:id="rId2"/><p:sldId id="258" r:id="rId4"/>
+	</p:sldIdLst></p:presentation>`
+	rels := `<Relationships>
+		<Relationship Id="rId2" Target="slides/slide1.xml"/>
+		<Relationship Id="rId3" Target="slides/slide2.xml"/>
+		<Relationship Id="rId4" Target="slides/slide3.xml"/>
+	</Relationships>`
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name: "slides follow the presentation's list",
+			files: map[string]string{
+				"ppt/presentation.xml":            presentation,
+				"ppt/_rels/presentation.xml.rels": rels,
+				"ppt/slides/slide1.xml":           slideXML("Moved", "to second"),
+				"ppt/slides/slide2.xml":           slideXML("Title slide"),
+				"ppt/slides/slide3.xml":           slideXML(),
+			},
+			expected: []string{"Title slide", "Moved\nto second", ""},
+		},
+		{
+			name: "without a slide list the file numbers decide",
+			files: map[string]string{
+				"ppt/slides/slide10.xml": slideXML("Ten"),
+				"ppt/slides/slide2.xml":  slideXML("Two"),
+				"ppt/slides/slide1.xml":  slideXML("One"),
+				"ppt/slides/_rels/x.xml": "<Relationships/>",
+			},
+			expected: []string{"One", "Two", "Ten"},
+		},
+		{
+			name:        "no slides",
+			files:       map[string]string{"ppt/presentation.xml": "<p:presentation/>"},
+			expectedErr: ErrInvalidDocument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slides, err := Pptx(zipFiles(t, tt.files))
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(slides, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, slides)
+			}
+		})
+	}
+}
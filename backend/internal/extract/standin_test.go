@@ -0,0 +1,37 @@
+package extract
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// standInPage is a page served by a stand-in site
+type standInPage struct {
+	ContentType string
+	Body        string
+	// Status defaults to 200
+	Status int
+}
+
+// newStandIn starts a local site serving pages by path, so fetching can be tested without the internet.
+// Fetch it with AllowPrivate set, the site listens on loopback. The server is closed when the test ends
+func newStandIn(t *testing.T, pages map[string]standInPage) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if page.ContentType != "" {
+			w.Header().Set("Content-Type", page.ContentType)
+		}
+		if page.Status != 0 {
+			w.WriteHeader(page.Status)
+		}
+		_, _ = w.Write([]byte(page.Body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
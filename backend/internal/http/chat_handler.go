@@ -47,13 +47,13 @@ func (srv *HTTPServer) ChatHandler(w http.ResponseWriter, r *http.Request) {
 			ResponseWithJSON(w, http.StatusOK, chatResponse{Reply: reply, Sources: sources})
 			return
 		} else {
-			slog.Warn("rag service unavailable, falling back to gemini", "err", err)
+			slog.Warn("rag service unavailable, falling back to the AI provider", "err", err)
 		}
 	}
 
-	reply, err := srv.geminiClient.Chat(r.Context(), req.Message)
+	reply, err := srv.aiProvider.Chat(r.Context(), req.Message)
 	if err != nil {
-		slog.Error("gemini chat error", "err", err)
+		slog.Error("ai chat error", "err", err)
 		ResponseWithErr(w, http.StatusInternalServerError, "failed to get response from AI")
 		return
 	}
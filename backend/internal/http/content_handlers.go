@@ -3,8 +3,12 @@ package http
 import (
 	"StudyHub/internal/content"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
@@ -16,32 +20,73 @@ type ListCardsForObjectsRequest struct {
 }
 
 func (s *HTTPServer) ListCardsFromObjects(w http.ResponseWriter, r *http.Request) {
+	uuids, ok := decodeObjectIDs(w, r)
+	if !ok {
+		return
+	}
+
+	cards, err := s.contentSrv.ListSelectedObjectsCards(r.Context(), uuids)
+	if err != nil {
+		slog.Error("failed to list cards", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to list cards")
+		return
+	}
+	ResponseWithJSON(w, 200, cards)
+}
+
+// ListSummariesFromObjects returns the generated summaries of the uploads. POST /conents/objects/summaries
+func (s *HTTPServer) ListSummariesFromObjects(w http.ResponseWriter, r *http.Request) {
+	uuids, ok := decodeObjectIDs(w, r)
+	if !ok {
+		return
+	}
+
+	summaries, err := s.contentSrv.ListSelectedObjectsSummaries(r.Context(), uuids)
+	if err != nil {
+		slog.Error("failed to list summaries", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to list summaries")
+		return
+	}
+	ResponseWithJSON(w, http.StatusOK, summaries)
+}
+
+// ListQuizzesFromObjects returns the generated quiz questions of the uploads. POST /conents/objects/quizzes
+func (s *HTTPServer) ListQuizzesFromObjects(w http.ResponseWriter, r *http.Request) {
+	uuids, ok := decodeObjectIDs(w, r)
+	if !ok {
+		return
+	}
+
+	questions, err := s.contentSrv.ListSelectedObjectsQuizzes(r.Context(), uuids)
+	if err != nil {
+		slog.Error("failed to list quizzes", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to list quizzes")
+		return
+	}
+	ResponseWithJSON(w, http.StatusOK, questions)
+}
+
+// decodeObjectIDs reads the storage object ids of a ListCardsForObjectsRequest body
+func decodeObjectIDs(w http.ResponseWriter, r *http.Request) ([]uuid.UUID, bool) {
 	var payload ListCardsForObjectsRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		slog.Error("failed to Decode Json", "error", err)
 		ResponseWithErr(w, http.StatusBadRequest, "invalid request")
-		return
+		return nil, false
 	}
 
-	uuids := make([]uuid.UUID, len(payload.IDs))
+	uuids := make([]uuid.UUID, 0, len(payload.IDs))
 	for i := range payload.IDs {
 		id, err := uuid.Parse(payload.IDs[i])
 		if err != nil {
 			slog.Error("failed to parse id", "error", err)
 			ResponseWithErr(w, http.StatusBadRequest, "invalid objectID")
-			return
+			return nil, false
 		}
 		uuids = append(uuids, id)
 	}
-
-	cards, err := s.contentSrv.ListSelectedObjectsCards(r.Context(), uuids)
-	if err != nil {
-		slog.Error("failed to list cards", "error", err)
-		ResponseWithErr(w, http.StatusInternalServerError, "failed to list cards")
-		return
-	}
-	ResponseWithJSON(w, 200, cards)
+	return uuids, true
 }
 
 // AddCardToDeckHandler adds an auto-generated flashcard to user's deck
@@ -89,7 +134,8 @@ func (s *HTTPServer) AddCardToDeckHandler(w http.ResponseWriter, r *http.Request
 	ResponseWithJSON(w, http.StatusCreated, nil)
 }
 
-// CreateCustomCardHandler creates a custom flashcard in user's deck
+// CreateCustomCardHandler creates a custom flashcard in user's deck, cloze and image occlusion
+// notes return one card per deletion or region
 func (s *HTTPServer) CreateCustomCardHandler(w http.ResponseWriter, r *http.Request) {
 	weekIDParam := chi.URLParam(r, "week_id")
 	weekID, ok := parseUUID(w, weekIDParam)
@@ -110,18 +156,22 @@ func (s *HTTPServer) CreateCustomCardHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	card, err := s.contentSrv.CreateCustomCard(r.Context(), userID, weekID, req.Front, req.Back)
+	cards, err := s.contentSrv.CreateCustomCard(r.Context(), userID, weekID, req)
 	if err != nil {
-		if strings.Contains(err.Error(), "empty") {
+		if errors.Is(err, content.ErrInvalidCard) {
 			ResponseWithErr(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		if strings.Contains(err.Error(), "not found") {
+			ResponseWithErr(w, http.StatusNotFound, err.Error())
+			return
+		}
 		slog.Error("failed to create custom card", "error", err)
 		ResponseWithErr(w, http.StatusInternalServerError, "failed to create custom card")
 		return
 	}
 
-	ResponseWithJSON(w, http.StatusCreated, card)
+	ResponseWithJSON(w, http.StatusCreated, cards)
 }
 
 // GetUserDeckHandler retrieves all cards in user's deck for a specific week
@@ -148,6 +198,43 @@ func (s *HTTPServer) GetUserDeckHandler(w http.ResponseWriter, r *http.Request)
 	ResponseWithJSON(w, http.StatusOK, cards)
 }
 
+// GetDueCardsHandler returns the cards due for review across all of the user's weeks
+// GET /decks/due?new_limit=&review_limit=&module_id=&module_run_id=
+func (s *HTTPServer) GetDueCardsHandler(w http.ResponseWriter, r *http.Request) {
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	filter := content.DueCardsFilter{}
+	if filter.NewLimit, ok = parseQueryInt(w, r, "new_limit", content.DefaultNewCardsLimit); !ok {
+		return
+	}
+	if filter.ReviewLimit, ok = parseQueryInt(w, r, "review_limit", content.DefaultReviewCardsLimit); !ok {
+		return
+	}
+	if filter.ModuleID, ok = parseQueryUUID(w, r, "module_id"); !ok {
+		return
+	}
+	if filter.ModuleRunID, ok = parseQueryUUID(w, r, "module_run_id"); !ok {
+		return
+	}
+
+	cards, err := s.contentSrv.GetDueCards(r.Context(), userID, filter)
+	if err != nil {
+		if strings.Contains(err.Error(), "negative") {
+			ResponseWithErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to get due cards", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to get due cards")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, cards)
+}
+
 // UpdateDeckCardHandler updates a card in user's deck
 func (s *HTTPServer) UpdateDeckCardHandler(w http.ResponseWriter, r *http.Request) {
 	cardIDParam := chi.URLParam(r, "card_id")
@@ -232,22 +319,104 @@ func (s *HTTPServer) RecordCardReviewHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err := s.contentSrv.RecordCardReview(r.Context(), cardID, userID, req.DifficultyRating)
+	card, err := s.contentSrv.RecordCardReview(r.Context(), cardID, userID, req.DifficultyRating, req.ResponseTimeMs)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "access denied") {
 			ResponseWithErr(w, http.StatusNotFound, err.Error())
 			return
 		}
-		if strings.Contains(err.Error(), "between 1 and 5") {
+		if strings.Contains(err.Error(), "between 1 and 5") || strings.Contains(err.Error(), "negative") {
 			ResponseWithErr(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		if errors.Is(err, content.ErrReviewConflict) {
+			ResponseWithErr(w, http.StatusConflict, err.Error())
+			return
+		}
 		slog.Error("failed to record review", "error", err)
 		ResponseWithErr(w, http.StatusInternalServerError, "failed to record review")
 		return
 	}
 
-	ResponseWithJSON(w, http.StatusOK, nil)
+	ResponseWithJSON(w, http.StatusOK, card)
+}
+
+// ListCardReviewsHandler pages through the review history of a card
+// GET /decks/cards/{card_id}/reviews?limit=&offset=
+func (s *HTTPServer) ListCardReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	cardIDParam := chi.URLParam(r, "card_id")
+	cardID, ok := parseUUID(w, cardIDParam)
+	if !ok {
+		return
+	}
+
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	reviews, err := s.contentSrv.ListCardReviews(r.Context(), cardID, userID, page)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "access denied") {
+			ResponseWithErr(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if strings.Contains(err.Error(), "negative") {
+			ResponseWithErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to list card reviews", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to list reviews")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, reviews)
+}
+
+// ListUserReviewsHandler pages through every review the user has made
+// GET /decks/reviews?limit=&offset=
+func (s *HTTPServer) ListUserReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	reviews, err := s.contentSrv.ListUserReviews(r.Context(), userID, page)
+	if err != nil {
+		if strings.Contains(err.Error(), "negative") {
+			ResponseWithErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to list user reviews", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to list reviews")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, reviews)
+}
+
+func parsePage(w http.ResponseWriter, r *http.Request) (content.Page, bool) {
+	limit, ok := parseQueryInt(w, r, "limit", content.DefaultPageLimit)
+	if !ok {
+		return content.Page{}, false
+	}
+	offset, ok := parseQueryInt(w, r, "offset", 0)
+	if !ok {
+		return content.Page{}, false
+	}
+	return content.Page{Limit: limit, Offset: offset}, true
 }
 
 // GetDeckStatsHandler retrieves statistics for user's deck in a week
@@ -273,3 +442,527 @@ func (s *HTTPServer) GetDeckStatsHandler(w http.ResponseWriter, r *http.Request)
 
 	ResponseWithJSON(w, http.StatusOK, stats)
 }
+
+// ImportDeckHandler adds the cards of an uploaded Anki package, CSV or Quizlet export to user's deck
+// POST /decks/weeks/{week_id}/import (multipart: file, format=apkg|csv|quizlet, dry_run=true|false)
+func (s *HTTPServer) ImportDeckHandler(w http.ResponseWriter, r *http.Request) {
+	weekIDParam := chi.URLParam(r, "week_id")
+	weekID, ok := parseUUID(w, weekIDParam)
+	if !ok {
+		return
+	}
+
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, content.MaxImportFileSize+1<<20)
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		slog.Error("failed to read import file", "error", err)
+		ResponseWithErr(w, http.StatusBadRequest, "cannot access form file data")
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			slog.Error("failed to close import file", "err", closeErr)
+		}
+	}()
+	if handler.Size > content.MaxImportFileSize {
+		ResponseWithErr(w, http.StatusRequestEntityTooLarge, "import file is too large")
+		return
+	}
+
+	format := content.ImportFormat(r.FormValue("format"))
+	if format == "" {
+		format = content.ImportFormatFromFileName(handler.Filename)
+	}
+	if !format.IsValid() {
+		ResponseWithErr(w, http.StatusBadRequest, "format must be one of apkg, csv, quizlet")
+		return
+	}
+
+	dryRun := false
+	if v := r.FormValue("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			ResponseWithErr(w, http.StatusBadRequest, "dry_run must be true or false")
+			return
+		}
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		slog.Error("failed to read import file", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to read import file")
+		return
+	}
+
+	result, err := s.contentSrv.ImportDeck(r.Context(), userID, weekID, format, data, dryRun)
+	if err != nil {
+		if errors.Is(err, content.ErrInvalidImportFile) {
+			ResponseWithErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to import deck", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to import deck")
+		return
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	ResponseWithJSON(w, status, result)
+}
+
+// ExportWeekDeckHandler downloads the user's deck for a week as an Anki package, CSV or JSON
+// GET /decks/weeks/{week_id}/export?format=apkg|csv|json
+func (s *HTTPServer) ExportWeekDeckHandler(w http.ResponseWriter, r *http.Request) {
+	weekIDParam := chi.URLParam(r, "week_id")
+	weekID, ok := parseUUID(w, weekIDParam)
+	if !ok {
+		return
+	}
+
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	format, ok := parseExportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	export, err := s.contentSrv.ExportWeekDeck(r.Context(), userID, weekID, format)
+	if err != nil {
+		writeExportErr(w, err)
+		return
+	}
+	writeExport(w, export)
+}
+
+// ExportModuleRunDeckHandler downloads the user's cards from every week of a module run
+// GET /decks/module-runs/{run_id}/export?format=apkg|csv|json
+func (s *HTTPServer) ExportModuleRunDeckHandler(w http.ResponseWriter, r *http.Request) {
+	runIDParam := chi.URLParam(r, "run_id")
+	runID, ok := parseUUID(w, runIDParam)
+	if !ok {
+		return
+	}
+
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	format, ok := parseExportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	export, err := s.contentSrv.ExportModuleRunDeck(r.Context(), userID, runID, format)
+	if err != nil {
+		writeExportErr(w, err)
+		return
+	}
+	writeExport(w, export)
+}
+
+// parseExportFormat reads the format query parameter, Anki packages are the default
+func parseExportFormat(w http.ResponseWriter, r *http.Request) (content.ExportFormat, bool) {
+	format := content.ExportFormat(r.URL.Query().Get("format"))
+	if format == "" {
+		return content.ExportAPKG, true
+	}
+	if !format.IsValid() {
+		ResponseWithErr(w, http.StatusBadRequest, "format must be one of apkg, csv, json")
+		return "", false
+	}
+	return format, true
+}
+
+func writeExportErr(w http.ResponseWriter, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		ResponseWithErr(w, http.StatusNotFound, err.Error())
+		return
+	}
+	slog.Error("failed to export deck", "error", err)
+	ResponseWithErr(w, http.StatusInternalServerError, "failed to export deck")
+}
+
+func writeExport(w http.ResponseWriter, export content.DeckExport) {
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.FileName}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(export.Data)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(export.Data); err != nil {
+		slog.Error("failed to write deck export", "error", err)
+	}
+}
+
+// PublishDeckHandler shares the user's deck for a week so classmates can subscribe to it
+// POST /decks/weeks/{week_id}/publish
+func (s *HTTPServer) PublishDeckHandler(w http.ResponseWriter, r *http.Request) {
+	weekIDParam := chi.URLParam(r, "week_id")
+	weekID, ok := parseUUID(w, weekIDParam)
+	if !ok {
+		return
+	}
+
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	var req content.PublishDeckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("failed to decode request", "error", err)
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	deck, err := s.contentSrv.PublishDeck(r.Context(), userID, weekID, req.Title, req.Description)
+	if err != nil {
+		writeSharedDeckErr(w, err, "failed to publish deck")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, deck)
+}
+
+// UnpublishDeckHandler stops sharing the user's deck for a week
+// DELETE /decks/weeks/{week_id}/publish
+func (s *HTTPServer) UnpublishDeckHandler(w http.ResponseWriter, r *http.Request) {
+	weekIDParam := chi.URLParam(r, "week_id")
+	weekID, ok := parseUUID(w, weekIDParam)
+	if !ok {
+		return
+	}
+
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	if err := s.contentSrv.UnpublishDeck(r.Context(), userID, weekID); err != nil {
+		writeSharedDeckErr(w, err, "failed to unpublish deck")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, nil)
+}
+
+// ListSharedDecksHandler lists the decks published for a week
+// GET /decks/weeks/{week_id}/shared
+func (s *HTTPServer) ListSharedDecksHandler(w http.ResponseWriter, r *http.Request) {
+	weekIDParam := chi.URLParam(r, "week_id")
+	weekID, ok := parseUUID(w, weekIDParam)
+	if !ok {
+		return
+	}
+
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	decks, err := s.contentSrv.ListSharedDecks(r.Context(), weekID, userID)
+	if err != nil {
+		slog.Error("failed to list shared decks", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to list shared decks")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, decks)
+}
+
+// GetSharedDeckHandler returns a published deck with its cards
+// GET /decks/shared/{deck_id}
+func (s *HTTPServer) GetSharedDeckHandler(w http.ResponseWriter, r *http.Request) {
+	deckID, userID, ok := parseSharedDeckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	deck, err := s.contentSrv.GetSharedDeck(r.Context(), deckID, userID)
+	if err != nil {
+		writeSharedDeckErr(w, err, "failed to get shared deck")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, deck)
+}
+
+// SubscribeToSharedDeckHandler clones a shared deck into the user's deck for the same week
+// POST /decks/shared/{deck_id}/subscribe
+func (s *HTTPServer) SubscribeToSharedDeckHandler(w http.ResponseWriter, r *http.Request) {
+	deckID, userID, ok := parseSharedDeckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := s.contentSrv.SubscribeToSharedDeck(r.Context(), deckID, userID)
+	if err != nil {
+		writeSharedDeckErr(w, err, "failed to subscribe to deck")
+		return
+	}
+
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+	ResponseWithJSON(w, status, result)
+}
+
+// UnsubscribeFromSharedDeckHandler stops updates from a shared deck, cloned cards are kept
+// DELETE /decks/shared/{deck_id}/subscribe
+func (s *HTTPServer) UnsubscribeFromSharedDeckHandler(w http.ResponseWriter, r *http.Request) {
+	deckID, userID, ok := parseSharedDeckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.contentSrv.UnsubscribeFromSharedDeck(r.Context(), deckID, userID); err != nil {
+		writeSharedDeckErr(w, err, "failed to unsubscribe from deck")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, nil)
+}
+
+// GetSharedDeckUpdatesHandler previews the author's edits since the user last synced
+// GET /decks/shared/{deck_id}/updates
+func (s *HTTPServer) GetSharedDeckUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	deckID, userID, ok := parseSharedDeckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	updates, err := s.contentSrv.GetSharedDeckUpdates(r.Context(), deckID, userID)
+	if err != nil {
+		writeSharedDeckErr(w, err, "failed to get deck updates")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, updates)
+}
+
+// ApplySharedDeckUpdatesHandler pulls the author's edits into the user's deck, the optional body says
+// which conflicting cards take the author's version and which keep the user's
+// POST /decks/shared/{deck_id}/updates
+func (s *HTTPServer) ApplySharedDeckUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	deckID, userID, ok := parseSharedDeckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req content.ApplySharedDeckUpdatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	updates, err := s.contentSrv.ApplySharedDeckUpdates(r.Context(), deckID, userID, req)
+	if err != nil {
+		writeSharedDeckErr(w, err, "failed to apply deck updates")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, updates)
+}
+
+func parseSharedDeckRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	deckID, ok := parseUUID(w, chi.URLParam(r, "deck_id"))
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	return deckID, userID, true
+}
+
+func writeSharedDeckErr(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		ResponseWithErr(w, http.StatusNotFound, err.Error())
+	case strings.Contains(err.Error(), "already"):
+		ResponseWithErr(w, http.StatusConflict, err.Error())
+	case strings.Contains(err.Error(), "cannot"):
+		ResponseWithErr(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Error(msg, "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, msg)
+	}
+}
+
+// SetCardTagsHandler replaces the tags of a card in user's deck
+// PUT /decks/cards/{card_id}/tags
+func (s *HTTPServer) SetCardTagsHandler(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := parseUUID(w, chi.URLParam(r, "card_id"))
+	if !ok {
+		return
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	var req content.SetCardTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tags, err := s.contentSrv.SetCardTags(r.Context(), cardID, userID, req.Tags)
+	if err != nil {
+		switch {
+		case errors.Is(err, content.ErrInvalidTags):
+			ResponseWithErr(w, http.StatusBadRequest, err.Error())
+		case strings.Contains(err.Error(), "not found"):
+			ResponseWithErr(w, http.StatusNotFound, err.Error())
+		default:
+			slog.Error("failed to set card tags", "error", err)
+			ResponseWithErr(w, http.StatusInternalServerError, "failed to set card tags")
+		}
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, tags)
+}
+
+// ListTagsHandler lists the tags used across the user's decks with their card counts
+// GET /decks/tags
+func (s *HTTPServer) ListTagsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	tags, err := s.contentSrv.ListTags(r.Context(), userID)
+	if err != nil {
+		slog.Error("failed to list tags", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to list tags")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, tags)
+}
+
+// SearchCardsHandler runs a full-text search over the fronts and backs of all the user's decks
+// GET /decks/search?q=&tag=&module=&limit=&offset=
+func (s *HTTPServer) SearchCardsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	search := content.CardSearchQuery{
+		Query: r.URL.Query().Get("q"),
+		Tag:   r.URL.Query().Get("tag"),
+	}
+	if search.ModuleID, ok = parseQueryUUID(w, r, "module"); !ok {
+		return
+	}
+	if search.Page, ok = parsePage(w, r); !ok {
+		return
+	}
+
+	results, err := s.contentSrv.SearchCards(r.Context(), userID, search)
+	if err != nil {
+		if errors.Is(err, content.ErrInvalidSearch) || errors.Is(err, content.ErrInvalidTags) || strings.Contains(err.Error(), "negative") {
+			ResponseWithErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to search cards", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to search cards")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, results)
+}
+
+// GetStudyAnalyticsHandler returns the review heatmap, streaks, retention and card maturity across all decks
+// GET /decks/analytics?days=&tz=
+func (s *HTTPServer) GetStudyAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	days, ok := parseQueryInt(w, r, "days", content.DefaultHeatmapDays)
+	if !ok {
+		return
+	}
+	loc, err := content.LoadTimeZone(r.URL.Query().Get("tz"))
+	if err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	analytics, err := s.contentSrv.GetStudyAnalytics(r.Context(), userID, days, loc)
+	if err != nil {
+		slog.Error("failed to get study analytics", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to get analytics")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, analytics)
+}
+
+// GetStudySettingsHandler returns the scheduler used for the user's reviews
+func (s *HTTPServer) GetStudySettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	settings, err := s.contentSrv.GetStudySettings(r.Context(), userID)
+	if err != nil {
+		slog.Error("failed to get study settings", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to get study settings")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, settings)
+}
+
+// UpdateStudySettingsHandler switches the user's scheduler between SM-2 and FSRS
+func (s *HTTPServer) UpdateStudySettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userIDStr := getUserID(r)
+	userID, ok := parseUUID(w, userIDStr)
+	if !ok {
+		return
+	}
+
+	var req content.UpdateStudySettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("failed to decode request", "error", err)
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	settings, err := s.contentSrv.UpdateStudySettings(r.Context(), userID, req.Scheduler, req.DesiredRetention)
+	if err != nil {
+		if strings.Contains(err.Error(), "must be") {
+			ResponseWithErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to update study settings", "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to update study settings")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, settings)
+}
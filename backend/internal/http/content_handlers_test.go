@@ -7,8 +7,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,13 +23,30 @@ import (
 
 // mockContentService implements the methods needed for testing deck handlers
 type mockContentService struct {
-	addCardToDeckFunc      func(ctx context.Context, userID, weekID, flashcardID uuid.UUID) error
-	createCustomCardFunc   func(ctx context.Context, userID, weekID uuid.UUID, front, back string) (content.UserDeckCard, error)
-	getUserDeckFunc        func(ctx context.Context, userID, weekID uuid.UUID) ([]content.UserDeckCard, error)
-	updateDeckCardFunc     func(ctx context.Context, cardID, userID uuid.UUID, front, back *string) error
-	removeCardFromDeckFunc func(ctx context.Context, cardID, userID uuid.UUID) error
-	recordCardReviewFunc   func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error
-	getDeckStatsFunc       func(ctx context.Context, userID, weekID uuid.UUID) (content.DeckStats, error)
+	addCardToDeckFunc       func(ctx context.Context, userID, weekID, flashcardID uuid.UUID) error
+	createCustomCardFunc    func(ctx context.Context, userID, weekID uuid.UUID, req content.CreateCustomCardRequest) ([]content.UserDeckCard, error)
+	getUserDeckFunc         func(ctx context.Context, userID, weekID uuid.UUID) ([]content.UserDeckCard, error)
+	updateDeckCardFunc      func(ctx context.Context, cardID, userID uuid.UUID, front, back *string) error
+	removeCardFromDeckFunc  func(ctx context.Context, cardID, userID uuid.UUID) error
+	recordCardReviewFunc    func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int, responseTimeMs *int) (content.UserDeckCard, error)
+	getDeckStatsFunc        func(ctx context.Context, userID, weekID uuid.UUID) (content.DeckStats, error)
+	getStudySettingsFunc    func(ctx context.Context, userID uuid.UUID) (content.StudySettings, error)
+	updateStudySettingsFunc func(ctx context.Context, userID uuid.UUID, scheduler content.SchedulerType, desiredRetention *float64) (content.StudySettings, error)
+	getDueCardsFunc         func(ctx context.Context, userID uuid.UUID, filter content.DueCardsFilter) ([]content.DueCard, error)
+	listCardReviewsFunc     func(ctx context.Context, cardID, userID uuid.UUID, page content.Page) ([]content.CardReview, error)
+	listUserReviewsFunc     func(ctx context.Context, userID uuid.UUID, page content.Page) ([]content.CardReview, error)
+	getStudyAnalyticsFunc   func(ctx context.Context, userID uuid.UUID, days int, loc *time.Location) (content.StudyAnalytics, error)
+	exportWeekDeckFunc      func(ctx context.Context, userID, weekID uuid.UUID, format content.ExportFormat) (content.DeckExport, error)
+	importDeckFunc          func(ctx context.Context, userID, weekID uuid.UUID, format content.ImportFormat, data []byte, dryRun bool) (content.ImportResult, error)
+	publishDeckFunc         func(ctx context.Context, ownerID, weekID uuid.UUID, title, description string) (content.SharedDeck, error)
+	subscribeFunc           func(ctx context.Context, deckID, userID uuid.UUID) (content.SubscribeResult, error)
+	searchCardsFunc         func(ctx context.Context, userID uuid.UUID, search content.CardSearchQuery) ([]content.CardSearchResult, error)
+	createExamFunc          func(ctx context.Context, userID uuid.UUID, req content.CreateExamRequest) (content.ExamSession, error)
+	answerExamQuestionFunc  func(ctx context.Context, userID, examID uuid.UUID, req content.AnswerExamQuestionRequest) (content.ExamSession, error)
+	getGenerationStatusFunc func(ctx context.Context, resourceID uuid.UUID) (content.GenerationJob, error)
+	replayDeadLettersFunc   func(ctx context.Context, objectIDs []uuid.UUID) ([]uuid.UUID, error)
+	listQuizzesFunc         func(ctx context.Context, ids []uuid.UUID) ([]content.QuizQuestion, error)
+	regenerateFunc          func(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts content.GenerationOptions) (content.GenerationJob, error)
 }
 
 func (m *mockContentService) AddCardToUserDeck(ctx context.Context, userID, weekID, flashcardID uuid.UUID) error {
@@ -34,11 +56,11 @@ func (m *mockContentService) AddCardToUserDeck(ctx context.Context, userID, week
 	return nil
 }
 
-func (m *mockContentService) CreateCustomCard(ctx context.Context, userID, weekID uuid.UUID, front, back string) (content.UserDeckCard, error) {
+func (m *mockContentService) CreateCustomCard(ctx context.Context, userID, weekID uuid.UUID, req content.CreateCustomCardRequest) ([]content.UserDeckCard, error) {
 	if m.createCustomCardFunc != nil {
-		return m.createCustomCardFunc(ctx, userID, weekID, front, back)
+		return m.createCustomCardFunc(ctx, userID, weekID, req)
 	}
-	return content.UserDeckCard{}, nil
+	return []content.UserDeckCard{}, nil
 }
 
 func (m *mockContentService) GetUserDeckForWeek(ctx context.Context, userID, weekID uuid.UUID) ([]content.UserDeckCard, error) {
@@ -62,11 +84,11 @@ func (m *mockContentService) RemoveCardFromDeck(ctx context.Context, cardID, use
 	return nil
 }
 
-func (m *mockContentService) RecordCardReview(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error {
+func (m *mockContentService) RecordCardReview(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int, responseTimeMs *int) (content.UserDeckCard, error) {
 	if m.recordCardReviewFunc != nil {
-		return m.recordCardReviewFunc(ctx, cardID, userID, difficultyRating)
+		return m.recordCardReviewFunc(ctx, cardID, userID, difficultyRating, responseTimeMs)
 	}
-	return nil
+	return content.UserDeckCard{}, nil
 }
 
 func (m *mockContentService) GetDeckStats(ctx context.Context, userID, weekID uuid.UUID) (content.DeckStats, error) {
@@ -76,6 +98,125 @@ func (m *mockContentService) GetDeckStats(ctx context.Context, userID, weekID uu
 	return content.DeckStats{}, nil
 }
 
+func (m *mockContentService) GetStudySettings(ctx context.Context, userID uuid.UUID) (content.StudySettings, error) {
+	if m.getStudySettingsFunc != nil {
+		return m.getStudySettingsFunc(ctx, userID)
+	}
+	return content.StudySettings{}, nil
+}
+
+func (m *mockContentService) UpdateStudySettings(ctx context.Context, userID uuid.UUID, scheduler content.SchedulerType, desiredRetention *float64) (content.StudySettings, error) {
+	if m.updateStudySettingsFunc != nil {
+		return m.updateStudySettingsFunc(ctx, userID, scheduler, desiredRetention)
+	}
+	return content.StudySettings{}, nil
+}
+
+func (m *mockContentService) GetDueCards(ctx context.Context, userID uuid.UUID, filter content.DueCardsFilter) ([]content.DueCard, error) {
+	if m.getDueCardsFunc != nil {
+		return m.getDueCardsFunc(ctx, userID, filter)
+	}
+	return []content.DueCard{}, nil
+}
+
+func (m *mockContentService) ListCardReviews(ctx context.Context, cardID, userID uuid.UUID, page content.Page) ([]content.CardReview, error) {
+	if m.listCardReviewsFunc != nil {
+		return m.listCardReviewsFunc(ctx, cardID, userID, page)
+	}
+	return []content.CardReview{}, nil
+}
+
+func (m *mockContentService) ListUserReviews(ctx context.Context, userID uuid.UUID, page content.Page) ([]content.CardReview, error) {
+	if m.listUserReviewsFunc != nil {
+		return m.listUserReviewsFunc(ctx, userID, page)
+	}
+	return []content.CardReview{}, nil
+}
+
+func (m *mockContentService) GetStudyAnalytics(ctx context.Context, userID uuid.UUID, days int, loc *time.Location) (content.StudyAnalytics, error) {
+	if m.getStudyAnalyticsFunc != nil {
+		return m.getStudyAnalyticsFunc(ctx, userID, days, loc)
+	}
+	return content.StudyAnalytics{}, nil
+}
+
+func (m *mockContentService) ExportWeekDeck(ctx context.Context, userID, weekID uuid.UUID, format content.ExportFormat) (content.DeckExport, error) {
+	if m.exportWeekDeckFunc != nil {
+		return m.exportWeekDeckFunc(ctx, userID, weekID, format)
+	}
+	return content.DeckExport{}, nil
+}
+
+func (m *mockContentService) ImportDeck(ctx context.Context, userID, weekID uuid.UUID, format content.ImportFormat, data []byte, dryRun bool) (content.ImportResult, error) {
+	if m.importDeckFunc != nil {
+		return m.importDeckFunc(ctx, userID, weekID, format, data, dryRun)
+	}
+	return content.ImportResult{}, nil
+}
+
+func (m *mockContentService) PublishDeck(ctx context.Context, ownerID, weekID uuid.UUID, title, description string) (content.SharedDeck, error) {
+	if m.publishDeckFunc != nil {
+		return m.publishDeckFunc(ctx, ownerID, weekID, title, description)
+	}
+	return content.SharedDeck{}, nil
+}
+
+func (m *mockContentService) SubscribeToSharedDeck(ctx context.Context, deckID, userID uuid.UUID) (content.SubscribeResult, error) {
+	if m.subscribeFunc != nil {
+		return m.subscribeFunc(ctx, deckID, userID)
+	}
+	return content.SubscribeResult{}, nil
+}
+
+func (m *mockContentService) SearchCards(ctx context.Context, userID uuid.UUID, search content.CardSearchQuery) ([]content.CardSearchResult, error) {
+	if m.searchCardsFunc != nil {
+		return m.searchCardsFunc(ctx, userID, search)
+	}
+	return []content.CardSearchResult{}, nil
+}
+
+func (m *mockContentService) CreateExam(ctx context.Context, userID uuid.UUID, req content.CreateExamRequest) (content.ExamSession, error) {
+	if m.createExamFunc != nil {
+		return m.createExamFunc(ctx, userID, req)
+	}
+	return content.ExamSession{}, nil
+}
+
+func (m *mockContentService) AnswerExamQuestion(ctx context.Context, userID, examID uuid.UUID, req content.AnswerExamQuestionRequest) (content.ExamSession, error) {
+	if m.answerExamQuestionFunc != nil {
+		return m.answerExamQuestionFunc(ctx, userID, examID, req)
+	}
+	return content.ExamSession{}, nil
+}
+
+func (m *mockContentService) GetGenerationStatus(ctx context.Context, resourceID uuid.UUID) (content.GenerationJob, error) {
+	if m.getGenerationStatusFunc != nil {
+		return m.getGenerationStatusFunc(ctx, resourceID)
+	}
+	return content.GenerationJob{}, nil
+}
+
+func (m *mockContentService) ReplayDeadLetters(ctx context.Context, objectIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if m.replayDeadLettersFunc != nil {
+		return m.replayDeadLettersFunc(ctx, objectIDs)
+	}
+	return []uuid.UUID{}, nil
+}
+
+func (m *mockContentService) ListSelectedObjectsQuizzes(ctx context.Context, ids []uuid.UUID) ([]content.QuizQuestion, error) {
+	if m.listQuizzesFunc != nil {
+		return m.listQuizzesFunc(ctx, ids)
+	}
+	return []content.QuizQuestion{}, nil
+}
+
+func (m *mockContentService) RegenerateFlashcards(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts content.GenerationOptions) (content.GenerationJob, error) {
+	if m.regenerateFunc != nil {
+		return m.regenerateFunc(ctx, resourceID, userID, asAdmin, opts)
+	}
+	return content.GenerationJob{}, nil
+}
+
 // Helper to add user ID to context (simulating auth middleware)
 func addUserIDToContext(r *http.Request, userID string) *http.Request {
 	ctx := context.WithValue(r.Context(), auth.UserIDContextKey, userID)
@@ -350,7 +491,7 @@ func TestRecordCardReviewHandler(t *testing.T) {
 		cardID         string
 		userID         string
 		requestBody    interface{}
-		mockFunc       func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error
+		mockFunc       func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int, responseTimeMs *int) (content.UserDeckCard, error)
 		expectedStatus int
 		expectedError  bool
 	}{
@@ -361,8 +502,9 @@ func TestRecordCardReviewHandler(t *testing.T) {
 			requestBody: content.RecordReviewRequest{
 				DifficultyRating: 3,
 			},
-			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error {
-				return nil
+			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int, responseTimeMs *int) (content.UserDeckCard, error) {
+				due := time.Now().AddDate(0, 0, 1)
+				return content.UserDeckCard{ID: cardID, UserID: userID, ReviewCount: 1, CardSchedule: content.CardSchedule{DueAt: &due, IntervalDays: 1, EaseFactor: 2.36}}, nil
 			},
 			expectedStatus: http.StatusOK,
 			expectedError:  false,
@@ -375,6 +517,20 @@ func TestRecordCardReviewHandler(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  true,
 		},
+		{
+			name:   "error - negative response time",
+			cardID: uuid.New().String(),
+			userID: uuid.New().String(),
+			requestBody: content.RecordReviewRequest{
+				DifficultyRating: 3,
+				ResponseTimeMs:   intPtr(-10),
+			},
+			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int, responseTimeMs *int) (content.UserDeckCard, error) {
+				return content.UserDeckCard{}, errors.New("response time cannot be negative")
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
 		{
 			name:   "error - card not found",
 			cardID: uuid.New().String(),
@@ -382,8 +538,8 @@ func TestRecordCardReviewHandler(t *testing.T) {
 			requestBody: content.RecordReviewRequest{
 				DifficultyRating: 3,
 			},
-			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error {
-				return errors.New("card not found or access denied")
+			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int, responseTimeMs *int) (content.UserDeckCard, error) {
+				return content.UserDeckCard{}, errors.New("card not found or access denied")
 			},
 			expectedStatus: http.StatusNotFound,
 			expectedError:  true,
@@ -395,8 +551,8 @@ func TestRecordCardReviewHandler(t *testing.T) {
 			requestBody: content.RecordReviewRequest{
 				DifficultyRating: 0,
 			},
-			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error {
-				return errors.New("difficulty rating must be between 1 and 5")
+			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int, responseTimeMs *int) (content.UserDeckCard, error) {
+				return content.UserDeckCard{}, errors.New("difficulty rating must be between 1 and 5")
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  true,
@@ -408,8 +564,8 @@ func TestRecordCardReviewHandler(t *testing.T) {
 			requestBody: content.RecordReviewRequest{
 				DifficultyRating: 6,
 			},
-			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int) error {
-				return errors.New("difficulty rating must be between 1 and 5")
+			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, difficultyRating int, responseTimeMs *int) (content.UserDeckCard, error) {
+				return content.UserDeckCard{}, errors.New("difficulty rating must be between 1 and 5")
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  true,
@@ -452,9 +608,9 @@ func TestRecordCardReviewHandler(t *testing.T) {
 					if err := json.NewDecoder(bytes.NewBuffer(body)).Decode(&reqBody); err != nil {
 						ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
 					} else {
-						err = mockSvc.RecordCardReview(req.Context(), cardID, userID, reqBody.DifficultyRating)
+						card, err := mockSvc.RecordCardReview(req.Context(), cardID, userID, reqBody.DifficultyRating, reqBody.ResponseTimeMs)
 						if err != nil {
-							if err.Error() == "difficulty rating must be between 1 and 5" {
+							if err.Error() == "difficulty rating must be between 1 and 5" || err.Error() == "response time cannot be negative" {
 								ResponseWithErr(w, http.StatusBadRequest, err.Error())
 							} else if err.Error() == "card not found or access denied" {
 								ResponseWithErr(w, http.StatusNotFound, err.Error())
@@ -462,7 +618,7 @@ func TestRecordCardReviewHandler(t *testing.T) {
 								ResponseWithErr(w, http.StatusInternalServerError, "failed to record review")
 							}
 						} else {
-							ResponseWithJSON(w, http.StatusOK, nil)
+							ResponseWithJSON(w, http.StatusOK, card)
 						}
 					}
 				}
@@ -665,14 +821,101 @@ func TestListCardsFromObjects(t *testing.T) {
 	}
 }
 
+func TestListQuizzesFromObjects(t *testing.T) {
+	objectID := uuid.New()
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockFunc       func(ctx context.Context, ids []uuid.UUID) ([]content.QuizQuestion, error)
+		expectedStatus int
+		expectedCount  int
+	}{
+		{
+			name:        "success - questions with their answers",
+			requestBody: `{"ids":["` + objectID.String() + `"]}`,
+			mockFunc: func(ctx context.Context, ids []uuid.UUID) ([]content.QuizQuestion, error) {
+				if len(ids) != 1 || ids[0] != objectID {
+					return nil, errors.New("unexpected ids")
+				}
+				return []content.QuizQuestion{
+					{ID: uuid.New(), StorageObjectID: objectID, Position: 1, Question: "Q1", Options: []string{"A", "B"}, CorrectOption: 1},
+					{ID: uuid.New(), StorageObjectID: objectID, Position: 2, Question: "Q2", Options: []string{"A", "B"}},
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+		},
+		{
+			name:        "success - objects without a quiz",
+			requestBody: `{"ids":[]}`,
+			mockFunc: func(ctx context.Context, ids []uuid.UUID) ([]content.QuizQuestion, error) {
+				return []content.QuizQuestion{}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "error - invalid JSON",
+			requestBody:    "invalid json",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid object ID",
+			requestBody:    `{"ids":["invalid-uuid"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "error - service error",
+			requestBody: `{"ids":["` + objectID.String() + `"]}`,
+			mockFunc: func(ctx context.Context, ids []uuid.UUID) ([]content.QuizQuestion, error) {
+				return nil, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{listQuizzesFunc: tt.mockFunc}
+			req := httptest.NewRequest(http.MethodPost, "/conents/objects/quizzes", strings.NewReader(tt.requestBody))
+			w := httptest.NewRecorder()
+
+			// Execute handler logic
+			if ids, ok := decodeObjectIDs(w, req); ok {
+				questions, err := mockSvc.ListSelectedObjectsQuizzes(req.Context(), ids)
+				if err != nil {
+					ResponseWithErr(w, http.StatusInternalServerError, "failed to list quizzes")
+				} else {
+					ResponseWithJSON(w, http.StatusOK, questions)
+				}
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					Data []content.QuizQuestion `json:"data"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if len(response.Data) != tt.expectedCount {
+					t.Errorf("expected %d questions, got %d", tt.expectedCount, len(response.Data))
+				}
+			}
+		})
+	}
+}
+
 func TestCreateCustomCardHandler(t *testing.T) {
 	tests := []struct {
 		name           string
 		weekID         string
 		userID         string
 		requestBody    interface{}
-		mockFunc       func(ctx context.Context, userID, weekID uuid.UUID, front, back string) (content.UserDeckCard, error)
+		mockFunc       func(ctx context.Context, userID, weekID uuid.UUID, req content.CreateCustomCardRequest) ([]content.UserDeckCard, error)
 		expectedStatus int
+		expectedCards  int
 		expectedError  bool
 	}{
 		{
@@ -683,19 +926,55 @@ func TestCreateCustomCardHandler(t *testing.T) {
 				Front: "What is Go?",
 				Back:  "A programming language",
 			},
-			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, front, back string) (content.UserDeckCard, error) {
-				return content.UserDeckCard{
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, req content.CreateCustomCardRequest) ([]content.UserDeckCard, error) {
+				return []content.UserDeckCard{{
 					ID:        uuid.New(),
 					UserID:    userID,
 					WeekID:    weekID,
-					Front:     front,
-					Back:      back,
+					Front:     req.Front,
+					Back:      req.Back,
 					IsCustom:  true,
+					CardType:  content.CardBasic,
 					CreatedAt: time.Now(),
 					UpdatedAt: time.Now(),
-				}, nil
+				}}, nil
+			},
+			expectedStatus: http.StatusCreated,
+			expectedCards:  1,
+			expectedError:  false,
+		},
+		{
+			name:   "success - cloze note creates a card per deletion",
+			weekID: uuid.New().String(),
+			userID: uuid.New().String(),
+			requestBody: content.CreateCustomCardRequest{
+				Type:    content.CardCloze,
+				Content: &content.CardContent{Text: "{{c1::Go}} was released in {{c2::2009}}"},
+			},
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, req content.CreateCustomCardRequest) ([]content.UserDeckCard, error) {
+				if req.Type != content.CardCloze || req.Content == nil {
+					return nil, fmt.Errorf("%w: cloze cards need content.text", content.ErrInvalidCard)
+				}
+				noteID := uuid.New()
+				cards := make([]content.UserDeckCard, 0, 2)
+				for _, index := range []int{1, 2} {
+					front, back := content.RenderCloze(req.Content.Text, index)
+					cards = append(cards, content.UserDeckCard{
+						ID:       uuid.New(),
+						UserID:   userID,
+						WeekID:   weekID,
+						Front:    front,
+						Back:     back,
+						IsCustom: true,
+						CardType: content.CardCloze,
+						Content:  &content.CardContent{Text: req.Content.Text, ClozeIndex: index},
+						NoteID:   &noteID,
+					})
+				}
+				return cards, nil
 			},
 			expectedStatus: http.StatusCreated,
+			expectedCards:  2,
 			expectedError:  false,
 		},
 		{
@@ -706,8 +985,8 @@ func TestCreateCustomCardHandler(t *testing.T) {
 				Front: "",
 				Back:  "A programming language",
 			},
-			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, front, back string) (content.UserDeckCard, error) {
-				return content.UserDeckCard{}, errors.New("front and back cannot be empty")
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, req content.CreateCustomCardRequest) ([]content.UserDeckCard, error) {
+				return nil, fmt.Errorf("%w: front and back cannot be empty", content.ErrInvalidCard)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  true,
@@ -720,12 +999,41 @@ func TestCreateCustomCardHandler(t *testing.T) {
 				Front: "What is Go?",
 				Back:  "",
 			},
-			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, front, back string) (content.UserDeckCard, error) {
-				return content.UserDeckCard{}, errors.New("front and back cannot be empty")
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, req content.CreateCustomCardRequest) ([]content.UserDeckCard, error) {
+				return nil, fmt.Errorf("%w: front and back cannot be empty", content.ErrInvalidCard)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
+		{
+			name:   "error - multiple choice without correct option",
+			weekID: uuid.New().String(),
+			userID: uuid.New().String(),
+			requestBody: content.CreateCustomCardRequest{
+				Type:    content.CardMultipleChoice,
+				Front:   "Which keyword starts a goroutine?",
+				Content: &content.CardContent{Options: []string{"go", "async"}},
+			},
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, req content.CreateCustomCardRequest) ([]content.UserDeckCard, error) {
+				return nil, fmt.Errorf("%w: correct_option must point at one of the options", content.ErrInvalidCard)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  true,
 		},
+		{
+			name:   "error - image object not found",
+			weekID: uuid.New().String(),
+			userID: uuid.New().String(),
+			requestBody: content.CreateCustomCardRequest{
+				Type: content.CardImage,
+				Back: "Mitochondria",
+			},
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, req content.CreateCustomCardRequest) ([]content.UserDeckCard, error) {
+				return nil, errors.New("image object not found")
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  true,
+		},
 		{
 			name:   "error - invalid week ID",
 			weekID: "invalid-uuid",
@@ -768,15 +1076,17 @@ func TestCreateCustomCardHandler(t *testing.T) {
 					if err := json.NewDecoder(bytes.NewBuffer(body)).Decode(&reqBody); err != nil {
 						ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
 					} else {
-						card, err := mockSvc.CreateCustomCard(req.Context(), userID, weekID, reqBody.Front, reqBody.Back)
+						cards, err := mockSvc.CreateCustomCard(req.Context(), userID, weekID, reqBody)
 						if err != nil {
-							if err.Error() == "front and back cannot be empty" {
+							if errors.Is(err, content.ErrInvalidCard) {
 								ResponseWithErr(w, http.StatusBadRequest, err.Error())
+							} else if strings.Contains(err.Error(), "not found") {
+								ResponseWithErr(w, http.StatusNotFound, err.Error())
 							} else {
 								ResponseWithErr(w, http.StatusInternalServerError, "failed to create custom card")
 							}
 						} else {
-							ResponseWithJSON(w, http.StatusCreated, card)
+							ResponseWithJSON(w, http.StatusCreated, cards)
 						}
 					}
 				}
@@ -794,6 +1104,17 @@ func TestCreateCustomCardHandler(t *testing.T) {
 				if _, exists := resp["error"]; !exists {
 					t.Error("expected error in response")
 				}
+				return
+			}
+
+			var resp struct {
+				Data []content.UserDeckCard `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if len(resp.Data) != tt.expectedCards {
+				t.Errorf("expected %d cards, got %d", tt.expectedCards, len(resp.Data))
 			}
 		})
 	}
@@ -1000,7 +1321,1216 @@ func TestRemoveDeckCardHandler(t *testing.T) {
 	}
 }
 
-// Helper function to create string pointers
-func stringPtr(s string) *string {
-	return &s
+func TestUpdateStudySettingsHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		requestBody    interface{}
+		mockFunc       func(ctx context.Context, userID uuid.UUID, scheduler content.SchedulerType, desiredRetention *float64) (content.StudySettings, error)
+		expectedStatus int
+	}{
+		{
+			name:        "success - switch to fsrs",
+			userID:      uuid.New().String(),
+			requestBody: content.UpdateStudySettingsRequest{Scheduler: content.SchedulerFSRS},
+			mockFunc: func(ctx context.Context, userID uuid.UUID, scheduler content.SchedulerType, desiredRetention *float64) (content.StudySettings, error) {
+				return content.StudySettings{UserID: userID, Scheduler: scheduler, DesiredRetention: 0.9}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "error - invalid request body",
+			userID:         uuid.New().String(),
+			requestBody:    "invalid json",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "error - unknown scheduler",
+			userID:      uuid.New().String(),
+			requestBody: content.UpdateStudySettingsRequest{Scheduler: "leitner"},
+			mockFunc: func(ctx context.Context, userID uuid.UUID, scheduler content.SchedulerType, desiredRetention *float64) (content.StudySettings, error) {
+				return content.StudySettings{}, errors.New("scheduler must be one of sm2, fsrs")
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "error - service error",
+			userID:      uuid.New().String(),
+			requestBody: content.UpdateStudySettingsRequest{Scheduler: content.SchedulerSM2},
+			mockFunc: func(ctx context.Context, userID uuid.UUID, scheduler content.SchedulerType, desiredRetention *float64) (content.StudySettings, error) {
+				return content.StudySettings{}, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{
+				updateStudySettingsFunc: tt.mockFunc,
+			}
+
+			var body []byte
+			var err error
+			if str, ok := tt.requestBody.(string); ok {
+				body = []byte(str)
+			} else {
+				body, err = json.Marshal(tt.requestBody)
+				if err != nil {
+					t.Fatalf("failed to marshal request body: %v", err)
+				}
+			}
+			req := httptest.NewRequest(http.MethodPut, "/decks/settings", bytes.NewBuffer(body))
+			req = addUserIDToContext(req, tt.userID)
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			userID, ok := parseUUID(w, getUserID(req))
+			if ok {
+				var reqBody content.UpdateStudySettingsRequest
+				if err := json.NewDecoder(bytes.NewBuffer(body)).Decode(&reqBody); err != nil {
+					ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+				} else {
+					settings, err := mockSvc.UpdateStudySettings(req.Context(), userID, reqBody.Scheduler, reqBody.DesiredRetention)
+					if err != nil {
+						if strings.Contains(err.Error(), "must be") {
+							ResponseWithErr(w, http.StatusBadRequest, err.Error())
+						} else {
+							ResponseWithErr(w, http.StatusInternalServerError, "failed to update study settings")
+						}
+					} else {
+						ResponseWithJSON(w, http.StatusOK, settings)
+					}
+				}
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestGetStudySettingsHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		mockFunc       func(ctx context.Context, userID uuid.UUID) (content.StudySettings, error)
+		expectedStatus int
+	}{
+		{
+			name:   "success - default settings",
+			userID: uuid.New().String(),
+			mockFunc: func(ctx context.Context, userID uuid.UUID) (content.StudySettings, error) {
+				return content.StudySettings{UserID: userID, Scheduler: content.SchedulerSM2, DesiredRetention: 0.9}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "error - service error",
+			userID: uuid.New().String(),
+			mockFunc: func(ctx context.Context, userID uuid.UUID) (content.StudySettings, error) {
+				return content.StudySettings{}, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{
+				getStudySettingsFunc: tt.mockFunc,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/decks/settings", nil)
+			req = addUserIDToContext(req, tt.userID)
+			w := httptest.NewRecorder()
+
+			userID, ok := parseUUID(w, getUserID(req))
+			if ok {
+				settings, err := mockSvc.GetStudySettings(req.Context(), userID)
+				if err != nil {
+					ResponseWithErr(w, http.StatusInternalServerError, "failed to get study settings")
+				} else {
+					ResponseWithJSON(w, http.StatusOK, settings)
+				}
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestGetDueCardsHandler(t *testing.T) {
+	moduleID := uuid.New()
+	tests := []struct {
+		name           string
+		query          string
+		mockFunc       func(ctx context.Context, userID uuid.UUID, filter content.DueCardsFilter) ([]content.DueCard, error)
+		expectedStatus int
+		expectedFilter *content.DueCardsFilter
+	}{
+		{
+			name:  "success - default limits",
+			query: "",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, filter content.DueCardsFilter) ([]content.DueCard, error) {
+				return []content.DueCard{{ModuleID: moduleID, ModuleName: "Networks", WeekNumber: 3}}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedFilter: &content.DueCardsFilter{NewLimit: content.DefaultNewCardsLimit, ReviewLimit: content.DefaultReviewCardsLimit},
+		},
+		{
+			name:  "success - custom limits and module filter",
+			query: "?new_limit=5&review_limit=50&module_id=" + moduleID.String(),
+			mockFunc: func(ctx context.Context, userID uuid.UUID, filter content.DueCardsFilter) ([]content.DueCard, error) {
+				return []content.DueCard{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedFilter: &content.DueCardsFilter{NewLimit: 5, ReviewLimit: 50, ModuleID: &moduleID},
+		},
+		{
+			name:           "error - invalid new_limit",
+			query:          "?new_limit=abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid module_run_id",
+			query:          "?module_run_id=invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "error - negative limit",
+			query: "?review_limit=-1",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, filter content.DueCardsFilter) ([]content.DueCard, error) {
+				return nil, errors.New("limits cannot be negative")
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "error - service error",
+			query: "",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, filter content.DueCardsFilter) ([]content.DueCard, error) {
+				return nil, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFilter content.DueCardsFilter
+			mockSvc := &mockContentService{
+				getDueCardsFunc: func(ctx context.Context, userID uuid.UUID, filter content.DueCardsFilter) ([]content.DueCard, error) {
+					gotFilter = filter
+					if tt.mockFunc != nil {
+						return tt.mockFunc(ctx, userID, filter)
+					}
+					return []content.DueCard{}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/decks/due"+tt.query, nil)
+			req = addUserIDToContext(req, uuid.New().String())
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				filter := content.DueCardsFilter{}
+				if filter.NewLimit, ok = parseQueryInt(w, req, "new_limit", content.DefaultNewCardsLimit); !ok {
+					return
+				}
+				if filter.ReviewLimit, ok = parseQueryInt(w, req, "review_limit", content.DefaultReviewCardsLimit); !ok {
+					return
+				}
+				if filter.ModuleID, ok = parseQueryUUID(w, req, "module_id"); !ok {
+					return
+				}
+				if filter.ModuleRunID, ok = parseQueryUUID(w, req, "module_run_id"); !ok {
+					return
+				}
+				cards, err := mockSvc.GetDueCards(req.Context(), userID, filter)
+				if err != nil {
+					if strings.Contains(err.Error(), "negative") {
+						ResponseWithErr(w, http.StatusBadRequest, err.Error())
+					} else {
+						ResponseWithErr(w, http.StatusInternalServerError, "failed to get due cards")
+					}
+					return
+				}
+				ResponseWithJSON(w, http.StatusOK, cards)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if tt.expectedFilter != nil {
+				if gotFilter.NewLimit != tt.expectedFilter.NewLimit || gotFilter.ReviewLimit != tt.expectedFilter.ReviewLimit {
+					t.Errorf("expected limits %d/%d, got %d/%d", tt.expectedFilter.NewLimit, tt.expectedFilter.ReviewLimit, gotFilter.NewLimit, gotFilter.ReviewLimit)
+				}
+				if (tt.expectedFilter.ModuleID == nil) != (gotFilter.ModuleID == nil) {
+					t.Errorf("expected module filter %v, got %v", tt.expectedFilter.ModuleID, gotFilter.ModuleID)
+				}
+			}
+		})
+	}
+}
+
+func TestListCardReviewsHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		cardID         string
+		query          string
+		mockFunc       func(ctx context.Context, cardID, userID uuid.UUID, page content.Page) ([]content.CardReview, error)
+		expectedStatus int
+		expectedPage   *content.Page
+	}{
+		{
+			name:   "success - default page",
+			cardID: uuid.New().String(),
+			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, page content.Page) ([]content.CardReview, error) {
+				return []content.CardReview{{ID: uuid.New(), CardID: &cardID, UserID: userID, DifficultyRating: 3, Recalled: true}}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedPage:   &content.Page{Limit: content.DefaultPageLimit, Offset: 0},
+		},
+		{
+			name:   "success - custom page",
+			cardID: uuid.New().String(),
+			query:  "?limit=10&offset=20",
+			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, page content.Page) ([]content.CardReview, error) {
+				return []content.CardReview{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedPage:   &content.Page{Limit: 10, Offset: 20},
+		},
+		{
+			name:           "error - invalid card ID",
+			cardID:         "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid limit",
+			cardID:         uuid.New().String(),
+			query:          "?limit=ten",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "error - card not found",
+			cardID: uuid.New().String(),
+			mockFunc: func(ctx context.Context, cardID, userID uuid.UUID, page content.Page) ([]content.CardReview, error) {
+				return nil, errors.New("card not found or access denied")
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPage content.Page
+			mockSvc := &mockContentService{
+				listCardReviewsFunc: func(ctx context.Context, cardID, userID uuid.UUID, page content.Page) ([]content.CardReview, error) {
+					gotPage = page
+					if tt.mockFunc != nil {
+						return tt.mockFunc(ctx, cardID, userID, page)
+					}
+					return []content.CardReview{}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/decks/cards/"+tt.cardID+"/reviews"+tt.query, nil)
+			req = addUserIDToContext(req, uuid.New().String())
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("card_id", tt.cardID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				cardID, ok := parseUUID(w, chi.URLParam(req, "card_id"))
+				if !ok {
+					return
+				}
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				page, ok := parsePage(w, req)
+				if !ok {
+					return
+				}
+				reviews, err := mockSvc.ListCardReviews(req.Context(), cardID, userID, page)
+				if err != nil {
+					if strings.Contains(err.Error(), "not found") {
+						ResponseWithErr(w, http.StatusNotFound, err.Error())
+					} else {
+						ResponseWithErr(w, http.StatusInternalServerError, "failed to list reviews")
+					}
+					return
+				}
+				ResponseWithJSON(w, http.StatusOK, reviews)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedPage != nil && gotPage != *tt.expectedPage {
+				t.Errorf("expected page %+v, got %+v", *tt.expectedPage, gotPage)
+			}
+		})
+	}
+}
+
+func TestListUserReviewsHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		mockFunc       func(ctx context.Context, userID uuid.UUID, page content.Page) ([]content.CardReview, error)
+		expectedStatus int
+	}{
+		{
+			name: "success - list reviews",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, page content.Page) ([]content.CardReview, error) {
+				return []content.CardReview{{ID: uuid.New(), UserID: userID, DifficultyRating: 5}}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "error - invalid offset",
+			query:          "?offset=x",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "error - negative limit",
+			query: "?limit=-5",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, page content.Page) ([]content.CardReview, error) {
+				return nil, errors.New("limit and offset cannot be negative")
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - service error",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, page content.Page) ([]content.CardReview, error) {
+				return nil, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{
+				listUserReviewsFunc: tt.mockFunc,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/decks/reviews"+tt.query, nil)
+			req = addUserIDToContext(req, uuid.New().String())
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				page, ok := parsePage(w, req)
+				if !ok {
+					return
+				}
+				reviews, err := mockSvc.ListUserReviews(req.Context(), userID, page)
+				if err != nil {
+					if strings.Contains(err.Error(), "negative") {
+						ResponseWithErr(w, http.StatusBadRequest, err.Error())
+					} else {
+						ResponseWithErr(w, http.StatusInternalServerError, "failed to list reviews")
+					}
+					return
+				}
+				ResponseWithJSON(w, http.StatusOK, reviews)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestGetStudyAnalyticsHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		mockFunc       func(ctx context.Context, userID uuid.UUID, days int, loc *time.Location) (content.StudyAnalytics, error)
+		expectedStatus int
+		expectedDays   int
+		expectedZone   string
+	}{
+		{
+			name: "success - default window",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, days int, loc *time.Location) (content.StudyAnalytics, error) {
+				return content.StudyAnalytics{
+					DailyReviews:  []content.DailyReviewCount{{Date: "2026-10-17", Count: 12}},
+					TotalReviews:  12,
+					CurrentStreak: 1,
+					LongestStreak: 4,
+					Cards:         content.CardMaturity{New: 3, Young: 8, Mature: 1},
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedDays:   content.DefaultHeatmapDays,
+			expectedZone:   "UTC",
+		},
+		{
+			name:  "success - last 30 days",
+			query: "?days=30",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, days int, loc *time.Location) (content.StudyAnalytics, error) {
+				return content.StudyAnalytics{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedDays:   30,
+		},
+		{
+			name:  "success - days in the user's time zone",
+			query: "?tz=Europe/Berlin",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, days int, loc *time.Location) (content.StudyAnalytics, error) {
+				return content.StudyAnalytics{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedZone:   "Europe/Berlin",
+		},
+		{
+			name:           "error - unknown time zone",
+			query:          "?tz=Mars/Olympus",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - server time zone",
+			query:          "?tz=Local",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid days",
+			query:          "?days=month",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - service error",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, days int, loc *time.Location) (content.StudyAnalytics, error) {
+				return content.StudyAnalytics{}, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotDays int
+			var gotZone string
+			mockSvc := &mockContentService{
+				getStudyAnalyticsFunc: func(ctx context.Context, userID uuid.UUID, days int, loc *time.Location) (content.StudyAnalytics, error) {
+					gotDays, gotZone = days, loc.String()
+					return tt.mockFunc(ctx, userID, days, loc)
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/decks/analytics"+tt.query, nil)
+			req = addUserIDToContext(req, uuid.New().String())
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				days, ok := parseQueryInt(w, req, "days", content.DefaultHeatmapDays)
+				if !ok {
+					return
+				}
+				loc, err := content.LoadTimeZone(req.URL.Query().Get("tz"))
+				if err != nil {
+					ResponseWithErr(w, http.StatusBadRequest, err.Error())
+					return
+				}
+				analytics, err := mockSvc.GetStudyAnalytics(req.Context(), userID, days, loc)
+				if err != nil {
+					ResponseWithErr(w, http.StatusInternalServerError, "failed to get analytics")
+					return
+				}
+				ResponseWithJSON(w, http.StatusOK, analytics)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedDays != 0 && gotDays != tt.expectedDays {
+				t.Errorf("expected days %d, got %d", tt.expectedDays, gotDays)
+			}
+			if tt.expectedZone != "" && gotZone != tt.expectedZone {
+				t.Errorf("expected time zone %s, got %s", tt.expectedZone, gotZone)
+			}
+		})
+	}
+}
+
+func TestImportDeckHandler(t *testing.T) {
+	validWeekID := uuid.New()
+
+	newImportRequest := func(fileName, body string, fields map[string]string) (*http.Request, error) {
+		buf := &bytes.Buffer{}
+		writer := multipart.NewWriter(buf)
+		part, err := writer.CreateFormFile("file", fileName)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write([]byte(body)); err != nil {
+			return nil, err
+		}
+		for k, v := range fields {
+			if err := writer.WriteField(k, v); err != nil {
+				return nil, err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		req := httptest.NewRequest(http.MethodPost, "/decks/weeks/"+validWeekID.String()+"/import", buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	}
+
+	tests := []struct {
+		name           string
+		fileName       string
+		body           string
+		fields         map[string]string
+		mockFunc       func(ctx context.Context, userID, weekID uuid.UUID, format content.ImportFormat, data []byte, dryRun bool) (content.ImportResult, error)
+		expectedStatus int
+		expectedFormat content.ImportFormat
+		expectedDryRun bool
+	}{
+		{
+			name:     "success - csv import",
+			fileName: "cards.csv",
+			body:     "front,back\nTCP,Transmission Control Protocol\n",
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ImportFormat, data []byte, dryRun bool) (content.ImportResult, error) {
+				return content.ImportResult{Total: 1, Imported: 1, Rows: []content.ImportRow{
+					{Row: 2, Front: "TCP", Back: "Transmission Control Protocol", Status: content.ImportRowOK},
+				}}, nil
+			},
+			expectedStatus: http.StatusCreated,
+			expectedFormat: content.ImportCSV,
+		},
+		{
+			name:     "success - quizlet dry run",
+			fileName: "quizlet.txt",
+			body:     "TCP\tTransmission Control Protocol\n",
+			fields:   map[string]string{"dry_run": "true"},
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ImportFormat, data []byte, dryRun bool) (content.ImportResult, error) {
+				return content.ImportResult{DryRun: true, Total: 1, Imported: 1}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedFormat: content.ImportQuizlet,
+			expectedDryRun: true,
+		},
+		{
+			name:     "success - explicit format overrides extension",
+			fileName: "export.data",
+			body:     "TCP,Transmission Control Protocol\n",
+			fields:   map[string]string{"format": "csv"},
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ImportFormat, data []byte, dryRun bool) (content.ImportResult, error) {
+				return content.ImportResult{}, nil
+			},
+			expectedStatus: http.StatusCreated,
+			expectedFormat: content.ImportCSV,
+		},
+		{
+			name:           "error - unknown format",
+			fileName:       "cards.xlsx",
+			body:           "binary",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid dry_run",
+			fileName:       "cards.csv",
+			body:           "a,b",
+			fields:         map[string]string{"dry_run": "maybe"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "error - unreadable file",
+			fileName: "deck.apkg",
+			body:     "not a zip",
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ImportFormat, data []byte, dryRun bool) (content.ImportResult, error) {
+				return content.ImportResult{}, fmt.Errorf("%w: zip: not a valid zip file", content.ErrInvalidImportFile)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedFormat: content.ImportAPKG,
+		},
+		{
+			name:     "error - service error",
+			fileName: "cards.csv",
+			body:     "a,b",
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ImportFormat, data []byte, dryRun bool) (content.ImportResult, error) {
+				return content.ImportResult{}, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedFormat: content.ImportCSV,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFormat content.ImportFormat
+			var gotDryRun bool
+			mockSvc := &mockContentService{
+				importDeckFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ImportFormat, data []byte, dryRun bool) (content.ImportResult, error) {
+					gotFormat, gotDryRun = format, dryRun
+					return tt.mockFunc(ctx, userID, weekID, format, data, dryRun)
+				},
+			}
+
+			req, err := newImportRequest(tt.fileName, tt.body, tt.fields)
+			if err != nil {
+				t.Fatalf("failed to setup request: %v", err)
+			}
+			req = addUserIDToContext(req, uuid.New().String())
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("week_id", validWeekID.String())
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				weekID, ok := parseUUID(w, chi.URLParam(req, "week_id"))
+				if !ok {
+					return
+				}
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				file, handler, err := req.FormFile("file")
+				if err != nil {
+					ResponseWithErr(w, http.StatusBadRequest, "cannot access form file data")
+					return
+				}
+				defer func() {
+					if closeErr := file.Close(); closeErr != nil {
+						t.Fatalf("failed to close file: %v", closeErr)
+					}
+				}()
+				format := content.ImportFormat(req.FormValue("format"))
+				if format == "" {
+					format = content.ImportFormatFromFileName(handler.Filename)
+				}
+				if !format.IsValid() {
+					ResponseWithErr(w, http.StatusBadRequest, "format must be one of apkg, csv, quizlet")
+					return
+				}
+				dryRun := false
+				if v := req.FormValue("dry_run"); v != "" {
+					if dryRun, err = strconv.ParseBool(v); err != nil {
+						ResponseWithErr(w, http.StatusBadRequest, "dry_run must be true or false")
+						return
+					}
+				}
+				data, err := io.ReadAll(file)
+				if err != nil {
+					ResponseWithErr(w, http.StatusInternalServerError, "failed to read import file")
+					return
+				}
+				result, err := mockSvc.ImportDeck(req.Context(), userID, weekID, format, data, dryRun)
+				if err != nil {
+					if errors.Is(err, content.ErrInvalidImportFile) {
+						ResponseWithErr(w, http.StatusBadRequest, err.Error())
+						return
+					}
+					ResponseWithErr(w, http.StatusInternalServerError, "failed to import deck")
+					return
+				}
+				status := http.StatusCreated
+				if dryRun {
+					status = http.StatusOK
+				}
+				ResponseWithJSON(w, status, result)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedFormat != "" && gotFormat != tt.expectedFormat {
+				t.Errorf("expected format %q, got %q", tt.expectedFormat, gotFormat)
+			}
+			if gotDryRun != tt.expectedDryRun {
+				t.Errorf("expected dry run %v, got %v", tt.expectedDryRun, gotDryRun)
+			}
+		})
+	}
+}
+
+func TestPublishDeckHandler(t *testing.T) {
+	validWeekID := uuid.New()
+
+	tests := []struct {
+		name           string
+		weekID         string
+		body           string
+		mockFunc       func(ctx context.Context, ownerID, weekID uuid.UUID, title, description string) (content.SharedDeck, error)
+		expectedStatus int
+	}{
+		{
+			name:   "success - publish deck",
+			weekID: validWeekID.String(),
+			body:   `{"title":"Week 3 networking","description":"TCP and UDP"}`,
+			mockFunc: func(ctx context.Context, ownerID, weekID uuid.UUID, title, description string) (content.SharedDeck, error) {
+				return content.SharedDeck{ID: uuid.New(), OwnerID: ownerID, WeekID: weekID, Title: title, Description: description, Version: 1, CardCount: 12}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "error - empty deck",
+			weekID: validWeekID.String(),
+			body:   `{"title":"Week 3"}`,
+			mockFunc: func(ctx context.Context, ownerID, weekID uuid.UUID, title, description string) (content.SharedDeck, error) {
+				return content.SharedDeck{}, errors.New("cannot publish an empty deck")
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid JSON",
+			weekID:         validWeekID.String(),
+			body:           `{invalid}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid week ID",
+			weekID:         "invalid-uuid",
+			body:           `{"title":"Week 3"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "error - service error",
+			weekID: validWeekID.String(),
+			body:   `{"title":"Week 3"}`,
+			mockFunc: func(ctx context.Context, ownerID, weekID uuid.UUID, title, description string) (content.SharedDeck, error) {
+				return content.SharedDeck{}, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{publishDeckFunc: tt.mockFunc}
+
+			req := httptest.NewRequest(http.MethodPost, "/decks/weeks/"+tt.weekID+"/publish", strings.NewReader(tt.body))
+			req = addUserIDToContext(req, uuid.New().String())
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("week_id", tt.weekID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				weekID, ok := parseUUID(w, chi.URLParam(req, "week_id"))
+				if !ok {
+					return
+				}
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				var body content.PublishDeckRequest
+				if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+					ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+					return
+				}
+				deck, err := mockSvc.PublishDeck(req.Context(), userID, weekID, body.Title, body.Description)
+				if err != nil {
+					writeSharedDeckErr(w, err, "failed to publish deck")
+					return
+				}
+				ResponseWithJSON(w, http.StatusOK, deck)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestSubscribeToSharedDeckHandler(t *testing.T) {
+	validDeckID := uuid.New()
+
+	tests := []struct {
+		name           string
+		deckID         string
+		mockFunc       func(ctx context.Context, deckID, userID uuid.UUID) (content.SubscribeResult, error)
+		expectedStatus int
+		expectedAdded  int
+	}{
+		{
+			name:   "success - subscribe",
+			deckID: validDeckID.String(),
+			mockFunc: func(ctx context.Context, deckID, userID uuid.UUID) (content.SubscribeResult, error) {
+				return content.SubscribeResult{
+					Deck:       content.SharedDeck{ID: deckID, Subscribed: true, CardCount: 8},
+					CardsAdded: 8,
+					Created:    true,
+				}, nil
+			},
+			expectedStatus: http.StatusCreated,
+			expectedAdded:  8,
+		},
+		{
+			name:   "error - deck not found",
+			deckID: validDeckID.String(),
+			mockFunc: func(ctx context.Context, deckID, userID uuid.UUID) (content.SubscribeResult, error) {
+				return content.SubscribeResult{}, errors.New("shared deck not found")
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "success - already subscribed",
+			deckID: validDeckID.String(),
+			mockFunc: func(ctx context.Context, deckID, userID uuid.UUID) (content.SubscribeResult, error) {
+				return content.SubscribeResult{Deck: content.SharedDeck{ID: deckID, Subscribed: true, CardCount: 8}}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "error - own deck",
+			deckID: validDeckID.String(),
+			mockFunc: func(ctx context.Context, deckID, userID uuid.UUID) (content.SubscribeResult, error) {
+				return content.SubscribeResult{}, errors.New("cannot subscribe to your own deck")
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid deck ID",
+			deckID:         "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{subscribeFunc: tt.mockFunc}
+
+			req := httptest.NewRequest(http.MethodPost, "/decks/shared/"+tt.deckID+"/subscribe", nil)
+			req = addUserIDToContext(req, uuid.New().String())
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("deck_id", tt.deckID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				deckID, userID, ok := parseSharedDeckRequest(w, req)
+				if !ok {
+					return
+				}
+				result, err := mockSvc.SubscribeToSharedDeck(req.Context(), deckID, userID)
+				if err != nil {
+					writeSharedDeckErr(w, err, "failed to subscribe to deck")
+					return
+				}
+				status := http.StatusOK
+				if result.Created {
+					status = http.StatusCreated
+				}
+				ResponseWithJSON(w, status, result)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if tt.expectedStatus == http.StatusCreated {
+				var response struct {
+					Data content.SubscribeResult `json:"data"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if response.Data.CardsAdded != tt.expectedAdded {
+					t.Errorf("expected %d cards added, got %d", tt.expectedAdded, response.Data.CardsAdded)
+				}
+			}
+		})
+	}
+}
+
+func TestExportWeekDeckHandler(t *testing.T) {
+	validWeekID := uuid.New()
+
+	tests := []struct {
+		name            string
+		weekID          string
+		query           string
+		mockFunc        func(ctx context.Context, userID, weekID uuid.UUID, format content.ExportFormat) (content.DeckExport, error)
+		expectedStatus  int
+		expectedFormat  content.ExportFormat
+		expectedHeaders map[string]string
+	}{
+		{
+			name:   "success - csv",
+			weekID: validWeekID.String(),
+			query:  "?format=csv",
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ExportFormat) (content.DeckExport, error) {
+				return content.DeckExport{
+					FileName:    "Networks - Week 3.csv",
+					ContentType: "text/csv; charset=utf-8",
+					Data:        []byte("id,module,week,front,back\n"),
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedFormat: content.ExportCSV,
+			expectedHeaders: map[string]string{
+				"Content-Type":        "text/csv; charset=utf-8",
+				"Content-Disposition": `attachment; filename="Networks - Week 3.csv"`,
+			},
+		},
+		{
+			name:   "success - defaults to apkg",
+			weekID: validWeekID.String(),
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ExportFormat) (content.DeckExport, error) {
+				return content.DeckExport{FileName: "deck.apkg", ContentType: "application/apkg", Data: []byte("PK")}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedFormat: content.ExportAPKG,
+			expectedHeaders: map[string]string{
+				"Content-Type": "application/apkg",
+			},
+		},
+		{
+			name:           "error - unsupported format",
+			weekID:         validWeekID.String(),
+			query:          "?format=xlsx",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid week ID",
+			weekID:         "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "error - empty deck",
+			weekID: validWeekID.String(),
+			query:  "?format=json",
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ExportFormat) (content.DeckExport, error) {
+				return content.DeckExport{}, errors.New("deck not found or empty")
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "error - service error",
+			weekID: validWeekID.String(),
+			mockFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ExportFormat) (content.DeckExport, error) {
+				return content.DeckExport{}, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFormat content.ExportFormat
+			mockSvc := &mockContentService{
+				exportWeekDeckFunc: func(ctx context.Context, userID, weekID uuid.UUID, format content.ExportFormat) (content.DeckExport, error) {
+					gotFormat = format
+					return tt.mockFunc(ctx, userID, weekID, format)
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/decks/weeks/"+tt.weekID+"/export"+tt.query, nil)
+			req = addUserIDToContext(req, uuid.New().String())
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("week_id", tt.weekID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				weekID, ok := parseUUID(w, chi.URLParam(req, "week_id"))
+				if !ok {
+					return
+				}
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				format, ok := parseExportFormat(w, req)
+				if !ok {
+					return
+				}
+				export, err := mockSvc.ExportWeekDeck(req.Context(), userID, weekID, format)
+				if err != nil {
+					writeExportErr(w, err)
+					return
+				}
+				writeExport(w, export)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedFormat != "" && gotFormat != tt.expectedFormat {
+				t.Errorf("expected format %q, got %q", tt.expectedFormat, gotFormat)
+			}
+			for header, want := range tt.expectedHeaders {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("expected %s %q, got %q", header, want, got)
+				}
+			}
+		})
+	}
+}
+
+// Helper function to create string pointers
+func stringPtr(s string) *string {
+	return &s
+}
+
+// Helper function to create int pointers
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestSearchCardsHandler(t *testing.T) {
+	moduleID := uuid.New()
+	tests := []struct {
+		name           string
+		query          string
+		mockFunc       func(ctx context.Context, userID uuid.UUID, search content.CardSearchQuery) ([]content.CardSearchResult, error)
+		expectedStatus int
+		expectedSearch *content.CardSearchQuery
+	}{
+		{
+			name:  "success - query with highlights",
+			query: "?q=tcp+handshake",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, search content.CardSearchQuery) ([]content.CardSearchResult, error) {
+				return []content.CardSearchResult{{
+					DueCard:        content.DueCard{ModuleID: moduleID, ModuleName: "Networks", WeekNumber: 4},
+					Rank:           0.6,
+					FrontHighlight: "What happens in the <mark>TCP</mark> three-way <mark>handshake</mark>?",
+				}}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedSearch: &content.CardSearchQuery{Query: "tcp handshake", Page: content.Page{Limit: content.DefaultPageLimit}},
+		},
+		{
+			name:           "success - tag and module filter",
+			query:          "?tag=exam&module=" + moduleID.String() + "&limit=10&offset=20",
+			expectedStatus: http.StatusOK,
+			expectedSearch: &content.CardSearchQuery{Tag: "exam", ModuleID: &moduleID, Page: content.Page{Limit: 10, Offset: 20}},
+		},
+		{
+			name:  "error - neither query nor tag",
+			query: "",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, search content.CardSearchQuery) ([]content.CardSearchResult, error) {
+				return nil, fmt.Errorf("%w: q or tag is required", content.ErrInvalidSearch)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid module",
+			query:          "?q=tcp&module=invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid limit",
+			query:          "?q=tcp&limit=abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "error - service error",
+			query: "?q=tcp",
+			mockFunc: func(ctx context.Context, userID uuid.UUID, search content.CardSearchQuery) ([]content.CardSearchResult, error) {
+				return nil, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotSearch content.CardSearchQuery
+			mockSvc := &mockContentService{
+				searchCardsFunc: func(ctx context.Context, userID uuid.UUID, search content.CardSearchQuery) ([]content.CardSearchResult, error) {
+					gotSearch = search
+					if tt.mockFunc != nil {
+						return tt.mockFunc(ctx, userID, search)
+					}
+					return []content.CardSearchResult{}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/decks/search"+tt.query, nil)
+			req = addUserIDToContext(req, uuid.New().String())
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				search := content.CardSearchQuery{
+					Query: req.URL.Query().Get("q"),
+					Tag:   req.URL.Query().Get("tag"),
+				}
+				if search.ModuleID, ok = parseQueryUUID(w, req, "module"); !ok {
+					return
+				}
+				if search.Page, ok = parsePage(w, req); !ok {
+					return
+				}
+				results, err := mockSvc.SearchCards(req.Context(), userID, search)
+				if err != nil {
+					if errors.Is(err, content.ErrInvalidSearch) || errors.Is(err, content.ErrInvalidTags) || strings.Contains(err.Error(), "negative") {
+						ResponseWithErr(w, http.StatusBadRequest, err.Error())
+					} else {
+						ResponseWithErr(w, http.StatusInternalServerError, "failed to search cards")
+					}
+					return
+				}
+				ResponseWithJSON(w, http.StatusOK, results)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if tt.expectedSearch != nil {
+				if gotSearch.Query != tt.expectedSearch.Query || gotSearch.Tag != tt.expectedSearch.Tag {
+					t.Errorf("expected q=%q tag=%q, got q=%q tag=%q", tt.expectedSearch.Query, tt.expectedSearch.Tag, gotSearch.Query, gotSearch.Tag)
+				}
+				if gotSearch.Page != tt.expectedSearch.Page {
+					t.Errorf("expected page %+v, got %+v", tt.expectedSearch.Page, gotSearch.Page)
+				}
+				if (tt.expectedSearch.ModuleID == nil) != (gotSearch.ModuleID == nil) {
+					t.Errorf("expected module filter %v, got %v", tt.expectedSearch.ModuleID, gotSearch.ModuleID)
+				}
+			}
+		})
+	}
 }
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"StudyHub/internal/content"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// CreateExamHandler starts a timed practice exam from a module run's cards
+// POST /exams
+func (s *HTTPServer) CreateExamHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	var req content.CreateExamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	session, err := s.contentSrv.CreateExam(r.Context(), userID, req)
+	if err != nil {
+		writeExamErr(w, err, "failed to create exam")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusCreated, session)
+}
+
+// ListExamsHandler lists the user's exam attempts, newest first
+// GET /exams?module_run_id=&limit=&offset=
+func (s *HTTPServer) ListExamsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+	moduleRunID, ok := parseQueryUUID(w, r, "module_run_id")
+	if !ok {
+		return
+	}
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	exams, err := s.contentSrv.ListExams(r.Context(), userID, moduleRunID, page)
+	if err != nil {
+		writeExamErr(w, err, "failed to list exams")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, exams)
+}
+
+// GetExamHandler returns the state of an exam and the question to answer next
+// GET /exams/{exam_id}
+func (s *HTTPServer) GetExamHandler(w http.ResponseWriter, r *http.Request) {
+	examID, userID, ok := parseExamRequest(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := s.contentSrv.GetExamSession(r.Context(), userID, examID)
+	if err != nil {
+		writeExamErr(w, err, "failed to get exam")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, session)
+}
+
+// AnswerExamQuestionHandler answers the current question and returns the next one
+// POST /exams/{exam_id}/answers
+func (s *HTTPServer) AnswerExamQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	examID, userID, ok := parseExamRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req content.AnswerExamQuestionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	session, err := s.contentSrv.AnswerExamQuestion(r.Context(), userID, examID, req)
+	if err != nil {
+		writeExamErr(w, err, "failed to answer question")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, session)
+}
+
+// SubmitExamHandler grades the exam and returns the result
+// POST /exams/{exam_id}/submit
+func (s *HTTPServer) SubmitExamHandler(w http.ResponseWriter, r *http.Request) {
+	examID, userID, ok := parseExamRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := s.contentSrv.SubmitExam(r.Context(), userID, examID)
+	if err != nil {
+		writeExamErr(w, err, "failed to submit exam")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, result)
+}
+
+// GetExamResultHandler returns the graded attempt with its per-week breakdown
+// GET /exams/{exam_id}/result
+func (s *HTTPServer) GetExamResultHandler(w http.ResponseWriter, r *http.Request) {
+	examID, userID, ok := parseExamRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := s.contentSrv.GetExamResult(r.Context(), userID, examID)
+	if err != nil {
+		writeExamErr(w, err, "failed to get exam result")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, result)
+}
+
+func parseExamRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	examID, ok := parseUUID(w, chi.URLParam(r, "exam_id"))
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	return examID, userID, true
+}
+
+func writeExamErr(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, content.ErrInvalidExam) || strings.Contains(err.Error(), "negative"):
+		ResponseWithErr(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, content.ErrExamFinished) || errors.Is(err, content.ErrExamInProgress) ||
+		errors.Is(err, content.ErrNotCurrentQuestion):
+		ResponseWithErr(w, http.StatusConflict, err.Error())
+	case strings.Contains(err.Error(), "not found"):
+		ResponseWithErr(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error(msg, "error", err)
+		ResponseWithErr(w, http.StatusInternalServerError, msg)
+	}
+}
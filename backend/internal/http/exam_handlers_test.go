@@ -0,0 +1,228 @@
+package http
+
+import (
+	"StudyHub/internal/content"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestCreateExamHandler(t *testing.T) {
+	moduleRunID := uuid.New()
+	tests := []struct {
+		name           string
+		body           string
+		mockFunc       func(ctx context.Context, userID uuid.UUID, req content.CreateExamRequest) (content.ExamSession, error)
+		expectedStatus int
+	}{
+		{
+			name: "success - first question is served",
+			body: fmt.Sprintf(`{"module_run_id":%q,"question_count":10,"duration_minutes":15}`, moduleRunID),
+			mockFunc: func(ctx context.Context, userID uuid.UUID, req content.CreateExamRequest) (content.ExamSession, error) {
+				if req.ModuleRunID != moduleRunID || req.QuestionCount != 10 || req.DurationMinutes != 15 {
+					return content.ExamSession{}, errors.New("unexpected request")
+				}
+				return content.ExamSession{
+					Exam:     content.Exam{ID: uuid.New(), Status: content.ExamInProgress, QuestionCount: 10, RemainingSeconds: 900},
+					Question: &content.ExamQuestion{ID: uuid.New(), Position: 1, Prompt: "What is TCP?", Options: []string{"a", "b", "c", "d"}},
+				}, nil
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "error - no cards in the selected weeks",
+			body: fmt.Sprintf(`{"module_run_id":%q}`, moduleRunID),
+			mockFunc: func(ctx context.Context, userID uuid.UUID, req content.CreateExamRequest) (content.ExamSession, error) {
+				return content.ExamSession{}, fmt.Errorf("%w: the selected weeks do not have enough cards to build questions from", content.ErrInvalidExam)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid body",
+			body:           `{"module_run_id":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - service error",
+			body: fmt.Sprintf(`{"module_run_id":%q}`, moduleRunID),
+			mockFunc: func(ctx context.Context, userID uuid.UUID, req content.CreateExamRequest) (content.ExamSession, error) {
+				return content.ExamSession{}, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{createExamFunc: tt.mockFunc}
+
+			req := httptest.NewRequest(http.MethodPost, "/exams", bytes.NewBufferString(tt.body))
+			req = addUserIDToContext(req, uuid.New().String())
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				var body content.CreateExamRequest
+				if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+					ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+					return
+				}
+				session, err := mockSvc.CreateExam(req.Context(), userID, body)
+				if err != nil {
+					writeExamErr(w, err, "failed to create exam")
+					return
+				}
+				ResponseWithJSON(w, http.StatusCreated, session)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if w.Code == http.StatusCreated {
+				var resp struct {
+					Data map[string]json.RawMessage `json:"data"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("failed to unmarshal response: %v", err)
+				}
+				var question map[string]any
+				if err := json.Unmarshal(resp.Data["question"], &question); err != nil {
+					t.Fatalf("failed to unmarshal question: %v", err)
+				}
+				if _, leaked := question["correct_option"]; leaked {
+					t.Error("served question must not reveal the correct option")
+				}
+			}
+		})
+	}
+}
+
+func TestAnswerExamQuestionHandler(t *testing.T) {
+	questionID := uuid.New()
+	tests := []struct {
+		name           string
+		examID         string
+		body           string
+		mockFunc       func(ctx context.Context, userID, examID uuid.UUID, req content.AnswerExamQuestionRequest) (content.ExamSession, error)
+		expectedStatus int
+	}{
+		{
+			name:   "success - next question served",
+			examID: uuid.New().String(),
+			body:   fmt.Sprintf(`{"question_id":%q,"option":2}`, questionID),
+			mockFunc: func(ctx context.Context, userID, examID uuid.UUID, req content.AnswerExamQuestionRequest) (content.ExamSession, error) {
+				if req.QuestionID != questionID || req.Option == nil || *req.Option != 2 {
+					return content.ExamSession{}, errors.New("unexpected request")
+				}
+				return content.ExamSession{
+					Exam:     content.Exam{Status: content.ExamInProgress, AnsweredCount: 1},
+					Question: &content.ExamQuestion{ID: uuid.New(), Position: 2},
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "success - skip question",
+			examID: uuid.New().String(),
+			body:   fmt.Sprintf(`{"question_id":%q,"option":null}`, questionID),
+			mockFunc: func(ctx context.Context, userID, examID uuid.UUID, req content.AnswerExamQuestionRequest) (content.ExamSession, error) {
+				if req.Option != nil {
+					return content.ExamSession{}, errors.New("expected a skipped question")
+				}
+				return content.ExamSession{Exam: content.Exam{Status: content.ExamInProgress, AnsweredCount: 1}}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "error - question already answered",
+			examID: uuid.New().String(),
+			body:   fmt.Sprintf(`{"question_id":%q,"option":0}`, questionID),
+			mockFunc: func(ctx context.Context, userID, examID uuid.UUID, req content.AnswerExamQuestionRequest) (content.ExamSession, error) {
+				return content.ExamSession{}, content.ErrNotCurrentQuestion
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:   "error - time is up",
+			examID: uuid.New().String(),
+			body:   fmt.Sprintf(`{"question_id":%q,"option":0}`, questionID),
+			mockFunc: func(ctx context.Context, userID, examID uuid.UUID, req content.AnswerExamQuestionRequest) (content.ExamSession, error) {
+				return content.ExamSession{}, content.ErrExamFinished
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:   "error - option out of range",
+			examID: uuid.New().String(),
+			body:   fmt.Sprintf(`{"question_id":%q,"option":7}`, questionID),
+			mockFunc: func(ctx context.Context, userID, examID uuid.UUID, req content.AnswerExamQuestionRequest) (content.ExamSession, error) {
+				return content.ExamSession{}, fmt.Errorf("%w: option must be between 0 and 3", content.ErrInvalidExam)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "error - exam not found",
+			examID: uuid.New().String(),
+			body:   fmt.Sprintf(`{"question_id":%q,"option":0}`, questionID),
+			mockFunc: func(ctx context.Context, userID, examID uuid.UUID, req content.AnswerExamQuestionRequest) (content.ExamSession, error) {
+				return content.ExamSession{}, errors.New("exam not found")
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "error - invalid exam ID",
+			examID:         "invalid-uuid",
+			body:           fmt.Sprintf(`{"question_id":%q,"option":0}`, questionID),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{answerExamQuestionFunc: tt.mockFunc}
+
+			req := httptest.NewRequest(http.MethodPost, "/exams/"+tt.examID+"/answers", bytes.NewBufferString(tt.body))
+			req = addUserIDToContext(req, uuid.New().String())
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("exam_id", tt.examID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			// Call handler logic with mock
+			func() {
+				examID, userID, ok := parseExamRequest(w, req)
+				if !ok {
+					return
+				}
+				var body content.AnswerExamQuestionRequest
+				if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+					ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+					return
+				}
+				session, err := mockSvc.AnswerExamQuestion(req.Context(), userID, examID, body)
+				if err != nil {
+					writeExamErr(w, err, "failed to answer question")
+					return
+				}
+				ResponseWithJSON(w, http.StatusOK, session)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
@@ -1,12 +1,14 @@
 package http
 
 import (
+	"StudyHub/internal/ai"
 	"StudyHub/internal/auth"
 	"StudyHub/internal/comments"
 	"StudyHub/internal/content"
-	"StudyHub/internal/gemini"
 	"StudyHub/internal/modules"
 	"StudyHub/internal/resources"
+	"StudyHub/internal/storage"
+	"StudyHub/internal/tus"
 	"StudyHub/internal/users"
 	"context"
 	"encoding/json"
@@ -27,13 +29,15 @@ type HTTPServer struct {
 	resourceSrv   *resources.ResourceService
 	contentSrv    *content.ContentService
 	commentSrv    *comments.CommentService
-	geminiClient  *gemini.GeminiClient
+	localFiles    *storage.LocalStorage // nil unless files are stored on the local disk
+	tusUploads    *tus.Store
+	aiProvider    ai.Provider
 	ragServiceURL string
 	httpServer    *http.Server
 	router        *chi.Mux
 }
 
-func NewHTTPServer(moduleSrv *modules.ModuleService, userSrv *users.UserService, authSrv *auth.AuthService, resSrv *resources.ResourceService, cntSrv *content.ContentService, commentSrv *comments.CommentService, geminiClient *gemini.GeminiClient, ragServiceURL string, port string) *HTTPServer {
+func NewHTTPServer(moduleSrv *modules.ModuleService, userSrv *users.UserService, authSrv *auth.AuthService, resSrv *resources.ResourceService, cntSrv *content.ContentService, commentSrv *comments.CommentService, localFiles *storage.LocalStorage, tusUploads *tus.Store, aiProvider ai.Provider, ragServiceURL string, port string) *HTTPServer {
 	router := chi.NewMux()
 	s := HTTPServer{
 		moduleSrv:     moduleSrv,
@@ -43,7 +47,9 @@ func NewHTTPServer(moduleSrv *modules.ModuleService, userSrv *users.UserService,
 		router:        router,
 		contentSrv:    cntSrv,
 		commentSrv:    commentSrv,
-		geminiClient:  geminiClient,
+		localFiles:    localFiles,
+		tusUploads:    tusUploads,
+		aiProvider:    aiProvider,
 		ragServiceURL: ragServiceURL,
 		httpServer: &http.Server{
 			Addr:              port,
@@ -59,9 +65,9 @@ func (srv *HTTPServer) registerRoutes() {
 
 	srv.router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:80", "http://0.0.0.0:80", "*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"},
+		ExposedHeaders:   []string{"Link", "ETag", "Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Offset", "Upload-Length", "Upload-Expires"},
 		AllowCredentials: true,
 		MaxAge:           300, // maximum age for preflight request cache
 	}))
@@ -77,6 +83,10 @@ func (srv *HTTPServer) registerRoutes() {
 			})
 			pub.Post("/auth/login", srv.LoginHandler)
 			pub.Post("/users", srv.CreateUserHandler)
+			// signed download links of the local storage driver
+			pub.Get("/files/{key}", srv.DownloadLocalFileHandler)
+			pub.Put("/files/uploads/{upload_id}/{part}", srv.UploadLocalPartHandler)
+			pub.Options("/resources/file/{week_id}/tus", srv.TusOptionsHandler)
 
 		})
 
@@ -110,8 +120,21 @@ func (srv *HTTPServer) registerRoutes() {
 			//Resources routes
 			priv.Post("/resources/file/{week_id}", srv.UploadFileHandler)
 			priv.Post("/resources/link/{week_id}", srv.CreateLinkResource)
+			priv.Post("/resources/file/{week_id}/tus", srv.CreateTusUploadHandler)
+			priv.Head("/resources/file/{week_id}/tus/{upload_id}", srv.TusUploadOffsetHandler)
+			priv.Patch("/resources/file/{week_id}/tus/{upload_id}", srv.PatchTusUploadHandler)
+			priv.Delete("/resources/file/{week_id}/tus/{upload_id}", srv.DeleteTusUploadHandler)
+			priv.Post("/resources/note/{week_id}", srv.CreateNoteResourceHandler)
+			priv.Post("/resources/check-hash", srv.CheckHashHandler)
+			priv.Get("/resources/usage", srv.StorageUsageHandler)
+			priv.Post("/resources/uploads", srv.StartUploadHandler)
+			priv.Post("/resources/uploads/{id}/complete", srv.CompleteUploadHandler)
+			priv.Get("/resources/uploads/{id}", srv.GetUploadHandler)
+			priv.Delete("/resources/uploads/{id}", srv.AbortUploadHandler)
 			priv.Delete("/resources/{id}", srv.DeleteResourceHandler)
 			priv.Get("/resources/{id}", srv.GetResourceHandler)
+			priv.Get("/resources/{id}/generation-status", srv.GetGenerationStatusHandler)
+			priv.Post("/resources/{id}/flashcards/regenerate", srv.RegenerateFlashcardsHandler)
 			priv.Get("/resources/weeks/{week_id}", srv.ListResourcesForWeekHandler)
 			priv.Get("/resources/users/{user_id}", srv.ListResourcesForUserHandler)
 
@@ -123,6 +146,8 @@ func (srv *HTTPServer) registerRoutes() {
 
 			//content routes
 			priv.Post("/conents/objects", srv.ListCardsFromObjects)
+			priv.Post("/conents/objects/summaries", srv.ListSummariesFromObjects)
+			priv.Post("/conents/objects/quizzes", srv.ListQuizzesFromObjects)
 
 			//user deck routes
 			priv.Post("/decks/weeks/{week_id}/cards", srv.AddCardToDeckHandler)
@@ -131,11 +156,47 @@ func (srv *HTTPServer) registerRoutes() {
 			priv.Patch("/decks/cards/{card_id}", srv.UpdateDeckCardHandler)
 			priv.Delete("/decks/cards/{card_id}", srv.RemoveDeckCardHandler)
 			priv.Post("/decks/cards/{card_id}/review", srv.RecordCardReviewHandler)
+			priv.Get("/decks/cards/{card_id}/reviews", srv.ListCardReviewsHandler)
+			priv.Put("/decks/cards/{card_id}/tags", srv.SetCardTagsHandler)
+			priv.Get("/decks/tags", srv.ListTagsHandler)
+			priv.Get("/decks/search", srv.SearchCardsHandler)
+			priv.Get("/decks/reviews", srv.ListUserReviewsHandler)
 			priv.Get("/decks/weeks/{week_id}/stats", srv.GetDeckStatsHandler)
+			priv.Post("/decks/weeks/{week_id}/import", srv.ImportDeckHandler)
+			priv.Get("/decks/weeks/{week_id}/export", srv.ExportWeekDeckHandler)
+			priv.Get("/decks/module-runs/{run_id}/export", srv.ExportModuleRunDeckHandler)
+			priv.Post("/decks/weeks/{week_id}/publish", srv.PublishDeckHandler)
+			priv.Delete("/decks/weeks/{week_id}/publish", srv.UnpublishDeckHandler)
+			priv.Get("/decks/weeks/{week_id}/shared", srv.ListSharedDecksHandler)
+			priv.Get("/decks/shared/{deck_id}", srv.GetSharedDeckHandler)
+			priv.Post("/decks/shared/{deck_id}/subscribe", srv.SubscribeToSharedDeckHandler)
+			priv.Delete("/decks/shared/{deck_id}/subscribe", srv.UnsubscribeFromSharedDeckHandler)
+			priv.Get("/decks/shared/{deck_id}/updates", srv.GetSharedDeckUpdatesHandler)
+			priv.Post("/decks/shared/{deck_id}/updates", srv.ApplySharedDeckUpdatesHandler)
+			priv.Get("/decks/due", srv.GetDueCardsHandler)
+			priv.Get("/decks/analytics", srv.GetStudyAnalyticsHandler)
+			priv.Get("/decks/settings", srv.GetStudySettingsHandler)
+			priv.Put("/decks/settings", srv.UpdateStudySettingsHandler)
+
+			//practice exam routes
+			priv.Post("/exams", srv.CreateExamHandler)
+			priv.Get("/exams", srv.ListExamsHandler)
+			priv.Get("/exams/{exam_id}", srv.GetExamHandler)
+			priv.Post("/exams/{exam_id}/answers", srv.AnswerExamQuestionHandler)
+			priv.Post("/exams/{exam_id}/submit", srv.SubmitExamHandler)
+			priv.Get("/exams/{exam_id}/result", srv.GetExamResultHandler)
 
 			// chat route
 			priv.Post("/chat", srv.ChatHandler)
 
+			//admin routes
+			priv.Group(func(admin chi.Router) {
+				admin.Use(srv.authSrv.AdminMiddleware)
+
+				admin.Get("/admin/generation/dead-letters", srv.ListDeadLettersHandler)
+				admin.Post("/admin/generation/dead-letters/replay", srv.ReplayDeadLettersHandler)
+			})
+
 			//interanl processes
 		})
 		r.Group(func(inter chi.Router) {
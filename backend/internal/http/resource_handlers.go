@@ -1,13 +1,18 @@
 package http
 
 import (
+	"StudyHub/internal/content"
 	"StudyHub/internal/resources"
+	"StudyHub/internal/storage"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"log"
 	"log/slog"
 	"net/http"
+	"path"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -27,13 +32,25 @@ func (s *HTTPServer) UploadFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if limit := s.resourceSrv.MaxFileSize(); limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
+	}
 	file, handler, err := r.FormFile("file")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ResponseWithErr(w, http.StatusRequestEntityTooLarge, resources.ErrFileTooLarge.Error())
+			return
+		}
 		ResponseWithErr(w, http.StatusBadRequest, "cannot access form file data")
 		log.Println(err)
 		return
 	}
+	// the type is detected from the content, the declared one only tells markdown from plain text and the like
 	fileType := r.FormValue("fileType")
+	if fileType == "" {
+		fileType = path.Ext(handler.Filename)
+	}
 
 	defer func() {
 		if closeErr := file.Close(); closeErr != nil {
@@ -47,6 +64,10 @@ func (s *HTTPServer) UploadFileHandler(w http.ResponseWriter, r *http.Request) {
 			ResponseWithErr(w, http.StatusBadRequest, "file is uploaded by other user already")
 			return
 		}
+		if status, ok := uploadLimitStatus(err); ok {
+			ResponseWithErr(w, status, err.Error())
+			return
+		}
 		slog.Error("failed to upload file", "err", err)
 		ResponseWithErr(w, 500, "failed to upload file")
 		return
@@ -87,6 +108,57 @@ func (s *HTTPServer) CreateLinkResource(w http.ResponseWriter, r *http.Request)
 
 }
 
+// CreateNoteResourceHandler stores a markdown note as a resource of the week, its cards are generated
+// like an uploaded file's. POST /resources/note/{week_id}
+func (s *HTTPServer) CreateNoteResourceHandler(w http.ResponseWriter, r *http.Request) {
+	weekID, ok := parseUUID(w, chi.URLParam(r, "week_id"))
+	if !ok {
+		return
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	var request CreateNoteResourceRequest
+	// the limit leaves room for the JSON around the content
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxNoteLength)).Decode(&request); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ResponseWithErr(w, http.StatusRequestEntityTooLarge, "note is too long")
+			return
+		}
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || strings.TrimSpace(request.Content) == "" {
+		ResponseWithErr(w, http.StatusBadRequest, "name and content are required")
+		return
+	}
+	if len(request.Content) > maxNoteLength {
+		ResponseWithErr(w, http.StatusRequestEntityTooLarge, "note is too long")
+		return
+	}
+
+	resource := resources.Resource{ID: uuid.New(), WeekID: weekID, UserID: userID, ResourceType: resources.ResourceNote, Name: request.Name, FileType: resources.NoteFileType}
+	err := s.resourceSrv.UploadResource(r.Context(), strings.NewReader(request.Content), int64(len(request.Content)), resource)
+	if err != nil {
+		if errors.Is(err, resources.ErrResourceExists) {
+			ResponseWithErr(w, http.StatusBadRequest, "note is uploaded by other user already")
+			return
+		}
+		if status, ok := uploadLimitStatus(err); ok {
+			ResponseWithErr(w, status, err.Error())
+			return
+		}
+		slog.Error("failed to create note", "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to create note")
+		return
+	}
+	ResponseWithJSON(w, http.StatusCreated, nil)
+}
+
 func (s *HTTPServer) ListResourcesForWeekHandler(w http.ResponseWriter, r *http.Request) {
 	weekIDParam := chi.URLParam(r, "week_id")
 	weekID, ok := parseUUID(w, weekIDParam)
@@ -138,6 +210,342 @@ func (s *HTTPServer) GetResourceHandler(w http.ResponseWriter, r *http.Request)
 	ResponseWithJSON(w, 200, map[string]string{"url": url})
 }
 
+// DownloadLocalFileHandler serves a file of the local storage driver through a signed link from GetResourceHandler.
+// GET /files/{key}?expires=&signature=
+func (s *HTTPServer) DownloadLocalFileHandler(w http.ResponseWriter, r *http.Request) {
+	if s.localFiles == nil {
+		ResponseWithErr(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	key := chi.URLParam(r, "key")
+	file, err := s.localFiles.OpenSigned(key, r.URL.Query().Get("expires"), r.URL.Query().Get("signature"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInvalidSignature), errors.Is(err, storage.ErrLinkExpired):
+			ResponseWithErr(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, storage.ErrNotFound):
+			ResponseWithErr(w, http.StatusNotFound, "file not found")
+		default:
+			slog.Error("failed to open local file", "key", key, "err", err)
+			ResponseWithErr(w, http.StatusInternalServerError, "failed to get file")
+		}
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		slog.Error("failed to stat local file", "key", key, "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to get file")
+		return
+	}
+	http.ServeContent(w, r, key, info.ModTime(), file)
+}
+
+// CheckHashHandler tells the client whether a file with the sha256 is stored, so it can skip the upload.
+// With week_id and name a stored file is added to the week. POST /resources/check-hash
+func (s *HTTPServer) CheckHashHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	var req resources.CheckHashRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	check, err := s.resourceSrv.CheckHash(r.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, resources.ErrInvalidHash) {
+			ResponseWithErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to check hash", "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to check hash")
+		return
+	}
+	status := http.StatusOK
+	if check.ResourceID != nil {
+		status = http.StatusCreated
+	}
+	ResponseWithJSON(w, status, check)
+}
+
+// StartUploadHandler opens a direct upload and returns the presigned urls of its parts. POST /resources/uploads
+func (s *HTTPServer) StartUploadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	var req resources.CreateUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	upload, err := s.resourceSrv.StartUpload(r.Context(), userID, req)
+	if err != nil {
+		writeUploadErr(w, err, "failed to start upload")
+		return
+	}
+	ResponseWithJSON(w, http.StatusCreated, upload)
+}
+
+// CompleteUploadHandler joins the parts of a direct upload, the resource is created once the file is
+// verified in the background. POST /resources/uploads/{id}/complete
+func (s *HTTPServer) CompleteUploadHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := parseUUID(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	var req resources.CompleteUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	session, err := s.resourceSrv.CompleteUpload(r.Context(), userID, sessionID, req.Parts)
+	if err != nil {
+		writeUploadErr(w, err, "failed to complete upload")
+		return
+	}
+	ResponseWithJSON(w, http.StatusAccepted, session)
+}
+
+// GetUploadHandler returns a direct upload session, with the resource id once it is verified. GET /resources/uploads/{id}
+func (s *HTTPServer) GetUploadHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := parseUUID(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	session, err := s.resourceSrv.GetUpload(r.Context(), userID, sessionID)
+	if err != nil {
+		writeUploadErr(w, err, "failed to get upload")
+		return
+	}
+	ResponseWithJSON(w, http.StatusOK, session)
+}
+
+// AbortUploadHandler drops a direct upload and its parts. DELETE /resources/uploads/{id}
+func (s *HTTPServer) AbortUploadHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := parseUUID(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	if err := s.resourceSrv.AbortUpload(r.Context(), userID, sessionID); err != nil {
+		writeUploadErr(w, err, "failed to abort upload")
+		return
+	}
+	ResponseWithJSON(w, http.StatusOK, nil)
+}
+
+func writeUploadErr(w http.ResponseWriter, err error, msg string) {
+	if status, ok := uploadLimitStatus(err); ok {
+		ResponseWithErr(w, status, err.Error())
+		return
+	}
+	switch {
+	case errors.Is(err, resources.ErrInvalidUpload), errors.Is(err, resources.ErrUploadMismatch):
+		ResponseWithErr(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, resources.ErrResourceExists):
+		ResponseWithErr(w, http.StatusBadRequest, "file is uploaded by other user already")
+	case errors.Is(err, resources.ErrUploadClosed):
+		ResponseWithErr(w, http.StatusConflict, err.Error())
+	case errors.Is(err, resources.ErrUploadExpired):
+		ResponseWithErr(w, http.StatusGone, err.Error())
+	case strings.Contains(err.Error(), "not found"):
+		ResponseWithErr(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error(msg, "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, msg)
+	}
+}
+
+// uploadLimitStatus maps the errors of files the limits turn away: 415 for a type that is not allowed and
+// 413 for a file over the size limit or the user's quota
+func uploadLimitStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, resources.ErrUnsupportedType):
+		return http.StatusUnsupportedMediaType, true
+	case errors.Is(err, resources.ErrFileTooLarge), errors.Is(err, resources.ErrQuotaExceeded):
+		return http.StatusRequestEntityTooLarge, true
+	}
+	return 0, false
+}
+
+// StorageUsageHandler returns how much of their quota the user's uploads take. GET /resources/usage
+func (s *HTTPServer) StorageUsageHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+	usage, err := s.resourceSrv.StorageUsage(r.Context(), userID)
+	if err != nil {
+		slog.Error("failed to get storage usage", "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to get storage usage")
+		return
+	}
+	ResponseWithJSON(w, http.StatusOK, usage)
+}
+
+// UploadLocalPartHandler takes a part of a direct upload put to a presigned url when files are stored on
+// the local disk, S3 takes them itself. PUT /files/uploads/{upload_id}/{part}
+func (s *HTTPServer) UploadLocalPartHandler(w http.ResponseWriter, r *http.Request) {
+	if s.localFiles == nil {
+		ResponseWithErr(w, http.StatusNotFound, "upload not found")
+		return
+	}
+
+	uploadID := chi.URLParam(r, "upload_id")
+	body := http.MaxBytesReader(w, r.Body, maxUploadPartSize)
+	etag, err := s.localFiles.WriteSignedPart(uploadID, chi.URLParam(r, "part"), r.URL.Query().Get("expires"), r.URL.Query().Get("signature"), body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.Is(err, storage.ErrInvalidSignature), errors.Is(err, storage.ErrLinkExpired):
+			ResponseWithErr(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, storage.ErrNotFound):
+			ResponseWithErr(w, http.StatusNotFound, "upload not found")
+		case errors.As(err, &tooLarge):
+			ResponseWithErr(w, http.StatusRequestEntityTooLarge, "part is too large")
+		default:
+			slog.Error("failed to write upload part", "upload id", uploadID, "err", err)
+			ResponseWithErr(w, http.StatusInternalServerError, "failed to write part")
+		}
+		return
+	}
+	// like S3 the client reads the ETag header and sends it back when completing the upload
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetGenerationStatusHandler returns the flashcard-generation job of an uploaded file. GET /resources/{id}/generation-status
+func (s *HTTPServer) GetGenerationStatusHandler(w http.ResponseWriter, r *http.Request) {
+	resourceID, ok := parseUUID(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	job, err := s.contentSrv.GetGenerationStatus(r.Context(), resourceID)
+	if err != nil {
+		writeGenerationStatusErr(w, err)
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, job)
+}
+
+func writeGenerationStatusErr(w http.ResponseWriter, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		ResponseWithErr(w, http.StatusNotFound, err.Error())
+		return
+	}
+	slog.Error("failed to get generation status", "err", err)
+	ResponseWithErr(w, http.StatusInternalServerError, "failed to get generation status")
+}
+
+// RegenerateFlashcardsHandler queues a new card set for an uploaded file, the body is optional and tunes the run.
+// POST /resources/{id}/flashcards/regenerate
+func (s *HTTPServer) RegenerateFlashcardsHandler(w http.ResponseWriter, r *http.Request) {
+	resourceID, ok := parseUUID(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	var opts content.GenerationOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	job, err := s.contentSrv.RegenerateFlashcards(r.Context(), resourceID, userID, s.authSrv.IsAdmin(r.Context(), userID), opts)
+	if err != nil {
+		writeRegenerateErr(w, err)
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusAccepted, job)
+}
+
+func writeRegenerateErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, content.ErrInvalidGenerationOptions):
+		ResponseWithErr(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, content.ErrGenerationInProgress):
+		ResponseWithErr(w, http.StatusConflict, err.Error())
+	case strings.Contains(err.Error(), "not found"):
+		ResponseWithErr(w, http.StatusNotFound, err.Error())
+	default:
+		slog.Error("failed to regenerate flashcards", "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to regenerate flashcards")
+	}
+}
+
+// ListDeadLettersHandler lists the uploads whose flashcard generation ran out of retries. GET /admin/generation/dead-letters?limit=
+func (s *HTTPServer) ListDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseQueryInt(w, r, "limit", 0)
+	if !ok {
+		return
+	}
+
+	letters, err := s.contentSrv.ListDeadLetters(r.Context(), limit)
+	if err != nil {
+		writeDeadLetterErr(w, err, "failed to list dead letters")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, letters)
+}
+
+// ReplayDeadLettersHandler puts dead-lettered uploads back on the generation queue. POST /admin/generation/dead-letters/replay
+func (s *HTTPServer) ReplayDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	var req content.ReplayDeadLettersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	replayed, err := s.contentSrv.ReplayDeadLetters(r.Context(), req.ObjectIDs)
+	if err != nil {
+		writeDeadLetterErr(w, err, "failed to replay dead letters")
+		return
+	}
+
+	ResponseWithJSON(w, http.StatusOK, map[string][]uuid.UUID{"replayed": replayed})
+}
+
+func writeDeadLetterErr(w http.ResponseWriter, err error, msg string) {
+	if strings.Contains(err.Error(), "negative") {
+		ResponseWithErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	slog.Error(msg, "err", err)
+	ResponseWithErr(w, http.StatusInternalServerError, msg)
+}
+
 func (s *HTTPServer) CleanOrphanObjectsHandler(w http.ResponseWriter, r *http.Request) {
 	//here try to do some authorization maybe by some token key
 	ids, err := s.resourceSrv.CleanOrphanObjects(r.Context())
@@ -174,3 +582,17 @@ type CreateLinkResourceRequest struct {
 	URL  string `json:"url"`
 	Name string `json:"name"`
 }
+
+// notes are short texts, longer material is uploaded as a file
+const maxNoteLength = 1 << 20
+
+// the largest part S3 takes, local uploads take the same
+const maxUploadPartSize = 5 << 30
+
+// room for the multipart boundaries and the other form fields around an uploaded file
+const multipartOverhead = 1 << 20
+
+type CreateNoteResourceRequest struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
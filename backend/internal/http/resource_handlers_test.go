@@ -1,17 +1,23 @@
 package http
 
 import (
+	"StudyHub/internal/content"
 	"StudyHub/internal/resources"
+	"StudyHub/internal/storage"
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -26,6 +32,7 @@ type mockResourceService struct {
 	getResourceFunc          func(ctx context.Context, resourceID uuid.UUID) (string, error)
 	deleteResourceFunc       func(ctx context.Context, userID, resourceID uuid.UUID) error
 	cleanOrphanObjectsFunc   func(ctx context.Context) ([]uuid.UUID, error)
+	checkHashFunc            func(ctx context.Context, userID uuid.UUID, req resources.CheckHashRequest) (resources.HashCheck, error)
 }
 
 func (m *mockResourceService) UploadResource(ctx context.Context, file io.Reader, size int64, resource resources.Resource) error {
@@ -77,6 +84,13 @@ func (m *mockResourceService) CleanOrphanObjects(ctx context.Context) ([]uuid.UU
 	return []uuid.UUID{}, nil
 }
 
+func (m *mockResourceService) CheckHash(ctx context.Context, userID uuid.UUID, req resources.CheckHashRequest) (resources.HashCheck, error) {
+	if m.checkHashFunc != nil {
+		return m.checkHashFunc(ctx, userID, req)
+	}
+	return resources.HashCheck{}, nil
+}
+
 func TestCreateLinkResourceHandler(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -599,6 +613,124 @@ func TestUploadFileHandler(t *testing.T) {
 	}
 }
 
+func TestCreateNoteResourceHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		weekID         string
+		body           string
+		mockFunc       func(ctx context.Context, file io.Reader, size int64, resource resources.Resource) error
+		expectedStatus int
+	}{
+		{
+			name:   "success - note stored as markdown",
+			weekID: uuid.New().String(),
+			body:   `{"name":"Lecture notes","content":"# Week 1\nTCP has a three-way handshake."}`,
+			mockFunc: func(ctx context.Context, file io.Reader, size int64, resource resources.Resource) error {
+				data, _ := io.ReadAll(file)
+				if resource.ResourceType != resources.ResourceNote || resource.FileType != resources.NoteFileType {
+					return fmt.Errorf("unexpected resource %s/%s", resource.ResourceType, resource.FileType)
+				}
+				if int64(len(data)) != size || !strings.HasPrefix(string(data), "# Week 1") {
+					return fmt.Errorf("unexpected content %q", data)
+				}
+				return nil
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "error - empty content",
+			weekID:         uuid.New().String(),
+			body:           `{"name":"Lecture notes","content":"   "}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid body",
+			weekID:         uuid.New().String(),
+			body:           `{"name":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - note too long",
+			weekID:         uuid.New().String(),
+			body:           `{"name":"Big","content":"` + strings.Repeat("a", maxNoteLength+1) + `"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "error - note already exists",
+			weekID: uuid.New().String(),
+			body:   `{"name":"Lecture notes","content":"same text"}`,
+			mockFunc: func(ctx context.Context, file io.Reader, size int64, resource resources.Resource) error {
+				return resources.ErrResourceExists
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid week ID",
+			weekID:         "invalid-uuid",
+			body:           `{"name":"Lecture notes","content":"text"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockResourceService{uploadResourceFunc: tt.mockFunc}
+			req := httptest.NewRequest(http.MethodPost, "/resources/note/"+tt.weekID, strings.NewReader(tt.body))
+			req = addUserIDToContext(req, uuid.New().String())
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("week_id", tt.weekID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			// Execute handler logic
+			func() {
+				weekID, ok := parseUUID(w, chi.URLParam(req, "week_id"))
+				if !ok {
+					return
+				}
+				userID, ok := parseUUID(w, getUserID(req))
+				if !ok {
+					return
+				}
+				var request CreateNoteResourceRequest
+				if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 2*maxNoteLength)).Decode(&request); err != nil {
+					var tooLarge *http.MaxBytesError
+					if errors.As(err, &tooLarge) {
+						ResponseWithErr(w, http.StatusRequestEntityTooLarge, "note is too long")
+						return
+					}
+					ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+					return
+				}
+				request.Name = strings.TrimSpace(request.Name)
+				if request.Name == "" || strings.TrimSpace(request.Content) == "" {
+					ResponseWithErr(w, http.StatusBadRequest, "name and content are required")
+					return
+				}
+				if len(request.Content) > maxNoteLength {
+					ResponseWithErr(w, http.StatusRequestEntityTooLarge, "note is too long")
+					return
+				}
+				resource := resources.Resource{ID: uuid.New(), WeekID: weekID, UserID: userID, ResourceType: resources.ResourceNote, Name: request.Name, FileType: resources.NoteFileType}
+				err := mockSvc.UploadResource(req.Context(), strings.NewReader(request.Content), int64(len(request.Content)), resource)
+				if err != nil {
+					if errors.Is(err, resources.ErrResourceExists) {
+						ResponseWithErr(w, http.StatusBadRequest, "note is uploaded by other user already")
+						return
+					}
+					ResponseWithErr(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+				ResponseWithJSON(w, http.StatusCreated, nil)
+			}()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestCleanOrphanObjectsHandler(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -641,3 +773,652 @@ func TestCleanOrphanObjectsHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestGetGenerationStatusHandler(t *testing.T) {
+	errMsg := "failed to convert file to pdf: bad status: 500 Internal Server Error"
+	tests := []struct {
+		name           string
+		resourceID     string
+		mockFunc       func(ctx context.Context, resourceID uuid.UUID) (content.GenerationJob, error)
+		expectedStatus int
+		expectedState  content.GenerationStatus
+	}{
+		{
+			name:       "success - job in progress",
+			resourceID: uuid.New().String(),
+			mockFunc: func(ctx context.Context, resourceID uuid.UUID) (content.GenerationJob, error) {
+				return content.GenerationJob{ID: uuid.New(), Status: content.GenerationGenerating, Attempts: 1}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedState:  content.GenerationGenerating,
+		},
+		{
+			name:       "success - failed job keeps its error",
+			resourceID: uuid.New().String(),
+			mockFunc: func(ctx context.Context, resourceID uuid.UUID) (content.GenerationJob, error) {
+				return content.GenerationJob{ID: uuid.New(), Status: content.GenerationFailed, Error: &errMsg, Attempts: 1}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedState:  content.GenerationFailed,
+		},
+		{
+			name:       "error - no job for resource",
+			resourceID: uuid.New().String(),
+			mockFunc: func(ctx context.Context, resourceID uuid.UUID) (content.GenerationJob, error) {
+				return content.GenerationJob{}, errors.New("generation job not found")
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "error - invalid resource ID",
+			resourceID:     "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "error - database error",
+			resourceID: uuid.New().String(),
+			mockFunc: func(ctx context.Context, resourceID uuid.UUID) (content.GenerationJob, error) {
+				return content.GenerationJob{}, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{getGenerationStatusFunc: tt.mockFunc}
+			req := httptest.NewRequest(http.MethodGet, "/resources/"+tt.resourceID+"/generation-status", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.resourceID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			// Execute handler logic
+			resourceID, ok := parseUUID(w, chi.URLParam(req, "id"))
+			if ok {
+				job, err := mockSvc.GetGenerationStatus(req.Context(), resourceID)
+				if err != nil {
+					writeGenerationStatusErr(w, err)
+				} else {
+					ResponseWithJSON(w, http.StatusOK, job)
+				}
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					Data content.GenerationJob `json:"data"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if response.Data.Status != tt.expectedState {
+					t.Errorf("expected status %q, got %q", tt.expectedState, response.Data.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestRegenerateFlashcardsHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		resourceID     string
+		requestBody    string
+		mockFunc       func(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts content.GenerationOptions) (content.GenerationJob, error)
+		expectedStatus int
+	}{
+		{
+			name:        "success - with options",
+			resourceID:  uuid.New().String(),
+			requestBody: `{"card_count":15,"difficulty":"hard","language":"German","focus_topics":["recursion"],"card_type":"cloze"}`,
+			mockFunc: func(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts content.GenerationOptions) (content.GenerationJob, error) {
+				if opts.CardCount != 15 || opts.Difficulty != content.DifficultyHard || opts.CardType != content.GeneratedCloze || len(opts.FocusTopics) != 1 {
+					return content.GenerationJob{}, errors.New("unexpected options")
+				}
+				return content.GenerationJob{ID: uuid.New(), Status: content.GenerationQueued, Options: &opts}, nil
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:        "success - empty body uses defaults",
+			resourceID:  uuid.New().String(),
+			requestBody: ``,
+			mockFunc: func(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts content.GenerationOptions) (content.GenerationJob, error) {
+				return content.GenerationJob{ID: uuid.New(), Status: content.GenerationQueued, Options: &opts}, nil
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "error - invalid body",
+			resourceID:     uuid.New().String(),
+			requestBody:    `{"card_count":"many"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "error - invalid options",
+			resourceID:  uuid.New().String(),
+			requestBody: `{"difficulty":"impossible"}`,
+			mockFunc: func(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts content.GenerationOptions) (content.GenerationJob, error) {
+				return content.GenerationJob{}, fmt.Errorf("%w: difficulty must be easy, medium or hard", content.ErrInvalidGenerationOptions)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "error - generation in progress",
+			resourceID:  uuid.New().String(),
+			requestBody: `{}`,
+			mockFunc: func(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts content.GenerationOptions) (content.GenerationJob, error) {
+				return content.GenerationJob{}, content.ErrGenerationInProgress
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:        "error - not the uploader",
+			resourceID:  uuid.New().String(),
+			requestBody: `{}`,
+			mockFunc: func(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts content.GenerationOptions) (content.GenerationJob, error) {
+				return content.GenerationJob{}, errors.New("file resource not found")
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "error - invalid resource ID",
+			resourceID:     "invalid-uuid",
+			requestBody:    `{}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "error - queue failure",
+			resourceID:  uuid.New().String(),
+			requestBody: `{}`,
+			mockFunc: func(ctx context.Context, resourceID, userID uuid.UUID, asAdmin bool, opts content.GenerationOptions) (content.GenerationJob, error) {
+				return content.GenerationJob{}, errors.New("failed to queue regeneration: not connected")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{regenerateFunc: tt.mockFunc}
+			userID := uuid.New()
+			req := httptest.NewRequest(http.MethodPost, "/resources/"+tt.resourceID+"/flashcards/regenerate", strings.NewReader(tt.requestBody))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.resourceID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			// Execute handler logic
+			resourceID, ok := parseUUID(w, chi.URLParam(req, "id"))
+			if ok {
+				var opts content.GenerationOptions
+				if err := json.NewDecoder(req.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+					ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+				} else {
+					job, err := mockSvc.RegenerateFlashcards(req.Context(), resourceID, userID, false, opts)
+					if err != nil {
+						writeRegenerateErr(w, err)
+					} else {
+						ResponseWithJSON(w, http.StatusAccepted, job)
+					}
+				}
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusAccepted {
+				var response struct {
+					Data content.GenerationJob `json:"data"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if response.Data.Status != content.GenerationQueued || response.Data.Options == nil {
+					t.Errorf("expected a queued job with its options, got %+v", response.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayDeadLettersHandler(t *testing.T) {
+	objectID := uuid.New()
+	tests := []struct {
+		name           string
+		requestBody    string
+		mockFunc       func(ctx context.Context, objectIDs []uuid.UUID) ([]uuid.UUID, error)
+		expectedStatus int
+		expectedCount  int
+	}{
+		{
+			name:        "success - replay selected objects",
+			requestBody: `{"object_ids":["` + objectID.String() + `"]}`,
+			mockFunc: func(ctx context.Context, objectIDs []uuid.UUID) ([]uuid.UUID, error) {
+				return objectIDs, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+		},
+		{
+			name:        "success - empty body replays everything",
+			requestBody: `{}`,
+			mockFunc: func(ctx context.Context, objectIDs []uuid.UUID) ([]uuid.UUID, error) {
+				if len(objectIDs) != 0 {
+					return nil, errors.New("expected no object ids")
+				}
+				return []uuid.UUID{uuid.New(), uuid.New()}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+		},
+		{
+			name:           "error - invalid object id",
+			requestBody:    `{"object_ids":["invalid-uuid"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "error - queue failure",
+			requestBody: `{}`,
+			mockFunc: func(ctx context.Context, objectIDs []uuid.UUID) ([]uuid.UUID, error) {
+				return nil, errors.New("failed to read dead letters: channel closed")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockContentService{replayDeadLettersFunc: tt.mockFunc}
+			req := httptest.NewRequest(http.MethodPost, "/admin/generation/dead-letters/replay", strings.NewReader(tt.requestBody))
+			w := httptest.NewRecorder()
+
+			// Execute handler logic
+			var reqData content.ReplayDeadLettersRequest
+			if err := json.NewDecoder(req.Body).Decode(&reqData); err != nil {
+				ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+			} else {
+				replayed, err := mockSvc.ReplayDeadLetters(req.Context(), reqData.ObjectIDs)
+				if err != nil {
+					writeDeadLetterErr(w, err, "failed to replay dead letters")
+				} else {
+					ResponseWithJSON(w, http.StatusOK, map[string][]uuid.UUID{"replayed": replayed})
+				}
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					Data struct {
+						Replayed []uuid.UUID `json:"replayed"`
+					} `json:"data"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if len(response.Data.Replayed) != tt.expectedCount {
+					t.Errorf("expected %d replayed, got %d", tt.expectedCount, len(response.Data.Replayed))
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadLocalFileHandler(t *testing.T) {
+	files, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/api/v1", "test-signing-key", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	key := uuid.New().String()
+	if _, err := files.UploadObject(context.Background(), key, 11, strings.NewReader("lecture pdf")); err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+	signed, err := files.CreatePresidedURL(context.Background(), key)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	query := signed[strings.Index(signed, "?"):]
+
+	tests := []struct {
+		name           string
+		server         *HTTPServer
+		key            string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "success - signed link",
+			server:         &HTTPServer{localFiles: files},
+			key:            key,
+			query:          query,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "lecture pdf",
+		},
+		{
+			name:           "error - signature for another key",
+			server:         &HTTPServer{localFiles: files},
+			key:            uuid.New().String(),
+			query:          query,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "error - tampered expiry",
+			server:         &HTTPServer{localFiles: files},
+			key:            key,
+			query:          strings.Replace(query, "expires=", "expires=9", 1),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "error - no signature",
+			server:         &HTTPServer{localFiles: files},
+			key:            key,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "error - storage is not local",
+			server:         &HTTPServer{},
+			key:            key,
+			query:          query,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/files/"+tt.key+tt.query, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("key", tt.key)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			tt.server.DownloadLocalFileHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUploadLocalPartHandler(t *testing.T) {
+	files, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/api/v1", "test-signing-key", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	key := uuid.New().String()
+	uploadID, err := files.CreateMultipartUpload(context.Background(), key)
+	if err != nil {
+		t.Fatalf("failed to create upload: %v", err)
+	}
+	signed, err := files.PresignUploadPart(context.Background(), key, uploadID, 1, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	query := signed[strings.Index(signed, "?"):]
+
+	tests := []struct {
+		name           string
+		server         *HTTPServer
+		uploadID       string
+		part           string
+		query          string
+		expectedStatus int
+	}{
+		{
+			name:           "success - signed part",
+			server:         &HTTPServer{localFiles: files},
+			uploadID:       uploadID,
+			part:           "1",
+			query:          query,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "error - signature for another part",
+			server:         &HTTPServer{localFiles: files},
+			uploadID:       uploadID,
+			part:           "2",
+			query:          query,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "error - no signature",
+			server:         &HTTPServer{localFiles: files},
+			uploadID:       uploadID,
+			part:           "1",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "error - storage is not local",
+			server:         &HTTPServer{},
+			uploadID:       uploadID,
+			part:           "1",
+			query:          query,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/files/uploads/"+tt.uploadID+"/"+tt.part+tt.query, strings.NewReader("lecture pdf"))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("upload_id", tt.uploadID)
+			rctx.URLParams.Add("part", tt.part)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			tt.server.UploadLocalPartHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && w.Header().Get("ETag") == "" {
+				t.Error("expected an ETag header")
+			}
+		})
+	}
+
+	// the uploaded part completes into the object
+	parts := []resources.UploadPart{{Number: 1, ETag: `"` + md5Hex("lecture pdf") + `"`}}
+	if _, err := files.CompleteMultipartUpload(context.Background(), key, uploadID, parts); err != nil {
+		t.Fatalf("failed to complete upload: %v", err)
+	}
+	object, err := files.GetObject(context.Background(), key)
+	if err != nil {
+		t.Fatalf("failed to get object: %v", err)
+	}
+	defer object.Close()
+	data, _ := io.ReadAll(object)
+	if string(data) != "lecture pdf" {
+		t.Errorf("expected object %q, got %q", "lecture pdf", data)
+	}
+}
+
+func TestWriteUploadErr(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{"invalid upload", fmt.Errorf("%w: size must be positive", resources.ErrInvalidUpload), http.StatusBadRequest},
+		{"file does not match", resources.ErrUploadMismatch, http.StatusBadRequest},
+		{"file already uploaded", resources.ErrResourceExists, http.StatusBadRequest},
+		{"session closed", resources.ErrUploadClosed, http.StatusConflict},
+		{"session expired", resources.ErrUploadExpired, http.StatusGone},
+		{"session not found", errors.New("upload session not found"), http.StatusNotFound},
+		{"type not allowed", fmt.Errorf("%w: zip", resources.ErrUnsupportedType), http.StatusUnsupportedMediaType},
+		{"file too large", resources.ErrFileTooLarge, http.StatusRequestEntityTooLarge},
+		{"quota exceeded", resources.ErrQuotaExceeded, http.StatusRequestEntityTooLarge},
+		{"storage error", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeUploadErr(w, tt.err, "failed to complete upload")
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestUploadFileHandler_SizeLimit(t *testing.T) {
+	srv := &HTTPServer{resourceSrv: resources.NewResourceService(nil, nil, nil, resources.Config{MaxFileSize: 1024})}
+	weekID := uuid.New().String()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "lecture.pdf")
+	_, _ = part.Write(bytes.Repeat([]byte("a"), 1024+multipartOverhead))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/resources/file/"+weekID, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("week_id", weekID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	req = addUserIDToContext(req, uuid.New().String())
+	w := httptest.NewRecorder()
+
+	// the body is cut off before it reaches the service
+	srv.UploadFileHandler(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCheckHashHandler(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	weekID := uuid.New()
+
+	tests := []struct {
+		name           string
+		userID         string
+		requestBody    interface{}
+		mockFunc       func(ctx context.Context, userID uuid.UUID, req resources.CheckHashRequest) (resources.HashCheck, error)
+		expectedStatus int
+		expectedCheck  resources.HashCheck
+	}{
+		{
+			name:        "success - unknown file",
+			userID:      uuid.New().String(),
+			requestBody: resources.CheckHashRequest{SHA256: hash},
+			mockFunc: func(ctx context.Context, userID uuid.UUID, req resources.CheckHashRequest) (resources.HashCheck, error) {
+				return resources.HashCheck{}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:        "success - file already in the week",
+			userID:      uuid.New().String(),
+			requestBody: resources.CheckHashRequest{SHA256: hash, WeekID: &weekID, Name: "lecture.pdf"},
+			mockFunc: func(ctx context.Context, userID uuid.UUID, req resources.CheckHashRequest) (resources.HashCheck, error) {
+				return resources.HashCheck{Exists: true, InWeek: true}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedCheck:  resources.HashCheck{Exists: true, InWeek: true},
+		},
+		{
+			name:        "success - stored file added to the week",
+			userID:      uuid.New().String(),
+			requestBody: resources.CheckHashRequest{SHA256: hash, WeekID: &weekID, Name: "lecture.pdf"},
+			mockFunc: func(ctx context.Context, userID uuid.UUID, req resources.CheckHashRequest) (resources.HashCheck, error) {
+				if req.WeekID == nil || *req.WeekID != weekID || req.Name != "lecture.pdf" {
+					t.Errorf("unexpected request %+v", req)
+				}
+				id := uuid.New()
+				return resources.HashCheck{Exists: true, ResourceID: &id}, nil
+			},
+			expectedStatus: http.StatusCreated,
+			expectedCheck:  resources.HashCheck{Exists: true},
+		},
+		{
+			name:        "error - invalid hash",
+			userID:      uuid.New().String(),
+			requestBody: resources.CheckHashRequest{SHA256: "abc"},
+			mockFunc: func(ctx context.Context, userID uuid.UUID, req resources.CheckHashRequest) (resources.HashCheck, error) {
+				return resources.HashCheck{}, resources.ErrInvalidHash
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid body",
+			userID:         uuid.New().String(),
+			requestBody:    "not an object",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "error - database error",
+			userID:      uuid.New().String(),
+			requestBody: resources.CheckHashRequest{SHA256: hash},
+			mockFunc: func(ctx context.Context, userID uuid.UUID, req resources.CheckHashRequest) (resources.HashCheck, error) {
+				return resources.HashCheck{}, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mockResourceService{checkHashFunc: tt.mockFunc}
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest(http.MethodPost, "/resources/check-hash", bytes.NewBuffer(body))
+			req = addUserIDToContext(req, tt.userID)
+			w := httptest.NewRecorder()
+
+			// Execute handler logic
+			userID, ok := parseUUID(w, getUserID(req))
+			if ok {
+				var reqData resources.CheckHashRequest
+				if err := json.NewDecoder(bytes.NewBuffer(body)).Decode(&reqData); err != nil {
+					ResponseWithErr(w, http.StatusBadRequest, "invalid request body")
+				} else {
+					check, err := mockSvc.CheckHash(req.Context(), userID, reqData)
+					switch {
+					case errors.Is(err, resources.ErrInvalidHash):
+						ResponseWithErr(w, http.StatusBadRequest, err.Error())
+					case err != nil:
+						ResponseWithErr(w, http.StatusInternalServerError, "failed to check hash")
+					case check.ResourceID != nil:
+						ResponseWithJSON(w, http.StatusCreated, check)
+					default:
+						ResponseWithJSON(w, http.StatusOK, check)
+					}
+				}
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code < http.StatusBadRequest {
+				var resp struct {
+					Data resources.HashCheck `json:"data"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if resp.Data.Exists != tt.expectedCheck.Exists || resp.Data.InWeek != tt.expectedCheck.InWeek {
+					t.Errorf("expected %+v, got %+v", tt.expectedCheck, resp.Data)
+				}
+				if (resp.Data.ResourceID != nil) != (tt.expectedStatus == http.StatusCreated) {
+					t.Errorf("expected a resource id only for a created resource, got %v", resp.Data.ResourceID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package http
+
+import (
+	"StudyHub/internal/resources"
+	"StudyHub/internal/tus"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// resumable uploads follow the tus 1.0.0 core protocol with the creation, expiration and termination extensions
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+	tusChunkType  = "application/offset+octet-stream"
+)
+
+// TusOptionsHandler tells tus clients what the server supports. OPTIONS /resources/file/{week_id}/tus
+func (s *HTTPServer) TusOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	if s.tusUploads != nil {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(s.tusUploads.MaxSize(), 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateTusUploadHandler starts a resumable upload, the name and file type come from the Upload-Metadata
+// keys name or filename and fileType. POST /resources/file/{week_id}/tus
+func (s *HTTPServer) CreateTusUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.checkTus(w, r) {
+		return
+	}
+	weekID, ok := parseUUID(w, chi.URLParam(r, "week_id"))
+	if !ok {
+		return
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+	metadata, err := tus.ParseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if tusResourceName(metadata) == "" {
+		ResponseWithErr(w, http.StatusBadRequest, "Upload-Metadata needs a name or filename")
+		return
+	}
+
+	// the whole file would be turned away once it arrived, the quota is checked again on completion
+	// since other uploads can finish in between
+	if s.resourceSrv != nil {
+		if s.resourceSrv.MaxFileSize() > 0 && length > s.resourceSrv.MaxFileSize() {
+			ResponseWithErr(w, http.StatusRequestEntityTooLarge, resources.ErrFileTooLarge.Error())
+			return
+		}
+		if err := s.resourceSrv.CheckQuota(r.Context(), userID, length); err != nil {
+			if status, ok := uploadLimitStatus(err); ok {
+				ResponseWithErr(w, status, err.Error())
+				return
+			}
+			slog.Error("failed to check storage quota", "err", err)
+			ResponseWithErr(w, http.StatusInternalServerError, "failed to create upload")
+			return
+		}
+	}
+	upload, err := s.tusUploads.Create(userID, weekID, length, metadata)
+	if err != nil {
+		if errors.Is(err, tus.ErrTooLarge) {
+			ResponseWithErr(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		slog.Error("failed to create tus upload", "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, upload.ID))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// TusUploadOffsetHandler returns how much of an upload arrived, clients resume from there. HEAD /resources/file/{week_id}/tus/{upload_id}
+func (s *HTTPServer) TusUploadOffsetHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.checkTus(w, r) {
+		return
+	}
+	upload, ok := s.getTusUpload(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
+
+// PatchTusUploadHandler appends a chunk at Upload-Offset. The chunk that completes the upload creates the
+// resource, a failed completion is retried with an empty chunk at the end. PATCH /resources/file/{week_id}/tus/{upload_id}
+func (s *HTTPServer) PatchTusUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.checkTus(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != tusChunkType {
+		ResponseWithErr(w, http.StatusUnsupportedMediaType, "chunks must be sent as "+tusChunkType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+
+	release, err := s.tusUploads.Lock(chi.URLParam(r, "upload_id"))
+	if err != nil {
+		ResponseWithErr(w, http.StatusLocked, err.Error())
+		return
+	}
+	defer release()
+
+	upload, ok := s.getTusUpload(w, r)
+	if !ok {
+		return
+	}
+	upload, err = s.tusUploads.Append(upload, offset, r.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, tus.ErrOffsetMismatch):
+			ResponseWithErr(w, http.StatusConflict, err.Error())
+		case errors.Is(err, tus.ErrTooLarge):
+			ResponseWithErr(w, http.StatusRequestEntityTooLarge, err.Error())
+		default:
+			// the bytes that arrived are kept, the client asks for the offset and resumes
+			slog.Error("failed to write tus chunk", "upload id", upload.ID, "offset", upload.Offset, "err", err)
+			ResponseWithErr(w, http.StatusInternalServerError, "failed to write chunk")
+		}
+		return
+	}
+
+	if upload.Complete() {
+		if !s.finishTusUpload(w, r, upload) {
+			return
+		}
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteTusUploadHandler drops an unfinished upload. DELETE /resources/file/{week_id}/tus/{upload_id}
+func (s *HTTPServer) DeleteTusUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.checkTus(w, r) {
+		return
+	}
+	release, err := s.tusUploads.Lock(chi.URLParam(r, "upload_id"))
+	if err != nil {
+		ResponseWithErr(w, http.StatusLocked, err.Error())
+		return
+	}
+	defer release()
+
+	upload, ok := s.getTusUpload(w, r)
+	if !ok {
+		return
+	}
+	if err := s.tusUploads.Remove(upload.ID); err != nil {
+		slog.Error("failed to remove tus upload", "upload id", upload.ID, "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to remove upload")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finishTusUpload stores a complete upload like a file sent in one request and removes its chunks
+func (s *HTTPServer) finishTusUpload(w http.ResponseWriter, r *http.Request, upload tus.Upload) bool {
+	file, err := s.tusUploads.Open(upload.ID)
+	if err != nil {
+		slog.Error("failed to open tus upload", "upload id", upload.ID, "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to upload file")
+		return false
+	}
+	defer func() { _ = file.Close() }()
+
+	resource := resources.Resource{
+		ID:           uuid.New(),
+		WeekID:       upload.WeekID,
+		UserID:       upload.UserID,
+		ResourceType: resources.ResourceFile,
+		Name:         tusResourceName(upload.Metadata),
+		FileType:     tusFileType(upload.Metadata),
+	}
+	err = s.resourceSrv.UploadResource(r.Context(), file, upload.Length, resource)
+	limitStatus, overLimit := uploadLimitStatus(err)
+	if err != nil && !errors.Is(err, resources.ErrResourceExists) && !overLimit {
+		// the chunks stay until the upload expires, so the completion can be retried
+		slog.Error("failed to upload file", "upload id", upload.ID, "err", err)
+		ResponseWithErr(w, http.StatusInternalServerError, "failed to upload file")
+		return false
+	}
+
+	if removeErr := s.tusUploads.Remove(upload.ID); removeErr != nil {
+		slog.Error("failed to remove tus upload", "upload id", upload.ID, "err", removeErr)
+	}
+	switch {
+	case overLimit:
+		// a retry would be turned away again, the upload is dropped
+		ResponseWithErr(w, limitStatus, err.Error())
+		return false
+	case err != nil:
+		ResponseWithErr(w, http.StatusBadRequest, "file is uploaded by other user already")
+		return false
+	}
+	return true
+}
+
+// getTusUpload loads the upload in the url, uploads of other users or weeks are not found
+func (s *HTTPServer) getTusUpload(w http.ResponseWriter, r *http.Request) (tus.Upload, bool) {
+	weekID, ok := parseUUID(w, chi.URLParam(r, "week_id"))
+	if !ok {
+		return tus.Upload{}, false
+	}
+	userID, ok := parseUUID(w, getUserID(r))
+	if !ok {
+		return tus.Upload{}, false
+	}
+
+	upload, err := s.tusUploads.Get(chi.URLParam(r, "upload_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, tus.ErrNotFound):
+			ResponseWithErr(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, tus.ErrExpired):
+			ResponseWithErr(w, http.StatusGone, err.Error())
+		default:
+			slog.Error("failed to get tus upload", "err", err)
+			ResponseWithErr(w, http.StatusInternalServerError, "failed to get upload")
+		}
+		return tus.Upload{}, false
+	}
+	if upload.UserID != userID || upload.WeekID != weekID {
+		ResponseWithErr(w, http.StatusNotFound, tus.ErrNotFound.Error())
+		return tus.Upload{}, false
+	}
+	return upload, true
+}
+
+// checkTus sets the protocol version on the response and turns away clients speaking another one
+func (s *HTTPServer) checkTus(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if s.tusUploads == nil {
+		ResponseWithErr(w, http.StatusNotFound, "resumable uploads are disabled")
+		return false
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		ResponseWithErr(w, http.StatusPreconditionFailed, "unsupported tus version")
+		return false
+	}
+	return true
+}
+
+func tusResourceName(metadata map[string]string) string {
+	if name := strings.TrimSpace(metadata["name"]); name != "" {
+		return name
+	}
+	return strings.TrimSpace(metadata["filename"])
+}
+
+// tusFileType takes the fileType key like the upload form, or the extension of the file name. Like
+// there, the type is detected from the content and this only picks between look-alikes.
+// tus clients send the mime type as filetype, it is not used
+func tusFileType(metadata map[string]string) string {
+	if fileType := strings.TrimSpace(metadata["fileType"]); fileType != "" {
+		return fileType
+	}
+	return strings.ToLower(strings.TrimPrefix(path.Ext(metadata["filename"]), "."))
+}
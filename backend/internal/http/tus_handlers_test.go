@@ -0,0 +1,272 @@
+package http
+
+import (
+	"StudyHub/internal/resources"
+	"StudyHub/internal/tus"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func newTusRequest(method, target, weekID, uploadID, userID string, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", tusVersion)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("week_id", weekID)
+	if uploadID != "" {
+		rctx.URLParams.Add("upload_id", uploadID)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	return addUserIDToContext(req, userID)
+}
+
+func TestCreateTusUploadHandler(t *testing.T) {
+	store, err := tus.NewStore(tus.Config{Dir: t.TempDir(), MaxSize: 100, Expiry: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create tus store: %v", err)
+	}
+	weekID := uuid.New().String()
+	userID := uuid.New().String()
+
+	tests := []struct {
+		name           string
+		length         string
+		metadata       string
+		version        string
+		expectedStatus int
+	}{
+		{
+			name:           "success",
+			length:         "11",
+			metadata:       "filename bGVjdHVyZS5wZGY=",
+			version:        tusVersion,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "error - no file name",
+			length:         "11",
+			version:        tusVersion,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - invalid metadata",
+			length:         "11",
+			metadata:       "filename not-base64!",
+			version:        tusVersion,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - missing length",
+			metadata:       "filename bGVjdHVyZS5wZGY=",
+			version:        tusVersion,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error - over the size limit",
+			length:         "101",
+			metadata:       "filename bGVjdHVyZS5wZGY=",
+			version:        tusVersion,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "error - other tus version",
+			length:         "11",
+			metadata:       "filename bGVjdHVyZS5wZGY=",
+			version:        "0.2.2",
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/v1/resources/file/" + weekID + "/tus"
+			req := newTusRequest(http.MethodPost, target, weekID, "", userID, "")
+			req.Header.Set("Tus-Resumable", tt.version)
+			req.Header.Set("Upload-Length", tt.length)
+			req.Header.Set("Upload-Metadata", tt.metadata)
+			w := httptest.NewRecorder()
+
+			(&HTTPServer{tusUploads: store}).CreateTusUploadHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Header().Get("Tus-Resumable") != tusVersion {
+				t.Errorf("expected Tus-Resumable %s, got %q", tusVersion, w.Header().Get("Tus-Resumable"))
+			}
+			if tt.expectedStatus == http.StatusCreated && !strings.HasPrefix(w.Header().Get("Location"), target+"/") {
+				t.Errorf("expected a Location under %s, got %q", target, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+// usageRepo reports a fixed storage usage, the quota check needs nothing else
+type usageRepo struct {
+	resources.ResourceRepository
+	used int64
+}
+
+func (r usageRepo) UserStorageUsage(ctx context.Context, userID uuid.UUID) (int64, int, error) {
+	return r.used, 1, nil
+}
+
+func TestCreateTusUploadHandler_Quota(t *testing.T) {
+	store, err := tus.NewStore(tus.Config{Dir: t.TempDir(), MaxSize: 100, Expiry: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create tus store: %v", err)
+	}
+	resourceSrv := resources.NewResourceService(usageRepo{used: 60}, nil, nil, resources.Config{UserQuota: 100})
+	weekID := uuid.New().String()
+
+	tests := []struct {
+		name           string
+		length         string
+		expectedStatus int
+	}{
+		{"fits in the quota", "40", http.StatusCreated},
+		{"error - over the quota", "41", http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTusRequest(http.MethodPost, "/api/v1/resources/file/"+weekID+"/tus", weekID, "", uuid.New().String(), "")
+			req.Header.Set("Upload-Length", tt.length)
+			req.Header.Set("Upload-Metadata", "filename bGVjdHVyZS5wZGY=")
+			w := httptest.NewRecorder()
+
+			(&HTTPServer{tusUploads: store, resourceSrv: resourceSrv}).CreateTusUploadHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPatchTusUploadHandler(t *testing.T) {
+	store, err := tus.NewStore(tus.Config{Dir: t.TempDir(), Expiry: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create tus store: %v", err)
+	}
+	weekID := uuid.New()
+	userID := uuid.New()
+	upload, err := store.Create(userID, weekID, 11, map[string]string{"filename": "lecture.pdf"})
+	if err != nil {
+		t.Fatalf("failed to create upload: %v", err)
+	}
+	server := &HTTPServer{tusUploads: store}
+
+	tests := []struct {
+		name           string
+		userID         uuid.UUID
+		offset         string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedOffset string
+	}{
+		{
+			name:           "success - first chunk",
+			userID:         userID,
+			offset:         "0",
+			contentType:    tusChunkType,
+			body:           "lecture",
+			expectedStatus: http.StatusNoContent,
+			expectedOffset: "7",
+		},
+		{
+			name:           "error - chunk at an old offset",
+			userID:         userID,
+			offset:         "0",
+			contentType:    tusChunkType,
+			body:           "lecture",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "error - wrong content type",
+			userID:         userID,
+			offset:         "7",
+			contentType:    "application/octet-stream",
+			body:           " pdf",
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "error - chunk past the upload length",
+			userID:         userID,
+			offset:         "7",
+			contentType:    tusChunkType,
+			body:           " pdf file",
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "error - upload of another user",
+			userID:         uuid.New(),
+			offset:         "7",
+			contentType:    tusChunkType,
+			body:           " pdf",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTusRequest(http.MethodPatch, "/", weekID.String(), upload.ID, tt.userID.String(), tt.body)
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Upload-Offset", tt.offset)
+			w := httptest.NewRecorder()
+
+			server.PatchTusUploadHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedOffset != "" && w.Header().Get("Upload-Offset") != tt.expectedOffset {
+				t.Errorf("expected Upload-Offset %s, got %q", tt.expectedOffset, w.Header().Get("Upload-Offset"))
+			}
+		})
+	}
+
+	// the rejected chunks left the upload where the first one ended
+	req := newTusRequest(http.MethodHead, "/", weekID.String(), upload.ID, userID.String(), "")
+	w := httptest.NewRecorder()
+	server.TusUploadOffsetHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w.Header().Get("Upload-Offset") != "7" || w.Header().Get("Upload-Length") != "11" {
+		t.Errorf("expected offset 7 of 11, got %q of %q", w.Header().Get("Upload-Offset"), w.Header().Get("Upload-Length"))
+	}
+}
+
+func TestTusUploadOffsetHandler_Expired(t *testing.T) {
+	store, err := tus.NewStore(tus.Config{Dir: t.TempDir(), Expiry: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("failed to create tus store: %v", err)
+	}
+	weekID := uuid.New()
+	userID := uuid.New()
+	upload, err := store.Create(userID, weekID, 11, map[string]string{"filename": "lecture.pdf"})
+	if err != nil {
+		t.Fatalf("failed to create upload: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	req := newTusRequest(http.MethodHead, "/", weekID.String(), upload.ID, userID.String(), "")
+	w := httptest.NewRecorder()
+	(&HTTPServer{tusUploads: store}).TusUploadOffsetHandler(w, req)
+	if w.Code != http.StatusGone {
+		t.Errorf("expected status %d, got %d", http.StatusGone, w.Code)
+	}
+
+	// the expired upload was removed with its chunks
+	if _, err := store.Get(upload.ID); err != tus.ErrNotFound {
+		t.Errorf("expected the upload to be removed, got %v", err)
+	}
+}
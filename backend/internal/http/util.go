@@ -4,6 +4,7 @@ import (
 	"StudyHub/internal/auth"
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -30,6 +31,34 @@ func parseUUID(w http.ResponseWriter, param string) (uuid.UUID, bool) {
 	return id, true
 }
 
+// parseQueryInt reads an optional integer query parameter, falling back to def when it is missing
+func parseQueryInt(w http.ResponseWriter, r *http.Request, name string, def int) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, true
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid "+name)
+		return 0, false
+	}
+	return value, true
+}
+
+// parseQueryUUID reads an optional UUID query parameter, nil when it is missing
+func parseQueryUUID(w http.ResponseWriter, r *http.Request, name string) (*uuid.UUID, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		ResponseWithErr(w, http.StatusBadRequest, "invalid "+name)
+		return nil, false
+	}
+	return &id, true
+}
+
 func getUserID(r *http.Request) string {
 	id := r.Context().Value(auth.UserIDContextKey).(string)
 	return id
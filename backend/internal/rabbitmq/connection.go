@@ -0,0 +1,211 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+
+	// how long a publish waits for the broker to come back before it gives up
+	connectWait       = 5 * time.Second
+	publisherPoolSize = 8
+	confirmTimeout    = 5 * time.Second
+)
+
+var ErrNotConnected = errors.New("rabbitmq is not connected")
+
+// connection keeps one AMQP connection alive, it reconnects with backoff when the broker closes it
+// and re-declares the topology before the connection is handed out again
+type connection struct {
+	url   string
+	setup func(ch *amqp.Channel) error
+
+	mu    sync.RWMutex
+	conn  *amqp.Connection
+	ready chan struct{} // closed while conn is usable, replaced when it drops
+
+	// publisher channels in confirm mode, only channels of the current connection are kept
+	channels chan *amqp.Channel
+}
+
+func dial(url string, setup func(ch *amqp.Channel) error) (*connection, error) {
+	c := &connection{
+		url:      url,
+		setup:    setup,
+		ready:    make(chan struct{}),
+		channels: make(chan *amqp.Channel, publisherPoolSize),
+	}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	go c.supervise()
+	return c, nil
+}
+
+func (c *connection) connect() error {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return fmt.Errorf("failed to dial: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to open setup channel: %w", err)
+	}
+	if err := c.setup(ch); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	_ = ch.Close()
+
+	c.mu.Lock()
+	c.conn = conn
+	close(c.ready)
+	c.mu.Unlock()
+	return nil
+}
+
+// supervise waits for the connection to close and dials again until it succeeds
+func (c *connection) supervise() {
+	for {
+		c.mu.RLock()
+		conn := c.conn
+		c.mu.RUnlock()
+
+		closeErr := <-conn.NotifyClose(make(chan *amqp.Error, 1))
+		slog.Error("rabbitmq connection closed", "err", closeErr)
+
+		c.mu.Lock()
+		c.ready = make(chan struct{})
+		c.mu.Unlock()
+		c.drainChannels()
+
+		delay := minReconnectDelay
+		for {
+			time.Sleep(delay)
+			err := c.connect()
+			if err == nil {
+				break
+			}
+			slog.Error("failed to reconnect to rabbitmq", "retry in", delay, "err", err)
+			delay = min(delay*2, maxReconnectDelay)
+		}
+		slog.Info("reconnected to rabbitmq")
+	}
+}
+
+// current returns the live connection, waiting up to wait for a reconnect. A zero wait blocks until ctx is done
+func (c *connection) current(ctx context.Context, wait time.Duration) (*amqp.Connection, error) {
+	c.mu.RLock()
+	conn, ready := c.conn, c.ready
+	c.mu.RUnlock()
+
+	select {
+	case <-ready:
+		return conn, nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ready:
+	case <-timeout:
+		return nil, ErrNotConnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn, nil
+}
+
+// channel opens a plain channel on the live connection, the caller closes it
+func (c *connection) channel(ctx context.Context) (*amqp.Channel, error) {
+	conn, err := c.current(ctx, connectWait)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create channel: %w", err)
+	}
+	return ch, nil
+}
+
+// publisher takes a confirm-mode channel from the pool or opens a new one
+func (c *connection) publisher(ctx context.Context) (*amqp.Channel, error) {
+	for {
+		select {
+		case ch := <-c.channels:
+			if !ch.IsClosed() {
+				return ch, nil
+			}
+		default:
+			ch, err := c.channel(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if err := ch.Confirm(false); err != nil {
+				_ = ch.Close()
+				return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+			}
+			return ch, nil
+		}
+	}
+}
+
+// release puts a publisher channel back, closed channels and the ones the pool has no room for are dropped
+func (c *connection) release(ch *amqp.Channel) {
+	if ch.IsClosed() {
+		return
+	}
+	select {
+	case c.channels <- ch:
+	default:
+		_ = ch.Close()
+	}
+}
+
+func (c *connection) drainChannels() {
+	for {
+		select {
+		case ch := <-c.channels:
+			_ = ch.Close()
+		default:
+			return
+		}
+	}
+}
+
+// publishConfirmed publishes on ch and waits for the broker to confirm the message
+func publishConfirmed(ctx context.Context, ch *amqp.Channel, exchange, key string, msg amqp.Publishing) error {
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, confirmTimeout)
+	defer cancel()
+	acked, err := confirm.WaitContext(waitCtx)
+	if err != nil {
+		return fmt.Errorf("failed to confirm message: %w", err)
+	}
+	if !acked {
+		return errors.New("broker rejected the message")
+	}
+	return nil
+}
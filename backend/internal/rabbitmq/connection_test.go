@@ -0,0 +1,105 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// closedChannel returns a channel that reports itself closed. The client only closes channels through
+// a broker, so the flag is set directly
+func closedChannel() *amqp.Channel {
+	ch := new(amqp.Channel)
+	field := reflect.ValueOf(ch).Elem().FieldByName("closed")
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().SetInt(1)
+	return ch
+}
+
+func TestCurrent(t *testing.T) {
+	t.Run("times out while disconnected", func(t *testing.T) {
+		c := &connection{ready: make(chan struct{})}
+		start := time.Now()
+		if _, err := c.current(context.Background(), 20*time.Millisecond); !errors.Is(err, ErrNotConnected) {
+			t.Fatalf("expected %v, got %v", ErrNotConnected, err)
+		}
+		if waited := time.Since(start); waited < 20*time.Millisecond {
+			t.Errorf("expected to wait for the reconnect, returned after %s", waited)
+		}
+	})
+
+	t.Run("returns once reconnected", func(t *testing.T) {
+		c := &connection{ready: make(chan struct{})}
+		conn := new(amqp.Connection)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			c.mu.Lock()
+			c.conn = conn
+			close(c.ready)
+			c.mu.Unlock()
+		}()
+		got, err := c.current(context.Background(), time.Second)
+		if err != nil || got != conn {
+			t.Fatalf("expected the new connection, got %p, %v", got, err)
+		}
+	})
+
+	t.Run("returns right away while connected", func(t *testing.T) {
+		ready := make(chan struct{})
+		close(ready)
+		conn := new(amqp.Connection)
+		c := &connection{conn: conn, ready: ready}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if got, err := c.current(ctx, time.Millisecond); err != nil || got != conn {
+			t.Fatalf("expected the live connection, got %p, %v", got, err)
+		}
+	})
+
+	t.Run("zero wait blocks until ctx is done", func(t *testing.T) {
+		c := &connection{ready: make(chan struct{})}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := c.current(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
+
+func TestRelease(t *testing.T) {
+	c := &connection{channels: make(chan *amqp.Channel, publisherPoolSize)}
+
+	c.release(closedChannel())
+	if len(c.channels) != 0 {
+		t.Fatalf("expected a closed channel to be dropped, the pool has %d", len(c.channels))
+	}
+
+	open := new(amqp.Channel)
+	c.release(open)
+	if len(c.channels) != 1 || <-c.channels != open {
+		t.Fatal("expected an open channel to go back to the pool")
+	}
+}
+
+func TestPublisherSkipsClosedChannels(t *testing.T) {
+	c := &connection{ready: make(chan struct{}), channels: make(chan *amqp.Channel, publisherPoolSize)}
+	open := new(amqp.Channel)
+	c.channels <- closedChannel()
+	c.channels <- open
+
+	got, err := c.publisher(context.Background())
+	if err != nil || got != open {
+		t.Fatalf("expected the open pooled channel, got %p, %v", got, err)
+	}
+
+	// with the pool empty a new channel needs the connection
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.publisher(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v while disconnected, got %v", context.Canceled, err)
+	}
+}
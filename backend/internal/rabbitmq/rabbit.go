@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -12,30 +15,40 @@ import (
 const (
 	fileUploadExchange string = "fileUploadExchange"
 	AIContentGenQueue  string = "aiContentGen"
+
+	// failed deliveries wait in the retry queue of their attempt, once the TTL runs out they are
+	// dead-lettered back to AIContentGenQueue
+	aiContentGenRetryExchange string = "aiContentGenRetry"
+	// deliveries that failed MaxDeliveryAttempts times end up here until they are replayed
+	aiContentGenDeadExchange string = "aiContentGenDead"
+	AIContentGenDeadQueue    string = "aiContentGen.dead"
+
+	MaxDeliveryAttempts = 5
+	baseRetryDelay      = 10 * time.Second
+	consumerPrefetch    = 10
+
+	attemptsHeader = "x-attempts"
+	errorHeader    = "x-last-error"
+	failedAtHeader = "x-failed-at"
+	maxErrorHeader = 1000
 )
 
 type RabbitMQ struct {
-	conn *amqp.Connection
+	conn *connection
 }
 
 func New(user, password, host string) *RabbitMQ {
 	connectionString := fmt.Sprintf("amqp://%s:%s@%s:5672/", user, password, host)
-	conn, err := amqp.Dial(connectionString)
+	conn, err := dial(connectionString, setup)
 	if err != nil {
 		log.Fatal("Failed to connect RabbitMQ ", err)
 	}
-	m := &RabbitMQ{conn: conn}
-	m.setup()
-	return m
+	return &RabbitMQ{conn: conn}
 }
 
-// here should create all the exchanges and the queue needed
-func (rbmq *RabbitMQ) setup() {
+// here should create all the exchanges and the queue needed, it runs again after every reconnect
+func setup(ch *amqp.Channel) error {
 	//create all the exchanges
-	ch, err := rbmq.conn.Channel()
-	if err != nil {
-		log.Fatal(err)
-	}
 	if err := ch.ExchangeDeclare(
 		fileUploadExchange,
 		"fanout",
@@ -45,7 +58,7 @@ func (rbmq *RabbitMQ) setup() {
 		false,
 		nil,
 	); err != nil {
-		log.Fatal("failed to declare exchange", err)
+		return fmt.Errorf("failed to declare exchange: %w", err)
 	}
 
 	if _, err := ch.QueueDeclare(
@@ -56,45 +69,278 @@ func (rbmq *RabbitMQ) setup() {
 		false,
 		nil,
 	); err != nil {
-		log.Fatal("failed to declare queue", err)
+		return fmt.Errorf("failed to declare queue: %w", err)
 	}
 
 	if err := ch.QueueBind(AIContentGenQueue, "", fileUploadExchange, false, nil); err != nil {
-		log.Fatal("failed to bind queue", err)
+		return fmt.Errorf("failed to bind queue: %w", err)
+	}
+
+	if err := ch.ExchangeDeclare(aiContentGenRetryExchange, "direct", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare retry exchange: %w", err)
+	}
+	// one retry queue per attempt, every message in a queue has the same TTL so an expired message
+	// is never stuck behind one that waits longer
+	for attempt := 1; attempt < MaxDeliveryAttempts; attempt++ {
+		queue := retryQueueName(attempt)
+		args := amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": AIContentGenQueue,
+		}
+		if _, err := ch.QueueDeclare(queue, true, false, false, false, args); err != nil {
+			return fmt.Errorf("failed to declare retry queue: %w", err)
+		}
+		if err := ch.QueueBind(queue, strconv.Itoa(attempt), aiContentGenRetryExchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind retry queue: %w", err)
+		}
 	}
 
+	if err := ch.ExchangeDeclare(aiContentGenDeadExchange, "fanout", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+	if _, err := ch.QueueDeclare(AIContentGenDeadQueue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+	if err := ch.QueueBind(AIContentGenDeadQueue, "", aiContentGenDeadExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
+	return nil
 }
 
 func (rbmq *RabbitMQ) Publish(ctx context.Context, objectID uuid.UUID) error {
-	ch, err := rbmq.conn.Channel()
-	if err != nil {
-		return fmt.Errorf("failed to craete channel: %w", err)
-	}
-	err = ch.PublishWithContext(ctx,
-		fileUploadExchange,
-		"",
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(objectID.String()),
-		})
+	return rbmq.publish(ctx, fileUploadExchange, "", amqp.Publishing{
+		ContentType: "text/plain",
+		Body:        []byte(objectID.String()),
+	})
+}
+
+// publish sends a persistent message on a pooled channel and waits for the broker's confirm
+func (rbmq *RabbitMQ) publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	ch, err := rbmq.conn.publisher(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+		return err
 	}
-	return nil
+	defer rbmq.conn.release(ch)
+
+	msg.DeliveryMode = amqp.Persistent
+	return publishConfirmed(ctx, ch, exchange, key, msg)
 }
 
+// Consume delivers the generation jobs, every delivery has to be acked or passed to Retry.
+// The consumer is started again after a reconnect, deliveries that were not acked are redelivered by the broker
 func (rbmq *RabbitMQ) Consume() chan amqp.Delivery {
-	ch, _ := rbmq.conn.Channel()
 	outCh := make(chan amqp.Delivery, 20)
 
 	go func() {
-		msgs, _ := ch.Consume(AIContentGenQueue, "", true, false, false, false, nil)
-		for msg := range msgs {
-			outCh <- msg
+		for {
+			ch, msgs, err := rbmq.startConsumer()
+			if err != nil {
+				slog.Error("failed to start consuming", "queue", AIContentGenQueue, "err", err)
+				time.Sleep(minReconnectDelay)
+				continue
+			}
+			for msg := range msgs {
+				outCh <- msg
+			}
+			_ = ch.Close()
+			slog.Info("consumer stopped, waiting for rabbitmq", "queue", AIContentGenQueue)
 		}
-		close(outCh)
 	}()
 	return outCh
 }
+
+func (rbmq *RabbitMQ) startConsumer() (*amqp.Channel, <-chan amqp.Delivery, error) {
+	// consumers wait for as long as the broker is down
+	conn, err := rbmq.conn.current(context.Background(), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ch.Qos(consumerPrefetch, 0, false); err != nil {
+		_ = ch.Close()
+		return nil, nil, fmt.Errorf("failed to set consumer prefetch: %w", err)
+	}
+	msgs, err := ch.Consume(AIContentGenQueue, "", false, false, false, false, nil)
+	if err != nil {
+		_ = ch.Close()
+		return nil, nil, err
+	}
+	return ch, msgs, nil
+}
+
+// DeadLetter is a generation delivery that failed every attempt and waits to be replayed
+type DeadLetter struct {
+	ObjectID uuid.UUID  `json:"object_id"`
+	Attempts int        `json:"attempts"`
+	Error    string     `json:"error"`
+	FailedAt *time.Time `json:"failed_at,omitempty"`
+}
+
+// Retry acks a failed delivery and publishes it to the retry queue of its next attempt, after
+// MaxDeliveryAttempts it goes to the dead-letter queue instead. Returns false when it was dead-lettered
+func (rbmq *RabbitMQ) Retry(ctx context.Context, msg amqp.Delivery, reason string) (bool, error) {
+	attempts := deliveryAttempts(msg) + 1
+	if attempts >= MaxDeliveryAttempts {
+		return false, rbmq.Reject(ctx, msg, reason)
+	}
+
+	publishing := failedPublishing(msg, attempts, reason)
+	publishing.Expiration = strconv.FormatInt(retryDelay(attempts).Milliseconds(), 10)
+	if err := rbmq.publish(ctx, aiContentGenRetryExchange, strconv.Itoa(attempts), publishing); err != nil {
+		requeueLater(ctx, msg, attempts)
+		return false, err
+	}
+	return true, msg.Ack(false)
+}
+
+// Reject acks a delivery that cannot succeed and publishes it to the dead-letter queue right away
+func (rbmq *RabbitMQ) Reject(ctx context.Context, msg amqp.Delivery, reason string) error {
+	attempts := deliveryAttempts(msg) + 1
+	publishing := failedPublishing(msg, attempts, reason)
+	publishing.Headers[failedAtHeader] = time.Now().UTC().Format(time.RFC3339)
+	if err := rbmq.publish(ctx, aiContentGenDeadExchange, "", publishing); err != nil {
+		requeueLater(ctx, msg, attempts)
+		return err
+	}
+	return msg.Ack(false)
+}
+
+func failedPublishing(msg amqp.Delivery, attempts int, reason string) amqp.Publishing {
+	if len(reason) > maxErrorHeader {
+		reason = reason[:maxErrorHeader]
+	}
+	return amqp.Publishing{
+		ContentType: msg.ContentType,
+		Body:        msg.Body,
+		Headers:     amqp.Table{attemptsHeader: int32(attempts), errorHeader: reason},
+	}
+}
+
+// requeueLater puts a delivery that could not be moved back on the main queue rather than losing it.
+// It waits for the retry delay first, requeueing at once would hand it straight back to a worker
+// while the broker still refuses publishes
+func requeueLater(ctx context.Context, msg amqp.Delivery, attempts int) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(retryDelay(attempts)):
+	}
+	if err := msg.Nack(false, true); err != nil {
+		slog.Error("failed to nack message", "err", err)
+	}
+}
+
+// ListDeadLetters returns up to limit dead-lettered deliveries, they stay in the queue
+func (rbmq *RabbitMQ) ListDeadLetters(ctx context.Context, limit int) ([]DeadLetter, error) {
+	ch, err := rbmq.conn.channel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = ch.Close() }()
+
+	letters := make([]DeadLetter, 0)
+	var lastTag uint64
+	for len(letters) < limit {
+		msg, ok, err := ch.Get(AIContentGenDeadQueue, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead letters: %w", err)
+		}
+		if !ok {
+			break
+		}
+		lastTag = msg.DeliveryTag
+		letters = append(letters, deadLetter(msg))
+	}
+	if lastTag > 0 {
+		if err := ch.Nack(lastTag, true, true); err != nil {
+			return nil, fmt.Errorf("failed to requeue dead letters: %w", err)
+		}
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetters moves dead-lettered deliveries back to the generation queue with their attempts reset,
+// no object ids replays all of them. Returns the ids that were replayed
+func (rbmq *RabbitMQ) ReplayDeadLetters(ctx context.Context, objectIDs []uuid.UUID) ([]uuid.UUID, error) {
+	ch, err := rbmq.conn.channel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	// unacked deliveries that were not replayed go back to the queue when the channel closes
+	defer func() { _ = ch.Close() }()
+
+	wanted := make(map[uuid.UUID]bool, len(objectIDs))
+	for _, id := range objectIDs {
+		wanted[id] = true
+	}
+
+	replayed := make([]uuid.UUID, 0)
+	for {
+		msg, ok, err := ch.Get(AIContentGenDeadQueue, false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read dead letters: %w", err)
+		}
+		if !ok {
+			break
+		}
+		letter := deadLetter(msg)
+		if len(wanted) > 0 && !wanted[letter.ObjectID] {
+			continue
+		}
+
+		err = publishConfirmed(ctx, ch, "", AIContentGenQueue, amqp.Publishing{
+			ContentType:  msg.ContentType,
+			DeliveryMode: amqp.Persistent,
+			Body:         msg.Body,
+		})
+		if err != nil {
+			return replayed, fmt.Errorf("failed to replay message: %w", err)
+		}
+		if err := msg.Ack(false); err != nil {
+			return replayed, fmt.Errorf("failed to ack dead letter: %w", err)
+		}
+		replayed = append(replayed, letter.ObjectID)
+	}
+	return replayed, nil
+}
+
+func retryQueueName(attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", AIContentGenQueue, attempt)
+}
+
+// retryDelay doubles with every attempt, 10s, 20s, 40s, ...
+func retryDelay(attempt int) time.Duration {
+	return baseRetryDelay << (attempt - 1)
+}
+
+func deliveryAttempts(msg amqp.Delivery) int {
+	switch v := msg.Headers[attemptsHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+func deadLetter(msg amqp.Delivery) DeadLetter {
+	letter := DeadLetter{Attempts: deliveryAttempts(msg)}
+	letter.ObjectID, _ = uuid.Parse(string(msg.Body))
+	if reason, ok := msg.Headers[errorHeader].(string); ok {
+		letter.Error = reason
+	}
+	if failedAt, ok := msg.Headers[failedAtHeader].(string); ok {
+		if t, err := time.Parse(time.RFC3339, failedAt); err == nil {
+			letter.FailedAt = &t
+		}
+	}
+	return letter
+}
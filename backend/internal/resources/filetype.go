@@ -0,0 +1,160 @@
+package resources
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// sniffLength is how much of a file is read to detect its type, the same as http.DetectContentType
+const sniffLength = 512
+
+// DefaultAllowedTypes are the file types accepted when no allow-list is configured, the ones the content
+// worker can generate from
+var DefaultAllowedTypes = []string{"pdf", "docx", "pptx", "xlsx", "doc", "ppt", "xls", "odt", "odp", "rtf", "txt", "md", "png", "jpg"}
+
+var (
+	mimeTypes = map[string]string{
+		"pdf":  "application/pdf",
+		"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"doc":  "application/msword",
+		"ppt":  "application/vnd.ms-powerpoint",
+		"xls":  "application/vnd.ms-excel",
+		"odt":  "application/vnd.oasis.opendocument.text",
+		"odp":  "application/vnd.oasis.opendocument.presentation",
+		"ods":  "application/vnd.oasis.opendocument.spreadsheet",
+		"rtf":  "application/rtf",
+		"txt":  "text/plain",
+		"md":   "text/markdown",
+		"csv":  "text/csv",
+		"html": "text/html",
+		"png":  "image/png",
+		"jpg":  "image/jpeg",
+		"gif":  "image/gif",
+		"webp": "image/webp",
+		"zip":  "application/zip",
+	}
+
+	// entries only found in one kind of office archive
+	archiveEntries = []struct{ name, fileType string }{
+		{"word/document.xml", "docx"},
+		{"ppt/presentation.xml", "pptx"},
+		{"xl/workbook.xml", "xlsx"},
+	}
+	openDocumentTypes = map[string]string{
+		"application/vnd.oasis.opendocument.text":         "odt",
+		"application/vnd.oasis.opendocument.presentation": "odp",
+		"application/vnd.oasis.opendocument.spreadsheet":  "ods",
+	}
+
+	// types the magic bytes can not tell apart, the declared type picks one of the family
+	textTypes        = []string{"txt", "md", "csv"}
+	compoundTypes    = []string{"doc", "ppt", "xls"}
+	zipArchiveTypes  = []string{"docx", "pptx", "xlsx", "odt", "odp", "ods"}
+	compoundFileHead = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+)
+
+// detectFileType tells the file type of an upload from its first bytes, the declared type (a form field
+// or the extension) only chooses between types that share their magic bytes, like markdown and plain
+// text. Office archives are told apart by their entries when archive is not nil, otherwise the declared
+// type is trusted if it is an office archive. Unknown binaries come back as "bin"
+func detectFileType(head []byte, archive io.ReaderAt, size int64, declared string) (fileType, mimeType string) {
+	declared = normalizeFileType(declared)
+	if len(head) > sniffLength {
+		head = head[:sniffLength]
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		fileType = "pdf"
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		fileType = zipFileType(archive, size, declared)
+	case bytes.HasPrefix(head, compoundFileHead):
+		fileType = familyType(compoundTypes, declared, "doc")
+	case bytes.HasPrefix(head, []byte(`{\rtf`)):
+		fileType = "rtf"
+	default:
+		switch detected := http.DetectContentType(head); {
+		case detected == "image/png":
+			fileType = "png"
+		case detected == "image/jpeg":
+			fileType = "jpg"
+		case detected == "image/gif":
+			fileType = "gif"
+		case detected == "image/webp":
+			fileType = "webp"
+		case strings.HasPrefix(detected, "text/html"):
+			// markdown may start with html, only a declared html file is taken as a page
+			fileType = familyType([]string{"txt", "md", "csv", "html"}, declared, "html")
+		case strings.HasPrefix(detected, "text/plain"):
+			fileType = familyType(textTypes, declared, "txt")
+		default:
+			return "bin", "application/octet-stream"
+		}
+	}
+	return fileType, mimeTypes[fileType]
+}
+
+func zipFileType(archive io.ReaderAt, size int64, declared string) string {
+	if archive == nil {
+		return familyType(zipArchiveTypes, declared, "zip")
+	}
+	reader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return "zip"
+	}
+	for _, file := range reader.File {
+		// OpenDocument names its type in an uncompressed first entry
+		if file.Name == "mimetype" {
+			if fileType, ok := openDocumentTypes[readSmallEntry(file)]; ok {
+				return fileType
+			}
+		}
+		for _, entry := range archiveEntries {
+			if file.Name == entry.name {
+				return entry.fileType
+			}
+		}
+	}
+	return "zip"
+}
+
+func readSmallEntry(file *zip.File) string {
+	entry, err := file.Open()
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = entry.Close() }()
+	data, _ := io.ReadAll(io.LimitReader(entry, 128))
+	return strings.TrimSpace(string(data))
+}
+
+// familyType returns the declared type when it belongs to the family, fallback otherwise
+func familyType(family []string, declared, fallback string) string {
+	for _, fileType := range family {
+		if fileType == declared {
+			return declared
+		}
+	}
+	return fallback
+}
+
+// normalizeFileType turns ".PDF", "Markdown" or "jpeg" into the type names used for storage objects
+func normalizeFileType(fileType string) string {
+	fileType = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(fileType), "."))
+	switch fileType {
+	case "markdown":
+		return "md"
+	case "text":
+		return "txt"
+	case "jpeg":
+		return "jpg"
+	case "htm":
+		return "html"
+	}
+	return fileType
+}
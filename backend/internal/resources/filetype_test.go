@@ -0,0 +1,134 @@
+package resources
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+)
+
+// zipArchive builds an archive with the given entries in order, the OpenDocument mimetype entry is stored
+// uncompressed as the format asks
+func zipArchive(t *testing.T, entries ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry[0], Method: zip.Deflate}
+		if entry[0] == "mimetype" {
+			header.Method = zip.Store
+		}
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(entry[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectFileType(t *testing.T) {
+	docx := zipArchive(t, [2]string{"[Content_Types].xml", "<Types/>"}, [2]string{"word/document.xml", "<w:document/>"})
+	pptx := zipArchive(t, [2]string{"ppt/presentation.xml", "<p:presentation/>"})
+	xlsx := zipArchive(t, [2]string{"xl/workbook.xml", "<workbook/>"})
+	odt := zipArchive(t, [2]string{"mimetype", "application/vnd.oasis.opendocument.text"}, [2]string{"content.xml", "<office/>"})
+	odp := zipArchive(t, [2]string{"mimetype", "application/vnd.oasis.opendocument.presentation"})
+	plainZip := zipArchive(t, [2]string{"notes/readme.txt", "hello"})
+	compound := append(append([]byte{}, compoundFileHead...), make([]byte, 64)...)
+
+	tests := []struct {
+		name         string
+		content      []byte
+		withArchive  bool
+		declared     string
+		expectedType string
+		expectedMime string
+	}{
+		{name: "pdf", content: []byte("%PDF-1.7\n%âãÏÓ"), declared: "txt", expectedType: "pdf", expectedMime: "application/pdf"},
+		{name: "docx told by its entries", content: docx, withArchive: true, declared: "pptx", expectedType: "docx", expectedMime: mimeTypes["docx"]},
+		{name: "pptx told by its entries", content: pptx, withArchive: true, expectedType: "pptx", expectedMime: mimeTypes["pptx"]},
+		{name: "xlsx told by its entries", content: xlsx, withArchive: true, expectedType: "xlsx", expectedMime: mimeTypes["xlsx"]},
+		{name: "odt from the mimetype entry", content: odt, withArchive: true, declared: "docx", expectedType: "odt", expectedMime: mimeTypes["odt"]},
+		{name: "odp from the mimetype entry", content: odp, withArchive: true, expectedType: "odp", expectedMime: mimeTypes["odp"]},
+		{name: "zip without office entries", content: plainZip, withArchive: true, declared: "docx", expectedType: "zip", expectedMime: "application/zip"},
+		{name: "no archive trusts a declared office type", content: docx, declared: ".PPTX", expectedType: "pptx", expectedMime: mimeTypes["pptx"]},
+		{name: "no archive and no office type", content: docx, declared: "pdf", expectedType: "zip", expectedMime: "application/zip"},
+		{name: "compound file picks the declared type", content: compound, declared: "xls", expectedType: "xls", expectedMime: "application/vnd.ms-excel"},
+		{name: "compound file defaults to doc", content: compound, expectedType: "doc", expectedMime: "application/msword"},
+		{name: "rtf", content: []byte(`{\rtf1\ansi hello}`), expectedType: "rtf", expectedMime: "application/rtf"},
+		{name: "png", content: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), declared: "jpg", expectedType: "png", expectedMime: "image/png"},
+		{name: "jpeg", content: []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), expectedType: "jpg", expectedMime: "image/jpeg"},
+		{name: "declared markdown", content: []byte("# Title\n\nSome notes"), declared: "Markdown", expectedType: "md", expectedMime: "text/markdown"},
+		{name: "declared csv", content: []byte("front,back\na,b\n"), declared: "csv", expectedType: "csv", expectedMime: "text/csv"},
+		{name: "text declared as pdf stays text", content: []byte("just some notes"), declared: "pdf", expectedType: "txt", expectedMime: "text/plain"},
+		{name: "html look-alike declared as markdown", content: []byte("<p>intro</p>\n\n# Heading"), declared: "md", expectedType: "md", expectedMime: "text/markdown"},
+		{name: "html look-alike without a text type", content: []byte("<!DOCTYPE html><html><body>hi</body></html>"), declared: "pdf", expectedType: "html", expectedMime: "text/html"},
+		{name: "unknown bytes", content: []byte{0x00, 0x01, 0x02, 0x03, 0xfe, 0xff}, declared: "txt", expectedType: "bin", expectedMime: "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var archive io.ReaderAt
+			if tt.withArchive {
+				archive = bytes.NewReader(tt.content)
+			}
+			fileType, mimeType := detectFileType(tt.content, archive, int64(len(tt.content)), tt.declared)
+			if fileType != tt.expectedType || mimeType != tt.expectedMime {
+				t.Errorf("detectFileType() = %q, %q, want %q, %q", fileType, mimeType, tt.expectedType, tt.expectedMime)
+			}
+		})
+	}
+}
+
+func TestDetectFileTypeSniffsOnlyTheHead(t *testing.T) {
+	content := append(bytes.Repeat([]byte("a"), sniffLength), 0x00, 0x01, 0x02)
+	if fileType, _ := detectFileType(content, nil, int64(len(content)), ""); fileType != "txt" {
+		t.Errorf("detectFileType() = %q, want txt from the first %d bytes", fileType, sniffLength)
+	}
+}
+
+func TestZipFileTypeBrokenArchive(t *testing.T) {
+	content := []byte("PK\x03\x04 not really a zip")
+	if fileType := zipFileType(bytes.NewReader(content), int64(len(content)), "docx"); fileType != "zip" {
+		t.Errorf("zipFileType() = %q, want zip", fileType)
+	}
+}
+
+func TestFamilyType(t *testing.T) {
+	tests := []struct {
+		declared string
+		fallback string
+		expected string
+	}{
+		{declared: "md", fallback: "txt", expected: "md"},
+		{declared: "csv", fallback: "txt", expected: "csv"},
+		{declared: "pdf", fallback: "txt", expected: "txt"},
+		{declared: "", fallback: "txt", expected: "txt"},
+	}
+	for _, tt := range tests {
+		if got := familyType(textTypes, tt.declared, tt.fallback); got != tt.expected {
+			t.Errorf("familyType(%q, %q) = %q, want %q", tt.declared, tt.fallback, got, tt.expected)
+		}
+	}
+}
+
+func TestNormalizeFileType(t *testing.T) {
+	tests := map[string]string{
+		".PDF":       "pdf",
+		" Markdown ": "md",
+		"text":       "txt",
+		"JPEG":       "jpg",
+		".htm":       "html",
+		"docx":       "docx",
+		"":           "",
+	}
+	for input, expected := range tests {
+		if got := normalizeFileType(input); got != expected {
+			t.Errorf("normalizeFileType(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -18,49 +19,60 @@ func NewResourceRepositoryPostgres(p *pgxpool.Pool) *ResourceRepositoryPostgres
 	return &ResourceRepositoryPostgres{pool: p}
 }
 
-func (r *ResourceRepositoryPostgres) CreateFileResource(ctx context.Context, resource Resource) error {
-	query := `INSERT INTO resources(id,name, type, storage_object_id) VALUES ($1, $2, $3, $4)`
-	_, err := r.pool.Exec(ctx, query, resource.ID, resource.Name, resource.ResourceType, resource.ObjectID)
+// CreateResource inserts a resource with its owner and week in one transaction, on the storage object with
+// the hash. Creates with the same hash take turns, so a file uploaded twice at once is stored once: the
+// object is inserted when no object has the hash yet and created reports whether this call inserted it.
+// A nil object means the caller expects the hash to be stored already. ErrResourceExists is returned when
+// the week already has a resource on the hash
+func (r *ResourceRepositoryPostgres) CreateResource(ctx context.Context, resource Resource, hash string, object *storageObject) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("CreateFileResource err: %w", err)
+		return false, fmt.Errorf("CreateResource begin: %w", err)
 	}
-	return nil
-}
+	defer func() { _ = tx.Rollback(ctx) }()
 
-func (r *ResourceRepositoryPostgres) CreateLinkResource(ctx context.Context, resource Resource) error {
-	query := `INSERT INTO resources(id,name, type, external_url) VALUES ($1, $2, $3, $4)`
-	_, err := r.pool.Exec(ctx, query, resource.ID, resource.Name, resource.ResourceType, resource.ExternalLink)
-	if err != nil {
-		return fmt.Errorf("CreateLinkResource err: %w", err)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, hash); err != nil {
+		return false, fmt.Errorf("CreateResource lock: %w", err)
 	}
-	return nil
-}
 
-func (r *ResourceRepositoryPostgres) CreateStorageObject(ctx context.Context, object storageObject) error {
-	query := `INSERT INTO storage_objects(id, hash, url, file_type) VALUES ($1, $2, $3, $4)`
-	_, err := r.pool.Exec(ctx, query, object.ID, object.Hash, object.URL, object.FileType)
-	if err != nil {
-		return fmt.Errorf("CreateStorageObject err: %w", err)
+	var inWeek int
+	query := `SELECT 1 FROM week_resources w JOIN resources r ON w.resource_id=r.id JOIN storage_objects s ON r.storage_object_id=s.id WHERE s.hash=$1 AND w.week_id=$2 LIMIT 1`
+	err = tx.QueryRow(ctx, query, hash, resource.WeekID).Scan(&inWeek)
+	if err == nil {
+		return false, ErrResourceExists
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("CreateResource week check: %w", err)
 	}
-	return nil
-}
 
-func (r *ResourceRepositoryPostgres) CreateUserResource(ctx context.Context, resource Resource) error {
-	query := `INSERT INTO resource_owners (resource_id, user_id) VALUES ($1, $2)`
-	_, err := r.pool.Exec(ctx, query, resource.ID, resource.UserID)
-	if err != nil {
-		return fmt.Errorf("CreateResourceOwner err: %w", err)
+	created := false
+	var objectID uuid.UUID
+	err = tx.QueryRow(ctx, `SELECT id FROM storage_objects WHERE hash=$1 ORDER BY created_at LIMIT 1`, hash).Scan(&objectID)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows) && object != nil:
+		query = `INSERT INTO storage_objects(id, hash, url, file_type, mime_type, size, uploaded_by) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)`
+		if _, err := tx.Exec(ctx, query, object.ID, object.Hash, object.URL, object.FileType, object.MimeType, object.Size, object.UploadedBy); err != nil {
+			return false, fmt.Errorf("CreateResource storage object: %w", err)
+		}
+		objectID, created = object.ID, true
+	default:
+		return false, fmt.Errorf("CreateResource storage object: %w", err)
 	}
-	return nil
-}
 
-func (r *ResourceRepositoryPostgres) CreateWeekResource(ctx context.Context, resource Resource) error {
-	query := `INSERT INTO week_resources (resource_id, week_id) VALUES ($1, $2)`
-	_, err := r.pool.Exec(ctx, query, resource.ID, resource.WeekID)
-	if err != nil {
-		return fmt.Errorf("CreateWeekResource err: %w", err)
+	batch := pgx.Batch{}
+	batch.Queue(`INSERT INTO resources(id, name, type, external_url, storage_object_id) VALUES ($1, $2, $3, $4, $5)`,
+		resource.ID, resource.Name, resource.ResourceType, resource.ExternalLink, objectID)
+	batch.Queue(`INSERT INTO resource_owners (resource_id, user_id) VALUES ($1, $2)`, resource.ID, resource.UserID)
+	batch.Queue(`INSERT INTO week_resources (resource_id, week_id) VALUES ($1, $2)`, resource.ID, resource.WeekID)
+	if err := tx.SendBatch(ctx, &batch).Close(); err != nil {
+		return false, fmt.Errorf("CreateResource insert: %w", err)
 	}
-	return nil
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("CreateResource commit: %w", err)
+	}
+	return created, nil
 }
 
 // this function will check if the resource with this hash exists, if yes returns id of it, if no it will return false
@@ -79,6 +91,16 @@ func (r *ResourceRepositoryPostgres) ObjectExists(ctx context.Context, hash stri
 
 }
 
+// ObjectUploadedBy reports whether the user stored the object with the hash
+func (r *ResourceRepositoryPostgres) ObjectUploadedBy(ctx context.Context, hash string, userID uuid.UUID) (bool, error) {
+	var uploaded bool
+	query := `SELECT EXISTS (SELECT 1 FROM storage_objects WHERE hash = $1 AND uploaded_by = $2)`
+	if err := r.pool.QueryRow(ctx, query, hash, userID).Scan(&uploaded); err != nil {
+		return false, fmt.Errorf("ObjectUploadedBy: %w", err)
+	}
+	return uploaded, nil
+}
+
 func (r *ResourceRepositoryPostgres) ListResourcesByWeek(ctx context.Context, weekID uuid.UUID) ([]ResourceWithUser, error) {
 	query := `SELECT r.id, r.name, r.type, r.storage_object_id, r.external_url, o.user_id, r.created_at, u.first_name FROM week_resources w JOIN resources r ON w.resource_id=r.id JOIN resource_owners o ON o.resource_id=w.resource_id JOIN users u ON o.user_id=u.id WHERE week_id=$1;`
 
@@ -195,3 +217,111 @@ func (r *ResourceRepositoryPostgres) DeleteResource(ctx context.Context, userID,
 	return nil
 
 }
+
+// CreateGenerationJob queues a flashcard-generation job for a new storage object, the content worker picks it up
+func (r *ResourceRepositoryPostgres) CreateGenerationJob(ctx context.Context, objectID uuid.UUID) (uuid.UUID, error) {
+	id := uuid.New()
+	query := `INSERT INTO generation_jobs (id, storage_object_id, status) VALUES ($1, $2, 'queued')`
+	_, err := r.pool.Exec(ctx, query, id, objectID)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("CreateGenerationJob err: %w", err)
+	}
+	return id, nil
+}
+
+func (r *ResourceRepositoryPostgres) FailGenerationJob(ctx context.Context, jobID uuid.UUID, errMsg string) error {
+	query := `UPDATE generation_jobs SET status = 'failed', error = $2, updated_at = NOW(), finished_at = NOW() WHERE id = $1`
+	_, err := r.pool.Exec(ctx, query, jobID, errMsg)
+	if err != nil {
+		return fmt.Errorf("FailGenerationJob err: %w", err)
+	}
+	return nil
+}
+
+func (r *ResourceRepositoryPostgres) CreateUploadSession(ctx context.Context, session UploadSession) error {
+	query := `INSERT INTO upload_sessions (id, user_id, week_id, name, file_type, size, sha256, object_id, upload_id, part_size, part_count, status, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+	_, err := r.pool.Exec(ctx, query, session.ID, session.UserID, session.WeekID, session.Name, session.FileType, session.Size, session.SHA256,
+		session.ObjectID, session.UploadID, session.PartSize, session.PartCount, session.Status, session.ExpiresAt, session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("CreateUploadSession err: %w", err)
+	}
+	return nil
+}
+
+// GetUploadSession returns pgx.ErrNoRows for sessions of other users as well
+func (r *ResourceRepositoryPostgres) GetUploadSession(ctx context.Context, sessionID, userID uuid.UUID) (UploadSession, error) {
+	var session UploadSession
+	query := `SELECT id, user_id, week_id, name, file_type, size, sha256, object_id, upload_id, part_size, part_count, status, resource_id,
+	COALESCE(error, ''), expires_at, created_at
+	FROM upload_sessions WHERE id = $1 AND user_id = $2`
+	err := r.pool.QueryRow(ctx, query, sessionID, userID).Scan(&session.ID, &session.UserID, &session.WeekID, &session.Name, &session.FileType,
+		&session.Size, &session.SHA256, &session.ObjectID, &session.UploadID, &session.PartSize, &session.PartCount, &session.Status,
+		&session.ResourceID, &session.Error, &session.ExpiresAt, &session.CreatedAt)
+	if err != nil {
+		return UploadSession{}, fmt.Errorf("GetUploadSession err: %w", err)
+	}
+	return session, nil
+}
+
+// ListExpiredUploadSessions returns the pending and verifying sessions past their expiry, oldest first
+func (r *ResourceRepositoryPostgres) ListExpiredUploadSessions(ctx context.Context, limit int) ([]UploadSession, error) {
+	query := `SELECT id, user_id, object_id, upload_id, status, expires_at FROM upload_sessions
+	WHERE status IN ('pending', 'verifying') AND expires_at < NOW() ORDER BY expires_at LIMIT $1`
+	rows, err := r.pool.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ListExpiredUploadSessions err: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]UploadSession, 0)
+	for rows.Next() {
+		var session UploadSession
+		if err := rows.Scan(&session.ID, &session.UserID, &session.ObjectID, &session.UploadID, &session.Status, &session.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("ListExpiredUploadSessions scan err: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// StartUploadVerification moves a pending session to verifying until the deadline, it returns false when
+// another request completed or aborted the session first
+func (r *ResourceRepositoryPostgres) StartUploadVerification(ctx context.Context, sessionID uuid.UUID, deadline time.Time) (bool, error) {
+	query := `UPDATE upload_sessions SET status = 'verifying', expires_at = $2, updated_at = NOW() WHERE id = $1 AND status = 'pending'`
+	tag, err := r.pool.Exec(ctx, query, sessionID, deadline)
+	if err != nil {
+		return false, fmt.Errorf("StartUploadVerification err: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *ResourceRepositoryPostgres) FinishUploadSession(ctx context.Context, sessionID uuid.UUID, status UploadSessionStatus, resourceID *uuid.UUID) error {
+	query := `UPDATE upload_sessions SET status = $2, resource_id = $3, updated_at = NOW() WHERE id = $1`
+	_, err := r.pool.Exec(ctx, query, sessionID, status, resourceID)
+	if err != nil {
+		return fmt.Errorf("FinishUploadSession err: %w", err)
+	}
+	return nil
+}
+
+// FailUploadSession aborts a session and keeps the reason for the client
+func (r *ResourceRepositoryPostgres) FailUploadSession(ctx context.Context, sessionID uuid.UUID, reason string) error {
+	query := `UPDATE upload_sessions SET status = 'aborted', error = $2, updated_at = NOW() WHERE id = $1`
+	_, err := r.pool.Exec(ctx, query, sessionID, reason)
+	if err != nil {
+		return fmt.Errorf("FailUploadSession err: %w", err)
+	}
+	return nil
+}
+
+// UserStorageUsage returns the bytes and the number of storage objects the user uploaded
+func (r *ResourceRepositoryPostgres) UserStorageUsage(ctx context.Context, userID uuid.UUID) (int64, int, error) {
+	var used int64
+	var objects int
+	query := `SELECT COALESCE(SUM(size), 0), COUNT(*) FROM storage_objects WHERE uploaded_by = $1`
+	if err := r.pool.QueryRow(ctx, query, userID).Scan(&used, &objects); err != nil {
+		return 0, 0, fmt.Errorf("UserStorageUsage err: %w", err)
+	}
+	return used, objects, nil
+}
@@ -5,21 +5,34 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-var ErrResourceExists = errors.New("resource already exists")
+var (
+	ErrResourceExists = errors.New("resource already exists")
+	// ErrInvalidHash is returned for hashes that are not a hex sha256
+	ErrInvalidHash = errors.New("sha256 must be 64 hex characters")
+	// ErrUnsupportedType is returned for files whose content is not one of the allowed types
+	ErrUnsupportedType = errors.New("file type is not allowed")
+	// ErrFileTooLarge is returned for files over the size limit
+	ErrFileTooLarge = errors.New("file is too large")
+	// ErrQuotaExceeded is returned when a file does not fit in what is left of the user's quota
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
 
 type ResourceRepository interface {
-	CreateFileResource(ctx context.Context, resource Resource) error
-	CreateLinkResource(ctx context.Context, resource Resource) error
-	CreateStorageObject(ctx context.Context, object storageObject) error
-	CreateUserResource(ctx context.Context, resource Resource) error
-	CreateWeekResource(ctx context.Context, resource Resource) error
+	CreateResource(ctx context.Context, resource Resource, hash string, object *storageObject) (bool, error)
 	ObjectExists(ctx context.Context, hash string) (uuid.UUID, bool, error)
+	ObjectUploadedBy(ctx context.Context, hash string, userID uuid.UUID) (bool, error)
 	ListResourcesByWeek(ctx context.Context, weekID uuid.UUID) ([]ResourceWithUser, error)
 	ListUserResources(ctx context.Context, userID uuid.UUID) ([]UserResources, error)
 	LinkExistsInWeek(ctx context.Context, resource Resource) (bool, error)
@@ -27,6 +40,15 @@ type ResourceRepository interface {
 	ListOrphanObjects(ctx context.Context) ([]uuid.UUID, error)
 	DeleteStorageObjects(ctx context.Context, ids []uuid.UUID) error
 	DeleteResource(ctx context.Context, userID, resourceID uuid.UUID) error
+	CreateGenerationJob(ctx context.Context, objectID uuid.UUID) (uuid.UUID, error)
+	FailGenerationJob(ctx context.Context, jobID uuid.UUID, errMsg string) error
+	CreateUploadSession(ctx context.Context, session UploadSession) error
+	GetUploadSession(ctx context.Context, sessionID, userID uuid.UUID) (UploadSession, error)
+	ListExpiredUploadSessions(ctx context.Context, limit int) ([]UploadSession, error)
+	StartUploadVerification(ctx context.Context, sessionID uuid.UUID, deadline time.Time) (bool, error)
+	FinishUploadSession(ctx context.Context, sessionID uuid.UUID, status UploadSessionStatus, resourceID *uuid.UUID) error
+	FailUploadSession(ctx context.Context, sessionID uuid.UUID, reason string) error
+	UserStorageUsage(ctx context.Context, userID uuid.UUID) (int64, int, error)
 }
 
 type Queue interface {
@@ -34,88 +56,131 @@ type Queue interface {
 }
 type FileStorage interface {
 	UploadObject(ctx context.Context, filename string, size int64, body io.Reader) (string, error)
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+	StatObject(ctx context.Context, key string) (int64, error)
 	DeleteObject(ctx context.Context, filename string) error
 	CreatePresidedURL(ctx context.Context, key string) (string, error)
+
+	// direct uploads, the client puts every part with a presigned url
+	CreateMultipartUpload(ctx context.Context, key string) (string, error)
+	PresignUploadPart(ctx context.Context, key, uploadID string, part int32, expiry time.Duration) (string, error)
+	// CompleteMultipartUpload joins the parts into the object and returns its url like UploadObject
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []UploadPart) (string, error)
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
 }
 
 type ResourceService struct {
 	resourceRepo ResourceRepository
 	filesStorage FileStorage
 	queue        Queue
+	spoolDir     string
+	maxFileSize  int64
+	userQuota    int64
+	allowedTypes map[string]bool
+	// direct uploads being hashed after their session was completed
+	verifications sync.WaitGroup
 }
 
-func NewResourceService(repo ResourceRepository, storage FileStorage, queue Queue) *ResourceService {
-	return &ResourceService{resourceRepo: repo, filesStorage: storage, queue: queue}
+func NewResourceService(repo ResourceRepository, storage FileStorage, queue Queue, cfg Config) *ResourceService {
+	if len(cfg.AllowedTypes) == 0 {
+		cfg.AllowedTypes = DefaultAllowedTypes
+	}
+	allowedTypes := make(map[string]bool, len(cfg.AllowedTypes))
+	for _, fileType := range cfg.AllowedTypes {
+		if fileType = normalizeFileType(fileType); fileType != "" {
+			allowedTypes[fileType] = true
+		}
+	}
+	return &ResourceService{
+		resourceRepo: repo,
+		filesStorage: storage,
+		queue:        queue,
+		spoolDir:     cfg.SpoolDir,
+		maxFileSize:  cfg.MaxFileSize,
+		userQuota:    cfg.UserQuota,
+		allowedTypes: allowedTypes,
+	}
 }
 
-//how to know if its pdf, only do this if its pdf
-
+// UploadResource hashes the file before it goes to storage, a file stored before is never uploaded again.
+// The file type comes from the content, resource.FileType only picks between types with the same magic bytes
 func (s *ResourceService) UploadResource(ctx context.Context, body io.Reader, size int64, resource Resource) error {
-	hasher := sha256.New()
-	tr := io.TeeReader(body, hasher)
-
-	//save object in the cloud
-	storageObjectID := uuid.New()
-	storageObjectUrl, err := s.filesStorage.UploadObject(ctx, storageObjectID.String(), size, tr)
-	if err != nil {
-		return err
+	if s.maxFileSize > 0 && size > s.maxFileSize {
+		return fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, s.maxFileSize)
 	}
-
-	hash := hex.EncodeToString(hasher.Sum(nil))
-	objectID, exists, err := s.resourceRepo.ObjectExists(ctx, hash)
+	file, cleanup, err := s.seekable(body)
 	if err != nil {
 		return err
 	}
+	defer cleanup()
 
-	//delete the file from storage if already exists
-	if exists {
-		slog.Info("resource exists")
-		//assign the ID returned from the DB, instead of ID in the request
-		resource.ObjectID = &objectID
-
-		// in the background delete the object from the storage
-		go func(deleteCtx context.Context, objectKey string) {
-			if deleteErr := s.filesStorage.DeleteObject(deleteCtx, objectKey); deleteErr != nil {
-				slog.Info("failed to delete from storage", "err", deleteErr)
-				return
-			}
-			slog.Info("successfully deleted the file from the storage")
-		}(ctx, storageObjectID.String())
-	} else {
-		resource.ObjectID = &storageObjectID
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("failed to read upload: %w", err)
+	}
+	archive, _ := file.(io.ReaderAt)
+	fileType, mimeType := detectFileType(head[:n], archive, size, resource.FileType)
+	if !s.allowedTypes[fileType] {
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, fileType)
+	}
+	resource.FileType = fileType
 
-		storageObject := storageObject{ID: storageObjectID, Hash: hash, URL: storageObjectUrl, FileType: resource.FileType}
-		err = s.resourceRepo.CreateStorageObject(ctx, storageObject)
-		if err != nil {
-			return err
-		}
-		//here should upload to the queue
-		err = s.queue.Publish(ctx, storageObject.ID)
-		if err != nil {
-			slog.Error("failed to publish message", "err", err.Error())
-		}
-		slog.Info("published message")
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind upload: %w", err)
+	}
+	hasher := sha256.New()
+	written, err := io.Copy(hasher, file)
+	if err != nil {
+		return fmt.Errorf("failed to hash upload: %w", err)
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if s.maxFileSize > 0 && written > s.maxFileSize {
+		return fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, s.maxFileSize)
 	}
 
-	//check if it exists in the week, to prevent resource deduplication
-	exists, err = s.resourceRepo.FileExistsInWeek(ctx, hash, resource.WeekID)
+	exists, err := s.resourceRepo.FileExistsInWeek(ctx, hash, resource.WeekID)
 	if err != nil {
 		return err
 	}
 	if exists {
 		return ErrResourceExists
 	}
-
-	err = s.resourceRepo.CreateFileResource(ctx, resource)
+	_, exists, err = s.resourceRepo.ObjectExists(ctx, hash)
 	if err != nil {
 		return err
 	}
-	err = s.resourceRepo.CreateUserResource(ctx, resource)
+	if exists {
+		// a stored file is shared, it does not count against the quota again
+		_, err := s.storeResource(ctx, resource, hash, nil)
+		return err
+	}
+	if err := s.CheckQuota(ctx, resource.UserID, written); err != nil {
+		return err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind upload: %w", err)
+	}
+	object := storageObject{ID: uuid.New(), Hash: hash, FileType: fileType, MimeType: mimeType, Size: written, UploadedBy: &resource.UserID}
+	object.URL, err = s.filesStorage.UploadObject(ctx, object.ID.String(), written, file)
 	if err != nil {
 		return err
 	}
-	return s.resourceRepo.CreateWeekResource(ctx, resource)
+	return s.createFileResource(ctx, object, resource)
+}
 
+// createFileResource records an object that is already in storage and creates the resource on it. When the
+// same file was stored before, the resource points at the existing object and the new copy is deleted
+func (s *ResourceService) createFileResource(ctx context.Context, object storageObject, resource Resource) error {
+	created, err := s.storeResource(ctx, resource, object.Hash, &object)
+	if err != nil || !created {
+		// nothing refers to the copy when the resource failed or points at an older object
+		if deleteErr := s.filesStorage.DeleteObject(ctx, object.ID.String()); deleteErr != nil {
+			slog.Error("failed to delete duplicate from storage", "object id", object.ID, "err", deleteErr)
+		}
+	}
+	return err
 }
 
 func (s *ResourceService) CreateLinkResource(ctx context.Context, resource Resource) error {
@@ -127,16 +192,137 @@ func (s *ResourceService) CreateLinkResource(ctx context.Context, resource Resou
 	if exists {
 		return ErrResourceExists
 	}
-	err = s.resourceRepo.CreateLinkResource(ctx, resource)
+	//links get a storage object as well, so the worker can fetch the page and generate cards from it,
+	//the same link shared in several weeks is fetched once
+	sum := sha256.Sum256([]byte(LinkFileType + ":" + *resource.ExternalLink))
+	hash := hex.EncodeToString(sum[:])
+	object := &storageObject{ID: uuid.New(), Hash: hash, URL: *resource.ExternalLink, FileType: LinkFileType}
+	_, err = s.storeResource(ctx, resource, hash, object)
+	return err
+}
+
+// CheckHash tells the client whether it has to upload a file. A stored file is added to the week when the
+// request names a week and a resource name, unless the week has it already. Only files the user uploaded
+// themselves count as stored, knowing the hash of someone else's file must not give access to it; other
+// users upload the bytes, which are still stored once
+func (s *ResourceService) CheckHash(ctx context.Context, userID uuid.UUID, req CheckHashRequest) (HashCheck, error) {
+	hash := strings.ToLower(strings.TrimSpace(req.SHA256))
+	if !sha256Pattern.MatchString(hash) {
+		return HashCheck{}, ErrInvalidHash
+	}
+	exists, err := s.resourceRepo.ObjectUploadedBy(ctx, hash, userID)
+	if err != nil || !exists || req.WeekID == nil {
+		return HashCheck{Exists: exists}, err
+	}
+
+	inWeek, err := s.resourceRepo.FileExistsInWeek(ctx, hash, *req.WeekID)
+	if err != nil || inWeek || strings.TrimSpace(req.Name) == "" {
+		return HashCheck{Exists: true, InWeek: inWeek}, err
+	}
+
+	resource := Resource{ID: uuid.New(), WeekID: *req.WeekID, UserID: userID, ResourceType: ResourceFile, Name: strings.TrimSpace(req.Name)}
+	if _, err := s.storeResource(ctx, resource, hash, nil); err != nil {
+		if errors.Is(err, ErrResourceExists) {
+			return HashCheck{Exists: true, InWeek: true}, nil
+		}
+		return HashCheck{}, err
+	}
+	return HashCheck{Exists: true, ResourceID: &resource.ID}, nil
+}
+
+// storeResource creates the resource with its owner and week in one transaction and queues the generation
+// of a storage object it inserted. object is nil when the hash is known to be stored, created reports
+// whether object was inserted or the resource points at an older object with the hash
+func (s *ResourceService) storeResource(ctx context.Context, resource Resource, hash string, object *storageObject) (bool, error) {
+	created, err := s.resourceRepo.CreateResource(ctx, resource, hash, object)
 	if err != nil {
-		return err
+		return false, err
 	}
-	err = s.resourceRepo.CreateUserResource(ctx, resource)
+	if !created {
+		slog.Info("resource exists", "hash", hash)
+		return false, nil
+	}
+	return true, s.queueGeneration(ctx, object.ID)
+}
+
+// MaxFileSize is the largest file accepted in bytes, 0 when there is no limit
+func (s *ResourceService) MaxFileSize() int64 {
+	return s.maxFileSize
+}
+
+// StorageUsage returns the bytes the user's uploads take next to the limits they are held to
+func (s *ResourceService) StorageUsage(ctx context.Context, userID uuid.UUID) (StorageUsage, error) {
+	used, objects, err := s.resourceRepo.UserStorageUsage(ctx, userID)
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	allowedTypes := make([]string, 0, len(s.allowedTypes))
+	for fileType := range s.allowedTypes {
+		allowedTypes = append(allowedTypes, fileType)
+	}
+	sort.Strings(allowedTypes)
+	return StorageUsage{UsedBytes: used, QuotaBytes: s.userQuota, Objects: objects, MaxFileSize: s.maxFileSize, AllowedTypes: allowedTypes}, nil
+}
+
+// CheckQuota fails when size more bytes would take the user over their quota
+func (s *ResourceService) CheckQuota(ctx context.Context, userID uuid.UUID, size int64) error {
+	if s.userQuota <= 0 {
+		return nil
+	}
+	used, _, err := s.resourceRepo.UserStorageUsage(ctx, userID)
 	if err != nil {
 		return err
 	}
-	return s.resourceRepo.CreateWeekResource(ctx, resource)
+	if used+size > s.userQuota {
+		return fmt.Errorf("%w: %d of %d bytes are used, the file needs %d", ErrQuotaExceeded, used, s.userQuota, size)
+	}
+	return nil
+}
 
+// seekable returns the upload as a file that can be read twice, to hash it before it goes to storage.
+// Form files, notes and finished resumable uploads already are, other bodies are spooled to disk
+func (s *ResourceService) seekable(body io.Reader) (io.ReadSeeker, func(), error) {
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		return seeker, func() {}, nil
+	}
+	spool, err := os.CreateTemp(s.spoolDir, "studyhub-upload-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to spool upload: %w", err)
+	}
+	cleanup := func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}
+	if _, err := io.Copy(spool, body); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to spool upload: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to spool upload: %w", err)
+	}
+	return spool, cleanup, nil
+}
+
+// queueGeneration hands a new storage object to the content worker. A failed publish only fails the job,
+// the resource is still created and its cards can be regenerated later
+func (s *ResourceService) queueGeneration(ctx context.Context, objectID uuid.UUID) error {
+	//the job is created before publishing, so the worker always finds it queued
+	jobID, err := s.resourceRepo.CreateGenerationJob(ctx, objectID)
+	if err != nil {
+		return err
+	}
+	//here should upload to the queue
+	err = s.queue.Publish(ctx, objectID)
+	if err != nil {
+		slog.Error("failed to publish message", "err", err.Error())
+		if failErr := s.resourceRepo.FailGenerationJob(ctx, jobID, "failed to queue: "+err.Error()); failErr != nil {
+			slog.Error("failed to update generation job", "err", failErr)
+		}
+	} else {
+		slog.Info("published message")
+	}
+	return nil
 }
 
 func (s *ResourceService) ListResourcesForWeek(ctx context.Context, weekID uuid.UUID) ([]ResourceWithUser, error) {
@@ -1,6 +1,7 @@
 package resources
 
 import (
+	"StudyHub/internal/storage"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,6 +15,13 @@ const (
 	ResourceNote ResourceType = "note"
 )
 
+const (
+	// LinkFileType is the file type of the storage object behind a link, the worker fetches its url
+	LinkFileType = "link"
+	// NoteFileType is the file type notes are stored with, they are markdown
+	NoteFileType = "md"
+)
+
 type Resource struct {
 	ID           uuid.UUID
 	WeekID       uuid.UUID
@@ -27,10 +35,30 @@ type Resource struct {
 }
 
 type storageObject struct {
-	ID       uuid.UUID
-	Hash     string
-	URL      string
-	FileType string
+	ID         uuid.UUID
+	Hash       string
+	URL        string
+	FileType   string
+	MimeType   string
+	Size       int64
+	UploadedBy *uuid.UUID // counts against the uploader's quota, nil for links
+}
+
+// Config limits what users can upload
+type Config struct {
+	SpoolDir     string   // uploads that can not be read twice are copied here to hash them first, "" is the os temp dir
+	MaxFileSize  int64    // largest file in bytes, 0 for no limit
+	UserQuota    int64    // bytes of storage objects a user may upload, 0 for no limit
+	AllowedTypes []string // file types accepted, DefaultAllowedTypes when empty
+}
+
+// StorageUsage is how much of their quota a user's uploads take
+type StorageUsage struct {
+	UsedBytes    int64    `json:"used_bytes"`
+	QuotaBytes   int64    `json:"quota_bytes"` // 0 when there is no quota
+	Objects      int      `json:"objects"`
+	MaxFileSize  int64    `json:"max_file_size"` // 0 when there is no limit
+	AllowedTypes []string `json:"allowed_types"`
 }
 
 // this is not Domain type , but instaed a struct that is used when we want +info about the Owner
@@ -61,3 +89,74 @@ type UserResources struct {
 	Name         string
 	CreatedAt    time.Time
 }
+
+type UploadSessionStatus string
+
+const (
+	UploadPending UploadSessionStatus = "pending"
+	// UploadVerifying sessions are joined in storage, the file is being hashed before the resource is created
+	UploadVerifying UploadSessionStatus = "verifying"
+	UploadCompleted UploadSessionStatus = "completed"
+	UploadAborted   UploadSessionStatus = "aborted"
+)
+
+// UploadSession is a direct upload, the client puts the parts into storage itself and completes the session
+type UploadSession struct {
+	ID         uuid.UUID           `json:"id"`
+	UserID     uuid.UUID           `json:"-"` // sessions are only shown to their owner
+	WeekID     uuid.UUID           `json:"week_id"`
+	Name       string              `json:"name"`
+	FileType   string              `json:"file_type"`
+	Size       int64               `json:"size"`
+	SHA256     *string             `json:"sha256,omitempty"`
+	ObjectID   uuid.UUID           `json:"-"` // key of the object in storage, and its storage_objects id
+	UploadID   string              `json:"-"` // the storage driver's multipart upload id
+	PartSize   int64               `json:"part_size"`
+	PartCount  int                 `json:"part_count"`
+	Status     UploadSessionStatus `json:"status"`
+	ResourceID *uuid.UUID          `json:"resource_id,omitempty"`
+	Error      string              `json:"error,omitempty"` // why an aborted session was turned away
+	ExpiresAt  time.Time           `json:"expires_at"`
+	CreatedAt  time.Time           `json:"created_at"`
+}
+
+// PresignedPart is where the client puts one part of a direct upload
+type PresignedPart struct {
+	Number int32  `json:"number"`
+	URL    string `json:"url"`
+}
+
+// UploadPart is a part the client uploaded, with the ETag storage answered the PUT with
+type UploadPart = storage.UploadPart
+
+// StartedUpload is a new upload session with the urls of its parts
+type StartedUpload struct {
+	UploadSession
+	Parts []PresignedPart `json:"parts"`
+}
+
+type CreateUploadRequest struct {
+	WeekID   uuid.UUID `json:"week_id"`
+	Name     string    `json:"name"`
+	FileType string    `json:"file_type"`
+	Size     int64     `json:"size"`
+	SHA256   *string   `json:"sha256"`
+}
+
+type CompleteUploadRequest struct {
+	Parts []UploadPart `json:"parts"`
+}
+
+// CheckHashRequest asks whether the user stored a file already. With a week and a name a stored file is
+// added to the week right away, so the client never uploads it
+type CheckHashRequest struct {
+	SHA256 string     `json:"sha256"`
+	WeekID *uuid.UUID `json:"week_id"`
+	Name   string     `json:"name"`
+}
+
+type HashCheck struct {
+	Exists     bool       `json:"exists"`                // the user stored a file with the hash before
+	InWeek     bool       `json:"in_week"`               // the week already has a resource with the file
+	ResourceID *uuid.UUID `json:"resource_id,omitempty"` // the resource created on the stored file
+}
@@ -0,0 +1,354 @@
+package resources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// presigned part urls and the session itself stop working after this
+	uploadSessionTTL = time.Hour
+	// S3 needs every part but the last to be at least 5 MiB, and takes at most 10000 parts
+	minUploadPartSize = 8 << 20
+	maxUploadParts    = 10000
+	maxUploadSize     = 5 << 30
+	// expired sessions aborted per sweep, the rest wait for the next tick
+	uploadSweepBatch = 100
+)
+
+var (
+	// ErrInvalidUpload is returned for upload requests and completions that do not add up
+	ErrInvalidUpload = errors.New("invalid upload")
+	// ErrUploadExpired is returned when a session is completed after its urls stopped working
+	ErrUploadExpired = errors.New("upload session expired")
+	// ErrUploadClosed is returned for sessions that were already completed or aborted
+	ErrUploadClosed = errors.New("upload session is already completed or aborted")
+	// ErrUploadMismatch is returned when the uploaded file is not the size or sha256 the session was started with
+	ErrUploadMismatch = errors.New("uploaded file does not match the upload session")
+	// errVerificationLost is kept on sessions whose background check never finished, e.g. after a restart
+	errVerificationLost = errors.New("upload verification did not finish, upload the file again")
+
+	sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// StartUpload opens a direct upload: the client puts the parts straight into storage with the returned
+// urls, so the file never passes through the backend, and then completes the session
+func (s *ResourceService) StartUpload(ctx context.Context, userID uuid.UUID, req CreateUploadRequest) (StartedUpload, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.FileType = normalizeFileType(req.FileType)
+	switch {
+	case req.Name == "" || req.FileType == "":
+		return StartedUpload{}, fmt.Errorf("%w: name and file_type are required", ErrInvalidUpload)
+	case req.Size <= 0 || req.Size > maxUploadSize:
+		return StartedUpload{}, fmt.Errorf("%w: size must be between 1 and %d bytes", ErrInvalidUpload, int64(maxUploadSize))
+	case s.maxFileSize > 0 && req.Size > s.maxFileSize:
+		return StartedUpload{}, fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, s.maxFileSize)
+	case !s.allowedTypes[req.FileType]:
+		// the declared type is checked against the content once the upload is complete
+		return StartedUpload{}, fmt.Errorf("%w: %s", ErrUnsupportedType, req.FileType)
+	}
+	if err := s.CheckQuota(ctx, userID, req.Size); err != nil {
+		return StartedUpload{}, err
+	}
+	if req.SHA256 != nil {
+		hash := strings.ToLower(strings.TrimSpace(*req.SHA256))
+		if !sha256Pattern.MatchString(hash) {
+			return StartedUpload{}, fmt.Errorf("%w: sha256 must be 64 hex characters", ErrInvalidUpload)
+		}
+		req.SHA256 = &hash
+	}
+
+	partSize := max(int64(minUploadPartSize), (req.Size+maxUploadParts-1)/maxUploadParts)
+	session := UploadSession{
+		ID:        uuid.New(),
+		UserID:    userID,
+		WeekID:    req.WeekID,
+		Name:      req.Name,
+		FileType:  req.FileType,
+		Size:      req.Size,
+		SHA256:    req.SHA256,
+		ObjectID:  uuid.New(),
+		PartSize:  partSize,
+		PartCount: int((req.Size + partSize - 1) / partSize),
+		Status:    UploadPending,
+		ExpiresAt: time.Now().UTC().Add(uploadSessionTTL),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	uploadID, err := s.filesStorage.CreateMultipartUpload(ctx, session.ObjectID.String())
+	if err != nil {
+		return StartedUpload{}, fmt.Errorf("failed to start upload: %w", err)
+	}
+	session.UploadID = uploadID
+
+	parts := make([]PresignedPart, session.PartCount)
+	for i := range parts {
+		number := int32(i + 1)
+		url, err := s.filesStorage.PresignUploadPart(ctx, session.ObjectID.String(), uploadID, number, uploadSessionTTL)
+		if err != nil {
+			s.abortStorageUpload(ctx, session)
+			return StartedUpload{}, fmt.Errorf("failed to presign upload part: %w", err)
+		}
+		parts[i] = PresignedPart{Number: number, URL: url}
+	}
+
+	if err := s.resourceRepo.CreateUploadSession(ctx, session); err != nil {
+		s.abortStorageUpload(ctx, session)
+		return StartedUpload{}, err
+	}
+	return StartedUpload{UploadSession: session, Parts: parts}, nil
+}
+
+// CompleteUpload joins the uploaded parts and checks their size, then hashes the file in the background:
+// reading a file of up to 5 GiB back does not fit in a request. The returned session is verifying, it
+// ends completed with the resource, deduplicated like a file sent through the backend, or aborted when
+// the file does not match its sha256 or is not an allowed type
+func (s *ResourceService) CompleteUpload(ctx context.Context, userID, sessionID uuid.UUID, parts []UploadPart) (UploadSession, error) {
+	session, err := s.openUploadSession(ctx, userID, sessionID)
+	if err != nil {
+		return UploadSession{}, err
+	}
+	if err := checkUploadParts(parts, session.PartCount); err != nil {
+		return UploadSession{}, err
+	}
+
+	key := session.ObjectID.String()
+	url, err := s.filesStorage.CompleteMultipartUpload(ctx, key, session.UploadID, parts)
+	if err != nil {
+		return UploadSession{}, fmt.Errorf("%w: failed to complete upload: %v", ErrInvalidUpload, err)
+	}
+
+	// storage only knows the parts, a file of the wrong size is turned away before anything is read
+	size, err := s.filesStorage.StatObject(ctx, key)
+	if err != nil {
+		return UploadSession{}, fmt.Errorf("failed to stat uploaded file: %w", err)
+	}
+	if size != session.Size {
+		err := fmt.Errorf("%w: got %d bytes, expected %d", ErrUploadMismatch, size, session.Size)
+		s.discardUpload(ctx, session, err)
+		return UploadSession{}, err
+	}
+	// other uploads may have used up the quota since this session started
+	if err := s.CheckQuota(ctx, session.UserID, size); err != nil {
+		s.discardUpload(ctx, session, err)
+		return UploadSession{}, err
+	}
+
+	deadline := time.Now().UTC().Add(uploadSessionTTL)
+	started, err := s.resourceRepo.StartUploadVerification(ctx, session.ID, deadline)
+	if err != nil {
+		return UploadSession{}, err
+	}
+	if !started {
+		return UploadSession{}, ErrUploadClosed
+	}
+	session.Status = UploadVerifying
+	session.ExpiresAt = deadline
+
+	s.verifications.Add(1)
+	go func() {
+		defer s.verifications.Done()
+		s.verifyUpload(context.WithoutCancel(ctx), session, url)
+	}()
+	return session, nil
+}
+
+// GetUpload returns a session of the user, clients poll it after completing until it is no longer verifying
+func (s *ResourceService) GetUpload(ctx context.Context, userID, sessionID uuid.UUID) (UploadSession, error) {
+	session, err := s.resourceRepo.GetUploadSession(ctx, sessionID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UploadSession{}, errors.New("upload session not found")
+	}
+	return session, err
+}
+
+// verifyUpload hashes a joined upload, checks it against the session and creates the resource
+func (s *ResourceService) verifyUpload(ctx context.Context, session UploadSession, url string) {
+	size, hash, head, err := s.hashObject(ctx, session.ObjectID.String())
+	if err != nil {
+		slog.Error("failed to verify upload", "session id", session.ID, "err", err)
+		s.discardUpload(ctx, session, err)
+		return
+	}
+	if size != session.Size || (session.SHA256 != nil && hash != *session.SHA256) {
+		s.discardUpload(ctx, session, fmt.Errorf("%w: got %d bytes with sha256 %s", ErrUploadMismatch, size, hash))
+		return
+	}
+	fileType, mimeType := detectFileType(head, nil, size, session.FileType)
+	if !s.allowedTypes[fileType] {
+		s.discardUpload(ctx, session, fmt.Errorf("%w: %s", ErrUnsupportedType, fileType))
+		return
+	}
+
+	resource := Resource{ID: uuid.New(), WeekID: session.WeekID, UserID: session.UserID, ResourceType: ResourceFile, Name: session.Name, FileType: fileType}
+	object := storageObject{ID: session.ObjectID, Hash: hash, URL: url, FileType: fileType, MimeType: mimeType, Size: size, UploadedBy: &session.UserID}
+	if err := s.createFileResource(ctx, object, resource); err != nil {
+		// createFileResource deleted the object already
+		if !errors.Is(err, ErrResourceExists) {
+			slog.Error("failed to create resource of upload", "session id", session.ID, "err", err)
+		}
+		s.failUploadSession(ctx, session.ID, err)
+		return
+	}
+	s.finishUploadSession(ctx, session.ID, UploadCompleted, &resource.ID)
+}
+
+// AbortUpload drops a pending session and the parts uploaded so far
+func (s *ResourceService) AbortUpload(ctx context.Context, userID, sessionID uuid.UUID) error {
+	session, err := s.openUploadSession(ctx, userID, sessionID)
+	if err != nil && !errors.Is(err, ErrUploadExpired) {
+		return err
+	}
+	if err == nil {
+		s.abortStorageUpload(ctx, session)
+		s.finishUploadSession(ctx, session.ID, UploadAborted, nil)
+	}
+	return nil
+}
+
+// AbortExpiredUploads drops the parts of pending sessions past their expiry, which clients left without
+// completing or aborting them, and the files of sessions whose verification never finished
+func (s *ResourceService) AbortExpiredUploads(ctx context.Context) (int, error) {
+	sessions, err := s.resourceRepo.ListExpiredUploadSessions(ctx, uploadSweepBatch)
+	if err != nil {
+		return 0, err
+	}
+	for _, session := range sessions {
+		if session.Status == UploadVerifying {
+			s.discardUpload(ctx, session, errVerificationLost)
+			continue
+		}
+		s.abortStorageUpload(ctx, session)
+		s.failUploadSession(ctx, session.ID, ErrUploadExpired)
+	}
+	return len(sessions), nil
+}
+
+// StartUploadSweep aborts expired upload sessions every interval until ctx is done, storage would keep
+// their parts otherwise
+func (s *ResourceService) StartUploadSweep(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				aborted, err := s.AbortExpiredUploads(ctx)
+				if err != nil {
+					slog.Error("failed to abort expired uploads", "err", err)
+					continue
+				}
+				if aborted > 0 {
+					slog.Info("aborted expired uploads", "count", aborted)
+				}
+			}
+		}
+	}()
+}
+
+// openUploadSession returns a pending session of the user, an expired one is aborted on the way
+func (s *ResourceService) openUploadSession(ctx context.Context, userID, sessionID uuid.UUID) (UploadSession, error) {
+	session, err := s.resourceRepo.GetUploadSession(ctx, sessionID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return UploadSession{}, errors.New("upload session not found")
+		}
+		return UploadSession{}, err
+	}
+	if session.Status != UploadPending {
+		return UploadSession{}, ErrUploadClosed
+	}
+	if time.Now().After(session.ExpiresAt) {
+		s.abortStorageUpload(ctx, session)
+		s.failUploadSession(ctx, session.ID, ErrUploadExpired)
+		return UploadSession{}, ErrUploadExpired
+	}
+	return session, nil
+}
+
+// checkUploadParts wants every part of the session exactly once, it sorts them by number as storage expects
+func checkUploadParts(parts []UploadPart, count int) error {
+	if len(parts) != count {
+		return fmt.Errorf("%w: expected %d parts, got %d", ErrInvalidUpload, count, len(parts))
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	for i, part := range parts {
+		if part.Number != int32(i+1) {
+			return fmt.Errorf("%w: parts must be numbered 1 to %d once each", ErrInvalidUpload, count)
+		}
+		if strings.TrimSpace(part.ETag) == "" {
+			return fmt.Errorf("%w: part %d has no etag", ErrInvalidUpload, part.Number)
+		}
+	}
+	return nil
+}
+
+// hashObject reads a stored object back and returns its size, sha256 and first bytes
+func (s *ResourceService) hashObject(ctx context.Context, key string) (int64, string, []byte, error) {
+	body, err := s.filesStorage.GetObject(ctx, key)
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	defer func() { _ = body.Close() }()
+
+	hasher := sha256.New()
+	head := &headWriter{limit: sniffLength}
+	size, err := io.Copy(io.MultiWriter(hasher, head), body)
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	return size, hex.EncodeToString(hasher.Sum(nil)), head.data, nil
+}
+
+// headWriter keeps the first limit bytes written to it
+type headWriter struct {
+	data  []byte
+	limit int
+}
+
+func (h *headWriter) Write(p []byte) (int, error) {
+	if rest := h.limit - len(h.data); rest > 0 {
+		h.data = append(h.data, p[:min(rest, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// discardUpload deletes a completed upload that failed the checks, the session can not be retried
+func (s *ResourceService) discardUpload(ctx context.Context, session UploadSession, reason error) {
+	if err := s.filesStorage.DeleteObject(ctx, session.ObjectID.String()); err != nil {
+		slog.Error("failed to delete rejected upload", "session id", session.ID, "err", err)
+	}
+	s.failUploadSession(ctx, session.ID, reason)
+}
+
+func (s *ResourceService) abortStorageUpload(ctx context.Context, session UploadSession) {
+	if err := s.filesStorage.AbortMultipartUpload(ctx, session.ObjectID.String(), session.UploadID); err != nil {
+		slog.Error("failed to abort multipart upload", "session id", session.ID, "err", err)
+	}
+}
+
+func (s *ResourceService) failUploadSession(ctx context.Context, sessionID uuid.UUID, reason error) {
+	if err := s.resourceRepo.FailUploadSession(ctx, sessionID, reason.Error()); err != nil {
+		slog.Error("failed to abort upload session", "session id", sessionID, "err", err)
+	}
+}
+
+func (s *ResourceService) finishUploadSession(ctx context.Context, sessionID uuid.UUID, status UploadSessionStatus, resourceID *uuid.UUID) {
+	if err := s.resourceRepo.FinishUploadSession(ctx, sessionID, status, resourceID); err != nil {
+		slog.Error("failed to update upload session", "session id", sessionID, "status", status, "err", err)
+	}
+}
@@ -0,0 +1,322 @@
+package resources
+
+import (
+	"StudyHub/internal/storage"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// uploadRepo keeps upload sessions and created resources in memory, verification writes from its own goroutine
+type uploadRepo struct {
+	ResourceRepository
+	mu        sync.Mutex
+	sessions  map[uuid.UUID]UploadSession
+	resources []Resource
+	objects   []storageObject
+	usedBytes int64
+	// existing makes CreateResource find the file in the week already
+	existing bool
+}
+
+func newUploadRepo() *uploadRepo {
+	return &uploadRepo{sessions: make(map[uuid.UUID]UploadSession)}
+}
+
+func (r *uploadRepo) CreateUploadSession(ctx context.Context, session UploadSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *uploadRepo) GetUploadSession(ctx context.Context, sessionID, userID uuid.UUID) (UploadSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[sessionID]
+	if !ok || session.UserID != userID {
+		return UploadSession{}, pgx.ErrNoRows
+	}
+	return session, nil
+}
+
+func (r *uploadRepo) StartUploadVerification(ctx context.Context, sessionID uuid.UUID, deadline time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session := r.sessions[sessionID]
+	if session.Status != UploadPending {
+		return false, nil
+	}
+	session.Status, session.ExpiresAt = UploadVerifying, deadline
+	r.sessions[sessionID] = session
+	return true, nil
+}
+
+func (r *uploadRepo) FinishUploadSession(ctx context.Context, sessionID uuid.UUID, status UploadSessionStatus, resourceID *uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session := r.sessions[sessionID]
+	session.Status, session.ResourceID = status, resourceID
+	r.sessions[sessionID] = session
+	return nil
+}
+
+func (r *uploadRepo) FailUploadSession(ctx context.Context, sessionID uuid.UUID, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session := r.sessions[sessionID]
+	session.Status, session.Error = UploadAborted, reason
+	r.sessions[sessionID] = session
+	return nil
+}
+
+func (r *uploadRepo) UserStorageUsage(ctx context.Context, userID uuid.UUID) (int64, int, error) {
+	return r.usedBytes, 0, nil
+}
+
+func (r *uploadRepo) CreateResource(ctx context.Context, resource Resource, hash string, object *storageObject) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.existing {
+		return false, ErrResourceExists
+	}
+	r.resources = append(r.resources, resource)
+	r.objects = append(r.objects, *object)
+	return true, nil
+}
+
+func (r *uploadRepo) CreateGenerationJob(ctx context.Context, objectID uuid.UUID) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func (r *uploadRepo) session(id uuid.UUID) UploadSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions[id]
+}
+
+type publishedQueue struct{}
+
+func (publishedQueue) Publish(ctx context.Context, objectID uuid.UUID) error { return nil }
+
+// putParts uploads content in the session's part size through the local driver's signed part urls
+func putParts(t *testing.T, files *storage.LocalStorage, started StartedUpload, content string) []UploadPart {
+	t.Helper()
+	parts := make([]UploadPart, 0, len(started.Parts))
+	for i, presigned := range started.Parts {
+		u, err := url.Parse(presigned.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := min(int64(i)*started.PartSize, int64(len(content)))
+		end := min(start+started.PartSize, int64(len(content)))
+		uploadID := path.Base(path.Dir(u.Path))
+		etag, err := files.WriteSignedPart(uploadID, path.Base(u.Path), u.Query().Get("expires"), u.Query().Get("signature"), strings.NewReader(content[start:end]))
+		if err != nil {
+			t.Fatalf("failed to put part %d: %v", presigned.Number, err)
+		}
+		parts = append(parts, UploadPart{Number: presigned.Number, ETag: etag})
+	}
+	return parts
+}
+
+func TestCompleteUpload(t *testing.T) {
+	pdf := "%PDF-1.7\nlecture slides"
+	sum := sha256.Sum256([]byte(pdf))
+	pdfHash := hex.EncodeToString(sum[:])
+	wrongHash := strings.Repeat("0", 64)
+
+	tests := []struct {
+		name           string
+		content        string
+		declaredSize   int64
+		sha256         *string
+		usedBytes      int64
+		existing       bool
+		expectedErr    error
+		expectedStatus UploadSessionStatus
+		expectedReason error
+	}{
+		{name: "verified upload creates the resource", content: pdf, sha256: &pdfHash, expectedStatus: UploadCompleted},
+		{name: "no declared sha256", content: pdf, expectedStatus: UploadCompleted},
+		{name: "size mismatch is turned away before reading", content: pdf, declaredSize: int64(len(pdf)) + 1, expectedErr: ErrUploadMismatch, expectedStatus: UploadAborted, expectedReason: ErrUploadMismatch},
+		{name: "quota used up since the start", content: pdf, usedBytes: 100, expectedErr: ErrQuotaExceeded, expectedStatus: UploadAborted, expectedReason: ErrQuotaExceeded},
+		{name: "sha256 mismatch aborts in the background", content: pdf, sha256: &wrongHash, expectedStatus: UploadAborted, expectedReason: ErrUploadMismatch},
+		{name: "type not allowed aborts in the background", content: "\x00\x01\x02 binary", expectedStatus: UploadAborted, expectedReason: ErrUnsupportedType},
+		{name: "file already in the week", content: pdf, existing: true, expectedStatus: UploadAborted, expectedReason: ErrResourceExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			files, err := storage.NewLocalStorage(t.TempDir(), "http://backend", "secret", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo := newUploadRepo()
+			srv := NewResourceService(repo, files, publishedQueue{}, Config{UserQuota: 100})
+			userID := uuid.New()
+
+			size := tt.declaredSize
+			if size == 0 {
+				size = int64(len(tt.content))
+			}
+			started, err := srv.StartUpload(ctx, userID, CreateUploadRequest{WeekID: uuid.New(), Name: "Slides", FileType: "pdf", Size: size, SHA256: tt.sha256})
+			if err != nil {
+				t.Fatalf("failed to start upload: %v", err)
+			}
+			parts := putParts(t, files, started, tt.content)
+			repo.usedBytes, repo.existing = tt.usedBytes, tt.existing
+
+			session, err := srv.CompleteUpload(ctx, userID, started.ID, parts)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err == nil && session.Status != UploadVerifying {
+				t.Errorf("expected the completed session to be verifying, got %s", session.Status)
+			}
+			srv.verifications.Wait()
+
+			stored := repo.session(started.ID)
+			if stored.Status != tt.expectedStatus {
+				t.Fatalf("expected session %s, got %s (%s)", tt.expectedStatus, stored.Status, stored.Error)
+			}
+			if tt.expectedReason != nil && !strings.Contains(stored.Error, tt.expectedReason.Error()) {
+				t.Errorf("expected the session error to say %q, got %q", tt.expectedReason, stored.Error)
+			}
+
+			key := started.ObjectID.String()
+			if tt.expectedStatus != UploadCompleted {
+				if _, err := files.GetObject(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+					t.Errorf("expected the rejected file to be deleted, got %v", err)
+				}
+				return
+			}
+			if len(repo.objects) != 1 || repo.objects[0].Hash != pdfHash || repo.objects[0].FileType != "pdf" || repo.objects[0].Size != int64(len(pdf)) {
+				t.Fatalf("unexpected storage objects %+v", repo.objects)
+			}
+			if stored.ResourceID == nil || *stored.ResourceID != repo.resources[0].ID {
+				t.Errorf("expected the session to point at the new resource, got %v", stored.ResourceID)
+			}
+			if _, err := srv.CompleteUpload(ctx, userID, started.ID, parts); !errors.Is(err, ErrUploadClosed) {
+				t.Errorf("expected completing again to fail with %v, got %v", ErrUploadClosed, err)
+			}
+		})
+	}
+}
+
+func TestCompleteUploadOtherUser(t *testing.T) {
+	files, err := storage.NewLocalStorage(t.TempDir(), "http://backend", "secret", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewResourceService(newUploadRepo(), files, publishedQueue{}, Config{})
+	started, err := srv.StartUpload(context.Background(), uuid.New(), CreateUploadRequest{WeekID: uuid.New(), Name: "Slides", FileType: "pdf", Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = srv.CompleteUpload(context.Background(), uuid.New(), started.ID, []UploadPart{{Number: 1, ETag: `"x"`}})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected another user's session to be not found, got %v", err)
+	}
+}
+
+func TestAbortExpiredUploads(t *testing.T) {
+	ctx := context.Background()
+	files, err := storage.NewLocalStorage(t.TempDir(), "http://backend", "secret", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &expiredRepo{uploadRepo: newUploadRepo()}
+	srv := NewResourceService(repo, files, publishedQueue{}, Config{})
+	userID := uuid.New()
+
+	pending, err := srv.StartUpload(ctx, userID, CreateUploadRequest{WeekID: uuid.New(), Name: "Slides", FileType: "pdf", Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifying := UploadSession{ID: uuid.New(), UserID: userID, ObjectID: uuid.New(), Status: UploadVerifying}
+	if _, err := files.UploadObject(ctx, verifying.ObjectID.String(), 4, strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+	repo.sessions[verifying.ID] = verifying
+
+	aborted, err := srv.AbortExpiredUploads(ctx)
+	if err != nil || aborted != 2 {
+		t.Fatalf("AbortExpiredUploads() = %d, %v", aborted, err)
+	}
+	if session := repo.session(pending.ID); session.Status != UploadAborted || session.Error != ErrUploadExpired.Error() {
+		t.Errorf("expected the pending session to expire, got %s %q", session.Status, session.Error)
+	}
+	if _, err := files.PresignUploadPart(ctx, pending.ObjectID.String(), pending.UploadID, 1, time.Minute); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the multipart upload to be aborted, got %v", err)
+	}
+	if session := repo.session(verifying.ID); session.Status != UploadAborted || session.Error != errVerificationLost.Error() {
+		t.Errorf("expected the lost verification to abort, got %s %q", session.Status, session.Error)
+	}
+	if _, err := files.GetObject(ctx, verifying.ObjectID.String()); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the unverified file to be deleted, got %v", err)
+	}
+}
+
+// expiredRepo treats every open session as expired
+type expiredRepo struct {
+	*uploadRepo
+}
+
+func (r *expiredRepo) ListExpiredUploadSessions(ctx context.Context, limit int) ([]UploadSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessions := make([]UploadSession, 0)
+	for _, session := range r.sessions {
+		if session.Status == UploadPending || session.Status == UploadVerifying {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func TestCheckUploadParts(t *testing.T) {
+	tests := []struct {
+		name        string
+		parts       []UploadPart
+		count       int
+		expectedErr bool
+	}{
+		{name: "every part once", parts: []UploadPart{{Number: 1, ETag: "a"}, {Number: 2, ETag: "b"}}, count: 2},
+		{name: "out of order is sorted", parts: []UploadPart{{Number: 3, ETag: "c"}, {Number: 1, ETag: "a"}, {Number: 2, ETag: "b"}}, count: 3},
+		{name: "missing part", parts: []UploadPart{{Number: 1, ETag: "a"}}, count: 2, expectedErr: true},
+		{name: "duplicate part", parts: []UploadPart{{Number: 1, ETag: "a"}, {Number: 1, ETag: "a"}}, count: 2, expectedErr: true},
+		{name: "part past the count", parts: []UploadPart{{Number: 1, ETag: "a"}, {Number: 3, ETag: "c"}}, count: 2, expectedErr: true},
+		{name: "part zero", parts: []UploadPart{{Number: 0, ETag: "a"}}, count: 1, expectedErr: true},
+		{name: "blank etag", parts: []UploadPart{{Number: 1, ETag: " "}}, count: 1, expectedErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUploadParts(tt.parts, tt.count)
+			if tt.expectedErr {
+				if !errors.Is(err, ErrInvalidUpload) {
+					t.Errorf("expected %v, got %v", ErrInvalidUpload, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			for i, part := range tt.parts {
+				if part.Number != int32(i+1) {
+					t.Errorf("expected the parts sorted by number, got %+v", tt.parts)
+				}
+			}
+		})
+	}
+}
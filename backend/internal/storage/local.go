@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultURLExpiry = time.Minute
+
+var (
+	// ErrInvalidSignature is returned for download links that were not signed by this server
+	ErrInvalidSignature = errors.New("invalid download signature")
+	// ErrLinkExpired is returned for download links past their expiry
+	ErrLinkExpired = errors.New("download link expired")
+
+	validKey = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]{0,199}$`)
+)
+
+// LocalStorage keeps objects on the local disk so the backend runs without S3. The tree is content
+// addressed, a blob is stored once under its sha256 however many keys point at it:
+//
+//	objects/ab/cd/<sha256>/data        the bytes
+//	objects/ab/cd/<sha256>/keys/<key>  one empty file per key using the blob
+//	refs/<key[:2]>/<key>               the sha256 of the key's blob
+//	tmp/                               uploads being written
+//
+// Downloads go through the backend at {publicURL}/files/{key} with an HMAC-signed expiry
+type LocalStorage struct {
+	root       string
+	publicURL  string
+	signingKey []byte
+	expiry     time.Duration
+	// uploads and deletes of a blob's keys must not interleave, a delete could remove a blob being linked
+	mu sync.Mutex
+}
+
+func NewLocalStorage(dir, publicURL, signingKey string, expiry time.Duration) (*LocalStorage, error) {
+	if dir == "" {
+		return nil, errors.New("local storage needs a directory")
+	}
+	if signingKey == "" {
+		return nil, errors.New("local storage needs a signing key for download links")
+	}
+	if expiry <= 0 {
+		expiry = defaultURLExpiry
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range []string{"objects", "refs", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, sub), 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create local storage: %w", err)
+		}
+	}
+	return &LocalStorage{
+		root:       root,
+		publicURL:  strings.TrimRight(publicURL, "/"),
+		signingKey: []byte(signingKey),
+		expiry:     expiry,
+	}, nil
+}
+
+func (l *LocalStorage) UploadObject(ctx context.Context, filename string, size int64, body io.Reader) (string, error) {
+	if err := checkKey(filename); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(l.root, "tmp"), "upload-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hasher), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write object: %w", err)
+	}
+	if size >= 0 && written != size {
+		return "", fmt.Errorf("object size mismatch: expected %d bytes, got %d", size, written)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// a key uploaded again now points at the new content, unlinked first so its old blob
+	// is not removed after the new one was put in place
+	if err := l.unlink(filename); err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+
+	blob := l.blobDir(hash)
+	if _, err := os.Stat(filepath.Join(blob, "data")); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Join(blob, "keys"), 0o750); err != nil {
+			return "", err
+		}
+		if err := os.Rename(tmp.Name(), filepath.Join(blob, "data")); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(blob, "keys", filename), nil, 0o640); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.refPath(filename)), 0o750); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(l.refPath(filename), []byte(hash), 0o640); err != nil {
+		return "", err
+	}
+	return l.publicURL + "/files/" + filename, nil
+}
+
+func (l *LocalStorage) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	return l.open(key)
+}
+
+func (l *LocalStorage) StatObject(ctx context.Context, key string) (int64, error) {
+	file, err := l.open(key)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (l *LocalStorage) DeleteObject(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// like S3, deleting a key that does not exist succeeds
+	if err := l.unlink(key); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// CreatePresidedURL returns a download link to the backend, it works until the expiry passes
+func (l *LocalStorage) CreatePresidedURL(ctx context.Context, key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(l.expiry).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {l.sign(key, expires)}}
+	return l.publicURL + "/files/" + url.PathEscape(key) + "?" + query.Encode(), nil
+}
+
+// OpenSigned opens the object of a download link after checking its signature and expiry
+func (l *LocalStorage) OpenSigned(key, expires, signature string) (*os.File, error) {
+	if err := checkKey(key); err != nil {
+		return nil, ErrNotFound
+	}
+	if !hmac.Equal([]byte(signature), []byte(l.sign(key, expires))) {
+		return nil, ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if time.Now().Unix() > unix {
+		return nil, ErrLinkExpired
+	}
+	return l.open(key)
+}
+
+func (l *LocalStorage) open(key string) (*os.File, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	hash, err := os.ReadFile(l.refPath(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(l.blobDir(string(hash)), "data"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// unlink removes a key and its blob once no key uses it, the caller holds the lock
+func (l *LocalStorage) unlink(key string) error {
+	hash, err := os.ReadFile(l.refPath(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return err
+	}
+	blob := l.blobDir(string(hash))
+	if err := os.Remove(filepath.Join(blob, "keys", key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(l.refPath(key)); err != nil {
+		return err
+	}
+
+	keys, err := os.ReadDir(filepath.Join(blob, "keys"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(keys) == 0 {
+		return os.RemoveAll(blob)
+	}
+	return nil
+}
+
+func (l *LocalStorage) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (l *LocalStorage) blobDir(hash string) string {
+	return filepath.Join(l.root, "objects", hash[:2], hash[2:4], hash)
+}
+
+func (l *LocalStorage) refPath(key string) string {
+	prefix := key
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return filepath.Join(l.root, "refs", prefix, key)
+}
+
+// checkKey keeps keys inside the storage tree, they are used as file names
+func checkKey(key string) error {
+	if !validKey.MatchString(key) {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func upload(t *testing.T, local *LocalStorage, key, content string) {
+	t.Helper()
+	if _, err := local.UploadObject(context.Background(), key, int64(len(content)), strings.NewReader(content)); err != nil {
+		t.Fatalf("failed to upload %s: %v", key, err)
+	}
+}
+
+func blobPath(local *LocalStorage, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return filepath.Join(local.blobDir(hex.EncodeToString(sum[:])), "data")
+}
+
+func TestLocalStorageSharesBlobs(t *testing.T) {
+	ctx := context.Background()
+	local := newTestStorage(t)
+
+	upload(t, local, "first", "same bytes")
+	upload(t, local, "second", "same bytes")
+
+	blobs, err := filepath.Glob(filepath.Join(local.root, "objects", "*", "*", "*", "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 || blobs[0] != blobPath(local, "same bytes") {
+		t.Fatalf("expected one blob for both keys, got %v", blobs)
+	}
+
+	if err := local.DeleteObject(ctx, "first"); err != nil {
+		t.Fatalf("failed to delete first: %v", err)
+	}
+	if _, err := local.GetObject(ctx, "first"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the deleted key to be gone, got %v", err)
+	}
+	if got := readObject(t, local, "second"); got != "same bytes" {
+		t.Errorf("expected the other key to keep the blob, got %q", got)
+	}
+
+	if err := local.DeleteObject(ctx, "second"); err != nil {
+		t.Fatalf("failed to delete second: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(blobPath(local, "same bytes"))); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the blob to be removed with its last key, got %v", err)
+	}
+	// like S3, deleting a missing key succeeds
+	if err := local.DeleteObject(ctx, "second"); err != nil {
+		t.Errorf("expected deleting a missing key to succeed, got %v", err)
+	}
+}
+
+func TestLocalStorageReupload(t *testing.T) {
+	local := newTestStorage(t)
+
+	upload(t, local, "notes", "old content")
+	upload(t, local, "copy", "old content")
+	upload(t, local, "notes", "new content")
+
+	if got := readObject(t, local, "notes"); got != "new content" {
+		t.Errorf("expected the new content, got %q", got)
+	}
+	if got := readObject(t, local, "copy"); got != "old content" {
+		t.Errorf("expected the other key to keep the old content, got %q", got)
+	}
+
+	upload(t, local, "copy", "new content")
+	if _, err := os.Stat(blobPath(local, "old content")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the old blob to be removed once no key uses it, got %v", err)
+	}
+	if _, err := os.Stat(blobPath(local, "new content")); err != nil {
+		t.Errorf("expected the new blob to stay, got %v", err)
+	}
+}
+
+func TestLocalStorageUploadSizeMismatch(t *testing.T) {
+	local := newTestStorage(t)
+	if _, err := local.UploadObject(context.Background(), "short", 10, strings.NewReader("abc")); err == nil {
+		t.Fatal("expected a size mismatch error")
+	}
+	if _, err := local.GetObject(context.Background(), "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected nothing stored, got %v", err)
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"6f1c2a9e-0d3b-4c1a-9b8e-2f4a6c8e0b1d", true},
+		{"notes.v2_final-1", true},
+		{"../x", false},
+		{"a/b", false},
+		{".hidden", false},
+		{"", false},
+		{"line\nbreak", false},
+		{strings.Repeat("a", 201), false},
+	}
+	for _, tt := range tests {
+		if err := checkKey(tt.key); (err == nil) != tt.valid {
+			t.Errorf("checkKey(%q) = %v, want valid %v", tt.key, err, tt.valid)
+		}
+	}
+
+	local := newTestStorage(t)
+	if _, err := local.UploadObject(context.Background(), "../x", 1, strings.NewReader("x")); err == nil {
+		t.Error("expected an upload to ../x to be rejected")
+	}
+	if _, err := local.GetObject(context.Background(), "../x"); err == nil {
+		t.Error("expected a read of ../x to be rejected")
+	}
+}
+
+func TestOpenSigned(t *testing.T) {
+	local := newTestStorage(t)
+	upload(t, local, "slides", "pdf bytes")
+
+	link, err := local.CreatePresidedURL(context.Background(), "slides")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	file, err := local.OpenSigned("slides", expires, signature)
+	if err != nil {
+		t.Fatalf("expected the signed link to open, got %v", err)
+	}
+	_ = file.Close()
+
+	if _, err := local.OpenSigned("other", expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected the signature to be bound to its key, got %v", err)
+	}
+	if _, err := local.OpenSigned("slides", "1", local.sign("slides", "1")); !errors.Is(err, ErrLinkExpired) {
+		t.Errorf("expected an expired link to fail, got %v", err)
+	}
+	if _, err := local.OpenSigned("../x", expires, signature); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an invalid key to be not found, got %v", err)
+	}
+}
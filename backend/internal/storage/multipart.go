@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 numbers parts from 1 to 10000, local uploads take the same
+const maxPartNumber = 10000
+
+var validUploadID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Multipart uploads of LocalStorage live in tmp/multipart/<upload id>/ until they are completed: a "key"
+// file with the object key and a file per part next to its ETag. The client puts the parts through the
+// backend at {publicURL}/files/uploads/{upload id}/{part} with an HMAC-signed expiry, like a download
+
+func (l *LocalStorage) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(id)
+	if err := os.MkdirAll(l.uploadDir(uploadID), 0o750); err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(l.uploadDir(uploadID), "key"), []byte(key), 0o640); err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return uploadID, nil
+}
+
+// PresignUploadPart returns the url the client puts one part to, it works until the expiry passes
+func (l *LocalStorage) PresignUploadPart(ctx context.Context, key, uploadID string, part int32, expiry time.Duration) (string, error) {
+	if _, err := l.uploadKey(uploadID); err != nil {
+		return "", err
+	}
+	if part < 1 || part > maxPartNumber {
+		return "", fmt.Errorf("invalid part number %d", part)
+	}
+	number := strconv.Itoa(int(part))
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {l.sign(partSubject(uploadID, number), expires)}}
+	return l.publicURL + "/files/uploads/" + uploadID + "/" + number + "?" + query.Encode(), nil
+}
+
+// WriteSignedPart stores a part put to a presigned url and returns its ETag, a part put again replaces the old one
+func (l *LocalStorage) WriteSignedPart(uploadID, part, expires, signature string, body io.Reader) (string, error) {
+	if !hmac.Equal([]byte(signature), []byte(l.sign(partSubject(uploadID, part), expires))) {
+		return "", ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	if time.Now().Unix() > unix {
+		return "", ErrLinkExpired
+	}
+	if number, err := strconv.Atoi(part); err != nil || number < 1 || number > maxPartNumber || strconv.Itoa(number) != part {
+		return "", fmt.Errorf("%w: part %s", ErrNotFound, part)
+	}
+	if _, err := l.uploadKey(uploadID); err != nil {
+		return "", err
+	}
+
+	dir := l.uploadDir(uploadID)
+	tmp, err := os.CreateTemp(dir, "part-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	hasher := md5.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write part: %w", err)
+	}
+
+	// the same quoted md5 S3 answers with
+	etag := `"` + hex.EncodeToString(hasher.Sum(nil)) + `"`
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, part)); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, part+".etag"), []byte(etag), 0o640); err != nil {
+		return "", err
+	}
+	return etag, nil
+}
+
+// CompleteMultipartUpload joins the parts in the given order into the object, every ETag must match the stored part
+func (l *LocalStorage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []UploadPart) (string, error) {
+	uploadKey, err := l.uploadKey(uploadID)
+	if err != nil {
+		return "", err
+	}
+	if uploadKey != key {
+		return "", fmt.Errorf("multipart upload %s is not for %s", uploadID, key)
+	}
+	if len(parts) == 0 {
+		return "", errors.New("multipart upload needs at least one part")
+	}
+
+	dir := l.uploadDir(uploadID)
+	readers := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		number := strconv.Itoa(int(part.Number))
+		etag, err := os.ReadFile(filepath.Join(dir, number+".etag"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return "", fmt.Errorf("part %d was not uploaded", part.Number)
+			}
+			return "", err
+		}
+		if strings.Trim(string(etag), `"`) != strings.Trim(part.ETag, `"`) {
+			return "", fmt.Errorf("part %d does not match its etag", part.Number)
+		}
+		file, err := os.Open(filepath.Join(dir, number))
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = file.Close() }()
+		readers = append(readers, file)
+	}
+
+	url, err := l.UploadObject(ctx, key, -1, io.MultiReader(readers...))
+	if err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// AbortMultipartUpload drops the parts uploaded so far, aborting an unknown upload succeeds like on S3
+func (l *LocalStorage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	if !validUploadID.MatchString(uploadID) {
+		return fmt.Errorf("invalid upload id %q", uploadID)
+	}
+	return os.RemoveAll(l.uploadDir(uploadID))
+}
+
+// uploadKey returns the object key of a pending multipart upload
+func (l *LocalStorage) uploadKey(uploadID string) (string, error) {
+	if !validUploadID.MatchString(uploadID) {
+		return "", fmt.Errorf("%w: upload %s", ErrNotFound, uploadID)
+	}
+	key, err := os.ReadFile(filepath.Join(l.uploadDir(uploadID), "key"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: upload %s", ErrNotFound, uploadID)
+		}
+		return "", err
+	}
+	return string(key), nil
+}
+
+func (l *LocalStorage) uploadDir(uploadID string) string {
+	return filepath.Join(l.root, "tmp", "multipart", uploadID)
+}
+
+// partSubject is what a part url signs, object keys can not contain a line break so it never passes as a download
+func partSubject(uploadID, part string) string {
+	return "upload\n" + uploadID + "\n" + part
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T) *LocalStorage {
+	t.Helper()
+	local, err := NewLocalStorage(t.TempDir(), "http://backend", "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	return local
+}
+
+// putPart writes a part through its presigned url the way the upload handler does
+func putPart(t *testing.T, local *LocalStorage, key, uploadID string, part int32, body string) string {
+	t.Helper()
+	presigned, err := local.PresignUploadPart(context.Background(), key, uploadID, part, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to presign part %d: %v", part, err)
+	}
+	u, err := url.Parse(presigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	etag, err := local.WriteSignedPart(uploadID, path.Base(u.Path), query.Get("expires"), query.Get("signature"), strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to write part %d: %v", part, err)
+	}
+	return etag
+}
+
+func readObject(t *testing.T, local *LocalStorage, key string) string {
+	t.Helper()
+	object, err := local.GetObject(context.Background(), key)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", key, err)
+	}
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLocalMultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	local := newTestStorage(t)
+
+	uploadID, err := local.CreateMultipartUpload(ctx, "lecture")
+	if err != nil {
+		t.Fatalf("failed to create upload: %v", err)
+	}
+	first := putPart(t, local, "lecture", uploadID, 1, "first part, ")
+	if sum := md5.Sum([]byte("first part, ")); first != `"`+hex.EncodeToString(sum[:])+`"` {
+		t.Errorf("expected the quoted md5 as etag, got %s", first)
+	}
+	second := putPart(t, local, "lecture", uploadID, 2, "second part")
+
+	url, err := local.CompleteMultipartUpload(ctx, "lecture", uploadID, []UploadPart{{Number: 1, ETag: first}, {Number: 2, ETag: second}})
+	if err != nil {
+		t.Fatalf("failed to complete upload: %v", err)
+	}
+	if url != "http://backend/files/lecture" {
+		t.Errorf("unexpected url %s", url)
+	}
+	if got := readObject(t, local, "lecture"); got != "first part, second part" {
+		t.Errorf("expected the joined parts, got %q", got)
+	}
+	size, err := local.StatObject(ctx, "lecture")
+	if err != nil || size != int64(len("first part, second part")) {
+		t.Errorf("StatObject() = %d, %v", size, err)
+	}
+	if _, err := os.Stat(local.uploadDir(uploadID)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the parts to be removed, got %v", err)
+	}
+}
+
+func TestLocalMultipartUploadRejects(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		key         string
+		parts       func(first string) []UploadPart
+		expectedMsg string
+	}{
+		{
+			name:        "etag mismatch",
+			key:         "lecture",
+			parts:       func(first string) []UploadPart { return []UploadPart{{Number: 1, ETag: `"0123"`}} },
+			expectedMsg: "part 1 does not match its etag",
+		},
+		{
+			name: "missing part",
+			key:  "lecture",
+			parts: func(first string) []UploadPart {
+				return []UploadPart{{Number: 1, ETag: first}, {Number: 2, ETag: first}}
+			},
+			expectedMsg: "part 2 was not uploaded",
+		},
+		{
+			name:        "no parts",
+			key:         "lecture",
+			parts:       func(first string) []UploadPart { return nil },
+			expectedMsg: "at least one part",
+		},
+		{
+			name:        "another key",
+			key:         "other",
+			parts:       func(first string) []UploadPart { return []UploadPart{{Number: 1, ETag: first}} },
+			expectedMsg: "is not for other",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := newTestStorage(t)
+			uploadID, err := local.CreateMultipartUpload(ctx, "lecture")
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := putPart(t, local, "lecture", uploadID, 1, "only part")
+
+			_, err = local.CompleteMultipartUpload(ctx, tt.key, uploadID, tt.parts(first))
+			if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+				t.Fatalf("expected error containing %q, got %v", tt.expectedMsg, err)
+			}
+			if _, err := local.GetObject(ctx, "lecture"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected no object after a failed completion, got %v", err)
+			}
+			// the parts are kept, the client can complete again with the right list
+			if _, err := local.CompleteMultipartUpload(ctx, "lecture", uploadID, []UploadPart{{Number: 1, ETag: first}}); err != nil {
+				t.Errorf("expected a retry to complete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteSignedPartRejects(t *testing.T) {
+	local := newTestStorage(t)
+	uploadID, err := local.CreateMultipartUpload(context.Background(), "lecture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := "9999999999"
+	past := "1"
+
+	tests := []struct {
+		name      string
+		uploadID  string
+		part      string
+		expires   string
+		signature string
+		expected  error
+	}{
+		{"bad signature", uploadID, "1", future, "deadbeef", ErrInvalidSignature},
+		{"expired", uploadID, "1", past, local.sign(partSubject(uploadID, "1"), past), ErrLinkExpired},
+		{"part out of range", uploadID, "10001", future, local.sign(partSubject(uploadID, "10001"), future), ErrNotFound},
+		{"part with leading zero", uploadID, "01", future, local.sign(partSubject(uploadID, "01"), future), ErrNotFound},
+		{"unknown upload", strings.Repeat("a", 32), "1", future, local.sign(partSubject(strings.Repeat("a", 32), "1"), future), ErrNotFound},
+		{"download signature", uploadID, "1", future, local.sign(uploadID, future), ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := local.WriteSignedPart(tt.uploadID, tt.part, tt.expires, tt.signature, strings.NewReader("data"))
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestAbortMultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	local := newTestStorage(t)
+	uploadID, err := local.CreateMultipartUpload(ctx, "lecture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	putPart(t, local, "lecture", uploadID, 1, "part")
+
+	if err := local.AbortMultipartUpload(ctx, "lecture", uploadID); err != nil {
+		t.Fatalf("failed to abort: %v", err)
+	}
+	if _, err := local.PresignUploadPart(ctx, "lecture", uploadID, 2, time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an aborted upload to be gone, got %v", err)
+	}
+	// like S3, aborting again succeeds
+	if err := local.AbortMultipartUpload(ctx, "lecture", uploadID); err != nil {
+		t.Errorf("expected a second abort to succeed, got %v", err)
+	}
+}
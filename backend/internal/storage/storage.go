@@ -0,0 +1,98 @@
+// Package storage picks where uploaded files live: S3, an S3-compatible server such as MinIO, or the local disk
+package storage
+
+import (
+	"StudyHub/internal/aws"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for keys that have no object
+var ErrNotFound = errors.New("object not found")
+
+// UploadPart is a part the client uploaded, with the ETag storage answered the PUT with
+type UploadPart struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// Storage is what the resource and content services need from a storage driver
+type Storage interface {
+	UploadObject(ctx context.Context, filename string, size int64, body io.Reader) (string, error)
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+	// StatObject returns the size of an object without reading it
+	StatObject(ctx context.Context, key string) (int64, error)
+	DeleteObject(ctx context.Context, key string) error
+	CreatePresidedURL(ctx context.Context, key string) (string, error)
+
+	CreateMultipartUpload(ctx context.Context, key string) (string, error)
+	PresignUploadPart(ctx context.Context, key, uploadID string, part int32, expiry time.Duration) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []UploadPart) (string, error)
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+}
+
+// Config selects a driver, only the fields of the chosen driver are read
+type Config struct {
+	Driver string // s3, minio or local
+
+	// s3 and minio
+	S3 aws.S3Config
+
+	// local
+	Dir        string        // root of the object tree
+	PublicURL  string        // base url of the backend, download links point at {PublicURL}/files/{key}
+	SigningKey string        // signs download links
+	URLExpiry  time.Duration // how long a download link works
+}
+
+// New builds the driver named in cfg
+func New(ctx context.Context, cfg Config) (Storage, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Driver)) {
+	case "", "s3":
+		return newS3(ctx, cfg.S3)
+	case "minio":
+		cfg.S3.UsePathStyle = true
+		if cfg.S3.Endpoint == "" {
+			return nil, errors.New("minio storage needs an endpoint")
+		}
+		if cfg.S3.Region == "" {
+			// MinIO ignores the region but the client will not sign without one
+			cfg.S3.Region = "us-east-1"
+		}
+		return newS3(ctx, cfg.S3)
+	case "local":
+		local, err := NewLocalStorage(cfg.Dir, cfg.PublicURL, cfg.SigningKey, cfg.URLExpiry)
+		if err != nil {
+			return nil, err
+		}
+		return local, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, expected s3, minio or local", cfg.Driver)
+	}
+}
+
+// newS3 keeps a failed client from turning into a non-nil Storage holding a nil pointer
+func newS3(ctx context.Context, cfg aws.S3Config) (Storage, error) {
+	client, err := aws.NewS3Storage(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s3Storage{client}, nil
+}
+
+// s3Storage hands the parts to the aws client in its own type, the aws package does not import storage
+type s3Storage struct {
+	*aws.S3Storage
+}
+
+func (s s3Storage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []UploadPart) (string, error) {
+	completed := make([]aws.CompletedPart, len(parts))
+	for i, part := range parts {
+		completed[i] = aws.CompletedPart{Number: part.Number, ETag: part.ETag}
+	}
+	return s.S3Storage.CompleteMultipartUpload(ctx, key, uploadID, completed)
+}
@@ -0,0 +1,315 @@
+// Package tus keeps the partial files of resumable uploads (https://tus.io) on the local disk until they
+// are complete and can be stored like any other upload
+package tus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultMaxSize = 2 << 30
+	defaultExpiry  = 24 * time.Hour
+)
+
+var (
+	ErrNotFound = errors.New("upload not found")
+	// ErrExpired is returned for uploads that were not resumed in time, their chunks are gone
+	ErrExpired = errors.New("upload expired")
+	// ErrOffsetMismatch is returned for chunks that do not start where the upload stopped
+	ErrOffsetMismatch = errors.New("upload offset does not match")
+	// ErrTooLarge is returned for uploads over the size limit and chunks past the upload length
+	ErrTooLarge = errors.New("upload is too large")
+	// ErrLocked is returned while another request writes to the upload
+	ErrLocked = errors.New("upload is locked by another request")
+
+	validID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+type Config struct {
+	Dir     string        // partial uploads, one .info and one .bin file each
+	MaxSize int64         // largest upload accepted
+	Expiry  time.Duration // uploads without a chunk for this long are removed
+}
+
+// Upload is a resumable upload, Offset is how many bytes of Length arrived so far
+type Upload struct {
+	ID        string            `json:"id"`
+	UserID    uuid.UUID         `json:"user_id"`
+	WeekID    uuid.UUID         `json:"week_id"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"-"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"-"`
+}
+
+// Complete reports whether every byte of the upload arrived
+func (u Upload) Complete() bool {
+	return u.Offset == u.Length
+}
+
+// Store keeps uploads as {id}.info, the JSON of the upload, and {id}.bin, the bytes received so far.
+// The offset is the size of the .bin file and an upload expires Expiry after its last chunk
+type Store struct {
+	dir     string
+	maxSize int64
+	expiry  time.Duration
+
+	mu   sync.Mutex
+	busy map[string]bool
+}
+
+func NewStore(cfg Config) (*Store, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("tus store needs a directory")
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultMaxSize
+	}
+	if cfg.Expiry <= 0 {
+		cfg.Expiry = defaultExpiry
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create tus store: %w", err)
+	}
+	return &Store{dir: cfg.Dir, maxSize: cfg.MaxSize, expiry: cfg.Expiry, busy: make(map[string]bool)}, nil
+}
+
+func (s *Store) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Create starts an empty upload of length bytes
+func (s *Store) Create(userID, weekID uuid.UUID, length int64, metadata map[string]string) (Upload, error) {
+	if length <= 0 {
+		return Upload{}, errors.New("upload length must be positive")
+	}
+	if length > s.maxSize {
+		return Upload{}, fmt.Errorf("%w: the limit is %d bytes", ErrTooLarge, s.maxSize)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Upload{}, err
+	}
+
+	upload := Upload{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		WeekID:    weekID,
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: time.Now().UTC(),
+	}
+	info, err := json.Marshal(upload)
+	if err != nil {
+		return Upload{}, err
+	}
+	if err := os.WriteFile(s.binPath(upload.ID), nil, 0o640); err != nil {
+		return Upload{}, fmt.Errorf("failed to create upload: %w", err)
+	}
+	if err := os.WriteFile(s.infoPath(upload.ID), info, 0o640); err != nil {
+		_ = os.Remove(s.binPath(upload.ID))
+		return Upload{}, fmt.Errorf("failed to create upload: %w", err)
+	}
+	upload.ExpiresAt = time.Now().Add(s.expiry)
+	return upload, nil
+}
+
+// Get returns an upload with its current offset, an expired upload is removed
+func (s *Store) Get(id string) (Upload, error) {
+	if !validID.MatchString(id) {
+		return Upload{}, ErrNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Upload{}, ErrNotFound
+		}
+		return Upload{}, err
+	}
+	var upload Upload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return Upload{}, fmt.Errorf("failed to read upload %s: %w", id, err)
+	}
+
+	stat, err := os.Stat(s.binPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Upload{}, ErrNotFound
+		}
+		return Upload{}, err
+	}
+	upload.Offset = stat.Size()
+	upload.ExpiresAt = stat.ModTime().Add(s.expiry)
+	if time.Now().After(upload.ExpiresAt) {
+		if err := s.Remove(id); err != nil {
+			slog.Error("failed to remove expired upload", "upload id", id, "err", err)
+		}
+		return Upload{}, ErrExpired
+	}
+	return upload, nil
+}
+
+// Lock keeps other requests off the upload until release is called, it fails with ErrLocked instead of waiting
+func (s *Store) Lock(id string) (release func(), err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy[id] {
+		return nil, ErrLocked
+	}
+	s.busy[id] = true
+	return func() {
+		s.mu.Lock()
+		delete(s.busy, id)
+		s.mu.Unlock()
+	}, nil
+}
+
+// Append writes a chunk at offset, which must be the current offset of the upload. The caller holds the lock.
+// Bytes written before the body fails are kept, the client resumes after them
+func (s *Store) Append(upload Upload, offset int64, body io.Reader) (Upload, error) {
+	if offset != upload.Offset {
+		return upload, fmt.Errorf("%w: the upload is at %d, the chunk starts at %d", ErrOffsetMismatch, upload.Offset, offset)
+	}
+
+	file, err := os.OpenFile(s.binPath(upload.ID), os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return upload, ErrNotFound
+		}
+		return upload, err
+	}
+	// one byte more than the upload misses shows a chunk that runs past its end
+	written, err := io.Copy(file, io.LimitReader(body, upload.Length-upload.Offset+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if upload.Offset+written > upload.Length {
+		// the whole chunk is dropped, its end can not be trusted
+		if truncErr := os.Truncate(s.binPath(upload.ID), upload.Offset); truncErr != nil {
+			return upload, truncErr
+		}
+		written = 0
+		err = fmt.Errorf("%w: the chunk runs past the upload length %d", ErrTooLarge, upload.Length)
+	}
+	upload.Offset += written
+	upload.ExpiresAt = time.Now().Add(s.expiry)
+	return upload, err
+}
+
+// Open returns the bytes of an upload
+func (s *Store) Open(id string) (*os.File, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	return os.Open(s.binPath(id))
+}
+
+// Remove deletes an upload, removing one that does not exist succeeds
+func (s *Store) Remove(id string) error {
+	if !validID.MatchString(id) {
+		return ErrNotFound
+	}
+	for _, path := range []string{s.infoPath(id), s.binPath(id)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveExpired deletes the uploads that were not resumed in time and returns how many it removed
+func (s *Store) RemoveExpired() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".info")
+		if !ok {
+			continue
+		}
+		release, err := s.Lock(id)
+		if err != nil {
+			continue
+		}
+		if _, err := s.Get(id); errors.Is(err, ErrExpired) {
+			removed++
+		}
+		release()
+	}
+	return removed, nil
+}
+
+// StartCleanup removes expired uploads every interval until ctx is done
+func (s *Store) StartCleanup(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				removed, err := s.RemoveExpired()
+				if err != nil {
+					slog.Error("failed to remove expired uploads", "err", err)
+					continue
+				}
+				if removed > 0 {
+					slog.Info("removed expired uploads", "count", removed)
+				}
+			}
+		}
+	}()
+}
+
+// ParseMetadata reads an Upload-Metadata header: comma separated pairs of a key and a base64 value,
+// the value may be left out
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata value for %q: %w", fields[0], err)
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("invalid metadata pair %q", strings.TrimSpace(pair))
+		}
+	}
+	return metadata, nil
+}
+
+func (s *Store) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+func (s *Store) binPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
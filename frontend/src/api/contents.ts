@@ -1,5 +1,5 @@
 import apiClient from './client'
-import type { Flashcard } from '@/types'
+import type { Flashcard, QuizQuestion, ResourceSummary } from '@/types'
 
 export const contentsApi = {
   getFlashcards: async (objectIds: string[]): Promise<Flashcard[]> => {
@@ -8,4 +8,14 @@ export const contentsApi = {
     const response = await apiClient.post('/conents/objects', { ids: objectIds })
     return response.data
   },
+
+  getSummaries: async (objectIds: string[]): Promise<ResourceSummary[]> => {
+    const response = await apiClient.post('/conents/objects/summaries', { ids: objectIds })
+    return response.data
+  },
+
+  getQuizzes: async (objectIds: string[]): Promise<QuizQuestion[]> => {
+    const response = await apiClient.post('/conents/objects/quizzes', { ids: objectIds })
+    return response.data
+  },
 }
@@ -21,7 +21,7 @@ export const decksApi = {
   },
 
   // Create custom card
-  createCustomCard: async (weekId: string, request: CreateCustomCardRequest): Promise<UserDeckCard> => {
+  createCustomCard: async (weekId: string, request: CreateCustomCardRequest): Promise<UserDeckCard[]> => {
     const response = await apiClient.post(`/decks/weeks/${weekId}/cards/custom`, request)
     return response.data
   },
@@ -1,5 +1,5 @@
 import apiClient from './client'
-import type { Resource, UserResource } from '@/types'
+import type { HashCheck, Resource, StartedUpload, StorageUsage, UploadPart, UploadSession, UserResource } from '@/types'
 
 export const resourcesApi = {
   getResourcesByWeek: async (weekId: string): Promise<Resource[]> => {
@@ -15,6 +15,55 @@ export const resourcesApi = {
     })
   },
 
+  // checkHash asks whether a file is stored already, with a week and a name a stored file is added to the week
+  checkHash: async (sha256: string, weekId?: string, name?: string): Promise<HashCheck> => {
+    const response = await apiClient.post<HashCheck>('/resources/check-hash', {
+      sha256,
+      week_id: weekId,
+      name,
+    })
+    return response.data
+  },
+
+  // getUsage returns the bytes the user's uploads take next to their quota and the upload limits
+  getUsage: async (): Promise<StorageUsage> => {
+    const response = await apiClient.get<StorageUsage>('/resources/usage')
+    return response.data
+  },
+
+  // uploadDirect puts the file straight into storage part by part, the backend only creates the resource
+  uploadDirect: async (weekId: string, file: File, name = file.name): Promise<UploadSession> => {
+    const fileType = file.name.split('.').pop()?.toLowerCase() ?? ''
+    const started = await apiClient.post<StartedUpload>('/resources/uploads', {
+      week_id: weekId,
+      name,
+      file_type: fileType,
+      size: file.size,
+    })
+    const upload = started.data
+
+    try {
+      const parts: UploadPart[] = []
+      for (const part of upload.parts) {
+        const start = (part.number - 1) * upload.part_size
+        const response = await fetch(part.url, {
+          method: 'PUT',
+          body: file.slice(start, start + upload.part_size),
+        })
+        if (!response.ok) {
+          throw new Error(`Failed to upload part ${part.number}`)
+        }
+        parts.push({ number: part.number, etag: response.headers.get('ETag') ?? '' })
+      }
+
+      const completed = await apiClient.post<UploadSession>(`/resources/uploads/${upload.id}/complete`, { parts })
+      return completed.data
+    } catch (error) {
+      await apiClient.delete(`/resources/uploads/${upload.id}`).catch(() => undefined)
+      throw error
+    }
+  },
+
   uploadLink: async (weekId: string, name: string, url: string): Promise<void> => {
     await apiClient.post(`/resources/link/${weekId}`, {
       name,
@@ -22,6 +71,13 @@ export const resourcesApi = {
     })
   },
 
+  createNote: async (weekId: string, name: string, content: string): Promise<void> => {
+    await apiClient.post(`/resources/note/${weekId}`, {
+      name,
+      content,
+    })
+  },
+
   downloadResource: async (objectId: string): Promise<void> => {
     try {
       // Backend now returns the presigned URL as JSON
@@ -102,6 +102,44 @@ export interface UserResource {
   CreatedAt: string
 }
 
+export interface HashCheck {
+  exists: boolean
+  in_week: boolean
+  resource_id?: string
+}
+
+export interface StorageUsage {
+  used_bytes: number
+  quota_bytes: number
+  objects: number
+  max_file_size: number
+  allowed_types: string[]
+}
+
+export interface UploadSession {
+  id: string
+  week_id: string
+  name: string
+  file_type: string
+  size: number
+  sha256?: string
+  part_size: number
+  part_count: number
+  status: 'pending' | 'completed' | 'aborted'
+  resource_id?: string
+  expires_at: string
+  created_at: string
+}
+
+export interface UploadPart {
+  number: number
+  etag: string
+}
+
+export interface StartedUpload extends UploadSession {
+  parts: { number: number; url: string }[]
+}
+
 // User types
 export interface User {
   ID: string
@@ -138,6 +176,35 @@ export interface Flashcard {
   WeekID: string | null
   Front: string
   Back: string
+  PageStart: number | null
+  PageEnd: number | null
+}
+
+// Generated summary and quiz types
+export interface SummaryKeyPoint {
+  text: string
+  page_start?: number
+  page_end?: number
+}
+
+export interface ResourceSummary {
+  storage_object_id: string
+  key_points: SummaryKeyPoint[]
+  glossary: { term: string; definition: string }[]
+  created_at: string
+  updated_at: string
+}
+
+export interface QuizQuestion {
+  id: string
+  storage_object_id: string
+  position: number
+  question: string
+  options: string[]
+  correct_option: number
+  explanation: string
+  page_start?: number
+  page_end?: number
 }
 
 // User Deck Card types
@@ -146,6 +213,9 @@ export interface UserDeckCard {
   UserID: string
   WeekID: string
   SourceFlashcardID: string | null
+  SourceObjectID: string | null
+  PageStart: number | null
+  PageEnd: number | null
   Front: string
   Back: string
   IsCustom: boolean
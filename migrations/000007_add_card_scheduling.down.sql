@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS user_study_settings;
+DROP INDEX IF EXISTS idx_user_deck_cards_user_due;
+ALTER TABLE user_deck_cards
+    DROP COLUMN IF EXISTS due_at,
+    DROP COLUMN IF EXISTS fsrs_difficulty,
+    DROP COLUMN IF EXISTS stability,
+    DROP COLUMN IF EXISTS lapses,
+    DROP COLUMN IF EXISTS repetitions,
+    DROP COLUMN IF EXISTS interval_days,
+    DROP COLUMN IF EXISTS ease_factor;
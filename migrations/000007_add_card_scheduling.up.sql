@@ -0,0 +1,17 @@
+ALTER TABLE user_deck_cards
+    ADD COLUMN IF NOT EXISTS ease_factor DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+    ADD COLUMN IF NOT EXISTS interval_days INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS repetitions INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS lapses INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS stability DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS fsrs_difficulty DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS due_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_user_deck_cards_user_due ON user_deck_cards(user_id, due_at);
+
+CREATE TABLE IF NOT EXISTS user_study_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    scheduler TEXT NOT NULL DEFAULT 'sm2' CHECK (scheduler IN ('sm2', 'fsrs')),
+    desired_retention DOUBLE PRECISION NOT NULL DEFAULT 0.9 CHECK (desired_retention > 0 AND desired_retention < 1),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
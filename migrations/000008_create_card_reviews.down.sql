@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_card_reviews_user;
+DROP INDEX IF EXISTS idx_card_reviews_card;
+DROP TABLE IF EXISTS card_reviews;
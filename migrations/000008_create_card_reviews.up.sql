@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS card_reviews (
+    id UUID PRIMARY KEY,
+    card_id UUID REFERENCES user_deck_cards(id) ON DELETE SET NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    week_id UUID NOT NULL REFERENCES weeks(id) ON DELETE CASCADE,
+    scheduler TEXT NOT NULL,
+    difficulty_rating INT NOT NULL CHECK (difficulty_rating BETWEEN 1 AND 5),
+    recalled BOOLEAN NOT NULL,
+    response_time_ms INT CHECK (response_time_ms >= 0),
+    previous_interval_days INT NOT NULL,
+    next_interval_days INT NOT NULL,
+    reviewed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_card_reviews_card ON card_reviews(card_id, reviewed_at DESC);
+CREATE INDEX idx_card_reviews_user ON card_reviews(user_id, reviewed_at DESC);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_shared_deck_subscriptions_user;
+DROP TABLE IF EXISTS shared_deck_subscriptions;
+
+DROP INDEX IF EXISTS idx_flashcards_shared_author_card;
+ALTER TABLE flashcards
+    DROP COLUMN IF EXISTS shared_version,
+    DROP COLUMN IF EXISTS author_card_id,
+    DROP COLUMN IF EXISTS shared_deck_id;
+
+DROP INDEX IF EXISTS idx_shared_decks_week;
+DROP TABLE IF EXISTS shared_decks;
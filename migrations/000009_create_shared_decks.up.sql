@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS shared_decks (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    week_id UUID NOT NULL REFERENCES weeks(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    version INT NOT NULL DEFAULT 1,
+    published_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(owner_id, week_id)
+);
+
+CREATE INDEX idx_shared_decks_week ON shared_decks(week_id);
+
+-- published cards are snapshots of the author's deck cards, stored as flashcards so subscribers
+-- receive them like any other flashcard and keep the lineage in user_deck_cards.source_flashcard_id
+ALTER TABLE flashcards
+    ADD COLUMN shared_deck_id UUID REFERENCES shared_decks(id) ON DELETE CASCADE,
+    ADD COLUMN author_card_id UUID REFERENCES user_deck_cards(id) ON DELETE SET NULL,
+    ADD COLUMN shared_version INT;
+
+CREATE UNIQUE INDEX idx_flashcards_shared_author_card ON flashcards(shared_deck_id, author_card_id);
+
+CREATE TABLE IF NOT EXISTS shared_deck_subscriptions (
+    shared_deck_id UUID NOT NULL REFERENCES shared_decks(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    synced_version INT NOT NULL,
+    subscribed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (shared_deck_id, user_id)
+);
+
+CREATE INDEX idx_shared_deck_subscriptions_user ON shared_deck_subscriptions(user_id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_user_deck_cards_note;
+ALTER TABLE user_deck_cards
+    DROP COLUMN IF EXISTS note_id,
+    DROP COLUMN IF EXISTS content,
+    DROP COLUMN IF EXISTS card_type;
+
+ALTER TABLE flashcards
+    DROP COLUMN IF EXISTS content,
+    DROP COLUMN IF EXISTS card_type;
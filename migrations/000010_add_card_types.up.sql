@@ -0,0 +1,13 @@
+-- content holds the structured payload of non-basic cards (cloze text, multiple-choice options,
+-- image object and occlusion regions). front/back stay filled with a plain-text rendering.
+ALTER TABLE flashcards
+    ADD COLUMN card_type TEXT NOT NULL DEFAULT 'basic' CHECK (card_type IN ('basic', 'cloze', 'multiple_choice', 'image')),
+    ADD COLUMN content JSONB;
+
+-- cards generated from the same note (one per cloze deletion or occlusion region) share a note_id
+ALTER TABLE user_deck_cards
+    ADD COLUMN card_type TEXT NOT NULL DEFAULT 'basic' CHECK (card_type IN ('basic', 'cloze', 'multiple_choice', 'image')),
+    ADD COLUMN content JSONB,
+    ADD COLUMN note_id UUID;
+
+CREATE INDEX idx_user_deck_cards_note ON user_deck_cards(note_id);
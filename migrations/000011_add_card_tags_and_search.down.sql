@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_user_deck_cards_tags;
+DROP INDEX IF EXISTS idx_user_deck_cards_search;
+ALTER TABLE user_deck_cards
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS tags;
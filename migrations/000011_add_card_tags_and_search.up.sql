@@ -0,0 +1,9 @@
+-- tags are normalised to lowercase by the service, search_vector weights fronts above backs
+ALTER TABLE user_deck_cards
+    ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', front), 'A') || setweight(to_tsvector('english', back), 'B')
+    ) STORED;
+
+CREATE INDEX idx_user_deck_cards_search ON user_deck_cards USING GIN (search_vector);
+CREATE INDEX idx_user_deck_cards_tags ON user_deck_cards USING GIN (tags);
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS exams (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    module_run_id UUID NOT NULL REFERENCES module_runs(id) ON DELETE CASCADE,
+    source TEXT NOT NULL CHECK (source IN ('flashcards', 'deck', 'both')),
+    status TEXT NOT NULL DEFAULT 'in_progress' CHECK (status IN ('in_progress', 'completed', 'expired')),
+    question_count INT NOT NULL CHECK (question_count > 0),
+    duration_seconds INT NOT NULL CHECK (duration_seconds > 0),
+    correct_count INT,
+    score DOUBLE PRECISION,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_exams_user ON exams(user_id, started_at DESC);
+
+-- questions keep a copy of the card so an attempt can still be reviewed after the card changes or is deleted.
+-- Every question is multiple choice, options holds the shuffled answers and correct_option indexes into it.
+CREATE TABLE IF NOT EXISTS exam_questions (
+    id UUID PRIMARY KEY,
+    exam_id UUID NOT NULL REFERENCES exams(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    week_id UUID REFERENCES weeks(id) ON DELETE SET NULL,
+    week_number INT NOT NULL,
+    flashcard_id UUID REFERENCES flashcards(id) ON DELETE SET NULL,
+    deck_card_id UUID REFERENCES user_deck_cards(id) ON DELETE SET NULL,
+    card_type TEXT NOT NULL,
+    prompt TEXT NOT NULL,
+    content JSONB,
+    options JSONB NOT NULL,
+    correct_option INT NOT NULL,
+    selected_option INT,
+    correct BOOLEAN,
+    answered_at TIMESTAMP,
+    UNIQUE (exam_id, position)
+);
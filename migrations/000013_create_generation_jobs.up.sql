@@ -0,0 +1,23 @@
+-- one row per run of the flashcard-generation pipeline over a storage object, the worker moves it through
+-- the stages and records the error when a stage fails
+CREATE TABLE IF NOT EXISTS generation_jobs (
+    id UUID PRIMARY KEY,
+    storage_object_id UUID NOT NULL REFERENCES storage_objects(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'converting', 'generating', 'parsing', 'saved', 'failed')),
+    error TEXT,
+    card_count INT,
+    attempts INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_generation_jobs_object ON generation_jobs(storage_object_id, created_at DESC);
+
+-- objects processed before jobs were tracked get a saved job if they have cards
+INSERT INTO generation_jobs (id, storage_object_id, status, card_count, attempts, created_at, updated_at, started_at, finished_at)
+SELECT gen_random_uuid(), so.id, 'saved', COUNT(f.id), 1, so.created_at, NOW(), so.created_at, NOW()
+FROM storage_objects so
+JOIN flashcards f ON f.storage_object_id = so.id
+GROUP BY so.id, so.created_at;
@@ -0,0 +1,4 @@
+ALTER TABLE flashcards
+    DROP CONSTRAINT IF EXISTS flashcards_page_range,
+    DROP COLUMN IF EXISTS page_end,
+    DROP COLUMN IF EXISTS page_start;
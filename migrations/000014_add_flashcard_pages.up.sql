@@ -0,0 +1,5 @@
+-- pages of the uploaded document a generated card came from, NULL for cards without a known source page
+ALTER TABLE flashcards
+    ADD COLUMN page_start INT,
+    ADD COLUMN page_end INT,
+    ADD CONSTRAINT flashcards_page_range CHECK (page_start IS NULL OR (page_start > 0 AND page_end >= page_start));
@@ -0,0 +1,11 @@
+DELETE FROM flashcards WHERE superseded_at IS NOT NULL;
+
+ALTER TABLE generation_jobs
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS options;
+
+DROP INDEX IF EXISTS idx_flashcards_current_object;
+
+ALTER TABLE flashcards
+    DROP COLUMN IF EXISTS superseded_at,
+    DROP COLUMN IF EXISTS version;
@@ -0,0 +1,14 @@
+-- a regeneration adds a new card set instead of replacing the old one, deck cards copied from an old
+-- version keep pointing at it. Only the cards that were never superseded are offered for an object
+ALTER TABLE flashcards
+    ADD COLUMN version INT NOT NULL DEFAULT 1,
+    ADD COLUMN superseded_at TIMESTAMP;
+
+CREATE INDEX idx_flashcards_current_object ON flashcards(storage_object_id) WHERE superseded_at IS NULL;
+
+-- options the run was asked for (null for uploads) and the card set version it saved
+ALTER TABLE generation_jobs
+    ADD COLUMN options JSONB,
+    ADD COLUMN version INT;
+
+UPDATE generation_jobs SET version = 1 WHERE status = 'saved';
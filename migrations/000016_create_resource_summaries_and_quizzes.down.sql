@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS resource_quiz_questions;
+DROP TABLE IF EXISTS resource_summaries;
@@ -0,0 +1,23 @@
+-- generated alongside the flashcards of an uploaded file, one summary and one quiz per storage object
+CREATE TABLE IF NOT EXISTS resource_summaries (
+    storage_object_id UUID PRIMARY KEY REFERENCES storage_objects(id) ON DELETE CASCADE,
+    key_points JSONB NOT NULL DEFAULT '[]', -- [{"text", "page_start", "page_end"}]
+    glossary JSONB NOT NULL DEFAULT '[]',   -- [{"term", "definition"}]
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS resource_quiz_questions (
+    id UUID PRIMARY KEY,
+    storage_object_id UUID NOT NULL REFERENCES storage_objects(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    question TEXT NOT NULL,
+    options JSONB NOT NULL,
+    correct_option INT NOT NULL,
+    explanation TEXT NOT NULL DEFAULT '',
+    page_start INT,
+    page_end INT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (storage_object_id, position),
+    CONSTRAINT resource_quiz_questions_page_range CHECK (page_start IS NULL OR (page_start > 0 AND page_end >= page_start))
+);
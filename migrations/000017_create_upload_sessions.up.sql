@@ -0,0 +1,22 @@
+-- direct uploads: the client puts the parts straight into storage with presigned urls and the backend
+-- only creates the resource once the upload is complete
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    week_id UUID NOT NULL REFERENCES weeks(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    file_type TEXT NOT NULL,
+    size BIGINT NOT NULL CHECK (size > 0),
+    sha256 TEXT,
+    object_id UUID NOT NULL,
+    upload_id TEXT NOT NULL,
+    part_size BIGINT NOT NULL,
+    part_count INT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'aborted')),
+    resource_id UUID REFERENCES resources(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_upload_sessions_pending ON upload_sessions(expires_at) WHERE status = 'pending';
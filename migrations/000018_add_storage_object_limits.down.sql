@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_storage_objects_uploaded_by;
+
+ALTER TABLE storage_objects
+    DROP COLUMN IF EXISTS uploaded_by,
+    DROP COLUMN IF EXISTS mime_type,
+    DROP COLUMN IF EXISTS size;
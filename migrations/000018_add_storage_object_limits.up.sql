@@ -0,0 +1,14 @@
+-- uploads count against the quota of the user who stored the object first, objects shared through
+-- deduplication are not counted again. Objects stored before this migration have no known size
+ALTER TABLE storage_objects
+    ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS mime_type TEXT,
+    ADD COLUMN IF NOT EXISTS uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+UPDATE storage_objects so SET uploaded_by = (
+    SELECT ro.user_id FROM resources r JOIN resource_owners ro ON ro.resource_id = r.id
+    WHERE r.storage_object_id = so.id ORDER BY ro.created_at LIMIT 1
+)
+WHERE so.file_type <> 'link';
+
+CREATE INDEX IF NOT EXISTS idx_storage_objects_uploaded_by ON storage_objects(uploaded_by);
@@ -0,0 +1 @@
+ALTER TABLE user_deck_cards DROP COLUMN IF EXISTS edited_at;
@@ -0,0 +1,2 @@
+-- cards the subscriber edited themselves are not overwritten by updates of a shared deck
+ALTER TABLE user_deck_cards ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
@@ -0,0 +1,13 @@
+-- a storage object has at most one generation job that did not finish, older duplicates are failed first
+UPDATE generation_jobs j
+SET status = 'failed', error = 'superseded by a newer job', finished_at = NOW(), updated_at = NOW()
+WHERE j.status NOT IN ('saved', 'failed')
+  AND EXISTS (
+      SELECT 1 FROM generation_jobs newer
+      WHERE newer.storage_object_id = j.storage_object_id
+        AND newer.status NOT IN ('saved', 'failed')
+        AND (newer.created_at, newer.id) > (j.created_at, j.id)
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_generation_jobs_active_object
+    ON generation_jobs(storage_object_id) WHERE status NOT IN ('saved', 'failed');
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_upload_sessions_verifying;
+UPDATE upload_sessions SET status = 'aborted' WHERE status = 'verifying';
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS error;
+ALTER TABLE upload_sessions DROP CONSTRAINT IF EXISTS upload_sessions_status_check;
+ALTER TABLE upload_sessions ADD CONSTRAINT upload_sessions_status_check
+    CHECK (status IN ('pending', 'completed', 'aborted'));
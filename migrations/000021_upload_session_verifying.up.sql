@@ -0,0 +1,7 @@
+-- completed direct uploads are hashed in the background, the session says why a file was turned away
+ALTER TABLE upload_sessions DROP CONSTRAINT IF EXISTS upload_sessions_status_check;
+ALTER TABLE upload_sessions ADD CONSTRAINT upload_sessions_status_check
+    CHECK (status IN ('pending', 'verifying', 'completed', 'aborted'));
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS error TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_verifying ON upload_sessions(expires_at) WHERE status = 'verifying';